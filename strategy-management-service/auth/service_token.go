@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"main/model/entity"
+	"sync"
+	"time"
+)
+
+// serviceTokenMargin is left of the lifetime of a service token when a new one is created
+const serviceTokenMargin = time.Minute
+
+// ServiceToken authenticates the service in the other services of the app: it is an access token
+// of the service role signed with the shared access secret, renewed before it expires.
+// Safe for concurrent use
+type ServiceToken struct {
+	tokens  TokenInterface
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func NewServiceToken() *ServiceToken {
+	return &ServiceToken{tokens: NewTokenService()}
+}
+
+// Token returns the current token, a new one when it is about to expire
+func (t *ServiceToken) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expires.Add(-serviceTokenMargin)) {
+		return t.token, nil
+	}
+	td, err := t.tokens.CreateToken(0, string(entity.ServiceRole))
+	if err != nil {
+		return "", err
+	}
+	t.token, t.expires = td.AccessToken, time.Unix(td.AtExpires, 0)
+	return t.token, nil
+}
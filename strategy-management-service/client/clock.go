@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"main/logging"
+	"main/utils"
+	"time"
+)
+
+// clockSync is how often the simulated clock reads the time of the tender service
+const clockSync = time.Second
+
+// FollowClock keeps the simulated clock at the time of the simulated clock of the tender service,
+// which is authoritative, until ctx is done. The clock fires its timers once per sync however
+// many times the tender service is advanced meanwhile
+func (c *TenderClient) FollowClock(ctx context.Context, clock *utils.FakeClock) {
+	ticker := time.NewTicker(clockSync)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now, err := c.GetClock(ctx)
+		if err != nil {
+			logging.ErrorFormat("Cannot get the simulated time of the tender service: %s", err)
+			continue
+		}
+		clock.AdvanceTo(now)
+	}
+}
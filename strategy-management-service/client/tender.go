@@ -0,0 +1,263 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"main/model/entity"
+	"main/model/response"
+	"main/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTenderUrl is the tender service running on the same host
+	DefaultTenderUrl = "http://localhost:8080"
+
+	apiV1 = "/api/v1"
+	// requestTimeout bounds a request to the tender service
+	requestTimeout = 10 * time.Second
+)
+
+// TokenSource returns the token the requests are authenticated with
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// TenderClient calls the sessions and bets API of the tender management service, safe for concurrent use
+type TenderClient struct {
+	baseUrl string
+	tokens  TokenSource
+	http    *http.Client
+	// streams are not limited by the request timeout
+	streams *http.Client
+}
+
+// NewTenderClient returns a client of the tender service at baseUrl, e.g. http://localhost:8080
+func NewTenderClient(baseUrl string, tokens TokenSource) *TenderClient {
+	return &TenderClient{
+		baseUrl: strings.TrimSuffix(baseUrl, "/") + apiV1,
+		tokens:  tokens,
+		http:    &http.Client{Timeout: requestTimeout},
+		streams: &http.Client{},
+	}
+}
+
+// APIError is an error response of the tender service
+type APIError struct {
+	Method string
+	Path   string
+	utils.HTTPError
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("tender service %s %s: %d %s", e.Method, e.Path, e.Code, e.Message)
+}
+
+// Unwrap returns the refusal of a bet reported with the error code, so that errors.Is matches it
+func (e *APIError) Unwrap() error {
+	return entity.BetError(e.ErrorCode)
+}
+
+// GetSessions returns the IDs and statuses of all sessions
+func (c *TenderClient) GetSessions(ctx context.Context) ([]entity.QuotationSessionShort, error) {
+	var res []entity.QuotationSessionShort
+	err := c.do(ctx, http.MethodGet, "/sessions", nil, nil, &res)
+	return res, err
+}
+
+// GetSessionsByStatus returns the sessions in the status with their last bet
+func (c *TenderClient) GetSessionsByStatus(ctx context.Context, status entity.SessionStatus) ([]*entity.QuotationSession, error) {
+	var res []*entity.QuotationSession
+	err := c.do(ctx, http.MethodGet, "/sessions", url.Values{"status": {string(status)}}, nil, &res)
+	return res, err
+}
+
+// GetSession returns the session with its last bet and products
+func (c *TenderClient) GetSession(ctx context.Context, id int64) (entity.QuotationSession, error) {
+	var res entity.QuotationSession
+	err := c.do(ctx, http.MethodGet, "/sessions/"+strconv.FormatInt(id, 10), nil, nil, &res)
+	return res, err
+}
+
+// NewSession starts a session at the current time of the tender service, returns its ID
+func (c *TenderClient) NewSession(ctx context.Context, data entity.QuotationSessionData) (int64, error) {
+	var res response.SessionCreated
+	if err := c.do(ctx, http.MethodPost, "/sessions", nil, data, &res); err != nil {
+		return 0, err
+	}
+	return res.Id, nil
+}
+
+// MakeBet makes the bet by the auction rules of the tender service, returns its ID,
+// 0 when the session has ended instead
+func (c *TenderClient) MakeBet(ctx context.Context, data entity.BetData) (int64, error) {
+	var res response.BetMade
+	if err := c.do(ctx, http.MethodPost, "/bets", nil, data, &res); err != nil {
+		return 0, err
+	}
+	return res.ID, nil
+}
+
+// TryBets tries the bets on a copy of the session by the auction rules, nothing is saved
+func (c *TenderClient) TryBets(ctx context.Context, trial entity.BetTrial) (entity.BetTrialResult, error) {
+	var res entity.BetTrialResult
+	err := c.do(ctx, http.MethodPost, "/bets/trial", nil, trial, &res)
+	return res, err
+}
+
+// GetSessionBets returns the bets made in the session
+func (c *TenderClient) GetSessionBets(ctx context.Context, sessionId int64) ([]*entity.Bet, error) {
+	var res []*entity.Bet
+	err := c.do(ctx, http.MethodGet, "/bets/"+strconv.FormatInt(sessionId, 10), nil, nil, &res)
+	return res, err
+}
+
+// GetBets returns the bets of any session matching the filter in the order they were made
+func (c *TenderClient) GetBets(ctx context.Context, filter entity.BetFilter) ([]*entity.Bet, error) {
+	query := url.Values{}
+	for _, id := range filter.ProviderIds {
+		query.Add("provider_id", strconv.FormatInt(id, 10))
+	}
+	if filter.Bot != nil {
+		query.Set("bot", strconv.FormatBool(*filter.Bot))
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	var res []*entity.Bet
+	err := c.do(ctx, http.MethodGet, "/bets", query, nil, &res)
+	return res, err
+}
+
+// GetClock returns the current time of the simulated clock of the tender service
+func (c *TenderClient) GetClock(ctx context.Context) (time.Time, error) {
+	var res response.ClockTime
+	err := c.do(ctx, http.MethodGet, "/clock", nil, nil, &res)
+	return res.Now, err
+}
+
+// AdvanceClock moves the simulated clock of the tender service forward, returns the new time
+func (c *TenderClient) AdvanceClock(ctx context.Context, d time.Duration) (time.Time, error) {
+	var res response.ClockTime
+	err := c.do(ctx, http.MethodPost, "/clock/advance", nil, entity.ClockAdvance{Duration: d.String()}, &res)
+	return res.Now, err
+}
+
+// StreamBets passes the bets made from now on to handle as the tender service streams them,
+// it returns once ctx is done or the stream breaks
+func (c *TenderClient) StreamBets(ctx context.Context, handle func(bet *entity.Bet)) error {
+	const path = "/bets/events"
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.streams.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return newAPIError(http.MethodGet, path, resp)
+	}
+
+	// the events are "event:bet" and "data:<bet JSON>" lines ended by an empty line
+	var event, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		case line == "":
+			if event == "bet" && data != "" {
+				bet := &entity.Bet{}
+				if err = json.Unmarshal([]byte(data), bet); err != nil {
+					return fmt.Errorf("tender service %s %s: invalid bet %s: %w", http.MethodGet, path, data, err)
+				}
+				handle(bet)
+			}
+			event, data = "", ""
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// do sends the request with the JSON body unless it is nil and decodes the response into res,
+// an error response is returned as *APIError
+func (c *TenderClient) do(ctx context.Context, method string, path string, query url.Values,
+	body interface{}, res interface{}) error {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return newAPIError(method, path, resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// newRequest returns the authenticated request with the JSON body unless it is nil
+func (c *TenderClient) newRequest(ctx context.Context, method string, path string, query url.Values,
+	body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(buf)
+	}
+	target := c.baseUrl + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get service token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// newAPIError reads the error response, the authorization errors are plain JSON strings
+func newAPIError(method string, path string, resp *http.Response) *APIError {
+	res := &APIError{Method: method, Path: path}
+	data, _ := ioutil.ReadAll(resp.Body)
+	if err := json.Unmarshal(data, &res.HTTPError); err != nil || res.Message == "" {
+		var message string
+		if json.Unmarshal(data, &message) != nil {
+			message = strings.TrimSpace(string(data))
+		}
+		res.Message = message
+	}
+	res.Code = resp.StatusCode
+	return res
+}
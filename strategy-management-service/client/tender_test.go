@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"main/model/entity"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	if t == "" {
+		return "", errors.New("no token")
+	}
+	return string(t), nil
+}
+
+// tenderServer answers the requests with handler and fails the test on a request without the token
+func tenderServer(t *testing.T, handler http.HandlerFunc) *TenderClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer service-token" {
+			t.Errorf("%s %s: Authorization = %q, want the service token", r.Method, r.URL.Path, got)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewTenderClient(server.URL+"/", staticToken("service-token"))
+}
+
+func TestTenderClientRequest(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	tender := tenderServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/bets/trial" {
+			t.Errorf("request = %s %s, want POST /api/v1/bets/trial", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		var trial entity.BetTrial
+		if err := json.NewDecoder(r.Body).Decode(&trial); err != nil {
+			t.Fatalf("cannot decode the trial: %s", err)
+		}
+		bet := trial.Bets[0]
+		_ = json.NewEncoder(w).Encode(entity.BetTrialResult{
+			Session: trial.Session,
+			Bets: []entity.TrialBetResult{{
+				Bet:    entity.Bet{ProviderId: bet.ProviderId, Time: bet.Time, NewPrice: 900},
+				Placed: true,
+			}},
+		})
+	})
+
+	res, err := tender.TryBets(context.Background(), entity.BetTrial{
+		Session: entity.QuotationSession{ID: 7, StartTime: start},
+		Bets:    []entity.TrialBet{{ProviderId: 3, Time: start.Add(time.Minute), MinPrice: 500}},
+	})
+	if err != nil {
+		t.Fatalf("TryBets() error = %v", err)
+	}
+	if res.Session.ID != 7 || len(res.Bets) != 1 || !res.Bets[0].Placed || res.Bets[0].Bet.ProviderId != 3 ||
+		res.Bets[0].Bet.NewPrice != 900 || !res.Bets[0].Bet.Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("TryBets() = %+v", res)
+	}
+}
+
+func TestTenderClientQuery(t *testing.T) {
+	since := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	tender := tenderServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if got := query["provider_id"]; len(got) != 2 || got[0] != "1" || got[1] != "2" {
+			t.Errorf("provider_id = %v, want [1 2]", got)
+		}
+		if query.Get("bot") != "true" || query.Get("since") != "2022-06-01T12:00:00Z" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		_, _ = io.WriteString(w, `[{"id":5,"provider_id":1,"bot":true}]`)
+	})
+
+	bot := true
+	bets, err := tender.GetBets(context.Background(), entity.BetFilter{ProviderIds: []int64{1, 2}, Bot: &bot,
+		Since: since})
+	if err != nil {
+		t.Fatalf("GetBets() error = %v", err)
+	}
+	if len(bets) != 1 || bets[0].ID != 5 || !bets[0].Bot {
+		t.Errorf("GetBets() = %+v", bets)
+	}
+}
+
+func TestTenderClientErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantMessage string
+		// the refusal errors.Is matches, nil for none
+		wantErr error
+	}{
+		{
+			name:        "price floor",
+			status:      http.StatusConflict,
+			body:        `{"code":409,"error_code":"price_floor","message":"bet refused"}`,
+			wantMessage: "bet refused",
+			wantErr:     entity.ErrPriceFloor,
+		},
+		{
+			name:        "repeated bet",
+			status:      http.StatusConflict,
+			body:        `{"code":409,"error_code":"repeated_bet","message":"bet refused"}`,
+			wantMessage: "bet refused",
+			wantErr:     entity.ErrRepeatedBet,
+		},
+		{
+			name:        "session not active",
+			status:      http.StatusConflict,
+			body:        `{"code":409,"error_code":"session_not_active","message":"bet refused"}`,
+			wantMessage: "bet refused",
+			wantErr:     entity.ErrSessionNotActive,
+		},
+		{
+			name:        "message of a refusal without a code",
+			status:      http.StatusConflict,
+			body:        fmt.Sprintf(`{"code":409,"message":%q}`, entity.ErrPriceFloor.Error()),
+			wantMessage: entity.ErrPriceFloor.Error(),
+		},
+		{
+			name:        "not found",
+			status:      http.StatusNotFound,
+			body:        `{"code":404,"message":"pg: no rows in result set"}`,
+			wantMessage: "pg: no rows in result set",
+		},
+		{
+			name:        "authorization error",
+			status:      http.StatusUnauthorized,
+			body:        `"token is expired"`,
+			wantMessage: "token is expired",
+		},
+		{
+			name:        "plain text",
+			status:      http.StatusBadGateway,
+			body:        "bad gateway\n",
+			wantMessage: "bad gateway",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tender := tenderServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+			_, err := tender.MakeBet(context.Background(), entity.BetData{QuotationSessionID: 7, ProviderId: 3})
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("MakeBet() error = %v, want an *APIError", err)
+			}
+			if apiErr.Code != tt.status || apiErr.Message != tt.wantMessage || apiErr.Method != http.MethodPost ||
+				apiErr.Path != "/bets" {
+				t.Errorf("error = %+v, want %d %q", apiErr, tt.status, tt.wantMessage)
+			}
+			for _, refusal := range []error{entity.ErrSessionNotActive, entity.ErrRepeatedBet, entity.ErrPriceFloor} {
+				if got := errors.Is(err, refusal); got != (refusal == tt.wantErr) {
+					t.Errorf("errors.Is(%q) = %t", refusal, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTenderClientTokenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request %s %s is sent without a token", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+	tender := NewTenderClient(server.URL, staticToken(""))
+	if _, err := tender.GetSession(context.Background(), 7); err == nil {
+		t.Errorf("GetSession() error = nil, want the token error")
+	}
+}
+
+func TestTenderClientStreamBets(t *testing.T) {
+	tender := tenderServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/bets/events" || r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("request = %s %s, Accept %q", r.Method, r.URL.Path, r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "event:bet\ndata:{\"id\":1,\"quotation_session_id\":7}\n\n"+
+			": keep-alive\n\n"+
+			"event:other\ndata:{\"id\":2}\n\n"+
+			"event: bet\ndata: {\"id\":3,\"quotation_session_id\":8}\n\n")
+	})
+
+	var got []int64
+	err := tender.StreamBets(context.Background(), func(bet *entity.Bet) {
+		got = append(got, bet.ID)
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("StreamBets() error = %v, want %v once the stream ends", err, io.ErrUnexpectedEOF)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("bets = %v, want [1 3]", got)
+	}
+}
+
+func TestTenderClientStreamBetsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "error response", status: http.StatusForbidden, body: `"forbidden"`},
+		{name: "invalid bet", status: http.StatusOK, body: "event:bet\ndata:{\"id\":\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tender := tenderServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+			err := tender.StreamBets(context.Background(), func(bet *entity.Bet) {
+				t.Errorf("bet %+v is handled", bet)
+			})
+			if err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("StreamBets() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Command backtest replays finished quotation sessions with a strategy taking part
+// and prints the final price and whether the strategy would have won.
+// The database and the tender service are configured with the same envs as the service.
+//
+//	go run ./cmd/backtest -user 42 -strategy aggressive -sessions 1,2,3
+//	go run ./cmd/backtest -user 42 -source my_strategy.txt -preferable 900 -acceptable 800
+//	go run ./cmd/backtest -user 42 -strategy aggressive -vars rebid_delay=30,granularity=2
+//	go run ./cmd/backtest -user 42 -account 7 -strategy-id 3
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"io/ioutil"
+	"main/auth"
+	"main/client"
+	conn "main/db/impl"
+	"main/model/entity"
+	repo "main/repository/impl"
+	"main/service"
+	"main/utils"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func main() {
+	var (
+		p          entity.BacktestParams
+		sourceFile string
+		sessions   string
+		vars       string
+		asJson     bool
+	)
+	flag.Int64Var(&p.UserId, "user", 0, "provider the runner bets for")
+	flag.Int64Var(&p.AccountId, "account", 0, "account the stored strategy belongs to")
+	flag.StringVar(&p.Str, "strategy", "aggressive", "predefined strategy: aggressive, waiting or progressive")
+	flag.Int64Var(&p.StrategyId, "strategy-id", 0, "stored custom strategy of -account, used instead of -strategy")
+	flag.StringVar(&sourceFile, "source", "", "file with a strategy in the rule language, used instead of -strategy")
+	flag.StringVar(&vars, "vars", "", "comma separated name=value params of the strategy, e.g. rebid_delay=30")
+	flag.StringVar(&sessions, "sessions", "", "comma separated IDs of finished sessions, all finished sessions by default")
+	flag.Float64Var(&p.MinimalPrice, "minimal", 0, "minimal price")
+	flag.Float64Var(&p.AcceptablePrice, "acceptable", 0, "acceptable price")
+	flag.Float64Var(&p.PreferablePrice, "preferable", 0, "preferable price")
+	flag.Int64Var(&p.MaxBets, "max-bets", 0, "max number of runner bets, unlimited when 0")
+	flag.Int64Var(&p.Seed, "seed", 0, "seed of the timing jitter of the strategy")
+	flag.BoolVar(&asJson, "json", false, "print the results as JSON")
+	flag.Parse()
+
+	if p.UserId == 0 {
+		fail(fmt.Errorf("-user is required"))
+	}
+	if p.StrategyId != 0 && p.AccountId == 0 {
+		fail(fmt.Errorf("-account is required with -strategy-id"))
+	}
+	if sourceFile != "" {
+		source, err := ioutil.ReadFile(sourceFile)
+		if err != nil {
+			fail(err)
+		}
+		p.Source = string(source)
+	}
+	for _, v := range strings.Split(vars, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			fail(fmt.Errorf("invalid var %q, expected name=value", v))
+		}
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			fail(fmt.Errorf("invalid value of var %q", parts[0]))
+		}
+		if p.Vars == nil {
+			p.Vars = map[string]float64{}
+		}
+		p.Vars[parts[0]] = value
+	}
+	for _, id := range strings.Split(sessions, ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		sessionId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			fail(fmt.Errorf("invalid session ID %q", id))
+		}
+		p.SessionIds = append(p.SessionIds, sessionId)
+	}
+
+	ctx := context.Background()
+	connection, err := conn.NewPgOrmConnectionProvider()
+	if err != nil {
+		fail(err)
+	}
+	db := connection.Connection().(*pg.DB)
+	tender := client.NewTenderClient(utils.GetEnv(utils.TenderApiUrlEnvKey, client.DefaultTenderUrl),
+		auth.NewServiceToken())
+	runnerRepo := repo.NewPgOrmStrategyRunnerRepository(ctx, db)
+	// the replay runs on the simulated time of the sessions, the clock is not used
+	clock := utils.NewSystemClock()
+	srv := service.NewStrategyService(ctx,
+		repo.NewApiQuotationSessionRepository(ctx, tender),
+		repo.NewApiBetRepository(ctx, tender, runnerRepo),
+		repo.NewPgOrmStrategyRepository(ctx, db),
+		repo.NewPgOrmDecisionTraceRepository(ctx, db),
+		runnerRepo,
+		repo.NewPgOrmBlockedActionRepository(ctx, db),
+		repo.NewPgOrmRunnerNotificationRepository(ctx, db),
+		repo.NewPgOrmBetProposalRepository(ctx, db),
+		repo.NewPgOrmAccountRepository(ctx, db),
+		repo.NewPgOrmOrgLimitsRepository(ctx, db),
+		repo.NewPgOrmPortfolioRepository(ctx, db),
+		clock)
+	results, err := srv.Backtest(p)
+	if err != nil {
+		fail(err)
+	}
+
+	if asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(results); err != nil {
+			fail(err)
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tRECORDED PRICE\tFINAL PRICE\tWINNER\tWON\tMY BETS\tBLOCKED\tNOTE")
+	won := 0
+	for _, r := range results {
+		if r.Won {
+			won++
+		}
+		fmt.Fprintf(w, "%d\t%.2f\t%.2f\t%d\t%t\t%d\t%d\t%s\n", r.SessionId, r.RecordedPrice,
+			r.FinalPrice, r.WinnerId, r.Won, r.MyBets, r.BlockedBets, r.Error)
+	}
+	fmt.Fprintf(w, "won %d of %d sessions\n", won, len(results))
+	w.Flush()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "backtest:", err)
+	os.Exit(1)
+}
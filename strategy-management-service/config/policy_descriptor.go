@@ -3,8 +3,22 @@ package config
 const (
 	//Resources-----------------------------------------
 	Strategy = "strategy"
+	//Simulated clock, routed in the simulation mode only
+	Clock = "clock"
 
 	//Actions-------------------------------------------
-	Run  = "run"
-	Stop = "stop"
+	Run    = "run"
+	Stop   = "stop"
+	Read   = "read"
+	Insert = "insert"
+	Update = "update"
+	Delete = "delete"
+	//Replay finished sessions with a strategy
+	Backtest = "backtest"
+	//Pause and resume runners
+	Pause = "pause"
+	//Approve and reject the bets proposed by semi-automatic runners
+	Approve = "approve"
+	//Set the risk limits of an organisation
+	Limits = "limits"
 )
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"github.com/casbin/casbin"
+	fileadapter "github.com/casbin/casbin/persist/file-adapter"
+	"testing"
+)
+
+func TestBasicPolicyLimits(t *testing.T) {
+	enforcer := casbin.NewEnforcer("rbac_model.conf", fileadapter.NewAdapter("basic_policy.csv"))
+	tests := []struct {
+		role string
+		act  string
+		want bool
+	}{
+		{role: "admin", act: Limits, want: true},
+		{role: "admin", act: Read, want: true},
+		{role: "provider", act: Limits, want: false},
+		{role: "provider", act: Read, want: true},
+		{role: "customer", act: Limits, want: false},
+	}
+	for _, tt := range tests {
+		if got := enforcer.Enforce(tt.role, Strategy, tt.act); got != tt.want {
+			t.Errorf("Enforce(%s, %s, %s) = %t, want %t", tt.role, Strategy, tt.act, got, tt.want)
+		}
+	}
+}
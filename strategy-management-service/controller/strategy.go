@@ -2,9 +2,14 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"io"
+	"main/auth"
 	"main/logging"
 	"main/model/entity"
+	"main/model/response"
 	"main/repository"
 	"main/service"
 	"main/utils"
@@ -12,20 +17,27 @@ import (
 	"strconv"
 )
 
+const (
+	defaultTraceLimit = 100
+	maxTraceLimit     = 1000
+)
+
 // Controller for strategy
 type StrategyController struct {
-	strategySrv service.StrategyService
-	ctx         context.Context
+	strategySrv       *service.StrategyService
+	customStrategySrv service.CustomStrategyService
+	clock             utils.Clock
+	ctx               context.Context
 }
 
-// NewStrategyController example
-func NewStrategyController(ctx context.Context, repo repository.QuotationSessionRepository,
-	betRepo repository.BetRepository) *StrategyController {
-
-	srv := service.NewStrategyService(ctx, repo, betRepo)
+// NewStrategyController serves the runners of srv, the service instance main.go resumes the runners on
+func NewStrategyController(ctx context.Context, srv *service.StrategyService,
+	customSrv service.CustomStrategyService, clock utils.Clock) *StrategyController {
 	return &StrategyController{
-		ctx:         ctx,
-		strategySrv: srv,
+		ctx:               ctx,
+		clock:             clock,
+		strategySrv:       srv,
+		customStrategySrv: customSrv,
 	}
 }
 
@@ -36,8 +48,8 @@ func NewStrategyController(ctx context.Context, repo repository.QuotationSession
 // @Accept                    json
 // @Produce                   json
 // @Param        Authorization  header    string  true  "Authentication header"
-// @Param               data            body      entity.StrategyParams true  "Strategy params"
-// @Success             200             {string}  string  "Strategy launched"
+// @Param               data            body      entity.StrategyParams true  "Strategy params, start_at and start_when schedule the start"
+// @Success             200             {string}  string  "Strategy launched or scheduled"
 // @Failure        400        {object}            utils.HTTPError
 // @Failure        404        {object}            utils.HTTPError
 // @Failure        500        {object}            utils.HTTPError
@@ -48,16 +60,154 @@ func (c StrategyController) RunStrategy(ctx *gin.Context) {
 		utils.NewError(ctx, http.StatusBadRequest, err)
 		return
 	}
-	err := c.strategySrv.RunStrategyRunner(p)
+	accountId, err := accountIdFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	p.AccountId = accountId
+	scheduled := p.Scheduled(c.clock.Now())
+	err = c.strategySrv.RunStrategyRunner(p)
 	if err != nil {
 		logging.ErrorFormat("Error launching strategy runner: %s", err)
-		utils.NewError(ctx, http.StatusInternalServerError, err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+	if scheduled {
+		ctx.JSON(http.StatusOK, "Runner successfully scheduled.")
 		return
 	}
 
 	ctx.JSON(http.StatusOK, "Runner successfully launched.")
 }
 
+// RunPortfolio godoc
+// @Summary            Run portfolio
+// @Description    Runs a strategy runner in each session, the runners share the capacity of the provider
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               data            body      entity.PortfolioParams true  "Portfolio params"
+// @Success             200             {object}  entity.PortfolioInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/portfolios [post]
+func (c StrategyController) RunPortfolio(ctx *gin.Context) {
+	var p entity.PortfolioParams
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := accountIdFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	p.AccountId = accountId
+	info, err := c.strategySrv.RunPortfolio(p)
+	if err != nil {
+		logging.ErrorFormat("Error launching portfolio: %s", err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, info)
+}
+
+// GetPortfolio godoc
+// @Summary            Get portfolio
+// @Description    Returns the portfolio with the last allocation of its sessions
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Portfolio ID"
+// @Success             200             {object}  entity.PortfolioInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/portfolios/{id} [get]
+func (c StrategyController) GetPortfolio(ctx *gin.Context) {
+	c.portfolio(ctx, c.strategySrv.GetPortfolio)
+}
+
+// StopPortfolio godoc
+// @Summary            Stop portfolio
+// @Description    Stops the portfolio together with the runners of its sessions
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Portfolio ID"
+// @Success             200             {object}  entity.PortfolioInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/portfolios/{id}/stop [post]
+func (c StrategyController) StopPortfolio(ctx *gin.Context) {
+	c.portfolio(ctx, c.strategySrv.StopPortfolio)
+}
+
+func (c StrategyController) portfolio(ctx *gin.Context,
+	handle func(accountId int64, id int64) (entity.PortfolioInfo, error)) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := ownerFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	info, err := handle(accountId, id)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, info)
+}
+
+// BacktestStrategy godoc
+// @Summary            Backtest strategy
+// @Description    Replays finished sessions with the strategy taking part and returns the outcomes
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               data            body      entity.BacktestParams true  "Backtest params"
+// @Success             200             {array}   entity.BacktestResult
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/backtest [post]
+func (c StrategyController) BacktestStrategy(ctx *gin.Context) {
+	var p entity.BacktestParams
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := accountIdFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	p.AccountId = accountId
+	res, err := c.strategySrv.Backtest(p)
+	if err != nil {
+		logging.ErrorFormat("Error backtesting strategy: %s", err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
 // StopStrategy godoc
 // @Summary            Stop user strategy
 // @Description    Stops selected strategy
@@ -69,6 +219,8 @@ func (c StrategyController) RunStrategy(ctx *gin.Context) {
 // @Param               userId            path      int true  "User ID param"
 // @Success             200             {string}  string  "Strategy stopped"
 // @Failure        400        {object}            utils.HTTPError
+// @Failure        401        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
 // @Failure        404        {object}            utils.HTTPError
 // @Failure        500        {object}            utils.HTTPError
 // @Router                    /api/v1/strategies/{sessionId}/{userId} [post]
@@ -86,12 +238,929 @@ func (c StrategyController) StopStrategy(ctx *gin.Context) {
 		utils.NewError(ctx, http.StatusBadRequest, err)
 		return
 	}
-	err = c.strategySrv.StopRunner(int64(isessionID), int64(iuserID))
+	accountId, err := ownerFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	err = c.strategySrv.StopRunner(accountId, int64(isessionID), int64(iuserID))
 	if err != nil {
 		logging.ErrorFormat("Error stopping strategy runner: %s", err)
-		utils.NewError(ctx, http.StatusInternalServerError, err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, "Runner successfully stopped.")
 }
+
+// ListTemplates godoc
+// @Summary            List strategy templates
+// @Description    Returns the predefined strategies with their params, the params are overridden by the vars of the run request
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Success             200             {array}   entity.StrategyTemplate
+// @Router                    /api/v1/strategies/templates [get]
+func (c StrategyController) ListTemplates(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.strategySrv.Templates())
+}
+
+// ListRunners godoc
+// @Summary            List runners
+// @Description    Returns the runners of the service: status, strategy, start time, bets made and the last decision
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               user_id            query      int false  "Only runners betting for the user"
+// @Param               session_id            query      int false  "Only runners of the session"
+// @Success             200             {array}   entity.RunnerInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/runners [get]
+func (c StrategyController) ListRunners(ctx *gin.Context) {
+	var filter entity.RunnerFilter
+	var err error
+	if userId := ctx.Query("user_id"); userId != "" {
+		if filter.UserId, err = strconv.ParseInt(userId, 10, 64); err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if sessionId := ctx.Query("session_id"); sessionId != "" {
+		if filter.QuotationSessionId, err = strconv.ParseInt(sessionId, 10, 64); err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+	metadata, err := auth.ExtractTokenMetadata(ctx.Request)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	// admins see the runners launched by any account
+	if metadata.Role != string(entity.AdminRole) {
+		filter.AccountId = metadata.AccountId
+	}
+
+	ctx.JSON(http.StatusOK, c.strategySrv.ListRunners(filter))
+}
+
+// ListScheduled godoc
+// @Summary            List scheduled runners
+// @Description    Returns the runners waiting for their start time or condition
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               user_id            query      int false  "Only runners betting for the user"
+// @Param               session_id            query      int false  "Only runners of the session"
+// @Success             200             {array}   entity.StrategyRunner
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/scheduled [get]
+func (c StrategyController) ListScheduled(ctx *gin.Context) {
+	var filter entity.RunnerFilter
+	var err error
+	if userId := ctx.Query("user_id"); userId != "" {
+		if filter.UserId, err = strconv.ParseInt(userId, 10, 64); err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if sessionId := ctx.Query("session_id"); sessionId != "" {
+		if filter.QuotationSessionId, err = strconv.ParseInt(sessionId, 10, 64); err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if filter.AccountId, err = ownerFromRequest(ctx); err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	scheduled, err := c.strategySrv.ListScheduled(filter)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheduled)
+}
+
+// CancelScheduled godoc
+// @Summary            Cancel scheduled runner
+// @Description    Cancels the start of a runner waiting for its start time or condition
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Runner ID"
+// @Success             200             {object}  entity.StrategyRunner
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/scheduled/{id} [delete]
+func (c StrategyController) CancelScheduled(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := ownerFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	runner, err := c.strategySrv.CancelScheduled(accountId, id)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, runner)
+}
+
+// PauseRunner godoc
+// @Summary            Pause runner
+// @Description    Keeps the runner registered but stops it acting until it is resumed
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               sessionId            path      int true  "Session ID param"
+// @Param               userId            path      int true  "User ID param"
+// @Success             200             {object}  entity.RunnerInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/runners/{sessionId}/{userId}/pause [post]
+func (c StrategyController) PauseRunner(ctx *gin.Context) {
+	c.setRunnerPaused(ctx, true)
+}
+
+// ResumeRunner godoc
+// @Summary            Resume runner
+// @Description    Makes a paused runner act again
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               sessionId            path      int true  "Session ID param"
+// @Param               userId            path      int true  "User ID param"
+// @Success             200             {object}  entity.RunnerInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/runners/{sessionId}/{userId}/resume [post]
+func (c StrategyController) ResumeRunner(ctx *gin.Context) {
+	c.setRunnerPaused(ctx, false)
+}
+
+func (c StrategyController) setRunnerPaused(ctx *gin.Context, paused bool) {
+	sessionId, err := strconv.ParseInt(ctx.Param("sessionId"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	userId, err := strconv.ParseInt(ctx.Param("userId"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	metadata, err := auth.ExtractTokenMetadata(ctx.Request)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	// admins manage the runners launched by any account
+	var accountId int64
+	if metadata.Role != string(entity.AdminRole) {
+		accountId = metadata.AccountId
+	}
+	var info entity.RunnerInfo
+	if paused {
+		info, err = c.strategySrv.PauseRunner(accountId, sessionId, userId)
+	} else {
+		info, err = c.strategySrv.ResumeRunner(accountId, sessionId, userId)
+	}
+	if err != nil {
+		logging.ErrorFormat("Error pausing or resuming strategy runner: %s", err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, info)
+}
+
+// GetDecisionTrace godoc
+// @Summary            Get runner decision trace
+// @Description    Returns the recorded ticks of a runner, the latest first: state, evaluated branches and action
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               sessionId            path      int true  "Session ID param"
+// @Param               userId            path      int true  "User ID param"
+// @Param               limit            query      int false  "Max number of ticks, 100 by default"
+// @Param               offset            query      int false  "Number of the latest ticks to skip"
+// @Success             200             {array}   entity.DecisionTrace
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/{sessionId}/{userId}/trace [get]
+func (c StrategyController) GetDecisionTrace(ctx *gin.Context) {
+	page, ok := runnerPageFromRequest(ctx)
+	if !ok {
+		return
+	}
+	traces, err := c.strategySrv.GetDecisionTrace(entity.DecisionTraceFilter{
+		QuotationSessionId: page.sessionId,
+		UserId:             page.userId,
+		AccountId:          page.accountId,
+		Limit:              page.limit,
+		Offset:             page.offset,
+	})
+	if err != nil {
+		logging.ErrorFormat("Error getting decision trace: %s", err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, traces)
+}
+
+// GetBlockedActions godoc
+// @Summary            Get runner blocked actions
+// @Description    Returns the bets of a runner refused by the minimal price or the max number of bets, the latest first
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               sessionId            path      int true  "Session ID param"
+// @Param               userId            path      int true  "User ID param"
+// @Param               limit            query      int false  "Max number of actions, 100 by default"
+// @Param               offset            query      int false  "Number of the latest actions to skip"
+// @Success             200             {array}   entity.BlockedAction
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/{sessionId}/{userId}/blocked [get]
+func (c StrategyController) GetBlockedActions(ctx *gin.Context) {
+	page, ok := runnerPageFromRequest(ctx)
+	if !ok {
+		return
+	}
+	actions, err := c.strategySrv.GetBlockedActions(entity.BlockedActionFilter{
+		QuotationSessionId: page.sessionId,
+		UserId:             page.userId,
+		AccountId:          page.accountId,
+		Limit:              page.limit,
+		Offset:             page.offset,
+	})
+	if err != nil {
+		logging.ErrorFormat("Error getting blocked actions: %s", err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, actions)
+}
+
+// GetNotifications godoc
+// @Summary            Get runner notifications
+// @Description    Returns the messages sent by the NOTIFY action of the runners launched by the account, the latest first
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               session_id            query      int false  "Only notifications of the session"
+// @Param               limit            query      int false  "Max number of notifications, 100 by default"
+// @Param               offset            query      int false  "Number of the latest notifications to skip"
+// @Success             200             {array}   entity.RunnerNotification
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/notifications [get]
+func (c StrategyController) GetNotifications(ctx *gin.Context) {
+	var page runnerPage
+	if sessionId := ctx.Query("session_id"); sessionId != "" {
+		var err error
+		if page.sessionId, err = strconv.ParseInt(sessionId, 10, 64); err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+	page, ok := pageFromRequest(ctx, page)
+	if !ok {
+		return
+	}
+	notifications, err := c.strategySrv.GetNotifications(entity.RunnerNotificationFilter{
+		AccountId:          page.accountId,
+		QuotationSessionId: page.sessionId,
+		Limit:              page.limit,
+		Offset:             page.offset,
+	})
+	if err != nil {
+		logging.ErrorFormat("Error getting runner notifications: %s", err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, notifications)
+}
+
+// ListProposals godoc
+// @Summary            Get bet proposals
+// @Description    Returns the bets proposed by the semi-automatic runners launched by the account, the latest first
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               session_id            query      int false  "Only proposals of the session"
+// @Param               status            query      string false  "Only proposals with the status: PENDING, APPROVED, REJECTED, EXPIRED or FAILED"
+// @Param               limit            query      int false  "Max number of proposals, 100 by default"
+// @Param               offset            query      int false  "Number of the latest proposals to skip"
+// @Success             200             {array}   entity.BetProposal
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/proposals [get]
+func (c StrategyController) ListProposals(ctx *gin.Context) {
+	var page runnerPage
+	if sessionId := ctx.Query("session_id"); sessionId != "" {
+		var err error
+		if page.sessionId, err = strconv.ParseInt(sessionId, 10, 64); err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+	page, ok := pageFromRequest(ctx, page)
+	if !ok {
+		return
+	}
+	proposals, err := c.strategySrv.GetProposals(entity.BetProposalFilter{
+		AccountId:          page.accountId,
+		QuotationSessionId: page.sessionId,
+		Status:             entity.ProposalStatus(ctx.Query("status")),
+		Limit:              page.limit,
+		Offset:             page.offset,
+	})
+	if err != nil {
+		logging.ErrorFormat("Error getting bet proposals: %s", err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, proposals)
+}
+
+// ProposalEvents godoc
+// @Summary            Stream bet proposals
+// @Description    Streams the bet proposals made for the account from now on as server-sent events named proposal, until the client disconnects
+// @Tags                      strategies
+// @Produce                   text/event-stream
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Success             200             {object}  entity.BetProposal
+// @Failure        401        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/proposals/events [get]
+func (c StrategyController) ProposalEvents(ctx *gin.Context) {
+	metadata, err := auth.ExtractTokenMetadata(ctx.Request)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	proposals, err := c.strategySrv.WatchProposals(ctx.Request.Context(), metadata.AccountId)
+	if err != nil {
+		logging.ErrorFormat("Error watching bet proposals of account %d: %s", metadata.AccountId, err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+	// the proposals are closed once the client disconnects
+	ctx.Stream(func(w io.Writer) bool {
+		proposal, ok := <-proposals
+		if ok {
+			ctx.SSEvent("proposal", proposal)
+		}
+		return ok
+	})
+}
+
+// GetProposal godoc
+// @Summary            Get bet proposal
+// @Description    Returns the bet proposal by ID
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Proposal ID"
+// @Success             200             {object}  entity.BetProposal
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/proposals/{id} [get]
+func (c StrategyController) GetProposal(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := ownerFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	proposal, err := c.strategySrv.GetProposal(accountId, id)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, proposal)
+}
+
+// DecideProposal godoc
+// @Summary            Approve or reject bet proposal
+// @Description    The approved bet is made if the proposal is still pending, has not expired and nobody has bet since it was made
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Proposal ID"
+// @Param               data            body      entity.ProposalDecision true  "APPROVED or REJECTED"
+// @Success             200             {object}  entity.BetProposal
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        409        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/proposals/{id} [put]
+func (c StrategyController) DecideProposal(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	var decision entity.ProposalDecision
+	if err = ctx.ShouldBindJSON(&decision); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := ownerFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	proposal, err := c.strategySrv.DecideProposal(accountId, id, decision.Status)
+	if err != nil {
+		logging.ErrorFormat("Error deciding bet proposal %d: %s", id, err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, proposal)
+}
+
+// GetOrgLimits godoc
+// @Summary            Get organisation risk limits
+// @Description    Returns the limits of the runners launched by the accounts of the organisation, 0 is no limit
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Profile ID of the organisation"
+// @Success             200             {object}  entity.OrgRiskLimits
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/orgs/{id}/limits [get]
+func (c StrategyController) GetOrgLimits(ctx *gin.Context) {
+	profileId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := ownerFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	limits, err := c.strategySrv.GetOrgLimits(accountId, profileId)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, limits)
+}
+
+// PutOrgLimits godoc
+// @Summary            Set organisation risk limits
+// @Description    Sets the max number of runners, bot bets per day and total discount of the sessions the runners of the organisation lead, admins only
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Profile ID of the organisation"
+// @Param               data            body      entity.OrgRiskLimits true  "Limits, 0 is no limit"
+// @Success             200             {object}  entity.OrgRiskLimits
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/orgs/{id}/limits [put]
+func (c StrategyController) PutOrgLimits(ctx *gin.Context) {
+	profileId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	var limits entity.OrgRiskLimits
+	if err = ctx.ShouldBindJSON(&limits); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	limits.ProfileId = profileId
+	accountId, err := ownerFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	if err = c.strategySrv.PutOrgLimits(accountId, limits); err != nil {
+		logging.ErrorFormat("Error setting risk limits of organisation %d: %s", profileId, err)
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, limits)
+}
+
+// StopOrganization godoc
+// @Summary            Stop organisation runners
+// @Description    Kill switch: stops every runner launched by the accounts of the organisation
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Profile ID of the organisation"
+// @Success             200             {array}   entity.RunnerInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/orgs/{id}/stop [post]
+func (c StrategyController) StopOrganization(ctx *gin.Context) {
+	c.haltOrganization(ctx, c.strategySrv.StopOrganization)
+}
+
+// PauseOrganization godoc
+// @Summary            Pause organisation runners
+// @Description    Pauses every runner launched by the accounts of the organisation
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Profile ID of the organisation"
+// @Success             200             {array}   entity.RunnerInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/orgs/{id}/pause [post]
+func (c StrategyController) PauseOrganization(ctx *gin.Context) {
+	c.haltOrganization(ctx, c.strategySrv.PauseOrganization)
+}
+
+// ResumeOrganization godoc
+// @Summary            Resume organisation runners
+// @Description    Resumes every paused runner launched by the accounts of the organisation
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Profile ID of the organisation"
+// @Success             200             {array}   entity.RunnerInfo
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        403        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/orgs/{id}/resume [post]
+func (c StrategyController) ResumeOrganization(ctx *gin.Context) {
+	c.haltOrganization(ctx, c.strategySrv.ResumeOrganization)
+}
+
+func (c StrategyController) haltOrganization(ctx *gin.Context,
+	halt func(accountId int64, profileId int64) ([]entity.RunnerInfo, error)) {
+	profileId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := ownerFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	affected, err := halt(accountId, profileId)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, affected)
+}
+
+// ownerFromRequest returns the account of the request, 0 for admins who manage the runners of any account
+func ownerFromRequest(ctx *gin.Context) (int64, error) {
+	metadata, err := auth.ExtractTokenMetadata(ctx.Request)
+	if err != nil {
+		return 0, err
+	}
+	if metadata.Role == string(entity.AdminRole) {
+		return 0, nil
+	}
+	return metadata.AccountId, nil
+}
+
+// runnerPage selects a page of the records of a runner
+type runnerPage struct {
+	sessionId int64
+	userId    int64
+	// the account which launched the runner, any account for admins
+	accountId int64
+	limit     int
+	offset    int
+}
+
+// runnerPageFromRequest parses the runner path params and the paging query,
+// the error response is written when it returns false
+func runnerPageFromRequest(ctx *gin.Context) (runnerPage, bool) {
+	var page runnerPage
+	var err error
+	// the session ID has the name of the other GET routes
+	page.sessionId, err = strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return page, false
+	}
+	page.userId, err = strconv.ParseInt(ctx.Param("userId"), 10, 64)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return page, false
+	}
+	return pageFromRequest(ctx, page)
+}
+
+// pageFromRequest parses the paging query and the account of the request into the page
+func pageFromRequest(ctx *gin.Context, page runnerPage) (runnerPage, bool) {
+	var err error
+	page.limit, err = strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(defaultTraceLimit)))
+	if err != nil || page.limit <= 0 || page.limit > maxTraceLimit {
+		utils.NewError(ctx, http.StatusBadRequest,
+			fmt.Errorf("limit should be a number from 1 to %d", maxTraceLimit))
+		return page, false
+	}
+	page.offset, err = strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if err != nil || page.offset < 0 {
+		utils.NewError(ctx, http.StatusBadRequest, fmt.Errorf("offset should be a non-negative number"))
+		return page, false
+	}
+	metadata, err := auth.ExtractTokenMetadata(ctx.Request)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return page, false
+	}
+	// admins see the runners launched by any account
+	if metadata.Role != string(entity.AdminRole) {
+		page.accountId = metadata.AccountId
+	}
+	return page, true
+}
+
+// AddStrategy godoc
+// @Summary            Add custom strategy
+// @Description    Save a new strategy written in the rule language or as a tree
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               data            body      entity.CustomStrategyData true  "Strategy"
+// @Success             201             {object}  response.StrategyCreated
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies [post]
+func (c StrategyController) AddStrategy(ctx *gin.Context) {
+	var data entity.CustomStrategyData
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := accountIdFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	id, err := c.customStrategySrv.CreateStrategy(accountId, data)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, response.StrategyCreated{
+		Msg: "Strategy created",
+		Id:  id,
+	})
+}
+
+// ValidateStrategy godoc
+// @Summary            Validate strategy
+// @Description    Checks a strategy written in the rule language or as a tree without saving it
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               data            body      entity.CustomStrategyData true  "Strategy"
+// @Success             200             {object}  response.StrategyValidation
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/validate [post]
+func (c StrategyController) ValidateStrategy(ctx *gin.Context) {
+	var data entity.CustomStrategyData
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	diagnostics := c.customStrategySrv.ValidateStrategy(data)
+	if diagnostics == nil {
+		diagnostics = entity.Diagnostics{}
+	}
+
+	ctx.JSON(http.StatusOK, response.StrategyValidation{
+		Valid:       !diagnostics.HasErrors(),
+		Diagnostics: diagnostics,
+	})
+}
+
+// ListStrategies godoc
+// @Summary            List custom strategies
+// @Description    Returns strategies of the current account
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Success             200             {array}   entity.CustomStrategy
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies [get]
+func (c StrategyController) ListStrategies(ctx *gin.Context) {
+	accountId, err := accountIdFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	strategies, err := c.customStrategySrv.ListStrategies(accountId)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, strategies)
+}
+
+// GetStrategy godoc
+// @Summary            Get custom strategy
+// @Description    Returns strategy by ID
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Strategy ID"
+// @Success             200             {object}  entity.CustomStrategy
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/{id} [get]
+func (c StrategyController) GetStrategy(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := accountIdFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	strategy, err := c.customStrategySrv.GetStrategy(accountId, int64(id))
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, strategy)
+}
+
+// PutStrategy godoc
+// @Summary            Update custom strategy
+// @Description    Replaces the strategy with the given one
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Strategy ID"
+// @Param               data            body      entity.CustomStrategyData true  "Strategy"
+// @Success             200             {string}  string  "Strategy updated"
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/{id} [put]
+func (c StrategyController) PutStrategy(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	var data entity.CustomStrategyData
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := accountIdFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	err = c.customStrategySrv.UpdateStrategy(accountId, int64(id), data)
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "Strategy successfully updated.")
+}
+
+// DeleteStrategy godoc
+// @Summary            Delete custom strategy
+// @Description    Deletes strategy by ID
+// @Tags                      strategies
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               id            path      int true  "Strategy ID"
+// @Success             200             {string}  string  "Strategy deleted"
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        404        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/strategies/{id} [delete]
+func (c StrategyController) DeleteStrategy(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	accountId, err := accountIdFromRequest(ctx)
+	if err != nil {
+		utils.NewError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+	err = c.customStrategySrv.DeleteStrategy(accountId, int64(id))
+	if err != nil {
+		utils.NewError(ctx, strategyErrorStatus(err), err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, "Strategy successfully deleted.")
+}
+
+func accountIdFromRequest(ctx *gin.Context) (int64, error) {
+	metadata, err := auth.ExtractTokenMetadata(ctx.Request)
+	if err != nil {
+		return -1, err
+	}
+	return metadata.AccountId, nil
+}
+
+func strategyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, service.ErrRunnerNotFound),
+		errors.Is(err, service.ErrPortfolioNotFound), errors.Is(err, service.ErrScheduleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrStrategyAccessDenied), errors.Is(err, service.ErrRunnerAccessDenied),
+		errors.Is(err, service.ErrProposalAccessDenied), errors.Is(err, service.ErrOrgAccessDenied),
+		errors.Is(err, service.ErrPortfolioAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidStrategy), errors.Is(err, service.ErrBetBlocked),
+		errors.Is(err, service.ErrInvalidDecision), errors.Is(err, service.ErrInvalidLimits),
+		errors.Is(err, entity.ErrInvalidPortfolio):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrProposalDecided), errors.Is(err, service.ErrProposalStale),
+		errors.Is(err, service.ErrOrgRunnerLimit):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -92,6 +92,14 @@ func (p pgOrmConnectionProvider) createSchema() error {
 		(*entity.Bet)(nil),
 		(*entity.QuotationSession)(nil),
 		(*entity.ProductJournal)(nil),
+		(*entity.CustomStrategy)(nil),
+		(*entity.DecisionTrace)(nil),
+		(*entity.StrategyRunner)(nil),
+		(*entity.BlockedAction)(nil),
+		(*entity.RunnerNotification)(nil),
+		(*entity.BetProposal)(nil),
+		(*entity.OrgRiskLimits)(nil),
+		(*entity.Portfolio)(nil),
 	}
 	for _, model := range models {
 		err := p.pgDb.Model(model).CreateTable(&orm.CreateTableOptions{
@@ -102,5 +110,30 @@ func (p pgOrmConnectionProvider) createSchema() error {
 			return err
 		}
 	}
+	for _, column := range addedColumns {
+		_, err := p.pgDb.Exec("ALTER TABLE ? ADD COLUMN IF NOT EXISTS ? "+column.definition,
+			pg.Ident(column.table), pg.Ident(column.name))
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// addedColumns are the columns added to the models after their tables were first created,
+// CreateTable skips the existing tables so they are added to them here
+var addedColumns = []struct {
+	table      string
+	name       string
+	definition string
+}{
+	{"strategy_runners", "strategy_name", "text"},
+	{"strategy_runners", "bets_made", "bigint"},
+	{"strategy_runners", "outcome", "jsonb"},
+	{"strategy_runners", "phase", "jsonb"},
+	{"strategy_runners", "last_step", "bigint"},
+	{"strategy_runners", "profile_id", "bigint"},
+	{"strategy_runners", "portfolio_id", "bigint"},
+	{"decision_traces", "phase", "text"},
+	{"decision_traces", "transition", "jsonb"},
+}
@@ -0,0 +1,20 @@
+package dsl
+
+import "fmt"
+
+// ParseError describes a problem in the strategy source
+type ParseError struct {
+	Position
+	Message string `json:"message"`
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+func errorf(pos Position, format string, args ...interface{}) *ParseError {
+	return &ParseError{
+		Position: pos,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
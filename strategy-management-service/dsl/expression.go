@@ -0,0 +1,400 @@
+package dsl
+
+import (
+	"main/model/entity"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	flagType     = reflect.TypeOf(true)
+	integerType  = reflect.TypeOf(int64(0))
+	numberType   = reflect.TypeOf(float64(0))
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// betProviderId is the author of a bet in the filter of an aggregate
+const betProviderId = "providerId"
+
+// expression is a parsed entity.Expression together with its static type
+type expression struct {
+	e        entity.Expression
+	typ      reflect.Type
+	hasParam bool
+	// a var is a number, compared with a duration it counts seconds
+	hasVar bool
+	// literal keeps the text of a plain number so it can take the type
+	// of the other side of a comparison
+	literal string
+	pos     Position
+}
+
+func (e expression) isParam() bool {
+	return e.e.IsParam
+}
+
+func (e expression) isFlag() bool {
+	return e.e.IsParam && e.typ == flagType
+}
+
+// coerce converts a number literal to the given type
+func (e expression) coerce(typ reflect.Type) (expression, error) {
+	switch typ {
+	case integerType:
+		v, err := strconv.ParseInt(e.literal, 10, 64)
+		if err != nil {
+			return e, errorf(e.pos, "expected an integer, got %q", e.literal)
+		}
+		e.e = entity.Expression{IsConst: true, Value: v}
+	case numberType:
+		v, err := strconv.ParseFloat(e.literal, 64)
+		if err != nil {
+			return e, errorf(e.pos, "invalid number %q", e.literal)
+		}
+		e.e = entity.Expression{IsConst: true, Value: v}
+	case durationType:
+		return e, errorf(e.pos, "expected a duration, use a literal with a unit such as %ss", e.literal)
+	default:
+		return e, nil
+	}
+	e.typ = typ
+	return e, nil
+}
+
+// expression := term {('+' | '-') term}
+func (p *parser) parseExpression() (expression, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return expression{}, err
+	}
+	for p.peek().kind == tokPlus || p.peek().kind == tokMinus {
+		opTok := p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return expression{}, err
+		}
+		left, err = combine(opTok, left, right)
+		if err != nil {
+			return expression{}, err
+		}
+	}
+	return left, nil
+}
+
+// term := factor {('*' | '/') factor}
+func (p *parser) parseTerm() (expression, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return expression{}, err
+	}
+	for p.peek().kind == tokStar || p.peek().kind == tokSlash {
+		opTok := p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return expression{}, err
+		}
+		left, err = combine(opTok, left, right)
+		if err != nil {
+			return expression{}, err
+		}
+	}
+	return left, nil
+}
+
+// factor := ['-'] number | ['-'] duration | param | var | call | aggregate | '(' expression ')'
+func (p *parser) parseFactor() (expression, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokMinus:
+		p.next()
+		if k := p.peek().kind; k != tokNumber && k != tokDuration {
+			return expression{}, p.unexpected("number or duration after '-'")
+		}
+		res, err := p.parseFactor()
+		if err != nil {
+			return expression{}, err
+		}
+		return negateLiteral(res, t.pos), nil
+	case tokNumber:
+		p.next()
+		res := expression{
+			literal: t.text,
+			pos:     t.pos,
+		}
+		if strings.Contains(t.text, ".") {
+			return res.coerce(numberType)
+		}
+		return res.coerce(integerType)
+	case tokDuration:
+		p.next()
+		d, err := time.ParseDuration(t.text)
+		if err != nil {
+			return expression{}, errorf(t.pos, "invalid duration %q", t.text)
+		}
+		return expression{
+			e:   entity.Expression{IsConst: true, Value: d},
+			typ: durationType,
+			pos: t.pos,
+		}, nil
+	case tokVar:
+		p.next()
+		if _, ok := p.vars[t.text]; !ok {
+			return expression{}, errorf(t.pos, "undeclared var $%s, declare it with %s %s = value",
+				t.text, kwVar, t.text)
+		}
+		return expression{
+			e:      entity.Expression{IsVar: true, VName: t.text},
+			typ:    numberType,
+			hasVar: true,
+			pos:    t.pos,
+		}, nil
+	case tokLParen:
+		p.next()
+		res, err := p.parseExpression()
+		if err != nil {
+			return expression{}, err
+		}
+		if p.peek().kind != tokRParen {
+			return expression{}, p.unexpected("')'")
+		}
+		p.next()
+		res.literal = ""
+		return res, nil
+	case tokIdent:
+		for _, kw := range keywords {
+			if strings.EqualFold(t.text, kw) {
+				return expression{}, p.unexpected("param or constant")
+			}
+		}
+		if p.isAggregate() {
+			return p.parseAggregate()
+		}
+		if _, ok := entity.LookupFunction(strings.ToLower(t.text)); ok &&
+			p.tokens[p.pos+1].kind == tokLParen {
+			return p.parseCall()
+		}
+		param, ok := entity.LookupParam(t.text)
+		if !ok {
+			return expression{}, errorf(t.pos, "unknown param %q", t.text)
+		}
+		p.next()
+		return expression{
+			e:        entity.Expression{IsParam: true, PName: param},
+			typ:      reflect.TypeOf(entity.ParamValue(param, entity.CurrentSessionState{})),
+			hasParam: true,
+			pos:      t.pos,
+		}, nil
+	}
+	return expression{}, p.unexpected("param or constant")
+}
+
+// call := (min | max) '(' expression {',' expression} ')'
+func (p *parser) parseCall() (expression, error) {
+	nameTok := p.next()
+	fn, _ := entity.LookupFunction(strings.ToLower(nameTok.text))
+	p.next()
+	var args []expression
+	for {
+		arg, err := p.parseExpression()
+		if err != nil {
+			return expression{}, err
+		}
+		args = append(args, arg)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	if p.peek().kind != tokRParen {
+		return expression{}, p.unexpected("',' or ')'")
+	}
+	p.next()
+	if len(args) < 2 {
+		return expression{}, errorf(nameTok.pos, "%s needs at least two arguments", nameTok.text)
+	}
+	res := expression{
+		e:   entity.NewFunctionExpression(fn),
+		pos: nameTok.pos,
+	}
+	for _, arg := range args {
+		res.e.Args = append(res.e.Args, arg.e)
+		res.hasParam = res.hasParam || arg.hasParam
+		res.hasVar = res.hasVar || arg.hasVar
+	}
+	return res.typed(nameTok)
+}
+
+// isAggregate tells an aggregate from a call of min or max by the bet metric in the parentheses
+func (p *parser) isAggregate() bool {
+	if p.pos+2 >= len(p.tokens) || p.tokens[p.pos+1].kind != tokLParen || p.tokens[p.pos+2].kind != tokIdent {
+		return false
+	}
+	_, isAggregate := entity.LookupAggregate(p.peek().text)
+	_, isMetric := entity.LookupMetric(p.tokens[p.pos+2].text)
+	return isAggregate && isMetric
+}
+
+// aggregate := (COUNT | SUM | AVG | MIN | MAX) '(' metric [WHERE filter] [',' LAST window] ')'
+// window := duration | integer (STEPS | STEP)
+func (p *parser) parseAggregate() (expression, error) {
+	nameTok := p.next()
+	fn, _ := entity.LookupAggregate(nameTok.text)
+	p.next()
+	metric, _ := entity.LookupMetric(p.next().text)
+	agg := entity.Aggregate{Fn: fn, Metric: metric}
+	if p.isKeyword(kwWhere) {
+		p.next()
+		if err := p.parseBetFilter(&agg); err != nil {
+			return expression{}, err
+		}
+	}
+	if p.peek().kind == tokComma {
+		p.next()
+		if err := p.expectKeyword(kwLast); err != nil {
+			return expression{}, err
+		}
+		if err := p.parseWindow(&agg); err != nil {
+			return expression{}, err
+		}
+	}
+	if p.peek().kind != tokRParen {
+		return expression{}, p.unexpected("')'")
+	}
+	p.next()
+	res := expression{
+		e:        entity.NewAggregateExpression(agg),
+		hasParam: true,
+		pos:      nameTok.pos,
+	}
+	return res.typed(nameTok)
+}
+
+// filter := providerId ('==' | '!=') myId
+func (p *parser) parseBetFilter(agg *entity.Aggregate) error {
+	if t := p.peek(); t.kind != tokIdent || t.text != betProviderId {
+		return p.unexpected(betProviderId)
+	}
+	p.next()
+	opTok := p.peek()
+	if opTok.kind != tokOperation || (opTok.text != "==" && opTok.text != "!=") {
+		return p.unexpected("'==' or '!='")
+	}
+	p.next()
+	if t := p.peek(); t.kind != tokIdent || t.text != entity.ParamMyId {
+		return p.unexpected(entity.ParamMyId)
+	}
+	p.next()
+	agg.Filter = entity.FilterMine
+	if opTok.text == "!=" {
+		agg.Filter = entity.FilterOthers
+	}
+	return nil
+}
+
+func (p *parser) parseWindow(agg *entity.Aggregate) error {
+	t := p.peek()
+	switch t.kind {
+	case tokDuration:
+		p.next()
+		d, err := time.ParseDuration(t.text)
+		if err != nil || d <= 0 {
+			return errorf(t.pos, "the window must be a positive duration, got %q", t.text)
+		}
+		agg.Period = d
+		return nil
+	case tokNumber:
+		p.next()
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil || n <= 0 {
+			return errorf(t.pos, "the window must be a positive number of steps, got %q", t.text)
+		}
+		if !p.isKeyword(kwSteps) && !p.isKeyword(kwStep) {
+			return p.unexpected(kwSteps)
+		}
+		p.next()
+		agg.Steps = n
+		return nil
+	}
+	return p.unexpected("duration or number of steps")
+}
+
+// combine applies a binary operator to the operands
+func combine(opTok token, left, right expression) (expression, error) {
+	fn, _ := entity.LookupFunction(opTok.text)
+	res := expression{
+		e:        entity.NewFunctionExpression(fn, left.e, right.e),
+		hasParam: left.hasParam || right.hasParam,
+		hasVar:   left.hasVar || right.hasVar,
+		pos:      left.pos,
+	}
+	return res.typed(opTok)
+}
+
+// typed calculates the type of the function result, reporting wrong operands
+func (e expression) typed(at token) (expression, error) {
+	typ, err := sampleVars(e.e).ValueType()
+	if err != nil {
+		return expression{}, errorf(at.pos, "%s", err)
+	}
+	e.typ = typ
+	return e, nil
+}
+
+// sampleVars replaces the vars with numbers, the values they have until the strategy is bound
+func sampleVars(e entity.Expression) entity.Expression {
+	if e.IsVar {
+		return entity.Expression{IsConst: true, Value: float64(1)}
+	}
+	if len(e.Args) > 0 {
+		args := make([]entity.Expression, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = sampleVars(arg)
+		}
+		e.Args = args
+	}
+	return e
+}
+
+// comparesVar reports a number with a var compared with an integer or a duration,
+// the integer is promoted to a number and the duration counts seconds
+func comparesVar(left, right expression) bool {
+	varNumber := func(e expression) bool {
+		return e.hasVar && e.typ == numberType
+	}
+	other := func(e expression) bool {
+		return e.typ == integerType || e.typ == durationType
+	}
+	return (varNumber(left) && other(right)) || (varNumber(right) && other(left))
+}
+
+func negateLiteral(e expression, pos Position) expression {
+	switch v := e.e.Value.(type) {
+	case int64:
+		e.e.Value = -v
+	case float64:
+		e.e.Value = -v
+	case time.Duration:
+		e.e.Value = -v
+	}
+	if e.literal != "" {
+		e.literal = "-" + e.literal
+	}
+	e.pos = pos
+	return e
+}
+
+func typeName(t reflect.Type) string {
+	switch t {
+	case flagType:
+		return "flag"
+	case integerType:
+		return "integer"
+	case numberType:
+		return "number"
+	case durationType:
+		return "duration"
+	}
+	return "unknown"
+}
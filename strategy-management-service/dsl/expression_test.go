@@ -0,0 +1,330 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"main/model/entity"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseConditionExpression(t *testing.T) {
+	state := entity.CurrentSessionState{
+		CurrentPrice:       250,
+		MinimalPrice:       100,
+		PreferablePrice:    300,
+		CurrentStepNumber:  4,
+		TimeTillEnd:        10 * time.Minute,
+		TimeSinceLastStep:  6 * time.Minute,
+		TimeSinceLastMyBet: 90 * time.Second,
+	}
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: "currentPrice - minimalPrice > 100", want: true},
+		{src: "currentPrice - minimalPrice * 2 > 100", want: false},
+		{src: "(currentPrice - minimalPrice) * 2 > 300", want: false},
+		{src: "currentStepNumber / 3 > 1.3", want: true},
+		{src: "max(currentPrice, preferablePrice, 200) == 300", want: true},
+		{src: "min(currentPrice, preferablePrice) * 2 >= 500", want: true},
+		{src: "timeTillEnd / 2 < timeSinceLastStep", want: true},
+		{src: "timeTillEnd - timeSinceLastStep > 5m", want: false},
+		{src: "2 * timeSinceLastMyBet == 3m", want: true},
+		{src: "timeSinceLastMyBet / timeTillEnd < 0.2", want: true},
+		{src: "timeSinceLastMyBet > timeTillEnd / 10", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			cond, err := ParseCondition(tt.src)
+			if err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+			if got := cond.Evaluate(state); got != tt.want {
+				t.Errorf("Evaluate() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConditionLiteralTypes(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{src: "currentStepNumber > 3", want: int64(3)},
+		{src: "currentStepNumber > -2", want: int64(-2)},
+		{src: "currentPrice > 3", want: float64(3)},
+		{src: "currentPrice > -2", want: float64(-2)},
+		{src: "currentPrice > 2.5", want: 2.5},
+		{src: "timeTillEnd < 90s", want: 90 * time.Second},
+		{src: "timeTillEnd > -5s", want: -5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			cond, err := ParseCondition(tt.src)
+			if err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+			got := cond.Left.(entity.Condition).Val.Value
+			if got != tt.want {
+				t.Errorf("value = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConditionExpressionErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		column  int
+		message string
+	}{
+		{
+			src:     "currentStepNumber > 1.5",
+			column:  21,
+			message: `expected an integer, got "1.5"`,
+		},
+		{
+			src:     "timeTillEnd > 90",
+			column:  15,
+			message: "expected a duration, use a literal with a unit such as 90s",
+		},
+		{
+			src:     "timeTillEnd > currentPrice",
+			column:  13,
+			message: "cannot compare duration with number",
+		},
+		{
+			src:     "currentPrice + timeTillEnd > 5",
+			column:  14,
+			message: "cannot apply + to number and duration",
+		},
+		{
+			src:     "isOnAdditionalPurchase + 1 > 2",
+			column:  24,
+			message: "cannot apply + to flag and integer",
+		},
+		{
+			src:     "min(currentPrice) > 5",
+			column:  1,
+			message: "min needs at least two arguments",
+		},
+		{
+			src:     "currentPrice > - minimalPrice",
+			column:  18,
+			message: `expected number or duration after '-', got "minimalPrice"`,
+		},
+		{
+			src:     "(currentPrice - 5 > 3 THEN",
+			column:  23,
+			message: `expected ')', got "THEN"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := ParseCondition(tt.src)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseCondition() error = %v, want a *ParseError", err)
+			}
+			if parseErr.Line != 1 || parseErr.Column != tt.column {
+				t.Errorf("position = %d:%d, want 1:%d", parseErr.Line, parseErr.Column, tt.column)
+			}
+			if parseErr.Message != tt.message {
+				t.Errorf("message = %q, want %q", parseErr.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestStrategyJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "else chain",
+			src: "EVERY 10s\n" +
+				"IF currentPrice - minimalPrice > 100 AND timeSinceLastMyBet > 1m30s THEN BET\n" +
+				"ELSE IF NOT isOnAdditionalPurchase THEN BET_AFTER(3)\n" +
+				"ELSE WAIT_TIME",
+		},
+		{
+			name: "functions and integers",
+			src: "EVERY 3 STEPS\n" +
+				"IF max(currentPrice, preferablePrice) * 2 >= 400 OR currentStepNumber != 3\n" +
+				"THEN NOTIFY(\"price {currentPrice}\")",
+		},
+		{
+			name: "durations and jitter",
+			src: "EVERY 30s JITTER TICK 2s BET 1s TO 3s\n" +
+				"IF timeTillEnd / 2 < timeSinceLastStep AND timeTillEnd > -5s THEN STOP",
+		},
+		{
+			name: "aggregates",
+			src: "EVERY STEP\n" +
+				"IF COUNT(bets WHERE providerId != myId, LAST 3m) >= 2 AND AVG(timeBetweenBets, LAST 5 STEPS) < 30s\n" +
+				"THEN BET",
+		},
+		{
+			name: "phases",
+			src: "VAR delay = 60s\n" +
+				"PHASE HOLD\n" +
+				"WHEN isOnAdditionalPurchase GOTO DEFEND SET delay = 5s\n" +
+				"PHASE DEFEND\n" +
+				"IF currentWinnerId != myId AND timeSinceLastMyBet > $delay THEN BET",
+		},
+		{
+			name: "vars",
+			src: "VAR margin = 50\n" +
+				"VAR delay = 1m\n" +
+				"IF currentPrice - $margin > minimalPrice AND timeSinceLastMyBet > $delay THEN BET",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := ParseStrategy(tt.src)
+			if err != nil {
+				t.Fatalf("ParseStrategy() error = %v", err)
+			}
+			data, err := json.Marshal(strategy)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var decoded entity.Strategy
+			if err = json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(decoded, strategy) {
+				t.Errorf("decoded strategy = %+v, want %+v", decoded, strategy)
+			}
+			again, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if !bytes.Equal(again, data) {
+				t.Errorf("encoded again = %s, want %s", again, data)
+			}
+		})
+	}
+}
+
+func TestParseAggregate(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	bets := []*entity.Bet{
+		{BetNumber: 0, ProviderId: 2, Time: start.Add(time.Minute), NewPrice: 900},
+		{BetNumber: 1, ProviderId: 1, Time: start.Add(2 * time.Minute), NewPrice: 800},
+		{BetNumber: 2, ProviderId: 3, Time: start.Add(4 * time.Minute), NewPrice: 700},
+		{BetNumber: 3, ProviderId: 2, Time: start.Add(7 * time.Minute), NewPrice: 600},
+	}
+	state := entity.CurrentSessionState{UserId: 1}
+	state.SetBetHistory(entity.QuotationSession{StartTime: start, StartPrice: 1000, CurrentPrice: 600},
+		bets, start.Add(8*time.Minute))
+	tests := []struct {
+		src  string
+		want entity.Aggregate
+		// the value of the condition in the state
+		holds bool
+	}{
+		{
+			src:   "COUNT(bets) == 4",
+			want:  entity.Aggregate{Fn: entity.AggCount, Metric: entity.MetricBets},
+			holds: true,
+		},
+		{
+			src: "count(bets WHERE providerId != myId, LAST 5m) >= 2",
+			want: entity.Aggregate{Fn: entity.AggCount, Metric: entity.MetricBets, Filter: entity.FilterOthers,
+				Period: 5 * time.Minute},
+			holds: true,
+		},
+		{
+			src:   "MAX(price WHERE providerId == myId) > 800",
+			want:  entity.Aggregate{Fn: entity.AggMax, Metric: entity.MetricPrice, Filter: entity.FilterMine},
+			holds: false,
+		},
+		{
+			src:   "AVG(timeBetweenBets, LAST 2 STEPS) > 2m",
+			want:  entity.Aggregate{Fn: entity.AggAvg, Metric: entity.MetricTimeBetweenBets, Steps: 2},
+			holds: true,
+		},
+		{
+			src:   "SUM(price, LAST 1 STEP) == 600",
+			want:  entity.Aggregate{Fn: entity.AggSum, Metric: entity.MetricPrice, Steps: 1},
+			holds: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			cond, err := ParseCondition(tt.src)
+			if err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+			expr := cond.Left.(entity.Condition).Expr
+			if expr == nil || !expr.IsAggregate || !reflect.DeepEqual(*expr.Agg, tt.want) {
+				t.Fatalf("left operand = %+v, want the aggregate %s", expr, tt.want)
+			}
+			if got := cond.Evaluate(state); got != tt.holds {
+				t.Errorf("Evaluate() = %t, want %t", got, tt.holds)
+			}
+		})
+	}
+}
+
+func TestParseAggregateErrors(t *testing.T) {
+	tests := []struct {
+		src     string
+		column  int
+		message string
+	}{
+		{
+			src:     "SUM(bets) > 1",
+			column:  1,
+			message: "invalid aggregate: bets can only be counted, SUM(bets) is not supported",
+		},
+		{
+			src:     "COUNT(bets WHERE providerId > myId) > 1",
+			column:  29,
+			message: `expected '==' or '!=', got ">"`,
+		},
+		{
+			src:     "COUNT(bets WHERE currentWinnerId == myId) > 1",
+			column:  18,
+			message: `expected providerId, got "currentWinnerId"`,
+		},
+		{
+			src:     "COUNT(bets, LAST 0 STEPS) > 1",
+			column:  18,
+			message: `the window must be a positive number of steps, got "0"`,
+		},
+		{
+			src:     "COUNT(bets, LAST 5) > 1",
+			column:  19,
+			message: `expected STEPS, got ")"`,
+		},
+		{
+			src:     "COUNT(bets, 5m) > 1",
+			column:  13,
+			message: `expected LAST, got "5m"`,
+		},
+		{
+			src:     "AVG(price) > 1s",
+			column:  12,
+			message: "cannot compare number with duration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := ParseCondition(tt.src)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseCondition() error = %v, want a *ParseError", err)
+			}
+			if parseErr.Column != tt.column || parseErr.Message != tt.message {
+				t.Errorf("error = %d: %q, want %d: %q", parseErr.Column, parseErr.Message, tt.column, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package dsl
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokDuration
+	tokOperation
+	tokLParen
+	tokRParen
+	tokComma
+	tokPlus
+	tokMinus
+	tokStar
+	tokSlash
+	tokVar
+	tokAssign
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  Position
+}
+
+// Position of a token in the source, both values start from 1
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type lexer struct {
+	src  []rune
+	off  int
+	line int
+	col  int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{
+		src:  []rune(src),
+		line: 1,
+		col:  1,
+	}
+}
+
+func (l *lexer) peekRune(n int) rune {
+	if l.off+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.off+n]
+}
+
+func (l *lexer) advance() rune {
+	r := l.src[l.off]
+	l.off++
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.off < len(l.src) {
+		r := l.src[l.off]
+		switch {
+		case unicode.IsSpace(r):
+			l.advance()
+		case r == '#':
+			for l.off < len(l.src) && l.src[l.off] != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipSpaceAndComments()
+	pos := Position{Line: l.line, Column: l.col}
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: pos}, nil
+	}
+	r := l.src[l.off]
+	switch {
+	case unicode.IsLetter(r) || r == '_':
+		var sb strings.Builder
+		for l.off < len(l.src) && (unicode.IsLetter(l.src[l.off]) ||
+			unicode.IsDigit(l.src[l.off]) || l.src[l.off] == '_') {
+			sb.WriteRune(l.advance())
+		}
+		return token{kind: tokIdent, text: sb.String(), pos: pos}, nil
+	case unicode.IsDigit(r):
+		return l.number(pos), nil
+	case r == '"':
+		return l.string(pos)
+	case r == '$':
+		l.advance()
+		var sb strings.Builder
+		for l.off < len(l.src) && (unicode.IsLetter(l.src[l.off]) ||
+			unicode.IsDigit(l.src[l.off]) || l.src[l.off] == '_') {
+			sb.WriteRune(l.advance())
+		}
+		if sb.Len() == 0 {
+			return token{}, errorf(pos, "expected a var name after '$'")
+		}
+		return token{kind: tokVar, text: sb.String(), pos: pos}, nil
+	case r == '(':
+		l.advance()
+		return token{kind: tokLParen, text: "(", pos: pos}, nil
+	case r == ')':
+		l.advance()
+		return token{kind: tokRParen, text: ")", pos: pos}, nil
+	case r == ',':
+		l.advance()
+		return token{kind: tokComma, text: ",", pos: pos}, nil
+	case r == '+':
+		l.advance()
+		return token{kind: tokPlus, text: "+", pos: pos}, nil
+	case r == '-':
+		l.advance()
+		return token{kind: tokMinus, text: "-", pos: pos}, nil
+	case r == '*':
+		l.advance()
+		return token{kind: tokStar, text: "*", pos: pos}, nil
+	case r == '/':
+		l.advance()
+		return token{kind: tokSlash, text: "/", pos: pos}, nil
+	case r == '>' || r == '<':
+		l.advance()
+		if l.peekRune(0) == '=' {
+			l.advance()
+			return token{kind: tokOperation, text: string(r) + "=", pos: pos}, nil
+		}
+		return token{kind: tokOperation, text: string(r), pos: pos}, nil
+	case r == '=' && l.peekRune(1) != '=':
+		l.advance()
+		return token{kind: tokAssign, text: "=", pos: pos}, nil
+	case r == '=' || r == '!':
+		l.advance()
+		if l.peekRune(0) != '=' {
+			return token{}, errorf(pos, "unexpected character %q, did you mean %q?", r, string(r)+"=")
+		}
+		l.advance()
+		return token{kind: tokOperation, text: string(r) + "=", pos: pos}, nil
+	}
+	return token{}, errorf(pos, "unexpected character %q", r)
+}
+
+// string scans a double-quoted string on a single line, \" and \\ are escaped
+func (l *lexer) string(pos Position) (token, error) {
+	l.advance()
+	var sb strings.Builder
+	for l.off < len(l.src) && l.src[l.off] != '\n' {
+		r := l.advance()
+		switch {
+		case r == '"':
+			return token{kind: tokString, text: sb.String(), pos: pos}, nil
+		case r == '\\' && (l.peekRune(0) == '"' || l.peekRune(0) == '\\'):
+			sb.WriteRune(l.advance())
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return token{}, errorf(pos, "unterminated string")
+}
+
+// number scans an integer, a float or a duration literal such as 90s or 1m30s
+func (l *lexer) number(pos Position) token {
+	var sb strings.Builder
+	kind := tokNumber
+	for l.off < len(l.src) {
+		r := l.src[l.off]
+		if unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(l.peekRune(1))) {
+			sb.WriteRune(l.advance())
+		} else if unicode.IsLetter(r) {
+			kind = tokDuration
+			sb.WriteRune(l.advance())
+		} else {
+			break
+		}
+	}
+	return token{kind: kind, text: sb.String(), pos: pos}
+}
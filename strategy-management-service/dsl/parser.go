@@ -0,0 +1,697 @@
+package dsl
+
+import (
+	"main/model/entity"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Strategy source example:
+//
+//	EVERY 5s
+//	IF currentPrice > preferablePrice AND currentWinnerId != myId THEN BET
+//	ELSE IF currentPrice > acceptablePrice AND timeSinceLastMyBet > 1m THEN BET
+//	ELSE IF NOT isOnAdditionalPurchase AND currentPrice > minimalPrice THEN BET
+//	ELSE WAIT_TIME
+//
+// The timing may be randomised after EVERY, TICK and BET are optional:
+//
+//	JITTER TICK 2s BET 1s TO 3s
+//
+// A phased strategy declares its vars and phases instead of a single rule:
+//
+//	VAR delay = 60s
+//	PHASE HOLD
+//	WHEN isOnAdditionalPurchase GOTO DEFEND SET delay = 5s
+//	PHASE DEFEND
+//	IF currentWinnerId != myId AND timeSinceLastMyBet > $delay THEN BET
+//
+// Keywords are case-insensitive, '#' starts a comment till the end of the line.
+
+const (
+	kwEvery = "EVERY"
+	kwIf    = "IF"
+	kwThen  = "THEN"
+	kwElse  = "ELSE"
+	kwAnd   = "AND"
+	kwOr    = "OR"
+	kwNot   = "NOT"
+	kwWhere = "WHERE"
+	kwLast  = "LAST"
+	kwSteps = "STEPS"
+	kwStep  = "STEP"
+	kwVar   = "VAR"
+	kwPhase = "PHASE"
+	kwWhen  = "WHEN"
+	kwGoto  = "GOTO"
+	kwSet   = "SET"
+
+	kwJitter = "JITTER"
+	// TICK, BET and TO are only keywords after JITTER
+	kwTick = "TICK"
+	kwBet  = "BET"
+	kwTo   = "TO"
+
+	// DefaultGranularity is used when the source has no EVERY clause (seconds)
+	DefaultGranularity = 5
+)
+
+var keywords = []string{kwEvery, kwIf, kwThen, kwElse, kwAnd, kwOr, kwNot, kwWhere, kwLast, kwSteps, kwStep,
+	kwVar, kwPhase, kwWhen, kwGoto, kwSet, kwJitter}
+
+var actions = map[string]entity.Action{
+	"BET":                             entity.ActionBet,
+	"WAIT":                            entity.ActionWaitTime,
+	string(entity.ActionWaitTime):     entity.ActionWaitTime,
+	string(entity.ActionWaitNextStep): entity.ActionWaitNextStep,
+	string(entity.ActionBetAfter):     entity.ActionBetAfter,
+	string(entity.ActionStop):         entity.ActionStop,
+	string(entity.ActionNotify):       entity.ActionNotify,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	// vars declared with VAR
+	vars map[string]float64
+}
+
+// ParseStrategy compiles the strategy source into a condition tree.
+// The returned error is a *ParseError with the position of the problem.
+func ParseStrategy(src string) (entity.Strategy, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return entity.Strategy{}, err
+	}
+	strategy := entity.Strategy{
+		R: entity.RepeaterFENSec,
+		N: DefaultGranularity,
+	}
+	if p.isKeyword(kwEvery) {
+		p.next()
+		if err = p.parseRepeater(&strategy); err != nil {
+			return entity.Strategy{}, err
+		}
+	}
+	if p.isKeyword(kwJitter) {
+		if strategy.Jitter, err = p.parseJitter(); err != nil {
+			return entity.Strategy{}, err
+		}
+	}
+	for p.isKeyword(kwVar) {
+		if err = p.parseVar(); err != nil {
+			return entity.Strategy{}, err
+		}
+	}
+	if len(p.vars) > 0 {
+		strategy.Vars = p.vars
+	}
+	if p.isKeyword(kwPhase) {
+		strategy.Phases, err = p.parsePhases()
+	} else {
+		strategy.BaseConditionSet, err = p.parseRule()
+	}
+	if err != nil {
+		return entity.Strategy{}, err
+	}
+	if p.peek().kind != tokEOF {
+		return entity.Strategy{}, p.unexpected("end of strategy")
+	}
+	return strategy, nil
+}
+
+// ParseCondition compiles a standalone condition, e.g. the start trigger of a runner:
+//
+//	timeTillEnd < 10m AND currentWinnerId != myId
+func ParseCondition(src string) (entity.Operator, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return entity.Operator{}, err
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return entity.Operator{}, err
+	}
+	if p.peek().kind != tokEOF {
+		return entity.Operator{}, p.unexpected("end of condition")
+	}
+	return asOperator(cond), nil
+}
+
+func newParser(src string) (*parser, error) {
+	l := newLexer(src)
+	p := &parser{vars: map[string]float64{}}
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		p.tokens = append(p.tokens, t)
+		if t.kind == tokEOF {
+			return p, nil
+		}
+	}
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.isKeyword(kw) {
+		return p.unexpected(kw)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return errorf(t.pos, "expected %s, got end of strategy", expected)
+	}
+	return errorf(t.pos, "expected %s, got %q", expected, t.text)
+}
+
+// repeater := EVERY (granularity | STEP | number STEPS)
+func (p *parser) parseRepeater(strategy *entity.Strategy) error {
+	if p.isKeyword(kwStep) {
+		p.next()
+		strategy.R, strategy.N = entity.RepeaterFES, 1
+		return nil
+	}
+	t := p.peek()
+	n, err := p.parseGranularity()
+	if t.kind != tokNumber || !p.isKeyword(kwSteps) && !p.isKeyword(kwStep) {
+		strategy.N = n
+		return err
+	}
+	p.next()
+	if err != nil {
+		return errorf(t.pos, "number of steps must be a positive integer, got %q", t.text)
+	}
+	strategy.R, strategy.N = entity.RepeaterFENS, n
+	if n == 1 {
+		strategy.R = entity.RepeaterFES
+	}
+	return nil
+}
+
+func (p *parser) parseGranularity() (int, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		n, err := strconv.Atoi(t.text)
+		if err != nil || n <= 0 {
+			return 0, errorf(t.pos, "granularity must be a positive number of seconds, got %q", t.text)
+		}
+		return n, nil
+	case tokDuration:
+		d, err := time.ParseDuration(t.text)
+		if err != nil {
+			return 0, errorf(t.pos, "invalid duration %q", t.text)
+		}
+		if d < time.Second || d%time.Second != 0 {
+			return 0, errorf(t.pos, "granularity must be a whole number of seconds, got %s", d)
+		}
+		return int(d / time.Second), nil
+	}
+	return 0, errorf(t.pos, "expected granularity, %s or a number of %s after %s", kwStep, kwSteps, kwEvery)
+}
+
+// jitter := JITTER [TICK duration] [BET duration [TO duration]]
+// BET without TO delays the bets by up to the duration
+func (p *parser) parseJitter() (*entity.Jitter, error) {
+	jitterTok := p.next()
+	res := &entity.Jitter{}
+	var err error
+	if p.isKeyword(kwTick) {
+		p.next()
+		if res.Tick, err = p.parseDuration(); err != nil {
+			return nil, err
+		}
+	}
+	if p.isKeyword(kwBet) {
+		p.next()
+		if res.BetMax, err = p.parseDuration(); err != nil {
+			return nil, err
+		}
+		if p.isKeyword(kwTo) {
+			p.next()
+			res.BetMin = res.BetMax
+			if res.BetMax, err = p.parseDuration(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if *res == (entity.Jitter{}) {
+		return nil, errorf(jitterTok.pos, "expected %s or %s after %s", kwTick, kwBet, kwJitter)
+	}
+	return res, nil
+}
+
+// duration := number | duration, a number is taken in seconds
+func (p *parser) parseDuration() (time.Duration, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return 0, errorf(t.pos, "invalid number %q", t.text)
+		}
+		return time.Duration(v * float64(time.Second)), nil
+	case tokDuration:
+		p.next()
+		d, err := time.ParseDuration(t.text)
+		if err != nil {
+			return 0, errorf(t.pos, "invalid duration %q", t.text)
+		}
+		return d, nil
+	}
+	return 0, p.unexpected("duration")
+}
+
+// var := VAR name '=' value
+func (p *parser) parseVar() error {
+	p.next()
+	nameTok, err := p.parseName("var name")
+	if err != nil {
+		return err
+	}
+	if _, ok := p.vars[nameTok.text]; ok {
+		return errorf(nameTok.pos, "var %s is declared twice", nameTok.text)
+	}
+	if p.peek().kind != tokAssign {
+		return p.unexpected("'='")
+	}
+	p.next()
+	p.vars[nameTok.text], err = p.parseValue()
+	return err
+}
+
+// value := ['-'] (number | duration), a duration is taken in seconds
+func (p *parser) parseValue() (float64, error) {
+	sign := 1.0
+	if p.peek().kind == tokMinus {
+		p.next()
+		sign = -1
+	}
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return 0, errorf(t.pos, "invalid number %q", t.text)
+		}
+		return sign * v, nil
+	case tokDuration:
+		p.next()
+		d, err := time.ParseDuration(t.text)
+		if err != nil {
+			return 0, errorf(t.pos, "invalid duration %q", t.text)
+		}
+		return sign * d.Seconds(), nil
+	}
+	return 0, p.unexpected("number or duration")
+}
+
+// parseName reads an identifier which is not a keyword
+func (p *parser) parseName(expected string) (token, error) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return token{}, p.unexpected(expected)
+	}
+	for _, kw := range keywords {
+		if strings.EqualFold(t.text, kw) {
+			return token{}, p.unexpected(expected)
+		}
+	}
+	p.next()
+	return t, nil
+}
+
+// phases := phase {phase}
+// phase := PHASE name {transition} [rule]
+func (p *parser) parsePhases() ([]entity.Phase, error) {
+	var phases []entity.Phase
+	declared := map[string]bool{}
+	// targets of the transitions, checked once all the phases are declared
+	var targets []token
+	for p.isKeyword(kwPhase) {
+		p.next()
+		nameTok, err := p.parseName("phase name")
+		if err != nil {
+			return nil, err
+		}
+		if declared[nameTok.text] {
+			return nil, errorf(nameTok.pos, "phase %s is declared twice", nameTok.text)
+		}
+		declared[nameTok.text] = true
+		phase := entity.Phase{Name: nameTok.text}
+		for p.isKeyword(kwWhen) {
+			transition, target, err := p.parseTransition()
+			if err != nil {
+				return nil, err
+			}
+			phase.Transitions = append(phase.Transitions, transition)
+			targets = append(targets, target)
+		}
+		if p.isKeyword(kwIf) {
+			if phase.ConditionSet, err = p.parseRule(); err != nil {
+				return nil, err
+			}
+		}
+		phases = append(phases, phase)
+	}
+	for _, t := range targets {
+		if !declared[t.text] {
+			return nil, errorf(t.pos, "unknown phase %s", t.text)
+		}
+	}
+	return phases, nil
+}
+
+// transition := WHEN condition GOTO name [SET name '=' value {',' name '=' value}]
+func (p *parser) parseTransition() (entity.Transition, token, error) {
+	p.next()
+	cond, err := p.parseOr()
+	if err != nil {
+		return entity.Transition{}, token{}, err
+	}
+	if err = p.expectKeyword(kwGoto); err != nil {
+		return entity.Transition{}, token{}, err
+	}
+	target, err := p.parseName("phase name")
+	if err != nil {
+		return entity.Transition{}, token{}, err
+	}
+	res := entity.Transition{
+		Op: asOperator(cond),
+		To: target.text,
+	}
+	if !p.isKeyword(kwSet) {
+		return res, target, nil
+	}
+	p.next()
+	res.Set = map[string]float64{}
+	for {
+		nameTok, err := p.parseName("var name")
+		if err != nil {
+			return entity.Transition{}, token{}, err
+		}
+		if _, ok := p.vars[nameTok.text]; !ok {
+			return entity.Transition{}, token{}, errorf(nameTok.pos,
+				"undeclared var %s, declare it with %s %s = value", nameTok.text, kwVar, nameTok.text)
+		}
+		if p.peek().kind != tokAssign {
+			return entity.Transition{}, token{}, p.unexpected("'='")
+		}
+		p.next()
+		if res.Set[nameTok.text], err = p.parseValue(); err != nil {
+			return entity.Transition{}, token{}, err
+		}
+		if p.peek().kind != tokComma {
+			return res, target, nil
+		}
+		p.next()
+	}
+}
+
+// rule := IF condition THEN action [ELSE (rule | WAIT_TIME)]
+func (p *parser) parseRule() (*entity.ConditionSet, error) {
+	if err := p.expectKeyword(kwIf); err != nil {
+		return nil, err
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expectKeyword(kwThen); err != nil {
+		return nil, err
+	}
+	set := &entity.ConditionSet{
+		Op: asOperator(cond),
+	}
+	if err = p.parseAction(set); err != nil {
+		return nil, err
+	}
+	if !p.isKeyword(kwElse) {
+		return set, nil
+	}
+	p.next()
+	if p.isKeyword(kwIf) {
+		set.Else, err = p.parseRule()
+		if err != nil {
+			return nil, err
+		}
+		return set, nil
+	}
+	t := p.peek()
+	var bare entity.ConditionSet
+	if err = p.parseAction(&bare); err != nil {
+		return nil, err
+	}
+	if bare.Action != entity.ActionWaitTime {
+		return nil, errorf(t.pos, "a bare %s can only be followed by %s, use %s ... %s %s instead",
+			kwElse, entity.ActionWaitTime, kwIf, kwThen, bare.Action)
+	}
+	return set, nil
+}
+
+// action := BET | WAIT_TIME | WAIT_NEXT_STEP | STOP | BET_AFTER '(' duration ')' | NOTIFY '(' string ')'
+func (p *parser) parseAction(set *entity.ConditionSet) error {
+	t := p.peek()
+	a, ok := actions[strings.ToUpper(t.text)]
+	if t.kind != tokIdent || !ok {
+		return p.unexpected("action (BET, BET_AFTER, WAIT_TIME, WAIT_NEXT_STEP, STOP or NOTIFY)")
+	}
+	p.next()
+	set.Action = a
+	if a != entity.ActionBetAfter && a != entity.ActionNotify {
+		return nil
+	}
+	if p.peek().kind != tokLParen {
+		return p.unexpected("'(' after " + string(a))
+	}
+	p.next()
+	arg := p.peek()
+	switch {
+	case a == entity.ActionBetAfter && (arg.kind == tokDuration || arg.kind == tokNumber):
+		p.next()
+		d, err := time.ParseDuration(arg.text)
+		if arg.kind == tokNumber {
+			d, err = time.ParseDuration(arg.text + "s")
+		}
+		if err != nil || d <= 0 || d%time.Second != 0 {
+			return errorf(arg.pos, "%s delay must be a positive whole number of seconds, got %q", a, arg.text)
+		}
+		set.Delay = int(d / time.Second)
+	case a == entity.ActionNotify && arg.kind == tokString:
+		p.next()
+		set.Message = arg.text
+	case a == entity.ActionBetAfter:
+		return p.unexpected("delay")
+	default:
+		return p.unexpected("message in double quotes")
+	}
+	if p.peek().kind != tokRParen {
+		return p.unexpected("')'")
+	}
+	p.next()
+	return nil
+}
+
+// or := and {OR and}
+func (p *parser) parseOr() (interface{}, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword(kwOr) {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = entity.Operator{O: entity.UnOpOr, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// and := unary {AND unary}
+func (p *parser) parseAnd() (interface{}, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword(kwAnd) {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = entity.Operator{O: entity.UnOpAnd, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// unary := NOT unary | '(' or ')' | comparison
+func (p *parser) parseUnary() (interface{}, error) {
+	if p.isKeyword(kwNot) {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negate(node), nil
+	}
+	if p.peek().kind != tokLParen {
+		return p.parseComparison()
+	}
+	// '(' starts either a group of conditions or an expression like (a - b) > c
+	start := p.pos
+	node, groupErr := p.parseGroup()
+	if groupErr == nil {
+		return node, nil
+	}
+	groupEnd := p.pos
+	p.pos = start
+	node, err := p.parseComparison()
+	if err == nil {
+		return node, nil
+	}
+	if groupEnd > p.pos {
+		return nil, groupErr
+	}
+	return nil, err
+}
+
+func (p *parser) parseGroup() (interface{}, error) {
+	p.next()
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokRParen {
+		return nil, p.unexpected("')'")
+	}
+	p.next()
+	return node, nil
+}
+
+// comparison := expression operation expression | flag
+func (p *parser) parseComparison() (interface{}, error) {
+	left, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if left.isFlag() {
+		if p.peek().kind == tokOperation {
+			return nil, errorf(p.peek().pos, "%s is a flag and cannot be compared, "+
+				"use it alone or with %s", left.e.PName, kwNot)
+		}
+		return entity.Condition{
+			Param: left.e.PName,
+			Op:    entity.OpNone,
+			Val:   entity.Expression{IsEmpty: true},
+		}, nil
+	}
+	opTok := p.peek()
+	if opTok.kind == tokAssign {
+		return nil, errorf(opTok.pos, "unexpected character '=', did you mean \"==\"?")
+	}
+	if opTok.kind != tokOperation {
+		return nil, p.unexpected("comparison operation")
+	}
+	p.next()
+	op, _ := entity.LookupOperation(opTok.text)
+	right, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if !left.hasParam && !right.hasParam {
+		return nil, errorf(left.pos, "at least one side of a comparison must use a param")
+	}
+	if !left.isParam() && right.isParam() {
+		left, right = right, left
+		op = op.Mirror()
+	}
+	for _, side := range []expression{left, right} {
+		if side.typ == flagType {
+			return nil, errorf(side.pos, "flags cannot be compared")
+		}
+	}
+	if right.literal != "" {
+		if right, err = right.coerce(left.typ); err != nil {
+			return nil, err
+		}
+	} else if left.literal != "" {
+		if left, err = left.coerce(right.typ); err != nil {
+			return nil, err
+		}
+	}
+	if left.typ != right.typ && !comparesVar(left, right) {
+		return nil, errorf(opTok.pos, "cannot compare %s with %s", typeName(left.typ), typeName(right.typ))
+	}
+	cond := entity.Condition{
+		Op:  op,
+		Val: right.e,
+	}
+	if left.isParam() {
+		cond.Param = left.e.PName
+	} else {
+		cond.Expr = &left.e
+	}
+	return cond, nil
+}
+
+// negate pushes NOT down to the conditions using De Morgan's laws
+func negate(node interface{}) interface{} {
+	switch n := node.(type) {
+	case entity.Condition:
+		if n.Op != entity.OpNone {
+			n.Op = n.Op.Negate()
+		} else if n.M == entity.ModNot {
+			n.M = ""
+		} else {
+			n.M = entity.ModNot
+		}
+		return n
+	case entity.Operator:
+		switch n.O {
+		case entity.UnOpAnd:
+			n.O = entity.UnOpOr
+		case entity.UnOpOr:
+			n.O = entity.UnOpAnd
+		}
+		n.Left = negate(n.Left)
+		if n.Right != nil {
+			n.Right = negate(n.Right)
+		}
+		return n
+	}
+	return node
+}
+
+func asOperator(node interface{}) entity.Operator {
+	if o, ok := node.(entity.Operator); ok {
+		return o
+	}
+	return entity.Operator{O: entity.UnOpNone, Left: node}
+}
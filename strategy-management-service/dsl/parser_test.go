@@ -0,0 +1,470 @@
+package dsl
+
+import (
+	"errors"
+	"main/model/entity"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStrategyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		line    int
+		column  int
+		message string
+	}{
+		{
+			name:    "single exclamation mark",
+			src:     "EVERY 5s\nIF currentPrice ! 5 THEN BET",
+			line:    2,
+			column:  17,
+			message: `unexpected character '!', did you mean "!="?`,
+		},
+		{
+			name:    "assignment instead of comparison",
+			src:     "IF currentPrice = 5 THEN BET",
+			line:    1,
+			column:  17,
+			message: `unexpected character '=', did you mean "=="?`,
+		},
+		{
+			name:    "unknown character",
+			src:     "IF currentPrice > 5 THEN BET;",
+			line:    1,
+			column:  29,
+			message: `unexpected character ';'`,
+		},
+		{
+			name:    "unterminated string",
+			src:     "IF currentPrice > 5 THEN NOTIFY(\"outbid\nELSE WAIT_TIME",
+			line:    1,
+			column:  33,
+			message: "unterminated string",
+		},
+		{
+			name:    "var without a name",
+			src:     "IF currentPrice > $ THEN BET",
+			line:    1,
+			column:  19,
+			message: "expected a var name after '$'",
+		},
+		{
+			name:    "unknown param",
+			src:     "# comment\n\nIF currentPrise > 5 THEN BET",
+			line:    3,
+			column:  4,
+			message: `unknown param "currentPrise"`,
+		},
+		{
+			name:    "missing action",
+			src:     "IF currentPrice > 5 THEN",
+			line:    1,
+			column:  25,
+			message: "expected action (BET, BET_AFTER, WAIT_TIME, WAIT_NEXT_STEP, STOP or NOTIFY), got end of strategy",
+		},
+		{
+			name:    "bare else with an action",
+			src:     "IF currentPrice > 5 THEN BET\nELSE STOP",
+			line:    2,
+			column:  6,
+			message: "a bare ELSE can only be followed by WAIT_TIME, use IF ... THEN STOP instead",
+		},
+		{
+			name:    "text after the rule",
+			src:     "IF currentPrice > 5 THEN BET\nELSE WAIT_TIME BET",
+			line:    2,
+			column:  16,
+			message: `expected end of strategy, got "BET"`,
+		},
+		{
+			name:    "flag compared",
+			src:     "IF isOnAdditionalPurchase == 1 THEN BET",
+			line:    1,
+			column:  27,
+			message: "isOnAdditionalPurchase is a flag and cannot be compared, use it alone or with NOT",
+		},
+		{
+			name:    "comparison without params",
+			src:     "IF 1 < 2 THEN BET",
+			line:    1,
+			column:  4,
+			message: "at least one side of a comparison must use a param",
+		},
+		{
+			name:    "number of steps",
+			src:     "EVERY 0 STEPS\nIF currentPrice > 5 THEN BET",
+			line:    1,
+			column:  7,
+			message: `number of steps must be a positive integer, got "0"`,
+		},
+		{
+			name:    "fractional granularity",
+			src:     "EVERY 1500ms\nIF currentPrice > 5 THEN BET",
+			line:    1,
+			column:  7,
+			message: "granularity must be a whole number of seconds, got 1.5s",
+		},
+		{
+			name:    "granularity with a unit typo",
+			src:     "EVERY 5x\nIF currentPrice > 5 THEN BET",
+			line:    1,
+			column:  7,
+			message: `invalid duration "5x"`,
+		},
+		{
+			name:    "bet after a fraction of a second",
+			src:     "IF currentPrice > 5 THEN BET_AFTER(500ms)",
+			line:    1,
+			column:  36,
+			message: `BET_AFTER delay must be a positive whole number of seconds, got "500ms"`,
+		},
+		{
+			name:    "jitter without bounds",
+			src:     "EVERY 10s JITTER\nIF currentPrice > 5 THEN BET",
+			line:    1,
+			column:  11,
+			message: "expected TICK or BET after JITTER",
+		},
+		{
+			name:    "var declared twice",
+			src:     "VAR delay = 5s\nVAR delay = 10s\nIF timeSinceLastMyBet > $delay THEN BET",
+			line:    2,
+			column:  5,
+			message: "var delay is declared twice",
+		},
+		{
+			name:    "undeclared var",
+			src:     "IF timeSinceLastMyBet > $delay THEN BET",
+			line:    1,
+			column:  25,
+			message: "undeclared var $delay, declare it with VAR delay = value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseStrategy(tt.src)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseStrategy() error = %v, want a *ParseError", err)
+			}
+			if parseErr.Line != tt.line || parseErr.Column != tt.column {
+				t.Errorf("position = %d:%d, want %d:%d", parseErr.Line, parseErr.Column, tt.line, tt.column)
+			}
+			if parseErr.Message != tt.message {
+				t.Errorf("message = %q, want %q", parseErr.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseErrorString(t *testing.T) {
+	err := &ParseError{Position: Position{Line: 2, Column: 7}, Message: "unknown param"}
+	if got, want := err.Error(), "line 2, column 7: unknown param"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestLexerNumber(t *testing.T) {
+	tests := []struct {
+		src  string
+		kind tokenKind
+		text string
+	}{
+		{src: "12", kind: tokNumber, text: "12"},
+		{src: "1.5", kind: tokNumber, text: "1.5"},
+		{src: "90s", kind: tokDuration, text: "90s"},
+		{src: "1m30s", kind: tokDuration, text: "1m30s"},
+		{src: "1.5h", kind: tokDuration, text: "1.5h"},
+		// the letters after the digits always make a duration, the parser reports a wrong unit
+		{src: "5x", kind: tokDuration, text: "5x"},
+		{src: "10abc)", kind: tokDuration, text: "10abc"},
+		// a dot without digits after it ends the number
+		{src: "5.", kind: tokNumber, text: "5"},
+		{src: "3 s", kind: tokNumber, text: "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tok, err := newLexer(tt.src).next()
+			if err != nil {
+				t.Fatalf("next() error = %v", err)
+			}
+			if tok.kind != tt.kind || tok.text != tt.text {
+				t.Errorf("next() = %d %q, want %d %q", tok.kind, tok.text, tt.kind, tt.text)
+			}
+		})
+	}
+}
+
+func TestParseConditionTrailingLetters(t *testing.T) {
+	_, err := ParseCondition("currentPrice > 10abc")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("ParseCondition() error = %v, want a *ParseError", err)
+	}
+	if parseErr.Column != 16 || parseErr.Message != `invalid duration "10abc"` {
+		t.Errorf("ParseCondition() error = %v", parseErr)
+	}
+}
+
+func TestParseStrategyRepeater(t *testing.T) {
+	tests := []struct {
+		src string
+		r   string
+		n   int
+	}{
+		{src: "IF currentPrice > 5 THEN BET", r: entity.RepeaterFENSec, n: DefaultGranularity},
+		{src: "EVERY 30 IF currentPrice > 5 THEN BET", r: entity.RepeaterFENSec, n: 30},
+		{src: "EVERY 2m IF currentPrice > 5 THEN BET", r: entity.RepeaterFENSec, n: 120},
+		{src: "every step if currentPrice > 5 then bet", r: entity.RepeaterFES, n: 1},
+		{src: "EVERY 1 STEP IF currentPrice > 5 THEN BET", r: entity.RepeaterFES, n: 1},
+		{src: "EVERY 3 STEPS IF currentPrice > 5 THEN BET", r: entity.RepeaterFENS, n: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			strategy, err := ParseStrategy(tt.src)
+			if err != nil {
+				t.Fatalf("ParseStrategy() error = %v", err)
+			}
+			if string(strategy.R) != tt.r || strategy.N != tt.n {
+				t.Errorf("repeater = %s %d, want %s %d", strategy.R, strategy.N, tt.r, tt.n)
+			}
+		})
+	}
+}
+
+func TestParseConditionMirror(t *testing.T) {
+	tests := []struct {
+		src string
+		op  string
+	}{
+		{src: "1000 < currentPrice", op: ">"},
+		{src: "1000 > currentPrice", op: "<"},
+		{src: "1000 <= currentPrice", op: ">="},
+		{src: "1000 >= currentPrice", op: "<="},
+		{src: "1000 == currentPrice", op: "=="},
+		{src: "1000 != currentPrice", op: "!="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := ParseCondition(tt.src)
+			if err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+			op, _ := entity.LookupOperation(tt.op)
+			want := entity.Operator{O: entity.UnOpNone, Left: entity.Condition{
+				Param: entity.ParamCurrentPrice,
+				Op:    op,
+				Val:   entity.Expression{IsConst: true, Value: float64(1000)},
+			}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseCondition() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseConditionNot(t *testing.T) {
+	price := func(op string, v float64) entity.Condition {
+		o, _ := entity.LookupOperation(op)
+		return entity.Condition{
+			Param: entity.ParamCurrentPrice,
+			Op:    o,
+			Val:   entity.Expression{IsConst: true, Value: v},
+		}
+	}
+	flag := func(negated bool) entity.Condition {
+		c := entity.Condition{
+			Param: entity.ParamIsOnAdditionalPurchase,
+			Op:    entity.OpNone,
+			Val:   entity.Expression{IsEmpty: true},
+		}
+		if negated {
+			c.M = entity.ModNot
+		}
+		return c
+	}
+	tests := []struct {
+		name string
+		src  string
+		want interface{}
+	}{
+		{
+			name: "comparison",
+			src:  "NOT currentPrice > 10",
+			want: price("<=", 10),
+		},
+		{
+			name: "flag",
+			src:  "NOT isOnAdditionalPurchase",
+			want: flag(true),
+		},
+		{
+			name: "double negation",
+			src:  "NOT NOT isOnAdditionalPurchase",
+			want: flag(false),
+		},
+		{
+			name: "and becomes or",
+			src:  "NOT (currentPrice >= 10 AND currentPrice < 20)",
+			want: entity.Operator{O: entity.UnOpOr, Left: price("<", 10), Right: price(">=", 20)},
+		},
+		{
+			name: "or becomes and",
+			src:  "NOT (currentPrice == 10 OR isOnAdditionalPurchase)",
+			want: entity.Operator{O: entity.UnOpAnd, Left: price("!=", 10), Right: flag(true)},
+		},
+		{
+			name: "nested groups",
+			src:  "NOT (currentPrice > 10 AND NOT (currentPrice < 5 OR isOnAdditionalPurchase))",
+			want: entity.Operator{O: entity.UnOpOr, Left: price("<=", 10),
+				Right: entity.Operator{O: entity.UnOpOr, Left: price("<", 5), Right: flag(false)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCondition(tt.src)
+			if err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+			want, ok := tt.want.(entity.Operator)
+			if !ok {
+				want = entity.Operator{O: entity.UnOpNone, Left: tt.want}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseCondition() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseConditionNotEvaluatesOpposite(t *testing.T) {
+	conditions := []string{
+		"currentPrice > 10 AND timeTillEnd < 5m",
+		"currentPrice <= 10 OR NOT isOnAdditionalPurchase",
+		"(currentPrice != 10 OR currentStepNumber >= 3) AND isOnAdditionalPurchase",
+	}
+	states := []entity.CurrentSessionState{
+		{CurrentPrice: 5, TimeTillEnd: time.Minute},
+		{CurrentPrice: 10, TimeTillEnd: 10 * time.Minute, IsOnAdditionalPurchase: true},
+		{CurrentPrice: 20, TimeTillEnd: time.Minute, CurrentStepNumber: 3, IsOnAdditionalPurchase: true},
+		{CurrentPrice: 20, TimeTillEnd: 10 * time.Minute},
+	}
+	for _, src := range conditions {
+		t.Run(src, func(t *testing.T) {
+			cond, err := ParseCondition(src)
+			if err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+			negated, err := ParseCondition("NOT (" + src + ")")
+			if err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+			for i, state := range states {
+				if cond.Evaluate(state) == negated.Evaluate(state) {
+					t.Errorf("state %d: the condition and its negation are both %t", i, cond.Evaluate(state))
+				}
+			}
+		})
+	}
+}
+
+func TestParseStrategyPhases(t *testing.T) {
+	src := "VAR delay = 60s\n" +
+		"VAR margin = 100\n" +
+		"PHASE HOLD\n" +
+		"WHEN isOnAdditionalPurchase GOTO DEFEND SET delay = 5s, margin = 50\n" +
+		"WHEN timeTillEnd < 5m GOTO DEFEND\n" +
+		"PHASE DEFEND\n" +
+		"IF currentWinnerId != myId AND timeSinceLastMyBet > $delay THEN BET"
+	strategy, err := ParseStrategy(src)
+	if err != nil {
+		t.Fatalf("ParseStrategy() error = %v", err)
+	}
+	if want := map[string]float64{"delay": 60, "margin": 100}; !reflect.DeepEqual(strategy.Vars, want) {
+		t.Errorf("vars = %v, want %v", strategy.Vars, want)
+	}
+	if strategy.BaseConditionSet != nil || len(strategy.Phases) != 2 {
+		t.Fatalf("strategy = %+v, want two phases", strategy)
+	}
+	hold, defend := strategy.Phases[0], strategy.Phases[1]
+	if hold.Name != "HOLD" || hold.ConditionSet != nil || len(hold.Transitions) != 2 {
+		t.Fatalf("first phase = %+v, want HOLD with two transitions", hold)
+	}
+	wantSet := map[string]float64{"delay": 5, "margin": 50}
+	if first := hold.Transitions[0]; first.To != "DEFEND" || !reflect.DeepEqual(first.Set, wantSet) {
+		t.Errorf("first transition = %+v, want DEFEND setting %v", first, wantSet)
+	}
+	if second := hold.Transitions[1]; second.To != "DEFEND" || second.Set != nil {
+		t.Errorf("second transition = %+v, want DEFEND setting nothing", second)
+	}
+	if defend.Name != "DEFEND" || defend.ConditionSet == nil || defend.ConditionSet.Action != entity.ActionBet {
+		t.Errorf("second phase = %+v, want DEFEND betting", defend)
+	}
+	if diags := strategy.Validate(); diags.HasErrors() {
+		t.Errorf("Validate() = %v", diags)
+	}
+}
+
+func TestParseStrategyPhasesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		line    int
+		column  int
+		message string
+	}{
+		{
+			name:    "keyword as a name",
+			src:     "PHASE IF",
+			line:    1,
+			column:  7,
+			message: `expected phase name, got "IF"`,
+		},
+		{
+			name:    "phase declared twice",
+			src:     "PHASE A\nIF currentPrice > 5 THEN BET\nPHASE A",
+			line:    3,
+			column:  7,
+			message: "phase A is declared twice",
+		},
+		{
+			name:    "unknown phase",
+			src:     "PHASE A\nWHEN isOnAdditionalPurchase GOTO B\nIF currentPrice > 5 THEN BET",
+			line:    2,
+			column:  34,
+			message: "unknown phase B",
+		},
+		{
+			name:    "transition without a target",
+			src:     "PHASE A\nWHEN isOnAdditionalPurchase THEN BET",
+			line:    2,
+			column:  29,
+			message: `expected GOTO, got "THEN"`,
+		},
+		{
+			name:    "transition sets an undeclared var",
+			src:     "PHASE A\nWHEN isOnAdditionalPurchase GOTO A SET delay = 5\nIF currentPrice > 5 THEN BET",
+			line:    2,
+			column:  40,
+			message: "undeclared var delay, declare it with VAR delay = value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseStrategy(tt.src)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("ParseStrategy() error = %v, want a *ParseError", err)
+			}
+			if parseErr.Line != tt.line || parseErr.Column != tt.column {
+				t.Errorf("position = %d:%d, want %d:%d", parseErr.Line, parseErr.Column, tt.line, tt.column)
+			}
+			if parseErr.Message != tt.message {
+				t.Errorf("message = %q, want %q", parseErr.Message, tt.message)
+			}
+		})
+	}
+}
@@ -2,16 +2,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	fileadapter "github.com/casbin/casbin/persist/file-adapter"
 	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"main/auth"
+	"main/client"
 	"main/config"
 	"main/controller"
+	"main/db"
 	conn "main/db/impl"
 	"main/docs"
 	"main/logging"
 	"main/middleware"
 	repo "main/repository/impl"
+	"main/service"
 	"main/utils"
+	"time"
 
 	"github.com/gin-contrib/pprof"
 	swaggerFiles "github.com/swaggo/files"
@@ -53,11 +60,53 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	err = connection.Migrate(db.PgMigrationsPath)
+	if err != nil {
+		panic(err)
+	}
 
-	sessionRepo := repo.NewPgOrmQuotationSessionRepository(connection.Connection().(*pg.DB))
-	betRepo := repo.NewPgOrmBetRepository(ctx, connection.Connection().(*pg.DB))
+	// the sessions and bets go through the API of the tender service, so that its auction rules
+	// apply to the bets of the runners
+	tenderUrl := utils.GetEnv(utils.TenderApiUrlEnvKey, client.DefaultTenderUrl)
+	tender := client.NewTenderClient(tenderUrl, auth.NewServiceToken())
+	logging.InfoFormat("Sessions and bets are accessed through the tender service at %s", tenderUrl)
+
+	// in the simulation mode the clock follows the simulated clock of the tender service
+	clock := utils.NewSystemClock()
+	var simClock *utils.FakeClock
+	if utils.GetEnvBool(utils.SimulationModeEnvKey, false) {
+		start, err := tender.GetClock(ctx)
+		if err != nil {
+			panic(fmt.Errorf("cannot get the simulated time of the tender service: %w", err))
+		}
+		simClock = utils.NewFakeClock(start)
+		clock = simClock
+		go tender.FollowClock(ctx, simClock)
+		logging.InfoFormat("Simulation mode, the clock starts at %s", simClock.Now().Format(time.RFC3339))
+	}
 
-	strategyC := controller.NewStrategyController(ctx, sessionRepo, betRepo)
+	strategyRepo := repo.NewPgOrmStrategyRepository(ctx, connection.Connection().(*pg.DB))
+	traceRepo := repo.NewPgOrmDecisionTraceRepository(ctx, connection.Connection().(*pg.DB))
+	runnerRepo := repo.NewPgOrmStrategyRunnerRepository(ctx, connection.Connection().(*pg.DB))
+	blockedRepo := repo.NewPgOrmBlockedActionRepository(ctx, connection.Connection().(*pg.DB))
+	notificationRepo := repo.NewPgOrmRunnerNotificationRepository(ctx, connection.Connection().(*pg.DB))
+	proposalRepo := repo.NewPgOrmBetProposalRepository(ctx, connection.Connection().(*pg.DB))
+	accountRepo := repo.NewPgOrmAccountRepository(ctx, connection.Connection().(*pg.DB))
+	limitsRepo := repo.NewPgOrmOrgLimitsRepository(ctx, connection.Connection().(*pg.DB))
+	portfolioRepo := repo.NewPgOrmPortfolioRepository(ctx, connection.Connection().(*pg.DB))
+	sessionRepo := repo.NewApiQuotationSessionRepository(ctx, tender)
+	betRepo := repo.NewApiBetRepository(ctx, tender, runnerRepo)
+
+	strategySrv := service.NewStrategyService(ctx, sessionRepo, betRepo, strategyRepo, traceRepo, runnerRepo,
+		blockedRepo, notificationRepo, proposalRepo, accountRepo, limitsRepo, portfolioRepo, clock)
+	strategySrv.SeedJitter(utils.GetEnvInt(utils.JitterSeedEnvKey, time.Now().UnixNano()))
+	strategySrv.ListenBets(repo.NewApiBetListener(ctx, tender))
+	err = strategySrv.ResumeRunners()
+	if err != nil {
+		logging.ErrorFormat("Cannot resume strategy runners: %s", err)
+	}
+	strategyC := controller.NewStrategyController(ctx, strategySrv,
+		service.NewCustomStrategyService(ctx, strategyRepo), clock)
 
 	hC := controller.NewHealthCheckController(ctx,
 		connection,
@@ -74,8 +123,75 @@ func main() {
 			{
 				strategies.POST("/run", middleware.Authorize(config.Strategy, config.Run, fileAdapter),
 					strategyC.RunStrategy)
+				strategies.POST("/backtest", middleware.Authorize(config.Strategy, config.Backtest, fileAdapter),
+					strategyC.BacktestStrategy)
 				strategies.POST(":sessionId/:userId", middleware.Authorize(config.Strategy, config.Stop, fileAdapter),
 					strategyC.StopStrategy)
+				// the session ID has to be named as in the other GET routes
+				strategies.GET(":id/:userId/trace", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.GetDecisionTrace)
+				strategies.GET(":id/:userId/blocked", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.GetBlockedActions)
+				strategies.GET("templates", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.ListTemplates)
+				strategies.GET("notifications", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.GetNotifications)
+				strategies.GET("proposals", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.ListProposals)
+				strategies.GET("proposals/events", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.ProposalEvents)
+				strategies.GET("proposals/:id", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.GetProposal)
+				strategies.PUT("proposals/:id", middleware.Authorize(config.Strategy, config.Approve, fileAdapter),
+					strategyC.DecideProposal)
+				strategies.GET("orgs/:id/limits", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.GetOrgLimits)
+				strategies.PUT("orgs/:id/limits", middleware.Authorize(config.Strategy, config.Limits, fileAdapter),
+					strategyC.PutOrgLimits)
+				strategies.POST("orgs/:id/stop", middleware.Authorize(config.Strategy, config.Stop, fileAdapter),
+					strategyC.StopOrganization)
+				strategies.POST("orgs/:id/pause", middleware.Authorize(config.Strategy, config.Pause, fileAdapter),
+					strategyC.PauseOrganization)
+				strategies.POST("orgs/:id/resume", middleware.Authorize(config.Strategy, config.Pause, fileAdapter),
+					strategyC.ResumeOrganization)
+				strategies.POST("portfolios", middleware.Authorize(config.Strategy, config.Run, fileAdapter),
+					strategyC.RunPortfolio)
+				strategies.GET("portfolios/:id", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.GetPortfolio)
+				strategies.POST("portfolios/:id/stop", middleware.Authorize(config.Strategy, config.Stop, fileAdapter),
+					strategyC.StopPortfolio)
+				strategies.GET("scheduled", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.ListScheduled)
+				strategies.DELETE("scheduled/:id", middleware.Authorize(config.Strategy, config.Stop, fileAdapter),
+					strategyC.CancelScheduled)
+				strategies.GET("runners", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.ListRunners)
+				strategies.POST("runners/:sessionId/:userId/pause", middleware.Authorize(config.Strategy, config.Pause,
+					fileAdapter), strategyC.PauseRunner)
+				strategies.POST("runners/:sessionId/:userId/resume", middleware.Authorize(config.Strategy, config.Pause,
+					fileAdapter), strategyC.ResumeRunner)
+				strategies.POST("", middleware.Authorize(config.Strategy, config.Insert, fileAdapter),
+					strategyC.AddStrategy)
+				strategies.POST("validate", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.ValidateStrategy)
+				strategies.GET("", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.ListStrategies)
+				strategies.GET(":id", middleware.Authorize(config.Strategy, config.Read, fileAdapter),
+					strategyC.GetStrategy)
+				strategies.PUT(":id", middleware.Authorize(config.Strategy, config.Update, fileAdapter),
+					strategyC.PutStrategy)
+				strategies.DELETE(":id", middleware.Authorize(config.Strategy, config.Delete, fileAdapter),
+					strategyC.DeleteStrategy)
+			}
+			if simClock != nil {
+				clockC := controller.NewClockController(ctx, simClock, tender)
+				simulation := v1.Group("/clock")
+				{
+					simulation.GET("", middleware.Authorize(config.Clock, config.Read, fileAdapter),
+						clockC.GetClock)
+					simulation.POST("advance", middleware.Authorize(config.Clock, config.Update, fileAdapter),
+						clockC.AdvanceClock)
+				}
 			}
 
 		}
@@ -11,6 +11,8 @@ const (
 	CustomerRole userRole = "customer"
 	AdminRole    userRole = "admin"
 	ProviderRole userRole = "provider"
+	// Other services of the app, it cannot be registered
+	ServiceRole userRole = "service"
 )
 
 type Account struct {
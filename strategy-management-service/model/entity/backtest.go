@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// BacktestParams selects the strategy the same way as StrategyParams.
+// UserId is the provider whose runner is simulated, the provider's recorded bets are left out.
+type BacktestParams struct {
+	StrategyParams
+	// FINISHED sessions to replay together with QuotationSessionId, all finished sessions when both are empty
+	SessionIds []int64 `json:"session_ids,omitempty"`
+	// Seed of the timing jitter of the strategy, the same seed replays the same jitter
+	Seed int64 `json:"seed,omitempty"`
+}
+
+type BacktestResult struct {
+	SessionId   int64  `json:"session_id"`
+	SessionName string `json:"session_name"`
+	// Outcome of the recorded session
+	RecordedPrice    float64 `json:"recorded_price"`
+	RecordedWinnerId int64   `json:"recorded_winner_id"`
+	// Outcome with the runner taking part
+	FinalPrice float64       `json:"final_price"`
+	WinnerId   int64         `json:"winner_id"`
+	Won        bool          `json:"won"`
+	Status     SessionStatus `json:"status"`
+	EndTime    time.Time     `json:"end_time"`
+	MyBets     int           `json:"my_bets"`
+	// Recorded bets made in the simulation and rejected by the auction rules,
+	// e.g. made after the session finished earlier because of the runner bets
+	ReplayedBets int `json:"replayed_bets"`
+	SkippedBets  int `json:"skipped_bets"`
+	// Runner bets refused by the minimal price or the max number of bets
+	BlockedBets int `json:"blocked_bets"`
+	// NOTIFY actions and whether the runner stopped itself with STOP
+	Notifications int    `json:"notifications"`
+	Stopped       bool   `json:"stopped"`
+	Error         string `json:"error,omitempty"`
+}
@@ -1,9 +1,40 @@
 package entity
 
 import (
+	"errors"
 	"time"
 )
 
+// The refusals of the bets by the tender service
+var (
+	ErrSessionNotActive = errors.New("cannot make bet: session isn't active")
+	ErrRepeatedBet      = errors.New("cannot make bet: this provider made the last bet")
+	ErrPriceFloor       = errors.New("cannot make bet: the price would fall below the minimal price")
+)
+
+// The error codes the tender service reports the refusals with
+const (
+	CodeSessionNotActive = "session_not_active"
+	CodeRepeatedBet      = "repeated_bet"
+	CodePriceFloor       = "price_floor"
+)
+
+// BetError returns the refusal reported with the error code, nil without a code
+func BetError(code string) error {
+	switch code {
+	case "":
+		return nil
+	case CodeSessionNotActive:
+		return ErrSessionNotActive
+	case CodeRepeatedBet:
+		return ErrRepeatedBet
+	case CodePriceFloor:
+		return ErrPriceFloor
+	default:
+		return errors.New("cannot make bet: " + code)
+	}
+}
+
 // Bet example
 type Bet struct {
 	ID                 int64     `pg:"id,pk" json:"id"`
@@ -20,4 +51,14 @@ type BetData struct {
 	QuotationSessionID int64 `json:"quotation_session_id"`
 	ProviderId         int64 `json:"provider_id"`
 	Bot                bool  `json:"bot"`
+	// The bet is refused with ErrPriceFloor when the new price would be lower, used by the runners
+	MinPrice float64 `json:"min_price,omitempty"`
+}
+
+// BetFilter selects the bets, an empty field matches any bet
+type BetFilter struct {
+	ProviderIds []int64
+	Bot         *bool
+	// Bets made at the time or later
+	Since time.Time
 }
@@ -0,0 +1,90 @@
+package entity
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// CompetitorBetsWindow is the number of the latest bets of the session competitorBetsLastN counts in
+	CompetitorBetsWindow = 10
+	// ActivityWindow is the period distinctActiveCompetitorsLast5m and priceDropRatePerMinute are calculated for
+	ActivityWindow = 5 * time.Minute
+)
+
+// SetBetHistory calculates the params depending on the bets made in the session so far:
+//
+//	myCurrentBetNumber               bets of the user
+//	timeSinceLastMyBet               time since the last bet of the user, NoBetYet without bets
+//	participantsCount                providers who bet
+//	myLastBetPrice                   price of the last bet of the user, 0 without bets
+//	lastCompetitorId                 author of the last bet of the others, 0 without bets
+//	competitorBetsLastN              bets of the others among the last CompetitorBetsWindow bets
+//	distinctActiveCompetitorsLast5m  others who bet during the ActivityWindow
+//	averageCompetitorResponseTime    average time the others take to outbid the user
+//	priceDropRatePerMinute           price drop during the ActivityWindow (or since the start) per minute
+//
+// The bets are kept in the state for the aggregates.
+func (s *CurrentSessionState) SetBetHistory(session QuotationSession, bets []*Bet, now time.Time) {
+	bets = append([]*Bet(nil), bets...)
+	sort.SliceStable(bets, func(i, j int) bool {
+		return bets[i].BetNumber < bets[j].BetNumber
+	})
+	s.history = bets
+	s.now = now
+
+	s.MyCurrentBetNumber = 0
+	s.TimeSinceLastMyBet = NoBetYet
+	s.MyLastBetPrice = 0
+	s.LastCompetitorId = 0
+	s.CompetitorBetsLastN = 0
+	var responses []time.Duration
+	windowStart := now.Add(-ActivityWindow)
+	active := map[int64]bool{}
+	participants := map[int64]bool{}
+	priceAtWindowStart := session.StartPrice
+	for i, b := range bets {
+		participants[b.ProviderId] = true
+		mine := b.ProviderId == s.UserId
+		if mine {
+			s.MyCurrentBetNumber++
+			s.TimeSinceLastMyBet = now.Sub(b.Time)
+			s.MyLastBetPrice = b.NewPrice
+			if i+1 < len(bets) {
+				responses = append(responses, bets[i+1].Time.Sub(b.Time))
+			}
+		} else {
+			s.LastCompetitorId = b.ProviderId
+			if len(bets)-i <= CompetitorBetsWindow {
+				s.CompetitorBetsLastN++
+			}
+		}
+		if b.Time.After(windowStart) {
+			if !mine {
+				active[b.ProviderId] = true
+			}
+		} else {
+			priceAtWindowStart = b.NewPrice
+		}
+	}
+	s.ParticipantsCount = int64(len(participants))
+	s.DistinctActiveCompetitorsLast5m = int64(len(active))
+
+	s.AverageCompetitorResponseTime = 0
+	if len(responses) > 0 {
+		var total time.Duration
+		for _, r := range responses {
+			total += r
+		}
+		s.AverageCompetitorResponseTime = total / time.Duration(len(responses))
+	}
+
+	s.PriceDropRatePerMinute = 0
+	window := ActivityWindow
+	if since := now.Sub(session.StartTime); since < window {
+		window = since
+	}
+	if window > 0 {
+		s.PriceDropRatePerMinute = (priceAtWindowStart - session.CurrentPrice) / window.Minutes()
+	}
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetBetHistoryCounts(t *testing.T) {
+	state := aggregateState()
+	if state.MyCurrentBetNumber != 2 || state.TimeSinceLastMyBet != 2*time.Minute || state.ParticipantsCount != 3 {
+		t.Errorf("my bets = %d, time since my last bet = %s, participants = %d, want 2, 2m0s, 3",
+			state.MyCurrentBetNumber, state.TimeSinceLastMyBet, state.ParticipantsCount)
+	}
+
+	// the state is recalculated from the bets of each tick
+	state.UserId = 4
+	state.SetBetHistory(QuotationSession{StartPrice: 1000, CurrentPrice: 500}, state.history, state.now)
+	if state.MyCurrentBetNumber != 0 || state.TimeSinceLastMyBet != NoBetYet || state.ParticipantsCount != 3 {
+		t.Errorf("without bets: my bets = %d, time since my last bet = %s, participants = %d, want 0, %s, 3",
+			state.MyCurrentBetNumber, state.TimeSinceLastMyBet, state.ParticipantsCount, NoBetYet)
+	}
+}
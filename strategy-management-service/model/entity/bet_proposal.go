@@ -0,0 +1,52 @@
+package entity
+
+import "time"
+
+type ProposalStatus string
+
+const (
+	// The proposal waits for the owner of the runner
+	ProposalPending = "PENDING"
+	// The owner has approved the proposal and the bet is made
+	ProposalApproved = "APPROVED"
+	ProposalRejected = "REJECTED"
+	// The proposal was not decided in time, or the session has moved on since it was made
+	ProposalExpired = "EXPIRED"
+	// The owner has approved the proposal but the bet failed
+	ProposalFailed = "FAILED"
+)
+
+// BetProposal is a bet of a semi-automatic runner, it is made only when the owner approves it before ExpiresAt
+type BetProposal struct {
+	ID                 int64 `pg:"id,pk" json:"id"`
+	QuotationSessionId int64 `pg:"quotation_session_id" json:"quotation_session_id"`
+	UserId             int64 `pg:"user_id" json:"user_id"`
+	AccountId          int64 `pg:"account_id" json:"-"`
+	RunnerId           int64 `pg:"runner_id" json:"runner_id"`
+	Tick               int64 `pg:"tick,use_zero" json:"tick"`
+	// Number of the last bet of the session when the proposal was made, the proposal is stale after a newer one
+	Step         int64          `pg:"step,use_zero" json:"step"`
+	CurrentPrice float64        `pg:"current_price,use_zero" json:"current_price"`
+	NewPrice     float64        `pg:"new_price,use_zero" json:"new_price"`
+	Status       ProposalStatus `pg:"status" json:"status"`
+	CreatedAt    time.Time      `pg:"created_at" json:"created_at"`
+	ExpiresAt    time.Time      `pg:"expires_at" json:"expires_at"`
+	DecidedAt    time.Time      `pg:"decided_at" json:"decided_at,omitempty"`
+	// Why the proposal expired or the bet failed
+	Message string `pg:"message" json:"message,omitempty"`
+}
+
+type BetProposalFilter struct {
+	// Only proposals of the runners launched by the account, any account when 0
+	AccountId          int64
+	QuotationSessionId int64
+	// Only proposals with the status, any status when empty
+	Status ProposalStatus
+	Limit  int
+	Offset int
+}
+
+// ProposalDecision is the answer of the owner to a bet proposal, APPROVED or REJECTED
+type ProposalDecision struct {
+	Status ProposalStatus `json:"status"`
+}
@@ -0,0 +1,47 @@
+package entity
+
+import "time"
+
+type blockReason string
+
+const (
+	// The bet would push the price below the minimal price of the runner
+	BlockPriceFloor = "PRICE_FLOOR"
+	// The runner has made the maximum number of bets
+	BlockMaxBets = "MAX_BETS"
+	// The runners of the organisation have made the maximum number of bets today
+	BlockOrgBetsPerDay = "ORG_BETS_PER_DAY"
+	// The bet would make the total discount of the sessions the organisation leads exceed its limit
+	BlockOrgDiscount = "ORG_DISCOUNT"
+	// The portfolio of the runner has withdrawn from the session
+	BlockPortfolioWithdrawn = "PORTFOLIO_WITHDRAWN"
+)
+
+// BlockedAction is an action of a runner refused by the bet guard
+type BlockedAction struct {
+	ID                 int64 `pg:"id,pk" json:"id"`
+	QuotationSessionId int64 `pg:"quotation_session_id" json:"quotation_session_id"`
+	UserId             int64 `pg:"user_id" json:"user_id"`
+	AccountId          int64 `pg:"account_id" json:"-"`
+	RunnerId           int64 `pg:"runner_id" json:"runner_id"`
+	// Tick of the runner, -1 for the initial bet
+	Tick    int64       `pg:"tick,use_zero" json:"tick"`
+	Time    time.Time   `pg:"time" json:"time"`
+	Action  Action      `pg:"action" json:"action"`
+	Reason  blockReason `pg:"reason" json:"reason"`
+	Message string      `pg:"message" json:"message"`
+	// Price the bet would have made
+	Price        float64 `pg:"price,use_zero" json:"price"`
+	MinimalPrice float64 `pg:"minimal_price,use_zero" json:"minimal_price"`
+	BetsMade     int64   `pg:"bets_made,use_zero" json:"bets_made"`
+	MaxBets      int64   `pg:"max_bets,use_zero" json:"max_bets"`
+}
+
+type BlockedActionFilter struct {
+	QuotationSessionId int64
+	UserId             int64
+	// Only actions of the runners launched by the account, any account when 0
+	AccountId int64
+	Limit     int
+	Offset    int
+}
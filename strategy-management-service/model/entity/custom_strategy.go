@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// CustomStrategy is a strategy created by a user
+type CustomStrategy struct {
+	ID        int64     `pg:"id,pk" json:"id"`
+	Name      string    `pg:"name" json:"name"`
+	OwnerId   int64     `pg:"owner_id" json:"owner_id"`
+	Source    string    `pg:"source" json:"source,omitempty"`
+	Strategy  Strategy  `pg:"strategy,type:jsonb" json:"strategy"`
+	CreatedAt time.Time `pg:"created_at" json:"created_at"`
+	UpdatedAt time.Time `pg:"updated_at" json:"updated_at"`
+}
+
+// CustomStrategyData example
+// Either the rule language source or the strategy tree should be provided
+type CustomStrategyData struct {
+	Name     string    `json:"name" example:"Careful"`
+	Source   string    `json:"source,omitempty" example:"IF currentPrice > preferablePrice THEN BET"`
+	Strategy *Strategy `json:"strategy,omitempty"`
+}
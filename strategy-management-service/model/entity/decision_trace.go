@@ -0,0 +1,53 @@
+package entity
+
+import "time"
+
+// DecisionTrace records a single tick of a runner: the state the decision
+// was made on, the branches evaluated and the action performed
+type DecisionTrace struct {
+	ID                 int64               `pg:"id,pk" json:"id"`
+	QuotationSessionId int64               `pg:"quotation_session_id" json:"quotation_session_id"`
+	UserId             int64               `pg:"user_id" json:"user_id"`
+	AccountId          int64               `pg:"account_id" json:"-"`
+	Tick               int64               `pg:"tick,use_zero" json:"tick"`
+	Time               time.Time           `pg:"time" json:"time"`
+	State              CurrentSessionState `pg:"state,type:jsonb" json:"state"`
+	Branches           []BranchTrace       `pg:"branches,type:jsonb" json:"branches"`
+	Action             Action              `pg:"action" json:"action"`
+	// Phase the action was chosen in and the transition taken to it on this tick, phased strategies only
+	Phase      string           `pg:"phase" json:"phase,omitempty"`
+	Transition *TransitionTrace `pg:"transition,type:jsonb" json:"transition,omitempty"`
+	// Error of the state recalculation or of the action
+	Error string `pg:"error" json:"error,omitempty"`
+}
+
+// BranchTrace is an evaluated ConditionSet of the Else chain
+type BranchTrace struct {
+	Path       string           `json:"path"`
+	Action     Action           `json:"action"`
+	Result     bool             `json:"result"`
+	Conditions []ConditionTrace `json:"conditions"`
+}
+
+// ConditionTrace keeps the values a Condition was evaluated with,
+// formatted so durations stay readable
+type ConditionTrace struct {
+	Path     string    `json:"path"`
+	Modifier modifier  `json:"modifier,omitempty"`
+	Left     string    `json:"left"`
+	Op       operation `json:"op"`
+	Right    string    `json:"right,omitempty"`
+	// Values of the operands, empty when the operand cannot be calculated
+	LeftValue  string `json:"left_value"`
+	RightValue string `json:"right_value,omitempty"`
+	Result     bool   `json:"result"`
+}
+
+type DecisionTraceFilter struct {
+	QuotationSessionId int64
+	UserId             int64
+	// Only traces of the runners launched by the account, any account when 0
+	AccountId int64
+	Limit     int
+	Offset    int
+}
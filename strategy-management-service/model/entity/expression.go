@@ -0,0 +1,279 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+//FUNCTIONS
+// +, -, *, /, min, max
+type function string
+
+const (
+	FnAdd function = "+"
+	FnSub function = "-"
+	FnMul function = "*"
+	FnDiv function = "/"
+	FnMin function = "min"
+	FnMax function = "max"
+)
+
+var (
+	errUnknownParam = errors.New("unknown param")
+	errUnboundVar   = errors.New("unbound var")
+)
+
+var knownFunctions = []function{FnAdd, FnSub, FnMul, FnDiv, FnMin, FnMax}
+
+// LookupFunction finds a function by its symbol or name
+func LookupFunction(name string) (function, bool) {
+	for _, fn := range knownFunctions {
+		if string(fn) == name {
+			return fn, true
+		}
+	}
+	return "", false
+}
+
+// NewFunctionExpression builds an expression applying fn to the arguments
+func NewFunctionExpression(fn function, args ...Expression) Expression {
+	return Expression{
+		IsCalculable: true,
+		Fn:           fn,
+		Args:         args,
+	}
+}
+
+func (e Expression) calculateFunction(state CurrentSessionState) interface{} {
+	if len(e.Args) == 0 {
+		return nil
+	}
+	res := e.Args[0].Calculate(state)
+	for _, arg := range e.Args[1:] {
+		var err error
+		res, err = applyFunction(e.Fn, res, arg.Calculate(state))
+		if err != nil {
+			return nil
+		}
+	}
+	return res
+}
+
+// ValueType returns the type the expression is calculated to
+// without calculating it, so the expression can be checked in advance
+func (e Expression) ValueType() (reflect.Type, error) {
+	sample, err := e.sample()
+	if err != nil {
+		return nil, err
+	}
+	return reflect.TypeOf(sample), nil
+}
+
+// sample returns a non-zero value of the expression type
+func (e Expression) sample() (interface{}, error) {
+	switch {
+	case e.IsEmpty:
+		return nil, nil
+	case e.IsConst:
+		return sampleOf(e.Value), nil
+	case e.IsParam:
+		v := getValueByParamName(e.PName, CurrentSessionState{})
+		if v == nil {
+			return nil, fmt.Errorf("%w %q", errUnknownParam, e.PName)
+		}
+		return sampleOf(v), nil
+	case e.IsVar:
+		return nil, fmt.Errorf("%w %q", errUnboundVar, e.VName)
+	case e.IsCalculable:
+		if len(e.Args) == 0 {
+			return nil, fmt.Errorf("function %s has no arguments", e.Fn)
+		}
+		res, err := e.Args[0].sample()
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range e.Args[1:] {
+			v, err := arg.sample()
+			if err != nil {
+				return nil, err
+			}
+			res, err = applyFunction(e.Fn, res, v)
+			if err != nil {
+				return nil, err
+			}
+			res = sampleOf(res)
+		}
+		return res, nil
+	case e.IsAggregate:
+		if e.Agg == nil {
+			return nil, fmt.Errorf("%w: aggregate is missing", errInvalidAggregate)
+		}
+		return e.Agg.sample()
+	}
+	return nil, nil
+}
+
+func sampleOf(v interface{}) interface{} {
+	switch v.(type) {
+	case int, int64:
+		return int64(1)
+	case float64:
+		return float64(1)
+	case time.Duration:
+		return time.Second
+	}
+	return v
+}
+
+// applyFunction calculates fn(a, b) promoting the operand types:
+//
+//	int64 and int64         -> int64, but / gives float64
+//	int64 and float64       -> float64
+//	duration +- duration    -> duration
+//	duration */ number      -> duration, number * duration -> duration
+//	duration / duration     -> float64
+//	min, max                -> the promoted type of the operands
+//
+// Other combinations, division by zero and flags are errors.
+func applyFunction(fn function, a, b interface{}) (interface{}, error) {
+	if v, ok := a.(int); ok {
+		a = int64(v)
+	}
+	if v, ok := b.(int); ok {
+		b = int64(v)
+	}
+	da, aIsDuration := a.(time.Duration)
+	db, bIsDuration := b.(time.Duration)
+	switch {
+	case aIsDuration && bIsDuration:
+		switch fn {
+		case FnAdd:
+			return da + db, nil
+		case FnSub:
+			return da - db, nil
+		case FnDiv:
+			if db == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return float64(da) / float64(db), nil
+		case FnMin:
+			if da < db {
+				return da, nil
+			}
+			return db, nil
+		case FnMax:
+			if da > db {
+				return da, nil
+			}
+			return db, nil
+		}
+	case aIsDuration:
+		f, ok := toFloat64(b)
+		if !ok {
+			break
+		}
+		switch fn {
+		case FnMul:
+			return time.Duration(float64(da) * f), nil
+		case FnDiv:
+			if f == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return time.Duration(float64(da) / f), nil
+		}
+	case bIsDuration:
+		f, ok := toFloat64(a)
+		if ok && fn == FnMul {
+			return time.Duration(f * float64(db)), nil
+		}
+	default:
+		ia, aIsInt := a.(int64)
+		ib, bIsInt := b.(int64)
+		if aIsInt && bIsInt && fn != FnDiv {
+			return applyInt64(fn, ia, ib)
+		}
+		fa, aOk := toFloat64(a)
+		fb, bOk := toFloat64(b)
+		if aOk && bOk {
+			return applyFloat64(fn, fa, fb)
+		}
+	}
+	return nil, fmt.Errorf("cannot apply %s to %s and %s", fn, typeDescription(a), typeDescription(b))
+}
+
+func applyInt64(fn function, a, b int64) (interface{}, error) {
+	switch fn {
+	case FnAdd:
+		return a + b, nil
+	case FnSub:
+		return a - b, nil
+	case FnMul:
+		return a * b, nil
+	case FnMin:
+		if a < b {
+			return a, nil
+		}
+		return b, nil
+	case FnMax:
+		if a > b {
+			return a, nil
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown function %q", fn)
+}
+
+func applyFloat64(fn function, a, b float64) (interface{}, error) {
+	switch fn {
+	case FnAdd:
+		return a + b, nil
+	case FnSub:
+		return a - b, nil
+	case FnMul:
+		return a * b, nil
+	case FnDiv:
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	case FnMin:
+		if a < b {
+			return a, nil
+		}
+		return b, nil
+	case FnMax:
+		if a > b {
+			return a, nil
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown function %q", fn)
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func typeDescription(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "flag"
+	case int, int64:
+		return "integer"
+	case float64:
+		return "number"
+	case time.Duration:
+		return "duration"
+	case nil:
+		return "nothing"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,17 @@
+package entity
+
+// OrgRiskLimits limit the runners launched by the accounts of an organisation (profile), 0 is no limit
+type OrgRiskLimits struct {
+	ProfileId int64 `pg:"profile_id,pk" json:"profile_id"`
+	// Runners running or paused at once
+	MaxRunners int `pg:"max_runners,use_zero" json:"max_runners"`
+	// Bot bets of the runners since the start of the day, UTC
+	MaxBetsPerDay int64 `pg:"max_bets_per_day,use_zero" json:"max_bets_per_day"`
+	// Sum of the discounts (start price - current price) of the active sessions the runners lead
+	MaxTotalDiscount float64 `pg:"max_total_discount,use_zero" json:"max_total_discount"`
+}
+
+// Valid reports whether the limits are not negative
+func (l OrgRiskLimits) Valid() bool {
+	return l.MaxRunners >= 0 && l.MaxBetsPerDay >= 0 && l.MaxTotalDiscount >= 0
+}
@@ -0,0 +1,201 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type portfolioSessionState string
+
+const (
+	// The provider made the last bet of the session, the session takes capacity until it is outbid
+	PortfolioLeading = "LEADING"
+	// The runner of the session bets, the capacity a win would take is reserved
+	PortfolioBidding = "BIDDING"
+	// The runner does not bet: the capacity is taken by the sessions of higher margin or the margin is gone
+	PortfolioWithdrawn = "WITHDRAWN"
+	// The session is over or its runner is stopped
+	PortfolioEnded = "ENDED"
+)
+
+var ErrInvalidPortfolio = errors.New("invalid portfolio")
+
+// CategoryLimit limits the sessions with products of the category the provider may win
+type CategoryLimit struct {
+	CategoryId int64 `json:"category_id"`
+	MaxWins    int   `json:"max_wins"`
+}
+
+// PortfolioParams launch a runner in each of the sessions, the runners share the capacity of the provider
+type PortfolioParams struct {
+	UserId int64 `json:"user_id"`
+	// Run requests of the sessions, the user of the portfolio bets in all of them
+	Sessions []StrategyParams `json:"sessions"`
+	// Max number of product units of the sessions the provider leads or bids in, unlimited when 0
+	MaxVolume int64 `json:"max_volume,omitempty"`
+	// Max number of sessions per category the provider leads or bids in
+	CategoryLimits []CategoryLimit `json:"category_limits,omitempty"`
+	// Account which launches the portfolio, taken from the access token
+	AccountId int64 `json:"-"`
+}
+
+// Validate reports params the portfolio cannot be launched with
+func (p PortfolioParams) Validate() error {
+	if len(p.Sessions) == 0 {
+		return fmt.Errorf("%w: no sessions", ErrInvalidPortfolio)
+	}
+	if p.MaxVolume < 0 {
+		return fmt.Errorf("%w: max_volume cannot be negative", ErrInvalidPortfolio)
+	}
+	seen := map[int64]bool{}
+	for _, session := range p.Sessions {
+		if seen[session.QuotationSessionId] {
+			return fmt.Errorf("%w: session %d is listed twice", ErrInvalidPortfolio, session.QuotationSessionId)
+		}
+		seen[session.QuotationSessionId] = true
+		if session.StartAt != nil || session.StartWhen != "" {
+			return fmt.Errorf("%w: the runners of a portfolio start at once, session %d is scheduled",
+				ErrInvalidPortfolio, session.QuotationSessionId)
+		}
+		// the margins are relative to the minimal price
+		if session.MinimalPrice <= 0 {
+			return fmt.Errorf("%w: minimal_price of session %d has to be positive", ErrInvalidPortfolio,
+				session.QuotationSessionId)
+		}
+	}
+	for _, limit := range p.CategoryLimits {
+		if limit.MaxWins < 0 {
+			return fmt.Errorf("%w: max_wins of category %d cannot be negative", ErrInvalidPortfolio,
+				limit.CategoryId)
+		}
+	}
+	return nil
+}
+
+// Portfolio is the stored state of a portfolio runner, its sessions have runners of their own
+type Portfolio struct {
+	ID        int64           `pg:"id,pk" json:"id"`
+	UserId    int64           `pg:"user_id" json:"user_id"`
+	AccountId int64           `pg:"account_id" json:"account_id"`
+	Params    PortfolioParams `pg:"params,type:jsonb" json:"params"`
+	Status    RunnerStatus    `pg:"status" json:"status"`
+	StartedAt time.Time       `pg:"started_at" json:"started_at"`
+	StoppedAt time.Time       `pg:"stopped_at" json:"stopped_at"`
+}
+
+// PortfolioSession is a session of a portfolio as seen by the allocation
+type PortfolioSession struct {
+	QuotationSessionId int64                 `json:"quotation_session_id"`
+	RunnerId           int64                 `json:"runner_id,omitempty"`
+	State              portfolioSessionState `json:"state"`
+	CurrentPrice       float64               `json:"current_price"`
+	MinimalPrice       float64               `json:"minimal_price"`
+	// Margin the provider expects winning at the price of its next bet, of the current one when it leads
+	Margin float64 `json:"margin"`
+	// Product units of the session and the categories of the products
+	Volume     int64   `json:"volume"`
+	Categories []int64 `json:"categories"`
+}
+
+// NewPortfolioSession describes the session for the allocation, the state is set
+// to LEADING or ENDED when it is known from the session only
+func NewPortfolioSession(session QuotationSession, userId int64, minimalPrice float64) PortfolioSession {
+	res := PortfolioSession{
+		QuotationSessionId: session.ID,
+		CurrentPrice:       session.CurrentPrice,
+		MinimalPrice:       minimalPrice,
+	}
+	seen := map[int64]bool{}
+	for _, p := range session.Products {
+		res.Volume += int64(p.Count)
+		if p.Product != nil && !seen[p.Product.CategoryId] {
+			seen[p.Product.CategoryId] = true
+			res.Categories = append(res.Categories, p.Product.CategoryId)
+		}
+	}
+	price := session.CurrentPrice
+	leading := session.LastBet != nil && session.LastBet.ProviderId == userId
+	if !leading {
+		price -= session.StepSize()
+	}
+	if minimalPrice > 0 {
+		res.Margin = price/minimalPrice - 1
+	}
+	switch {
+	case session.Status != StatusActive:
+		res.State = PortfolioEnded
+	case leading:
+		res.State = PortfolioLeading
+	}
+	return res
+}
+
+// PortfolioUsage is the capacity taken by the sessions the provider leads or bids in
+type PortfolioUsage struct {
+	Volume int64 `json:"volume"`
+	// Sessions by category
+	Wins map[int64]int `json:"wins,omitempty"`
+}
+
+// Allocate sets the states of the active sessions. The sessions the provider leads take the capacity first,
+// they cannot be withdrawn from. The others bid in the order of their margins while their wins fit the capacity
+// left, the rest and the sessions without margin are withdrawn
+func (p PortfolioParams) Allocate(sessions []PortfolioSession) ([]PortfolioSession, PortfolioUsage) {
+	res := make([]PortfolioSession, len(sessions))
+	copy(res, sessions)
+	usage := PortfolioUsage{Wins: map[int64]int{}}
+	for _, s := range res {
+		if s.State == PortfolioLeading {
+			usage.take(s)
+		}
+	}
+	order := make([]int, 0, len(res))
+	for i, s := range res {
+		if s.State != PortfolioLeading && s.State != PortfolioEnded {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return res[order[a]].Margin > res[order[b]].Margin
+	})
+	for _, i := range order {
+		if res[i].Margin < 0 || !p.fits(usage, res[i]) {
+			res[i].State = PortfolioWithdrawn
+			continue
+		}
+		res[i].State = PortfolioBidding
+		usage.take(res[i])
+	}
+	return res, usage
+}
+
+// fits reports whether winning the session stays within the limits
+func (p PortfolioParams) fits(usage PortfolioUsage, session PortfolioSession) bool {
+	if p.MaxVolume > 0 && usage.Volume+session.Volume > p.MaxVolume {
+		return false
+	}
+	for _, limit := range p.CategoryLimits {
+		for _, category := range session.Categories {
+			if category == limit.CategoryId && usage.Wins[category] >= limit.MaxWins {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (u *PortfolioUsage) take(session PortfolioSession) {
+	u.Volume += session.Volume
+	for _, category := range session.Categories {
+		u.Wins[category]++
+	}
+}
+
+// PortfolioInfo describes a portfolio runner with the last allocation of its sessions
+type PortfolioInfo struct {
+	Portfolio
+	Sessions []PortfolioSession `json:"sessions"`
+	Usage    PortfolioUsage     `json:"usage"`
+}
@@ -10,6 +10,10 @@ const (
 	StatusDidntTakePlace = "DIDNT_TAKE_PLACE"
 )
 
+// AdditionalPurchaseDuration is the window the tender service prolongs a session by
+// for a bet made during its last minutes
+const AdditionalPurchaseDuration = 5 * time.Minute
+
 type QuotationSession struct {
 	ID        int64         `pg:"id,pk" json:"id"`
 	Name      string        `pg:"name" json:"name"`
@@ -36,3 +40,26 @@ type QuotationSessionData struct {
 	SessionStepPercent float64               `pg:"session_step_percent" json:"session_step_percent"`
 	Products           []*ProductJournalData `json:"products"`
 }
+
+type QuotationSessionShort struct {
+	SessionId int64         `json:"session_id"`
+	Status    SessionStatus `json:"status"`
+}
+
+// EndTime returns the time the session ends, including the additional purchase
+func (s QuotationSession) EndTime() time.Time {
+	return s.StartTime.Add(time.Duration(s.SessionDuration) * time.Minute)
+}
+
+// CurrentStep returns the number of the last bet of the session, -1 before the first bet
+func (s QuotationSession) CurrentStep() int64 {
+	if s.LastBet == nil {
+		return -1
+	}
+	return int64(s.LastBet.BetNumber)
+}
+
+// StepSize returns the price decrease made by each bet
+func (s QuotationSession) StepSize() float64 {
+	return s.StartPrice * (s.SessionStepPercent / 100)
+}
@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"regexp"
+	"time"
+)
+
+// RunnerNotification is an alert a runner sent to the account which launched it with NOTIFY
+type RunnerNotification struct {
+	ID                 int64     `pg:"id,pk" json:"id"`
+	QuotationSessionId int64     `pg:"quotation_session_id" json:"quotation_session_id"`
+	UserId             int64     `pg:"user_id" json:"user_id"`
+	AccountId          int64     `pg:"account_id" json:"-"`
+	RunnerId           int64     `pg:"runner_id" json:"runner_id"`
+	Tick               int64     `pg:"tick,use_zero" json:"tick"`
+	Time               time.Time `pg:"time" json:"time"`
+	Message            string    `pg:"message" json:"message"`
+}
+
+type RunnerNotificationFilter struct {
+	// Only notifications of the runners launched by the account, any account when 0
+	AccountId int64
+	// Only notifications of the session, any session when 0
+	QuotationSessionId int64
+	Limit              int
+	Offset             int
+}
+
+var messageParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// RenderMessage replaces the {param} placeholders of a NOTIFY message with the values of the params,
+// e.g. "outbid at {currentPrice}", unknown placeholders are left as they are
+func RenderMessage(template string, state CurrentSessionState) string {
+	return messageParam.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name, ok := LookupParam(placeholder[1 : len(placeholder)-1])
+		if !ok {
+			return placeholder
+		}
+		return formatValue(getValueByParamName(name, state))
+	})
+}
+
+// unknownMessageParams returns the placeholders of the message which are not params
+func unknownMessageParams(template string) []string {
+	var res []string
+	for _, m := range messageParam.FindAllStringSubmatch(template, -1) {
+		if _, ok := LookupParam(m[1]); !ok {
+			res = append(res, m[1])
+		}
+	}
+	return res
+}
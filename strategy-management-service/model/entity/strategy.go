@@ -1,18 +1,25 @@
 package entity
 
 import (
-	"reflect"
+	"strings"
 	"time"
 )
 
 //ACTIONS
-// Wait next step, Make a bet
+// Wait next step, Make a bet, Make a bet after a delay, Stop the runner, Notify the owner
 type Action string
 
 const (
+	// ActionWaitNextStep skips the ticks until a new bet is made in the session
 	ActionWaitNextStep Action = "WAIT_NEXT_STEP"
 	ActionWaitTime     Action = "WAIT_TIME"
 	ActionBet          Action = "BET"
+	// ActionBetAfter makes a bet ConditionSet.Delay seconds into the tick
+	ActionBetAfter Action = "BET_AFTER"
+	// ActionStop ends the runner
+	ActionStop Action = "STOP"
+	// ActionNotify sends ConditionSet.Message to the account which launched the runner
+	ActionNotify Action = "NOTIFY"
 )
 
 //OPERATION
@@ -29,6 +36,52 @@ const (
 	OpNone        operation = "NONE"
 )
 
+var knownOperations = []operation{OpGreater, OpLess, OpNotEq, OpLessOrEq, OpGreaterOrEq, OpEquals}
+
+// LookupOperation finds a comparison operation by its symbol
+func LookupOperation(symbol string) (operation, bool) {
+	for _, op := range knownOperations {
+		if string(op) == symbol {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// Mirror returns the operation to use when the operands are swapped
+func (o operation) Mirror() operation {
+	switch o {
+	case OpGreater:
+		return OpLess
+	case OpLess:
+		return OpGreater
+	case OpGreaterOrEq:
+		return OpLessOrEq
+	case OpLessOrEq:
+		return OpGreaterOrEq
+	}
+	return o
+}
+
+// Negate returns the operation with the opposite result
+func (o operation) Negate() operation {
+	switch o {
+	case OpGreater:
+		return OpLessOrEq
+	case OpLess:
+		return OpGreaterOrEq
+	case OpGreaterOrEq:
+		return OpLess
+	case OpLessOrEq:
+		return OpGreater
+	case OpEquals:
+		return OpNotEq
+	case OpNotEq:
+		return OpEquals
+	}
+	return o
+}
+
 //CONDITIONS
 // MODIFIER (param @OPERATION@ val )
 // AND/OR
@@ -51,12 +104,17 @@ const (
 )
 
 //REPEATER
+// ForEachNSeconds
 // ForEachStep
 // ForEachNStep
 type repeater string
 
 const (
-	RepeaterFES  = "ForEachStep"
+	// RepeaterFENSec evaluates the strategy every N seconds, the default one
+	RepeaterFENSec = "ForEachNSeconds"
+	// RepeaterFES evaluates the strategy once per new bet in the session
+	RepeaterFES = "ForEachStep"
+	// RepeaterFENS evaluates the strategy once every N new bets in the session
 	RepeaterFENS = "ForEachNStep"
 )
 
@@ -77,6 +135,12 @@ const (
 // acceptablePrice
 // preferablePrice
 // participantsCount
+// myLastBetPrice
+// lastCompetitorId
+// competitorBetsLastN
+// distinctActiveCompetitorsLast5m
+// averageCompetitorResponseTime
+// priceDropRatePerMinute
 
 type paramName string
 
@@ -98,8 +162,18 @@ const (
 	ParamMinimalPrice           = "minimalPrice"
 	ParamAcceptablePrice        = "acceptablePrice"
 	ParamPreferablePrice        = "preferablePrice"
+
+	ParamMyLastBetPrice                  = "myLastBetPrice"
+	ParamLastCompetitorId                = "lastCompetitorId"
+	ParamCompetitorBetsLastN             = "competitorBetsLastN"
+	ParamDistinctActiveCompetitorsLast5m = "distinctActiveCompetitorsLast5m"
+	ParamAverageCompetitorResponseTime   = "averageCompetitorResponseTime"
+	ParamPriceDropRatePerMinute          = "priceDropRatePerMinute"
 )
 
+// NoBetYet is the TimeSinceLastMyBet of a user who hasn't bet in the session
+const NoBetYet time.Duration = -1
+
 type CurrentSessionState struct {
 	// PARAMS
 	// isOnAdditionalPurchase
@@ -118,36 +192,65 @@ type CurrentSessionState struct {
 	// acceptablePrice
 	// preferablePrice
 	// participantsCount
-	IsOnAdditionalPurchase bool
-	CurrentWinnerId        int64
-	UserId                 int64
-	MyCurrentBetNumber     int64
-	CurrentStepNumber      int64
-	StepsTillZero          int64
-	CurrentPrice           float64
-	CurrentDiscount        float64
-	TimeSinceLastStep      time.Duration
-	TimeSinceLastMyBet     time.Duration
-	StepSize               float64
-	TimeSinceStart         time.Duration
-	TimeTillEnd            time.Duration
-	ParticipantsCount      int64
-
-	MinimalPrice    float64
-	AcceptablePrice float64
-	PreferablePrice float64
+	// myLastBetPrice
+	// lastCompetitorId
+	// competitorBetsLastN
+	// distinctActiveCompetitorsLast5m
+	// averageCompetitorResponseTime
+	// priceDropRatePerMinute
+	IsOnAdditionalPurchase bool          `json:"is_on_additional_purchase"`
+	CurrentWinnerId        int64         `json:"current_winner_id"`
+	UserId                 int64         `json:"user_id"`
+	MyCurrentBetNumber     int64         `json:"my_current_bet_number"`
+	CurrentStepNumber      int64         `json:"current_step_number"`
+	StepsTillZero          int64         `json:"steps_till_zero"`
+	CurrentPrice           float64       `json:"current_price"`
+	CurrentDiscount        float64       `json:"current_discount"`
+	TimeSinceLastStep      time.Duration `json:"time_since_last_step"`
+	TimeSinceLastMyBet     time.Duration `json:"time_since_last_my_bet"`
+	StepSize               float64       `json:"step_size"`
+	TimeSinceStart         time.Duration `json:"time_since_start"`
+	TimeTillEnd            time.Duration `json:"time_till_end"`
+	ParticipantsCount      int64         `json:"participants_count"`
+
+	MinimalPrice    float64 `json:"minimal_price"`
+	AcceptablePrice float64 `json:"acceptable_price"`
+	PreferablePrice float64 `json:"preferable_price"`
+
+	// Params calculated from the bets of the session, see SetBetHistory
+	MyLastBetPrice                  float64       `json:"my_last_bet_price"`
+	LastCompetitorId                int64         `json:"last_competitor_id"`
+	CompetitorBetsLastN             int64         `json:"competitor_bets_last_n"`
+	DistinctActiveCompetitorsLast5m int64         `json:"distinct_active_competitors_last_5m"`
+	AverageCompetitorResponseTime   time.Duration `json:"average_competitor_response_time"`
+	PriceDropRatePerMinute          float64       `json:"price_drop_rate_per_minute"`
+
+	// bets of the session ordered by number and the time of the state, aggregates are calculated over them
+	history []*Bet
+	now     time.Time
 }
 
 type Expression struct {
 	IsParam bool
 	PName   paramName
 
+	// Var of the strategy, replaced with its value by Strategy.Bind
+	IsVar bool
+	VName string
+
 	IsConst bool
 	Value   interface{}
 
 	IsEmpty bool
 
-	//IsCalculableExpression
+	// Fn applied to Args, see applyFunction for the type promotion rules
+	IsCalculable bool
+	Fn           function
+	Args         []Expression
+
+	// Aggregate over the bets of the session, see Aggregate
+	IsAggregate bool
+	Agg         *Aggregate
 }
 
 func (e Expression) Calculate(state CurrentSessionState) interface{} {
@@ -156,36 +259,51 @@ func (e Expression) Calculate(state CurrentSessionState) interface{} {
 			return e.Value
 		} else if e.IsParam {
 			return getValueByParamName(e.PName, state)
+		} else if e.IsCalculable {
+			return e.calculateFunction(state)
+		} else if e.IsAggregate && e.Agg != nil {
+			return e.Agg.calculate(state)
 		}
 	}
 	return nil
 }
 
 type Condition struct {
-	M     modifier
-	Param paramName
-	Op    operation
-	Val   Expression
+	M     modifier   `json:"modifier,omitempty"`
+	Param paramName  `json:"param,omitempty"`
+	Op    operation  `json:"op"`
+	Val   Expression `json:"val"`
+	// Left operand, used instead of Param when set
+	Expr *Expression `json:"expr,omitempty"`
+}
+
+func (r Condition) leftValue(state CurrentSessionState) interface{} {
+	if r.Expr != nil {
+		return r.Expr.Calculate(state)
+	}
+	return getValueByParamName(r.Param, state)
 }
 
+// Evaluate applies the NOT modifier to the comparison result.
+// A condition which cannot be evaluated (unknown param, incompatible types)
+// is false regardless of the modifier.
 func (r Condition) Evaluate(state CurrentSessionState) bool {
-	v1 := getValueByParamName(r.Param, state)
-	switch v1.(type) {
-	case bool:
-		return v1.(bool)
+	res, err := r.compare(state)
+	if err != nil {
+		return false
 	}
-	v2 := r.Val.Calculate(state)
-	if reflect.TypeOf(v1) == reflect.TypeOf(v2) {
-		switch v1.(type) {
-		case int64:
-			return r.PerformOperationInt64(v1.(int64), v2.(int64))
-		case float64:
-			return r.PerformOperationFloat64(v1.(float64), v2.(float64))
-		case time.Duration:
-			return r.PerformOperationDuration(v1.(time.Duration), v2.(time.Duration))
-		}
+	if r.M == ModNot {
+		return !res
 	}
-	return false
+	return res
+}
+
+func (r Condition) compare(state CurrentSessionState) (bool, error) {
+	v1 := r.leftValue(state)
+	if flag, ok := v1.(bool); ok && (r.Op == OpNone || r.Val.IsEmpty) {
+		return flag, nil
+	}
+	return compareValues(r.Op, v1, r.Val.Calculate(state))
 }
 
 func (r Condition) PerformOperationInt64(v1 int64, v2 int64) bool {
@@ -277,12 +395,65 @@ func getValueByParamName(val paramName, state CurrentSessionState) interface{} {
 		return state.TimeSinceStart
 	case ParamMyCurrentBetNumber:
 		return state.MyCurrentBetNumber
+	case ParamMyLastBetPrice:
+		return state.MyLastBetPrice
+	case ParamLastCompetitorId:
+		return state.LastCompetitorId
+	case ParamCompetitorBetsLastN:
+		return state.CompetitorBetsLastN
+	case ParamDistinctActiveCompetitorsLast5m:
+		return state.DistinctActiveCompetitorsLast5m
+	case ParamAverageCompetitorResponseTime:
+		return state.AverageCompetitorResponseTime
+	case ParamPriceDropRatePerMinute:
+		return state.PriceDropRatePerMinute
 
 	default:
 		return nil
 	}
 }
 
+var knownParams = []paramName{
+	ParamIsOnAdditionalPurchase,
+	ParamCurrentWinnerId,
+	ParamMyId,
+	ParamCurrentStepNumber,
+	ParamMyCurrentBetNumber,
+	ParamStepsTillZero,
+	ParamCurrentPrice,
+	ParamCurrentDiscount,
+	ParamTimeSinceLastStep,
+	ParamTimeSinceLastMyBet,
+	ParamStepSize,
+	ParamTimeSinceStart,
+	ParamTimeTillEnd,
+	ParamParticipantsCount,
+	ParamMinimalPrice,
+	ParamAcceptablePrice,
+	ParamPreferablePrice,
+	ParamMyLastBetPrice,
+	ParamLastCompetitorId,
+	ParamCompetitorBetsLastN,
+	ParamDistinctActiveCompetitorsLast5m,
+	ParamAverageCompetitorResponseTime,
+	ParamPriceDropRatePerMinute,
+}
+
+// LookupParam finds a known param by its name, ignoring case
+func LookupParam(name string) (paramName, bool) {
+	for _, p := range knownParams {
+		if strings.EqualFold(string(p), name) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// ParamValue returns the value of the param for the given state
+func ParamValue(p paramName, state CurrentSessionState) interface{} {
+	return getValueByParamName(p, state)
+}
+
 //(condition AND condition) AND (condition OR condition)
 //     L |operator| R             	L  |operator| R
 //			L			|operator|         R
@@ -338,9 +509,13 @@ func (o Operator) Evaluate(state CurrentSessionState) bool {
 }
 
 type ConditionSet struct {
-	Op     Operator
-	Action Action
-	Else   *ConditionSet
+	Op     Operator `json:"op"`
+	Action Action   `json:"action"`
+	// Seconds BET_AFTER waits before the bet, less than the granularity of ForEachNSeconds
+	Delay int `json:"delay,omitempty"`
+	// Message template of NOTIFY, see RenderMessage
+	Message string        `json:"message,omitempty"`
+	Else    *ConditionSet `json:"else,omitempty"`
 }
 
 func (c ConditionSet) Define(state CurrentSessionState) Action {
@@ -363,8 +538,13 @@ type Strategy struct {
 	//		ACTION
 	//	Else
 	//	...
-	BaseConditionSet *ConditionSet
-	Vars             map[string]float64
-	R                repeater
-	N                int
+	BaseConditionSet *ConditionSet `json:"condition_set"`
+	// Phases of a phased strategy, used instead of BaseConditionSet, the first one is the initial
+	Phases []Phase            `json:"phases,omitempty"`
+	Vars   map[string]float64 `json:"vars,omitempty"`
+	R      repeater           `json:"repeater,omitempty"`
+	// Seconds between the evaluations, or bets with ForEachNStep
+	N int `json:"granularity"`
+	// Random offsets of the ticks and delays of the bets, none when nil
+	Jitter *Jitter `json:"jitter,omitempty"`
 }
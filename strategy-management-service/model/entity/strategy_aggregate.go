@@ -0,0 +1,223 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//AGGREGATES
+// COUNT, SUM, AVG, MIN, MAX
+type aggregateFunction string
+
+const (
+	AggCount aggregateFunction = "COUNT"
+	AggSum   aggregateFunction = "SUM"
+	AggAvg   aggregateFunction = "AVG"
+	AggMin   aggregateFunction = "MIN"
+	AggMax   aggregateFunction = "MAX"
+)
+
+//BET METRICS
+// bets, price, timeBetweenBets
+type betMetric string
+
+const (
+	// MetricBets is counted only
+	MetricBets betMetric = "bets"
+	// MetricPrice is the price after the bet
+	MetricPrice betMetric = "price"
+	// MetricTimeBetweenBets is the time since the previous bet of the session,
+	// the first bet of the session has none
+	MetricTimeBetweenBets betMetric = "timeBetweenBets"
+)
+
+//BET FILTERS
+// mine, others
+type betFilter string
+
+const (
+	FilterMine   betFilter = "mine"
+	FilterOthers betFilter = "others"
+)
+
+var (
+	knownAggregates = []aggregateFunction{AggCount, AggSum, AggAvg, AggMin, AggMax}
+	knownMetrics    = []betMetric{MetricBets, MetricPrice, MetricTimeBetweenBets}
+
+	errInvalidAggregate = errors.New("invalid aggregate")
+)
+
+// LookupAggregate finds an aggregate function by its name, case-insensitive
+func LookupAggregate(name string) (aggregateFunction, bool) {
+	for _, fn := range knownAggregates {
+		if strings.EqualFold(string(fn), name) {
+			return fn, true
+		}
+	}
+	return "", false
+}
+
+// LookupMetric finds a bet metric by its name
+func LookupMetric(name string) (betMetric, bool) {
+	for _, m := range knownMetrics {
+		if string(m) == name {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// Aggregate is calculated over the bets of the session, the bets are taken from
+// the window first and then filtered:
+//
+//	COUNT(bets WHERE providerId != myId, last 3m)  bets of the others during the last 3 minutes
+//	AVG(timeBetweenBets, last 5 steps)             average time between the last 5 bets
+//
+// Without Period and Steps the window is the whole session.
+// AVG, MIN and MAX of no bets have no value, so the comparison is false.
+type Aggregate struct {
+	Fn     aggregateFunction
+	Metric betMetric
+	Filter betFilter
+	// Period keeps the bets made during the last Period
+	Period time.Duration
+	// Steps keeps the last Steps bets of the session
+	Steps int64
+}
+
+// NewAggregateExpression builds an expression calculating the aggregate
+func NewAggregateExpression(agg Aggregate) Expression {
+	return Expression{
+		IsAggregate: true,
+		Agg:         &agg,
+	}
+}
+
+// check reports an aggregate which cannot be calculated
+func (a Aggregate) check() error {
+	if _, ok := LookupAggregate(string(a.Fn)); !ok {
+		return fmt.Errorf("%w: unknown function %q", errInvalidAggregate, a.Fn)
+	}
+	if _, ok := LookupMetric(string(a.Metric)); !ok {
+		return fmt.Errorf("%w: unknown metric %q", errInvalidAggregate, a.Metric)
+	}
+	if (a.Fn == AggCount) != (a.Metric == MetricBets) {
+		return fmt.Errorf("%w: %s can only be counted, %s(%s) is not supported", errInvalidAggregate,
+			MetricBets, a.Fn, a.Metric)
+	}
+	if a.Filter != "" && a.Filter != FilterMine && a.Filter != FilterOthers {
+		return fmt.Errorf("%w: unknown filter %q", errInvalidAggregate, a.Filter)
+	}
+	if a.Period < 0 || a.Steps < 0 || (a.Period > 0 && a.Steps > 0) {
+		return fmt.Errorf("%w: the window is either a positive period or a positive number of steps",
+			errInvalidAggregate)
+	}
+	return nil
+}
+
+// sample returns a value of the aggregate type
+func (a Aggregate) sample() (interface{}, error) {
+	if err := a.check(); err != nil {
+		return nil, err
+	}
+	switch {
+	case a.Fn == AggCount:
+		return int64(1), nil
+	case a.Metric == MetricTimeBetweenBets:
+		return time.Second, nil
+	}
+	return float64(1), nil
+}
+
+func (a Aggregate) calculate(state CurrentSessionState) interface{} {
+	if a.check() != nil {
+		return nil
+	}
+	bets := state.history
+	if a.Steps > 0 && int64(len(bets)) > a.Steps {
+		bets = bets[int64(len(bets))-a.Steps:]
+	}
+	var values []interface{}
+	for i, b := range bets {
+		if a.Period > 0 && !b.Time.After(state.now.Add(-a.Period)) {
+			continue
+		}
+		if (a.Filter == FilterMine && b.ProviderId != state.UserId) ||
+			(a.Filter == FilterOthers && b.ProviderId == state.UserId) {
+			continue
+		}
+		switch a.Metric {
+		case MetricBets:
+			values = append(values, int64(1))
+		case MetricPrice:
+			values = append(values, b.NewPrice)
+		case MetricTimeBetweenBets:
+			prev := previousBet(state.history, bets, i)
+			if prev != nil {
+				values = append(values, b.Time.Sub(prev.Time))
+			}
+		}
+	}
+
+	if a.Fn == AggCount {
+		return int64(len(values))
+	}
+	if len(values) == 0 {
+		switch {
+		case a.Fn != AggSum:
+			return nil
+		case a.Metric == MetricTimeBetweenBets:
+			return time.Duration(0)
+		}
+		return float64(0)
+	}
+	fn := FnAdd
+	switch a.Fn {
+	case AggMin:
+		fn = FnMin
+	case AggMax:
+		fn = FnMax
+	}
+	res := values[0]
+	for _, v := range values[1:] {
+		var err error
+		if res, err = applyFunction(fn, res, v); err != nil {
+			return nil
+		}
+	}
+	if a.Fn == AggAvg {
+		res, _ = applyFunction(FnDiv, res, float64(len(values)))
+	}
+	return res
+}
+
+// previousBet returns the bet of the session made before window[i], window is the tail of history
+func previousBet(history, window []*Bet, i int) *Bet {
+	j := len(history) - len(window) + i - 1
+	if j < 0 {
+		return nil
+	}
+	return history[j]
+}
+
+// String returns the aggregate in the rule language
+func (a Aggregate) String() string {
+	var sb strings.Builder
+	sb.WriteString(string(a.Fn) + "(" + string(a.Metric))
+	switch a.Filter {
+	case FilterMine:
+		sb.WriteString(" WHERE providerId == " + ParamMyId)
+	case FilterOthers:
+		sb.WriteString(" WHERE providerId != " + ParamMyId)
+	}
+	switch {
+	case a.Period > 0:
+		sb.WriteString(", last " + a.Period.String())
+	case a.Steps > 0:
+		sb.WriteString(fmt.Sprintf(", last %d steps", a.Steps))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
@@ -0,0 +1,217 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// aggregateState is the state of user 1 ten minutes into a session with five bets
+func aggregateState() CurrentSessionState {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	bet := func(number int, provider int64, after time.Duration, price float64) *Bet {
+		return &Bet{BetNumber: number, ProviderId: provider, Time: start.Add(after), NewPrice: price}
+	}
+	// the bets are sorted by their numbers
+	bets := []*Bet{
+		bet(3, 2, 7*time.Minute, 600),
+		bet(0, 2, time.Minute, 900),
+		bet(1, 1, 2*time.Minute, 800),
+		bet(2, 3, 4*time.Minute, 700),
+		bet(4, 1, 8*time.Minute, 500),
+	}
+	state := CurrentSessionState{UserId: 1}
+	session := QuotationSession{StartTime: start, StartPrice: 1000, CurrentPrice: 500}
+	state.SetBetHistory(session, bets, start.Add(10*time.Minute))
+	return state
+}
+
+func TestAggregateCalculate(t *testing.T) {
+	tests := []struct {
+		name string
+		agg  Aggregate
+		want interface{}
+	}{
+		{
+			name: "count",
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets},
+			want: int64(5),
+		},
+		{
+			name: "count mine",
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets, Filter: FilterMine},
+			want: int64(2),
+		},
+		{
+			name: "count the others during a period",
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets, Filter: FilterOthers, Period: 4 * time.Minute},
+			want: int64(1),
+		},
+		{
+			name: "period leaves out the bet at its start",
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets, Period: 3 * time.Minute},
+			want: int64(1),
+		},
+		{
+			name: "count the last steps",
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets, Steps: 2},
+			want: int64(2),
+		},
+		{
+			name: "more steps than bets",
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets, Steps: 10},
+			want: int64(5),
+		},
+		{
+			name: "min price",
+			agg:  Aggregate{Fn: AggMin, Metric: MetricPrice},
+			want: float64(500),
+		},
+		{
+			name: "max price of the others",
+			agg:  Aggregate{Fn: AggMax, Metric: MetricPrice, Filter: FilterOthers},
+			want: float64(900),
+		},
+		{
+			name: "average price of the last steps",
+			agg:  Aggregate{Fn: AggAvg, Metric: MetricPrice, Steps: 2},
+			want: float64(550),
+		},
+		{
+			name: "sum of my prices",
+			agg:  Aggregate{Fn: AggSum, Metric: MetricPrice, Filter: FilterMine},
+			want: float64(1300),
+		},
+		{
+			name: "average time between bets leaves out the first bet",
+			agg:  Aggregate{Fn: AggAvg, Metric: MetricTimeBetweenBets},
+			want: 105 * time.Second,
+		},
+		{
+			name: "time between bets of the last steps counts from the earlier bets",
+			agg:  Aggregate{Fn: AggMax, Metric: MetricTimeBetweenBets, Steps: 2},
+			want: 3 * time.Minute,
+		},
+		{
+			name: "sum of the time others took",
+			agg:  Aggregate{Fn: AggSum, Metric: MetricTimeBetweenBets, Filter: FilterOthers},
+			want: 5 * time.Minute,
+		},
+		{
+			name: "count of no bets",
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets, Period: time.Minute},
+			want: int64(0),
+		},
+		{
+			name: "sum of no prices",
+			agg:  Aggregate{Fn: AggSum, Metric: MetricPrice, Period: time.Minute},
+			want: float64(0),
+		},
+		{
+			name: "sum of no time",
+			agg:  Aggregate{Fn: AggSum, Metric: MetricTimeBetweenBets, Period: time.Minute},
+			want: time.Duration(0),
+		},
+		{
+			name: "average of no prices",
+			agg:  Aggregate{Fn: AggAvg, Metric: MetricPrice, Period: time.Minute},
+			want: nil,
+		},
+		{
+			name: "invalid aggregate",
+			agg:  Aggregate{Fn: AggSum, Metric: MetricBets},
+			want: nil,
+		},
+	}
+	state := aggregateState()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewAggregateExpression(tt.agg).Calculate(state)
+			if got != tt.want {
+				t.Errorf("Calculate() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateWithoutValueIsFalse(t *testing.T) {
+	avg := NewAggregateExpression(Aggregate{Fn: AggAvg, Metric: MetricPrice, Period: time.Minute})
+	state := aggregateState()
+	for _, cond := range []Condition{
+		{Expr: &avg, Op: OpGreater, Val: constant(0.0)},
+		{M: ModNot, Expr: &avg, Op: OpGreater, Val: constant(0.0)},
+	} {
+		if cond.Evaluate(state) {
+			t.Errorf("Evaluate() of %s %s = true, want false", cond.M, cond.Op)
+		}
+	}
+}
+
+func TestAggregateCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		agg     Aggregate
+		wantErr bool
+	}{
+		{name: "count", agg: Aggregate{Fn: AggCount, Metric: MetricBets, Filter: FilterMine, Steps: 3}},
+		{name: "average", agg: Aggregate{Fn: AggAvg, Metric: MetricPrice, Period: time.Minute}},
+		{name: "unknown function", agg: Aggregate{Fn: "MEDIAN", Metric: MetricPrice}, wantErr: true},
+		{name: "unknown metric", agg: Aggregate{Fn: AggSum, Metric: "discount"}, wantErr: true},
+		{name: "sum of bets", agg: Aggregate{Fn: AggSum, Metric: MetricBets}, wantErr: true},
+		{name: "count of prices", agg: Aggregate{Fn: AggCount, Metric: MetricPrice}, wantErr: true},
+		{name: "unknown filter", agg: Aggregate{Fn: AggCount, Metric: MetricBets, Filter: "bots"}, wantErr: true},
+		{name: "negative period", agg: Aggregate{Fn: AggCount, Metric: MetricBets, Period: -time.Minute},
+			wantErr: true},
+		{name: "negative steps", agg: Aggregate{Fn: AggCount, Metric: MetricBets, Steps: -1}, wantErr: true},
+		{name: "period and steps", agg: Aggregate{Fn: AggCount, Metric: MetricBets, Period: time.Minute, Steps: 2},
+			wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.agg.check()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("check() error = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errInvalidAggregate) {
+				t.Errorf("check() error = %v, want an invalid aggregate", err)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidAggregate(t *testing.T) {
+	sum := NewAggregateExpression(Aggregate{Fn: AggSum, Metric: MetricBets})
+	strategy := Strategy{N: 5, BaseConditionSet: rule(when(Condition{Expr: &sum, Op: OpGreater,
+		Val: constant(1)}), ActionBet)}
+	diags := strategy.Validate()
+	if len(diags) != 1 || diags[0].Code != DiagInvalidAggregate || diags[0].Path != "condition_set.op.left.expr" {
+		t.Errorf("Validate() = %v, want an %s error", diags, DiagInvalidAggregate)
+	}
+}
+
+func TestAggregateString(t *testing.T) {
+	tests := []struct {
+		agg  Aggregate
+		want string
+	}{
+		{
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets},
+			want: "COUNT(bets)",
+		},
+		{
+			agg:  Aggregate{Fn: AggCount, Metric: MetricBets, Filter: FilterOthers, Period: 3 * time.Minute},
+			want: "COUNT(bets WHERE providerId != myId, last 3m0s)",
+		},
+		{
+			agg:  Aggregate{Fn: AggAvg, Metric: MetricTimeBetweenBets, Filter: FilterMine, Steps: 5},
+			want: "AVG(timeBetweenBets WHERE providerId == myId, last 5 steps)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.agg.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explain works as Define and also returns the branches evaluated on the way
+// to the action, the last one is the branch which fired unless none did
+func (c ConditionSet) Explain(state CurrentSessionState) (Action, []BranchTrace) {
+	fired, branches := c.explain(state, "condition_set")
+	if fired == nil {
+		return ActionWaitTime, branches
+	}
+	return fired.Action, branches
+}
+
+// explain evaluates the Else chain starting at the path, returns the branch which fired,
+// nil when none did
+func (c *ConditionSet) explain(state CurrentSessionState, path string) (*ConditionSet, []BranchTrace) {
+	var branches []BranchTrace
+	for set := c; set != nil; set, path = set.Else, path+".else" {
+		branch := BranchTrace{
+			Path:   path,
+			Action: set.Action,
+		}
+		branch.Result = set.Op.explain(state, path+".op", &branch.Conditions)
+		branches = append(branches, branch)
+		if branch.Result {
+			return set, branches
+		}
+	}
+	return nil, branches
+}
+
+func (o Operator) explain(state CurrentSessionState, path string, trace *[]ConditionTrace) bool {
+	switch o.O {
+	case UnOpNone:
+		return explainNode(o.Left, state, path+".left", trace)
+	case UnOpOr:
+		left := explainNode(o.Left, state, path+".left", trace)
+		right := explainNode(o.Right, state, path+".right", trace)
+		return left || right
+	case UnOpAnd:
+		left := explainNode(o.Left, state, path+".left", trace)
+		right := explainNode(o.Right, state, path+".right", trace)
+		return left && right
+	}
+	return false
+}
+
+func explainNode(node interface{}, state CurrentSessionState, path string, trace *[]ConditionTrace) bool {
+	switch n := node.(type) {
+	case Operator:
+		return n.explain(state, path, trace)
+	case Condition:
+		res := n.Evaluate(state)
+		t := ConditionTrace{
+			Path:      path,
+			Modifier:  n.M,
+			Left:      n.left().String(),
+			Op:        n.Op,
+			LeftValue: formatValue(n.leftValue(state)),
+			Result:    res,
+		}
+		if !n.Val.IsEmpty {
+			t.Right = n.Val.String()
+			t.RightValue = formatValue(n.Val.Calculate(state))
+		}
+		*trace = append(*trace, t)
+		return res
+	}
+	return false
+}
+
+// String returns the expression in the rule language
+func (e Expression) String() string {
+	switch {
+	case e.IsEmpty:
+		return ""
+	case e.IsConst:
+		return formatValue(e.Value)
+	case e.IsParam:
+		return string(e.PName)
+	case e.IsVar:
+		return "$" + e.VName
+	case e.IsCalculable:
+		args := make([]string, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = arg.String()
+		}
+		if e.Fn == FnMin || e.Fn == FnMax {
+			return fmt.Sprintf("%s(%s)", e.Fn, strings.Join(args, ", "))
+		}
+		return "(" + strings.Join(args, " "+string(e.Fn)+" ") + ")"
+	case e.IsAggregate && e.Agg != nil:
+		return e.Agg.String()
+	}
+	return ""
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
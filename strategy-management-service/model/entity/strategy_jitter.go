@@ -0,0 +1,72 @@
+package entity
+
+import "time"
+
+// jitterEndMargin is kept between a delayed bet and the end of the session
+const jitterEndMargin = time.Second
+
+// Jitter randomises the timing of a runner, so that its ticks and bets are not regular
+type Jitter struct {
+	// Max offset added to or subtracted from the interval between the ticks of ForEachNSeconds
+	Tick time.Duration
+	// Each bet is delayed by a random time between BetMin and BetMax
+	BetMin time.Duration
+	BetMax time.Duration
+}
+
+// Random is the source of the jitter, e.g. a seeded *rand.Rand for reproducible simulations
+type Random interface {
+	Int63n(n int64) int64
+}
+
+// TickOffset returns a random offset within ±Tick, 0 without jitter
+func (j *Jitter) TickOffset(rnd Random) time.Duration {
+	if j == nil || j.Tick <= 0 {
+		return 0
+	}
+	return time.Duration(rnd.Int63n(int64(2*j.Tick)+1)) - j.Tick
+}
+
+// BetDelay returns a random delay between BetMin and BetMax, 0 without jitter. The delayed bet never
+// crosses the end of the session: the delay is cut to end a second before it, no delay when the end is closer
+func (j *Jitter) BetDelay(rnd Random, timeTillEnd time.Duration) time.Duration {
+	if j == nil || j.BetMax <= 0 {
+		return 0
+	}
+	res := j.BetMin
+	if j.BetMax > j.BetMin {
+		res += time.Duration(rnd.Int63n(int64(j.BetMax-j.BetMin) + 1))
+	}
+	if limit := timeTillEnd - jitterEndMargin; res > limit {
+		res = limit
+	}
+	if res < 0 {
+		return 0
+	}
+	return res
+}
+
+// validateJitter checks the bounds, the ticks of ForEachNSeconds have to stay apart
+func (s Strategy) validateJitter() Diagnostics {
+	j := s.Jitter
+	if j == nil {
+		return nil
+	}
+	var res Diagnostics
+	if j.Tick < 0 || j.BetMin < 0 || j.BetMax < 0 {
+		res = append(res, errorDiag(DiagInvalidJitter, "jitter", "jitter cannot be negative"))
+	}
+	if j.BetMax < j.BetMin {
+		res = append(res, errorDiag(DiagInvalidJitter, "jitter.bet_delay_max",
+			"max bet delay %s is less than the min one %s", j.BetMax, j.BetMin))
+	}
+	if !s.StepBased() && s.N > 0 && j.Tick >= time.Duration(s.N)*time.Second {
+		res = append(res, errorDiag(DiagInvalidJitter, "jitter.tick",
+			"tick jitter %s must be less than the granularity %ds", j.Tick, s.N))
+	}
+	if s.StepBased() && j.Tick > 0 {
+		res = append(res, warningDiag(DiagInvalidJitter, "jitter.tick",
+			"tick jitter is ignored, %s evaluates the strategy on the steps", s.Repeater()))
+	}
+	return res
+}
@@ -0,0 +1,320 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Operator.Left/Right and Expression.Value are interfaces, so they are encoded
+// together with their type:
+//
+//	{"type": "condition", "value": {...}}
+//	{"type": "duration", "value": "1m0s"}
+
+const (
+	nodeTypeOperator  = "operator"
+	nodeTypeCondition = "condition"
+
+	valueTypeBool     = "bool"
+	valueTypeInt      = "int"
+	valueTypeInt64    = "int64"
+	valueTypeFloat64  = "float64"
+	valueTypeDuration = "duration"
+)
+
+type typedJson struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type operatorJson struct {
+	O     union_operation `json:"o"`
+	Left  *typedJson      `json:"left,omitempty"`
+	Right *typedJson      `json:"right,omitempty"`
+}
+
+type expressionJson struct {
+	IsParam      bool         `json:"is_param,omitempty"`
+	PName        paramName    `json:"param,omitempty"`
+	IsVar        bool         `json:"is_var,omitempty"`
+	VName        string       `json:"var,omitempty"`
+	IsConst      bool         `json:"is_const,omitempty"`
+	Value        *typedJson   `json:"value,omitempty"`
+	IsEmpty      bool         `json:"is_empty,omitempty"`
+	IsCalculable bool         `json:"is_calculable,omitempty"`
+	Fn           function     `json:"fn,omitempty"`
+	Args         []Expression `json:"args,omitempty"`
+	IsAggregate  bool         `json:"is_aggregate,omitempty"`
+	Agg          *Aggregate   `json:"aggregate,omitempty"`
+}
+
+// aggregateJson keeps the period as a duration string like the duration values
+type aggregateJson struct {
+	Fn     aggregateFunction `json:"fn"`
+	Metric betMetric         `json:"metric"`
+	Filter betFilter         `json:"filter,omitempty"`
+	Period string            `json:"period,omitempty"`
+	Steps  int64             `json:"steps,omitempty"`
+}
+
+func (o Operator) MarshalJSON() ([]byte, error) {
+	left, err := encodeNode(o.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := encodeNode(o.Right)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(operatorJson{
+		O:     o.O,
+		Left:  left,
+		Right: right,
+	})
+}
+
+func (o *Operator) UnmarshalJSON(data []byte) error {
+	var buf operatorJson
+	if err := json.Unmarshal(data, &buf); err != nil {
+		return err
+	}
+	left, err := decodeNode(buf.Left)
+	if err != nil {
+		return err
+	}
+	right, err := decodeNode(buf.Right)
+	if err != nil {
+		return err
+	}
+	*o = Operator{
+		O:     buf.O,
+		Left:  left,
+		Right: right,
+	}
+	return nil
+}
+
+func encodeNode(node interface{}) (*typedJson, error) {
+	var nodeType string
+	switch node.(type) {
+	case nil:
+		return nil, nil
+	case Operator:
+		nodeType = nodeTypeOperator
+	case Condition:
+		nodeType = nodeTypeCondition
+	default:
+		return nil, fmt.Errorf("unsupported operator operand %T", node)
+	}
+	raw, err := json.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	return &typedJson{Type: nodeType, Value: raw}, nil
+}
+
+func decodeNode(node *typedJson) (interface{}, error) {
+	if node == nil {
+		return nil, nil
+	}
+	switch node.Type {
+	case nodeTypeOperator:
+		var o Operator
+		err := json.Unmarshal(node.Value, &o)
+		return o, err
+	case nodeTypeCondition:
+		var c Condition
+		err := json.Unmarshal(node.Value, &c)
+		return c, err
+	}
+	return nil, fmt.Errorf("unknown operator operand type %q", node.Type)
+}
+
+func (e Expression) MarshalJSON() ([]byte, error) {
+	value, err := encodeValue(e.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(expressionJson{
+		IsParam:      e.IsParam,
+		PName:        e.PName,
+		IsVar:        e.IsVar,
+		VName:        e.VName,
+		IsConst:      e.IsConst,
+		Value:        value,
+		IsEmpty:      e.IsEmpty,
+		IsCalculable: e.IsCalculable,
+		Fn:           e.Fn,
+		Args:         e.Args,
+		IsAggregate:  e.IsAggregate,
+		Agg:          e.Agg,
+	})
+}
+
+func (e *Expression) UnmarshalJSON(data []byte) error {
+	var buf expressionJson
+	if err := json.Unmarshal(data, &buf); err != nil {
+		return err
+	}
+	value, err := decodeValue(buf.Value)
+	if err != nil {
+		return err
+	}
+	*e = Expression{
+		IsParam:      buf.IsParam,
+		PName:        buf.PName,
+		IsVar:        buf.IsVar,
+		VName:        buf.VName,
+		IsConst:      buf.IsConst,
+		Value:        value,
+		IsEmpty:      buf.IsEmpty,
+		IsCalculable: buf.IsCalculable,
+		Fn:           buf.Fn,
+		Args:         buf.Args,
+		IsAggregate:  buf.IsAggregate,
+		Agg:          buf.Agg,
+	}
+	return nil
+}
+
+func (a Aggregate) MarshalJSON() ([]byte, error) {
+	buf := aggregateJson{
+		Fn:     a.Fn,
+		Metric: a.Metric,
+		Filter: a.Filter,
+		Steps:  a.Steps,
+	}
+	if a.Period != 0 {
+		buf.Period = a.Period.String()
+	}
+	return json.Marshal(buf)
+}
+
+func (a *Aggregate) UnmarshalJSON(data []byte) error {
+	var buf aggregateJson
+	if err := json.Unmarshal(data, &buf); err != nil {
+		return err
+	}
+	*a = Aggregate{
+		Fn:     buf.Fn,
+		Metric: buf.Metric,
+		Filter: buf.Filter,
+		Steps:  buf.Steps,
+	}
+	if buf.Period != "" {
+		period, err := time.ParseDuration(buf.Period)
+		if err != nil {
+			return fmt.Errorf("invalid aggregate period %q: %w", buf.Period, err)
+		}
+		a.Period = period
+	}
+	return nil
+}
+
+// jitterJson keeps the durations as strings like the duration values
+type jitterJson struct {
+	Tick   string `json:"tick,omitempty"`
+	BetMin string `json:"bet_delay_min,omitempty"`
+	BetMax string `json:"bet_delay_max,omitempty"`
+}
+
+func (j Jitter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jitterJson{
+		Tick:   jitterString(j.Tick),
+		BetMin: jitterString(j.BetMin),
+		BetMax: jitterString(j.BetMax),
+	})
+}
+
+func (j *Jitter) UnmarshalJSON(data []byte) error {
+	var buf jitterJson
+	if err := json.Unmarshal(data, &buf); err != nil {
+		return err
+	}
+	var err error
+	*j = Jitter{}
+	if j.Tick, err = parseJitter(buf.Tick); err != nil {
+		return err
+	}
+	if j.BetMin, err = parseJitter(buf.BetMin); err != nil {
+		return err
+	}
+	j.BetMax, err = parseJitter(buf.BetMax)
+	return err
+}
+
+func jitterString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+func parseJitter(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid jitter %q: %w", s, err)
+	}
+	return d, nil
+}
+
+func encodeValue(value interface{}) (*typedJson, error) {
+	var valueType string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		valueType = valueTypeBool
+	case int:
+		valueType = valueTypeInt
+	case int64:
+		valueType = valueTypeInt64
+	case float64:
+		valueType = valueTypeFloat64
+	case time.Duration:
+		valueType = valueTypeDuration
+		value = v.String()
+	default:
+		return nil, fmt.Errorf("unsupported expression value %T", value)
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &typedJson{Type: valueType, Value: raw}, nil
+}
+
+func decodeValue(value *typedJson) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch value.Type {
+	case valueTypeBool:
+		var v bool
+		err := json.Unmarshal(value.Value, &v)
+		return v, err
+	case valueTypeInt:
+		var v int
+		err := json.Unmarshal(value.Value, &v)
+		return v, err
+	case valueTypeInt64:
+		var v int64
+		err := json.Unmarshal(value.Value, &v)
+		return v, err
+	case valueTypeFloat64:
+		var v float64
+		err := json.Unmarshal(value.Value, &v)
+		return v, err
+	case valueTypeDuration:
+		var s string
+		if err := json.Unmarshal(value.Value, &s); err != nil {
+			return nil, err
+		}
+		return time.ParseDuration(s)
+	}
+	return nil, fmt.Errorf("unknown expression value type %q", value.Type)
+}
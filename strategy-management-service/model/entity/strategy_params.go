@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 type StrategyParams struct {
 	UserId             int64   `json:"user_id"`
 	QuotationSessionId int64   `json:"quotation_session_id"`
@@ -7,4 +9,31 @@ type StrategyParams struct {
 	AcceptablePrice    float64 `json:"acceptable_price"`
 	PreferablePrice    float64 `json:"preferable_price"`
 	Str                string  `json:"strategy"`
+	// Values of the template params or of the vars of a custom strategy, the defaults are used for the rest
+	Vars map[string]float64 `json:"vars,omitempty"`
+	// Source of a custom strategy, used instead of Str when set
+	Source string `json:"source,omitempty"`
+	// ID of a stored custom strategy, used instead of Str and Source when set
+	StrategyId int64 `json:"strategy_id,omitempty"`
+	// Max number of bets the runner makes including the initial one, unlimited when 0
+	MaxBets int64 `json:"max_bets,omitempty"`
+	// Bets of a semi-automatic runner are proposed to the account and made only once approved
+	SemiAutomatic bool `json:"semi_automatic,omitempty"`
+	// Seconds a bet proposal waits for the approval, 60 by default
+	ProposalTTL int `json:"proposal_ttl,omitempty"`
+	// The runner is scheduled to start at StartAt and once the StartWhen condition is true, e.g.
+	// "timeTillEnd < 10m". Either is optional, the runner starts at once without both
+	StartAt   *time.Time `json:"start_at,omitempty"`
+	StartWhen string     `json:"start_when,omitempty"`
+	// The runner starts without the initial bet
+	NoInitialBet bool `json:"no_initial_bet,omitempty"`
+	// Account which launches the runner, taken from the access token
+	AccountId int64 `json:"-"`
+	// Portfolio launching the runner, see PortfolioParams
+	PortfolioId int64 `json:"-"`
+}
+
+// Scheduled reports whether the runner waits for its start time or condition
+func (p StrategyParams) Scheduled(now time.Time) bool {
+	return p.StartWhen != "" || p.StartAt != nil && p.StartAt.After(now)
 }
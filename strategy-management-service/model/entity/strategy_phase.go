@@ -0,0 +1,173 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// Phase of a phased strategy, the runner evaluates the ConditionSet of its current phase only.
+// A phase without conditions waits for a transition
+type Phase struct {
+	Name         string        `json:"name"`
+	ConditionSet *ConditionSet `json:"condition_set,omitempty"`
+	Transitions  []Transition  `json:"transitions,omitempty"`
+}
+
+// Transition moves the runner to the phase To when its condition is true,
+// the vars in Set get the new values on the way
+type Transition struct {
+	Op  Operator           `json:"op"`
+	To  string             `json:"to"`
+	Set map[string]float64 `json:"set,omitempty"`
+}
+
+// PhaseState is the current phase of a runner and the values of the vars the transitions set
+type PhaseState struct {
+	Phase string             `json:"phase"`
+	Vars  map[string]float64 `json:"vars,omitempty"`
+}
+
+// TransitionTrace records a transition taken by a runner
+type TransitionTrace struct {
+	Path string             `json:"path"`
+	From string             `json:"from"`
+	To   string             `json:"to"`
+	Set  map[string]float64 `json:"set,omitempty"`
+	// Conditions of the transition with the values they were evaluated with
+	Conditions []ConditionTrace `json:"conditions"`
+}
+
+// StrategyDecision is the result of evaluating a strategy on a tick
+type StrategyDecision struct {
+	Phase      PhaseState
+	Transition *TransitionTrace
+	Action     Action
+	// Delay of BET_AFTER and the rendered message of NOTIFY
+	Delay    time.Duration
+	Message  string
+	Branches []BranchTrace
+}
+
+// Phased reports whether the strategy is a state machine of phases
+func (s Strategy) Phased() bool {
+	return len(s.Phases) > 0
+}
+
+// InitialPhase returns the state a runner of the strategy starts in: the first phase
+// with the declared values of the vars the transitions set
+func (s Strategy) InitialPhase() PhaseState {
+	if !s.Phased() {
+		return PhaseState{}
+	}
+	res := PhaseState{Phase: s.Phases[0].Name}
+	for name := range s.settableVars() {
+		if v, ok := s.Vars[name]; ok {
+			if res.Vars == nil {
+				res.Vars = map[string]float64{}
+			}
+			res.Vars[name] = v
+		}
+	}
+	return res
+}
+
+// Decide evaluates the strategy in the given phase. The first transition of the phase whose condition
+// is true is taken, then the condition set of the resulting phase chooses the action.
+// A strategy without phases evaluates BaseConditionSet
+func (s Strategy) Decide(current PhaseState, state CurrentSessionState) StrategyDecision {
+	if !s.Phased() {
+		fired, branches := s.BaseConditionSet.explain(state, "condition_set")
+		res := StrategyDecision{Branches: branches}
+		res.fire(fired, state)
+		return res
+	}
+	res := StrategyDecision{Phase: current}
+	i, ok := s.phaseIndex(current.Phase)
+	if !ok {
+		// the phase was removed from the strategy, start over
+		i, res.Phase = 0, s.InitialPhase()
+	}
+	values := res.Phase.values()
+	for j, t := range s.Phases[i].Transitions {
+		trace := TransitionTrace{
+			Path: fmt.Sprintf("phases[%d].transitions[%d]", i, j),
+			From: s.Phases[i].Name,
+			To:   t.To,
+			Set:  t.Set,
+		}
+		op := bindNode(t.Op, values).(Operator)
+		next, known := s.phaseIndex(t.To)
+		if !known || !op.explain(state, trace.Path+".op", &trace.Conditions) {
+			continue
+		}
+		res.Phase = res.Phase.moveTo(t)
+		res.Transition = &trace
+		i = next
+		break
+	}
+	set := s.Phases[i].ConditionSet.bind(res.Phase.values())
+	fired, branches := set.explain(state, fmt.Sprintf("phases[%d].condition_set", i))
+	res.Branches = branches
+	res.fire(fired, state)
+	return res
+}
+
+// fire sets the action of the branch which fired, WAIT_TIME when none did
+func (d *StrategyDecision) fire(set *ConditionSet, state CurrentSessionState) {
+	if set == nil {
+		d.Action = ActionWaitTime
+		return
+	}
+	d.Action = set.Action
+	switch set.Action {
+	case ActionBetAfter:
+		d.Delay = time.Duration(set.Delay) * time.Second
+	case ActionNotify:
+		d.Message = RenderMessage(set.Message, state)
+	}
+}
+
+func (s Strategy) phaseIndex(name string) (int, bool) {
+	for i, p := range s.Phases {
+		if p.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// settableVars returns the vars set by the transitions, they stay vars
+// when the strategy is bound and get their values on each tick
+func (s Strategy) settableVars() map[string]bool {
+	res := map[string]bool{}
+	for _, p := range s.Phases {
+		for _, t := range p.Transitions {
+			for name := range t.Set {
+				res[name] = true
+			}
+		}
+	}
+	return res
+}
+
+func (p PhaseState) moveTo(t Transition) PhaseState {
+	res := PhaseState{Phase: t.To}
+	if len(p.Vars)+len(t.Set) > 0 {
+		res.Vars = make(map[string]float64, len(p.Vars)+len(t.Set))
+	}
+	for name, v := range p.Vars {
+		res.Vars[name] = v
+	}
+	for name, v := range t.Set {
+		res.Vars[name] = v
+	}
+	return res
+}
+
+func (p PhaseState) values() map[string]interface{} {
+	res := make(map[string]interface{}, len(p.Vars))
+	for name, v := range p.Vars {
+		res[name] = v
+	}
+	return res
+}
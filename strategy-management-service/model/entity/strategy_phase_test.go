@@ -0,0 +1,321 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func variable(name string) Expression {
+	return Expression{IsVar: true, VName: name}
+}
+
+// phasedStrategy holds until the additional purchase or the last 5 minutes, then outbids the others
+// once delay has passed since the last bet and stops below the margin:
+//
+//	VAR delay = 60
+//	VAR margin = 100
+//	PHASE HOLD
+//	WHEN isOnAdditionalPurchase GOTO DEFEND SET delay = 5
+//	WHEN timeTillEnd < 5m GOTO DEFEND
+//	PHASE DEFEND
+//	WHEN currentPrice < $margin GOTO DONE
+//	IF currentWinnerId != myId AND timeSinceLastMyBet > $delay THEN BET
+//	PHASE DONE
+//	IF currentPrice < $margin THEN STOP
+func phasedStrategy() Strategy {
+	return Strategy{
+		N:    5,
+		Vars: map[string]float64{"delay": 60, "margin": 100},
+		Phases: []Phase{
+			{
+				Name: "HOLD",
+				Transitions: []Transition{
+					{
+						Op:  when(Condition{Param: ParamIsOnAdditionalPurchase, Op: OpNone, Val: Expression{IsEmpty: true}}),
+						To:  "DEFEND",
+						Set: map[string]float64{"delay": 5},
+					},
+					{
+						Op: when(compare(ParamTimeTillEnd, OpLess, 5*time.Minute)),
+						To: "DEFEND",
+					},
+				},
+			},
+			{
+				Name: "DEFEND",
+				Transitions: []Transition{
+					{
+						Op: when(Condition{Param: ParamCurrentPrice, Op: OpLess, Val: variable("margin")}),
+						To: "DONE",
+					},
+				},
+				ConditionSet: rule(and(
+					Condition{Param: ParamCurrentWinnerId, Op: OpNotEq, Val: Expression{IsParam: true, PName: ParamMyId}},
+					Condition{Param: ParamTimeSinceLastMyBet, Op: OpGreater, Val: variable("delay")},
+				), ActionBet),
+			},
+			{
+				Name:         "DONE",
+				ConditionSet: rule(when(Condition{Param: ParamCurrentPrice, Op: OpLess, Val: variable("margin")}), ActionStop),
+			},
+		},
+	}
+}
+
+func TestStrategyInitialPhase(t *testing.T) {
+	want := PhaseState{Phase: "HOLD", Vars: map[string]float64{"delay": 60}}
+	if got := phasedStrategy().InitialPhase(); !reflect.DeepEqual(got, want) {
+		t.Errorf("InitialPhase() = %+v, want %+v", got, want)
+	}
+	if got := (Strategy{N: 5}).InitialPhase(); !reflect.DeepEqual(got, PhaseState{}) {
+		t.Errorf("InitialPhase() of a strategy without phases = %+v", got)
+	}
+}
+
+func TestStrategyDecide(t *testing.T) {
+	// the other bidder leads, the price is above the margin
+	outbid := CurrentSessionState{
+		UserId:             1,
+		CurrentWinnerId:    2,
+		CurrentPrice:       500,
+		TimeTillEnd:        30 * time.Minute,
+		TimeSinceLastMyBet: 10 * time.Second,
+	}
+	with := func(change func(s *CurrentSessionState)) CurrentSessionState {
+		s := outbid
+		change(&s)
+		return s
+	}
+	hold := PhaseState{Phase: "HOLD", Vars: map[string]float64{"delay": 60}}
+	tests := []struct {
+		name           string
+		current        PhaseState
+		state          CurrentSessionState
+		wantPhase      PhaseState
+		wantTransition string
+		wantAction     Action
+	}{
+		{
+			name:       "phase without conditions waits",
+			current:    hold,
+			state:      outbid,
+			wantPhase:  hold,
+			wantAction: ActionWaitTime,
+		},
+		{
+			name:    "transition sets the var the new phase uses",
+			current: hold,
+			state: with(func(s *CurrentSessionState) {
+				s.IsOnAdditionalPurchase = true
+			}),
+			wantPhase:      PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 5}},
+			wantTransition: "phases[0].transitions[0]",
+			wantAction:     ActionBet,
+		},
+		{
+			name:    "transition keeps the vars it does not set",
+			current: hold,
+			state: with(func(s *CurrentSessionState) {
+				s.TimeTillEnd = 4 * time.Minute
+			}),
+			wantPhase:      PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 60}},
+			wantTransition: "phases[0].transitions[1]",
+			wantAction:     ActionWaitTime,
+		},
+		{
+			name:    "first transition which is true is taken",
+			current: hold,
+			state: with(func(s *CurrentSessionState) {
+				s.IsOnAdditionalPurchase = true
+				s.TimeTillEnd = 4 * time.Minute
+			}),
+			wantPhase:      PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 5}},
+			wantTransition: "phases[0].transitions[0]",
+			wantAction:     ActionBet,
+		},
+		{
+			name:    "one transition per tick",
+			current: hold,
+			state: with(func(s *CurrentSessionState) {
+				s.IsOnAdditionalPurchase = true
+				s.CurrentPrice = 50
+			}),
+			wantPhase:      PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 5}},
+			wantTransition: "phases[0].transitions[0]",
+			wantAction:     ActionBet,
+		},
+		{
+			name:       "phase keeps its vars",
+			current:    PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 5}},
+			state:      outbid,
+			wantPhase:  PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 5}},
+			wantAction: ActionBet,
+		},
+		{
+			name:       "phase waits while the user leads",
+			current:    PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 5}},
+			state:      with(func(s *CurrentSessionState) { s.CurrentWinnerId = 1 }),
+			wantPhase:  PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 5}},
+			wantAction: ActionWaitTime,
+		},
+		{
+			name:           "transition with a fixed var",
+			current:        PhaseState{Phase: "DEFEND", Vars: map[string]float64{"delay": 5}},
+			state:          with(func(s *CurrentSessionState) { s.CurrentPrice = 50 }),
+			wantPhase:      PhaseState{Phase: "DONE", Vars: map[string]float64{"delay": 5}},
+			wantTransition: "phases[1].transitions[0]",
+			wantAction:     ActionStop,
+		},
+		{
+			name:       "removed phase starts over",
+			current:    PhaseState{Phase: "REMOVED", Vars: map[string]float64{"delay": 5}},
+			state:      outbid,
+			wantPhase:  hold,
+			wantAction: ActionWaitTime,
+		},
+	}
+	strategy, err := phasedStrategy().WithVars(nil)
+	if err != nil {
+		t.Fatalf("WithVars() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := strategy.Decide(tt.current, tt.state)
+			if !reflect.DeepEqual(decision.Phase, tt.wantPhase) {
+				t.Errorf("phase = %+v, want %+v", decision.Phase, tt.wantPhase)
+			}
+			var transition string
+			if decision.Transition != nil {
+				transition = decision.Transition.Path
+			}
+			if transition != tt.wantTransition {
+				t.Errorf("transition = %q, want %q", transition, tt.wantTransition)
+			}
+			if decision.Action != tt.wantAction {
+				t.Errorf("action = %s, want %s", decision.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestStrategyDecideDoesNotChangeTheCurrentPhase(t *testing.T) {
+	strategy, err := phasedStrategy().WithVars(nil)
+	if err != nil {
+		t.Fatalf("WithVars() error = %v", err)
+	}
+	current := strategy.InitialPhase()
+	strategy.Decide(current, CurrentSessionState{IsOnAdditionalPurchase: true})
+	if want := (PhaseState{Phase: "HOLD", Vars: map[string]float64{"delay": 60}}); !reflect.DeepEqual(current, want) {
+		t.Errorf("current phase = %+v, want %+v", current, want)
+	}
+}
+
+func TestStrategyValidatePhases(t *testing.T) {
+	priceAbove := when(compare(ParamCurrentPrice, OpGreater, 100.0))
+	tests := []struct {
+		name   string
+		change func(s *Strategy)
+		want   []diag
+	}{
+		{
+			name:   "valid phases",
+			change: func(s *Strategy) {},
+			want:   []diag{{SeverityWarning, DiagImplicitConversion, "phases[1].condition_set.op.right"}},
+		},
+		{
+			name: "condition set next to the phases",
+			change: func(s *Strategy) {
+				s.BaseConditionSet = rule(priceAbove, ActionBet)
+			},
+			want: []diag{{SeverityError, DiagInvalidPhase, "condition_set"}},
+		},
+		{
+			name: "phase without a name",
+			change: func(s *Strategy) {
+				s.Phases = append(s.Phases, Phase{ConditionSet: rule(priceAbove, ActionBet)})
+			},
+			want: []diag{
+				{SeverityError, DiagInvalidPhase, "phases[3].name"},
+				{SeverityWarning, DiagUnreachablePhase, "phases[3]"},
+			},
+		},
+		{
+			name: "phase declared twice",
+			change: func(s *Strategy) {
+				s.Phases = append(s.Phases, Phase{Name: "DONE"})
+			},
+			want: []diag{{SeverityError, DiagInvalidPhase, "phases[3].name"}},
+		},
+		{
+			name: "transition to an unknown phase",
+			change: func(s *Strategy) {
+				s.Phases[1].Transitions[0].To = "FINISH"
+			},
+			want: []diag{
+				{SeverityError, DiagUnknownPhase, "phases[1].transitions[0].to"},
+				{SeverityWarning, DiagUnreachablePhase, "phases[2]"},
+			},
+		},
+		{
+			name: "transition sets an undeclared var",
+			change: func(s *Strategy) {
+				s.Phases[0].Transitions[1].Set = map[string]float64{"pause": 10}
+			},
+			want: []diag{{SeverityError, DiagUnboundVar, "phases[0].transitions[1].set"}},
+		},
+		{
+			name: "transition without a condition",
+			change: func(s *Strategy) {
+				s.Phases[0].Transitions[1].Op = Operator{}
+			},
+			want: []diag{{SeverityError, DiagEmptyBranch, "phases[0].transitions[1].op"}},
+		},
+		{
+			name: "transition with a wrong condition",
+			change: func(s *Strategy) {
+				s.Phases[0].Transitions[1].Op = when(compare(ParamIsOnAdditionalPurchase, OpEquals, 1))
+			},
+			want: []diag{{SeverityError, DiagTypeMismatch, "phases[0].transitions[1].op.left"}},
+		},
+		{
+			name: "wrong condition in a phase",
+			change: func(s *Strategy) {
+				s.Phases[2].ConditionSet = rule(when(compare("price", OpLess, 1.0)), ActionStop)
+			},
+			want: []diag{{SeverityError, DiagUnknownParam, "phases[2].condition_set.op.left.param"}},
+		},
+		{
+			name: "phase nothing leads to",
+			change: func(s *Strategy) {
+				s.Phases[1].Transitions = nil
+			},
+			want: []diag{{SeverityWarning, DiagUnreachablePhase, "phases[2]"}},
+		},
+		{
+			name: "phases only wait",
+			change: func(s *Strategy) {
+				s.Phases[1].ConditionSet.Action = ActionWaitTime
+				s.Phases[2].ConditionSet.Action = ActionWaitTime
+			},
+			want: []diag{{SeverityError, DiagNeverFires, "phases"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := phasedStrategy()
+			tt.change(&strategy)
+			var got []diag
+			for _, d := range strategy.Validate() {
+				// $delay compared with timeSinceLastMyBet is counted in seconds, the valid case reports it
+				if d.Code == DiagImplicitConversion && tt.name != "valid phases" {
+					continue
+				}
+				got = append(got, diag{d.Severity, d.Code, d.Path})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package entity
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxSplits limits the number of OR operators expanded by neverTrue,
+// a condition too big to analyse is assumed to be satisfiable
+const maxSplits = 256
+
+// atom is a valid condition reduced to "left op right" with NOT applied.
+// A constant right operand is kept as a number in the units of the left
+// operand (seconds for durations, 1 and 0 for flags).
+type atom struct {
+	left     string
+	op       operation
+	right    string
+	bound    float64
+	hasBound bool
+}
+
+func atomOf(r Condition) (atom, bool) {
+	if r.diagnose("").HasErrors() {
+		return atom{}, false
+	}
+	a := atom{
+		left: r.left().key(),
+		op:   r.Op,
+	}
+	switch {
+	case r.Op == OpNone || r.Val.IsEmpty:
+		// a flag used alone
+		a.op = OpEquals
+		a.bound = 1
+		a.hasBound = true
+	case r.Val.IsConst:
+		if flag, ok := r.Val.Value.(bool); ok {
+			a.bound = 0
+			if flag {
+				a.bound = 1
+			}
+		} else {
+			v, err := ConvertValue(r.Val.Value, TypeNumber)
+			if err != nil {
+				return atom{}, false
+			}
+			a.bound = v.(float64)
+		}
+		a.hasBound = true
+	default:
+		a.right = r.Val.key()
+	}
+	if r.M == ModNot {
+		a.op = a.op.Negate()
+	}
+	return a, true
+}
+
+// key identifies the expression, equal keys are calculated to equal values
+func (e Expression) key() string {
+	switch {
+	case e.IsParam:
+		return string(e.PName)
+	case e.IsConst:
+		v, err := ConvertValue(e.Value, TypeNumber)
+		if err != nil {
+			return "?"
+		}
+		return strconv.FormatFloat(v.(float64), 'g', -1, 64)
+	case e.IsCalculable:
+		args := make([]string, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = arg.key()
+		}
+		if e.Fn == FnAdd || e.Fn == FnMul || e.Fn == FnMin || e.Fn == FnMax {
+			sort.Strings(args)
+		}
+		return string(e.Fn) + "(" + strings.Join(args, ",") + ")"
+	case e.IsAggregate && e.Agg != nil:
+		return e.Agg.String()
+	}
+	return ""
+}
+
+// outcome is a set of possible results of comparing two values
+type outcome int
+
+const (
+	outLess outcome = 1 << iota
+	outEqual
+	outGreater
+)
+
+func outcomesOf(op operation) outcome {
+	switch op {
+	case OpLess:
+		return outLess
+	case OpLessOrEq:
+		return outLess | outEqual
+	case OpEquals:
+		return outEqual
+	case OpGreaterOrEq:
+		return outGreater | outEqual
+	case OpGreater:
+		return outGreater
+	case OpNotEq:
+		return outLess | outGreater
+	}
+	return outLess | outEqual | outGreater
+}
+
+// bounds is the range of values allowed by the constant comparisons
+type bounds struct {
+	lo, hi             float64
+	hasLo, hasHi       bool
+	loStrict, hiStrict bool
+	excluded           []float64
+}
+
+func (b *bounds) raiseLo(v float64, strict bool) {
+	if !b.hasLo || v > b.lo || (v == b.lo && strict) {
+		b.lo, b.loStrict, b.hasLo = v, strict, true
+	}
+}
+
+func (b *bounds) lowerHi(v float64, strict bool) {
+	if !b.hasHi || v < b.hi || (v == b.hi && strict) {
+		b.hi, b.hiStrict, b.hasHi = v, strict, true
+	}
+}
+
+func (b bounds) empty() bool {
+	if !b.hasLo || !b.hasHi {
+		return false
+	}
+	if b.lo > b.hi || (b.lo == b.hi && (b.loStrict || b.hiStrict)) {
+		return true
+	}
+	if b.lo == b.hi {
+		for _, v := range b.excluded {
+			if v == b.lo {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contradicts reports whether the atoms can never be true at the same time,
+// e.g. currentPrice > x AND currentPrice < x
+func contradicts(atoms []atom) bool {
+	ranges := map[string]*bounds{}
+	relations := map[[2]string]outcome{}
+	for _, a := range atoms {
+		if a.hasBound {
+			b, ok := ranges[a.left]
+			if !ok {
+				b = &bounds{}
+				ranges[a.left] = b
+			}
+			switch a.op {
+			case OpGreater, OpGreaterOrEq:
+				b.raiseLo(a.bound, a.op == OpGreater)
+			case OpLess, OpLessOrEq:
+				b.lowerHi(a.bound, a.op == OpLess)
+			case OpEquals:
+				b.raiseLo(a.bound, false)
+				b.lowerHi(a.bound, false)
+			case OpNotEq:
+				b.excluded = append(b.excluded, a.bound)
+			}
+			if b.empty() {
+				return true
+			}
+			continue
+		}
+		left, right, op := a.left, a.right, a.op
+		if left == right {
+			if outcomesOf(op)&outEqual == 0 {
+				return true
+			}
+			continue
+		}
+		if left > right {
+			left, right, op = right, left, op.Mirror()
+		}
+		pair := [2]string{left, right}
+		possible, ok := relations[pair]
+		if !ok {
+			possible = outLess | outEqual | outGreater
+		}
+		possible &= outcomesOf(op)
+		if possible == 0 {
+			return true
+		}
+		relations[pair] = possible
+	}
+	return false
+}
+
+// neverTrue reports whether the conjunction of the nodes can be proven to be
+// always false. Invalid conditions and anything not understood are assumed
+// to be satisfiable.
+func neverTrue(nodes []interface{}) bool {
+	splits := maxSplits
+	return conjunctionNeverTrue(nodes, nil, &splits)
+}
+
+func conjunctionNeverTrue(nodes []interface{}, atoms []atom, splits *int) bool {
+	for len(nodes) > 0 {
+		node := nodes[0]
+		nodes = nodes[1:]
+		switch n := node.(type) {
+		case Condition:
+			if a, ok := atomOf(n); ok {
+				atoms = append(atoms, a)
+			}
+		case Operator:
+			switch n.O {
+			case UnOpNone:
+				nodes = append([]interface{}{n.Left}, nodes...)
+			case UnOpAnd:
+				nodes = append([]interface{}{n.Left, n.Right}, nodes...)
+			case UnOpOr:
+				if contradicts(atoms) {
+					return true
+				}
+				if *splits <= 0 {
+					return false
+				}
+				*splits--
+				leftAtoms := append([]atom(nil), atoms...)
+				rightAtoms := append([]atom(nil), atoms...)
+				return conjunctionNeverTrue(append([]interface{}{n.Left}, nodes...), leftAtoms, splits) &&
+					conjunctionNeverTrue(append([]interface{}{n.Right}, nodes...), rightAtoms, splits)
+			}
+		}
+	}
+	return contradicts(atoms)
+}
+
+// negate returns the node with the opposite result
+func negate(node interface{}) interface{} {
+	switch n := node.(type) {
+	case Condition:
+		if n.M == ModNot {
+			n.M = ""
+		} else {
+			n.M = ModNot
+		}
+		return n
+	case Operator:
+		switch n.O {
+		case UnOpNone:
+			return Operator{O: UnOpNone, Left: negate(n.Left)}
+		case UnOpAnd:
+			return Operator{O: UnOpOr, Left: negate(n.Left), Right: negate(n.Right)}
+		case UnOpOr:
+			return Operator{O: UnOpAnd, Left: negate(n.Left), Right: negate(n.Right)}
+		}
+	}
+	return node
+}
+
+func negateAll(nodes []interface{}) []interface{} {
+	res := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		res[i] = negate(node)
+	}
+	return res
+}
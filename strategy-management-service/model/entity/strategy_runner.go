@@ -0,0 +1,108 @@
+package entity
+
+import "time"
+
+type RunnerStatus string
+
+const (
+	RunnerRunning  = "RUNNING"
+	RunnerPaused   = "PAUSED"
+	RunnerStopped  = "STOPPED"
+	RunnerFinished = "FINISHED"
+	// The runner waits for its start time or condition
+	RunnerScheduled = "SCHEDULED"
+	// The scheduled start is cancelled before the runner started
+	RunnerCancelled = "CANCELLED"
+)
+
+// StrategyRunner is the stored state of a runner, used to resume it after a restart
+type StrategyRunner struct {
+	ID                 int64          `pg:"id,pk" json:"id"`
+	QuotationSessionId int64          `pg:"quotation_session_id" json:"quotation_session_id"`
+	UserId             int64          `pg:"user_id" json:"user_id"`
+	AccountId          int64          `pg:"account_id" json:"account_id"`
+	ProfileId          int64          `pg:"profile_id" json:"profile_id"`
+	Params             StrategyParams `pg:"params,type:jsonb" json:"params"`
+	StrategyName       string         `pg:"strategy_name" json:"strategy_name"`
+	Strategy           Strategy       `pg:"strategy,type:jsonb" json:"strategy"`
+	Status             RunnerStatus   `pg:"status" json:"status"`
+	StartedAt          time.Time      `pg:"started_at" json:"started_at"`
+	// Number of the last tick made, -1 before the first one
+	LastTick   int64     `pg:"last_tick,use_zero" json:"last_tick"`
+	LastTickAt time.Time `pg:"last_tick_at" json:"last_tick_at"`
+	BetsMade   int64     `pg:"bets_made,use_zero" json:"bets_made"`
+	StoppedAt  time.Time `pg:"stopped_at" json:"stopped_at"`
+	// Number of the last step of the session the runner has handled: the step of the initial bet
+	// or the last one the strategy was evaluated on
+	LastStep int64 `pg:"last_step,use_zero" json:"last_step"`
+	// Current phase of a phased strategy
+	Phase *PhaseState `pg:"phase,type:jsonb" json:"phase,omitempty"`
+	// Portfolio the runner bets for, 0 for a standalone runner
+	PortfolioId int64 `pg:"portfolio_id" json:"portfolio_id,omitempty"`
+	// Set when the runner is finished by the end of the session
+	Outcome *RunnerOutcome `pg:"outcome,type:jsonb" json:"outcome,omitempty"`
+}
+
+// RunnerOutcome is the result of the session for the user a runner bet for
+type RunnerOutcome struct {
+	SessionStatus SessionStatus `json:"session_status"`
+	Won           bool          `json:"won"`
+	FinalPrice    float64       `json:"final_price"`
+	WinnerId      int64         `json:"winner_id,omitempty"`
+	// The last bet of the user, nil when the user made none
+	LastBet *Bet `json:"last_bet,omitempty"`
+}
+
+// NewRunnerOutcome sums up the ended session for the user, bets are the bets made in the session
+func NewRunnerOutcome(session QuotationSession, userId int64, bets []*Bet) RunnerOutcome {
+	res := RunnerOutcome{
+		SessionStatus: session.Status,
+		FinalPrice:    session.CurrentPrice,
+	}
+	if session.Status == StatusFinished && session.LastBet != nil {
+		res.WinnerId = session.LastBet.ProviderId
+		res.Won = res.WinnerId == userId
+	}
+	for _, bet := range bets {
+		if bet.ProviderId == userId && (res.LastBet == nil || bet.BetNumber > res.LastBet.BetNumber) {
+			res.LastBet = bet
+		}
+	}
+	return res
+}
+
+// RunnerInfo describes a runner registered in the service
+type RunnerInfo struct {
+	RunnerId           int64           `json:"runner_id"`
+	QuotationSessionId int64           `json:"quotation_session_id"`
+	UserId             int64           `json:"user_id"`
+	AccountId          int64           `json:"account_id"`
+	ProfileId          int64           `json:"profile_id"`
+	Strategy           string          `json:"strategy"`
+	Status             RunnerStatus    `json:"status"`
+	StartedAt          time.Time       `json:"started_at"`
+	BetsMade           int64           `json:"bets_made"`
+	LastDecision       *RunnerDecision `json:"last_decision,omitempty"`
+	// Current phase of a phased strategy
+	Phase       *PhaseState `json:"phase,omitempty"`
+	PortfolioId int64       `json:"portfolio_id,omitempty"`
+}
+
+// RunnerDecision is the outcome of the last tick, see DecisionTrace for the details
+type RunnerDecision struct {
+	Tick   int64     `json:"tick"`
+	Time   time.Time `json:"time"`
+	Action Action    `json:"action"`
+	// Path of the branch which fired, empty when none did
+	Branch string `json:"branch,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RunnerFilter selects runners, zero fields match any runner
+type RunnerFilter struct {
+	QuotationSessionId int64
+	UserId             int64
+	AccountId          int64
+	ProfileId          int64
+	PortfolioId        int64
+}
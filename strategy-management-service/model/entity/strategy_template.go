@@ -0,0 +1,196 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// VarGranularity is the template param setting Strategy.N
+const VarGranularity = "granularity"
+
+var (
+	ErrUnknownVar = errors.New("unknown var")
+	ErrInvalidVar = errors.New("invalid var value")
+)
+
+// TemplateParam is a named param of a strategy template, referenced in the strategy
+// as a var. Default, Min and Max are in the units of Type, durations in seconds.
+type TemplateParam struct {
+	Name        string    `json:"name"`
+	Type        valueType `json:"type"`
+	Default     float64   `json:"default"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Description string    `json:"description"`
+}
+
+// StrategyTemplate is a strategy whose thresholds are params, filled in for each run
+type StrategyTemplate struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Params      []TemplateParam `json:"params"`
+	Strategy    Strategy        `json:"strategy"`
+}
+
+// Instantiate returns the strategy with the params set to the overrides or to the defaults,
+// the overrides must be the params of the template within their bounds
+func (t StrategyTemplate) Instantiate(overrides map[string]float64) (Strategy, error) {
+	declared := map[string]bool{}
+	for _, p := range t.Params {
+		declared[p.Name] = true
+	}
+	for _, name := range sortedNames(overrides) {
+		if !declared[name] {
+			return Strategy{}, fmt.Errorf("%w %q, template %s has no such param", ErrUnknownVar, name, t.Name)
+		}
+	}
+
+	vars := make(map[string]float64, len(t.Params))
+	values := make(map[string]interface{}, len(t.Params))
+	for _, p := range t.Params {
+		v, ok := overrides[p.Name]
+		if !ok {
+			v = p.Default
+		}
+		if v < p.Min || v > p.Max {
+			return Strategy{}, fmt.Errorf("%w: %s should be from %v to %v, got %v", ErrInvalidVar,
+				p.Name, p.Min, p.Max, v)
+		}
+		typed, err := ConvertValue(v, p.Type)
+		if err != nil {
+			return Strategy{}, fmt.Errorf("%w: %s should be of type %s: %s", ErrInvalidVar, p.Name, p.Type, err)
+		}
+		vars[p.Name] = v
+		values[p.Name] = typed
+	}
+
+	s := t.Strategy.Bind(t.Strategy.fixed(values))
+	s.Vars = vars
+	if g, ok := vars[VarGranularity]; ok {
+		s.N = int(g)
+	}
+	return s, nil
+}
+
+// WithVars returns the strategy with the values of the declared vars replaced by the overrides
+// and the vars bound, the vars are numbers. The vars set by the transitions of a phased strategy
+// are left to the runner
+func (s Strategy) WithVars(overrides map[string]float64) (Strategy, error) {
+	vars := make(map[string]float64, len(s.Vars))
+	for name, v := range s.Vars {
+		vars[name] = v
+	}
+	for _, name := range sortedNames(overrides) {
+		if _, ok := vars[name]; !ok {
+			return Strategy{}, fmt.Errorf("%w %q, the strategy declares no such var", ErrUnknownVar, name)
+		}
+		vars[name] = overrides[name]
+	}
+	res := s
+	res.Vars = vars
+	return res.Bind(res.fixed(res.varValues())), nil
+}
+
+// fixed leaves out the values of the vars the transitions set
+func (s Strategy) fixed(values map[string]interface{}) map[string]interface{} {
+	settable := s.settableVars()
+	res := make(map[string]interface{}, len(values))
+	for name, v := range values {
+		if !settable[name] {
+			res[name] = v
+		}
+	}
+	return res
+}
+
+func (s Strategy) varValues() map[string]interface{} {
+	res := make(map[string]interface{}, len(s.Vars))
+	for name, v := range s.Vars {
+		res[name] = v
+	}
+	return res
+}
+
+// Bind returns a copy of the strategy with the vars replaced by the constant values,
+// vars without a value are left as they are
+func (s Strategy) Bind(values map[string]interface{}) Strategy {
+	res := s
+	res.BaseConditionSet = s.BaseConditionSet.bind(values)
+	if len(s.Phases) > 0 {
+		res.Phases = make([]Phase, len(s.Phases))
+		for i, p := range s.Phases {
+			res.Phases[i] = p.bind(values)
+		}
+	}
+	return res
+}
+
+func (p Phase) bind(values map[string]interface{}) Phase {
+	res := p
+	res.ConditionSet = p.ConditionSet.bind(values)
+	if len(p.Transitions) > 0 {
+		res.Transitions = make([]Transition, len(p.Transitions))
+		for i, t := range p.Transitions {
+			t.Op = bindNode(t.Op, values).(Operator)
+			res.Transitions[i] = t
+		}
+	}
+	return res
+}
+
+func (c *ConditionSet) bind(values map[string]interface{}) *ConditionSet {
+	if c == nil {
+		return nil
+	}
+	return &ConditionSet{
+		Op:      bindNode(c.Op, values).(Operator),
+		Action:  c.Action,
+		Delay:   c.Delay,
+		Message: c.Message,
+		Else:    c.Else.bind(values),
+	}
+}
+
+func bindNode(node interface{}, values map[string]interface{}) interface{} {
+	switch n := node.(type) {
+	case Operator:
+		n.Left = bindNode(n.Left, values)
+		n.Right = bindNode(n.Right, values)
+		return n
+	case Condition:
+		n.Val = n.Val.bind(values)
+		if n.Expr != nil {
+			expr := n.Expr.bind(values)
+			n.Expr = &expr
+		}
+		return n
+	}
+	return node
+}
+
+func (e Expression) bind(values map[string]interface{}) Expression {
+	if e.IsVar {
+		if v, ok := values[e.VName]; ok {
+			return Expression{IsConst: true, Value: v}
+		}
+		return e
+	}
+	if len(e.Args) > 0 {
+		args := make([]Expression, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = arg.bind(values)
+		}
+		e.Args = args
+	}
+	return e
+}
+
+func sortedNames(m map[string]float64) []string {
+	res := make([]string, 0, len(m))
+	for name := range m {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,89 @@
+package entity
+
+// timeParams change while no bets are made
+var timeParams = []paramName{
+	ParamTimeSinceLastStep,
+	ParamTimeSinceLastMyBet,
+	ParamTimeSinceStart,
+	ParamTimeTillEnd,
+	// the windows slide with time
+	ParamDistinctActiveCompetitorsLast5m,
+	ParamPriceDropRatePerMinute,
+	paramAggregatePeriod,
+}
+
+// paramAggregatePeriod marks an aggregate over a period, its window slides with time as well
+const paramAggregatePeriod paramName = "aggregate period"
+
+// Repeater returns the mode the strategy is evaluated in, ForEachNSeconds when none or an unknown one is set
+func (s Strategy) Repeater() repeater {
+	switch s.R {
+	case RepeaterFES, RepeaterFENS:
+		return s.R
+	}
+	return RepeaterFENSec
+}
+
+// StepBased reports whether the strategy is evaluated on the bets of the session instead of every N seconds
+func (s Strategy) StepBased() bool {
+	return s.Repeater() != RepeaterFENSec
+}
+
+// StepDue reports whether a step based strategy last evaluated on the step last has to be evaluated
+// on the step: ForEachStep evaluates each step once, ForEachNStep once N steps have passed.
+// The steps made while the strategy is evaluated are not evaluated on their own, only the latest one is
+func (s Strategy) StepDue(last int64, step int64) bool {
+	switch s.Repeater() {
+	case RepeaterFES:
+		return step > last
+	case RepeaterFENS:
+		return step >= last+int64(s.N)
+	}
+	return false
+}
+
+// TimeBased reports whether the strategy refers to a param which changes with time,
+// such a strategy has to be evaluated again even when nobody bets
+func (s Strategy) TimeBased() bool {
+	used := map[paramName]bool{}
+	for set := s.BaseConditionSet; set != nil; set = set.Else {
+		collectParams(set.Op, used)
+	}
+	for _, p := range s.Phases {
+		for set := p.ConditionSet; set != nil; set = set.Else {
+			collectParams(set.Op, used)
+		}
+		for _, t := range p.Transitions {
+			collectParams(t.Op, used)
+		}
+	}
+	for _, p := range timeParams {
+		if used[p] {
+			return true
+		}
+	}
+	return false
+}
+
+func collectParams(node interface{}, used map[paramName]bool) {
+	switch n := node.(type) {
+	case Operator:
+		collectParams(n.Left, used)
+		collectParams(n.Right, used)
+	case Condition:
+		n.left().collectParams(used)
+		n.Val.collectParams(used)
+	}
+}
+
+func (e Expression) collectParams(used map[paramName]bool) {
+	if e.IsParam {
+		used[e.PName] = true
+	}
+	if e.IsAggregate && e.Agg != nil && e.Agg.Period > 0 {
+		used[paramAggregatePeriod] = true
+	}
+	for _, arg := range e.Args {
+		arg.collectParams(used)
+	}
+}
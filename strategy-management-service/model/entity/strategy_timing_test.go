@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStepDue(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		// step the strategy was last evaluated on and the steps of the session seen, in order
+		last int64
+		seen []int64
+		want []int64
+	}{
+		{
+			// launched before the first bet
+			name:     "each step once",
+			strategy: Strategy{R: RepeaterFES},
+			last:     -1,
+			seen:     []int64{-1, 0, 1, 1, 2, 3, 3, 3, 4},
+			want:     []int64{0, 1, 2, 3, 4},
+		},
+		{
+			name:     "steps made meanwhile",
+			strategy: Strategy{R: RepeaterFES},
+			last:     2,
+			seen:     []int64{2, 5, 5, 6, 9},
+			want:     []int64{5, 6, 9},
+		},
+		{
+			name:     "every 3 steps",
+			strategy: Strategy{R: RepeaterFENS, N: 3},
+			seen:     []int64{1, 2, 3, 3, 4, 5, 6, 7, 8, 9},
+			want:     []int64{3, 6, 9},
+		},
+		{
+			// the next 3 steps count from the step evaluated
+			name:     "every 3 steps made meanwhile",
+			strategy: Strategy{R: RepeaterFENS, N: 3},
+			last:     1,
+			seen:     []int64{2, 5, 6, 7, 8, 12},
+			want:     []int64{5, 8, 12},
+		},
+		{
+			name:     "every step of N 1",
+			strategy: Strategy{R: RepeaterFENS, N: 1},
+			seen:     []int64{0, 1, 2, 2, 3},
+			want:     []int64{1, 2, 3},
+		},
+		{
+			name:     "not step based",
+			strategy: Strategy{N: 5},
+			seen:     []int64{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := tt.last
+			var got []int64
+			for _, step := range tt.seen {
+				if tt.strategy.StepDue(last, step) {
+					got = append(got, step)
+					last = step
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("steps evaluated = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//SEVERITY
+// error, warning
+type severity string
+
+const (
+	SeverityError   severity = "error"
+	SeverityWarning severity = "warning"
+)
+
+// Diagnostic codes
+const (
+	DiagSyntax             = "syntax"
+	DiagInvalidGranularity = "invalid-granularity"
+	DiagUnknownRepeater    = "unknown-repeater"
+	DiagEmptyStrategy      = "empty-strategy"
+	DiagEmptyBranch        = "empty-branch"
+	DiagUnknownAction      = "unknown-action"
+	DiagUnknownOperator    = "unknown-operator"
+	DiagMissingOperand     = "missing-operand"
+	DiagUnknownModifier    = "unknown-modifier"
+	DiagUnknownParam       = "unknown-param"
+	DiagUnboundVar         = "unbound-var"
+	DiagUnknownOperation   = "unknown-operation"
+	DiagMissingComparison  = "missing-comparison"
+	DiagMissingValue       = "missing-value"
+	DiagInvalidExpression  = "invalid-expression"
+	DiagInvalidAggregate   = "invalid-aggregate"
+	DiagTypeMismatch       = "type-mismatch"
+	DiagImplicitConversion = "implicit-conversion"
+	DiagContradiction      = "contradiction"
+	DiagUnreachableBranch  = "unreachable-branch"
+	DiagNeverFires         = "never-fires"
+	DiagInvalidPhase       = "invalid-phase"
+	DiagUnknownPhase       = "unknown-phase"
+	DiagUnreachablePhase   = "unreachable-phase"
+	DiagInvalidDelay       = "invalid-delay"
+	DiagMissingMessage     = "missing-message"
+	DiagInvalidJitter      = "invalid-jitter"
+)
+
+// Diagnostic is a problem found in a strategy.
+// Path points to the node by the JSON field names, e.g. condition_set.else.op.left
+type Diagnostic struct {
+	Severity severity `json:"severity"`
+	Code     string   `json:"code"`
+	Path     string   `json:"path,omitempty"`
+	Message  string   `json:"message"`
+	// Position in the rule language source, set for syntax errors
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Path == "" {
+		return d.Message
+	}
+	return fmt.Sprintf("%s: %s", d.Path, d.Message)
+}
+
+func errorDiag(code, path, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func warningDiag(code, path, format string, args ...interface{}) Diagnostic {
+	d := errorDiag(code, path, format, args...)
+	d.Severity = SeverityWarning
+	return d
+}
+
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) HasErrors() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err joins the error diagnostics, warnings are left out.
+// Returns nil when there are no errors.
+func (d Diagnostics) Err() error {
+	var problems []string
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			problems = append(problems, diag.String())
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+var knownActions = []Action{ActionWaitNextStep, ActionWaitTime, ActionBet, ActionBetAfter, ActionStop, ActionNotify}
+
+// Validate checks the strategy before it is run:
+// - every condition is type checked against the params, the vars have to be declared in Vars
+// - operators and branches are not empty
+// - branches whose condition can never be true, or is always covered
+// by the earlier branches, are reported
+// - at least one branch can fire an action other than WAIT_TIME
+// - the phases of a phased strategy are consistent, see validatePhases
+func (s Strategy) Validate() Diagnostics {
+	// the vars declared by the strategy are numbers until they are bound
+	s = s.Bind(s.varValues())
+	var res Diagnostics
+	switch {
+	case s.Repeater() == RepeaterFENS && s.N <= 0:
+		res = append(res, errorDiag(DiagInvalidGranularity, "granularity",
+			"granularity of %s must be a positive number of steps, got %d", RepeaterFENS, s.N))
+	case s.Repeater() == RepeaterFENSec && s.N <= 0:
+		res = append(res, errorDiag(DiagInvalidGranularity, "granularity",
+			"granularity must be a positive number of seconds, got %d", s.N))
+	}
+	if s.R != "" && s.R != RepeaterFENSec && s.R != RepeaterFES && s.R != RepeaterFENS {
+		res = append(res, warningDiag(DiagUnknownRepeater, "repeater",
+			"unknown repeater %q is ignored, the strategy is evaluated every %d seconds", s.R, s.N))
+	}
+	res = append(res, s.validateJitter()...)
+	if s.Phased() {
+		return append(res, s.validatePhases()...)
+	}
+	if s.BaseConditionSet == nil {
+		return append(res, errorDiag(DiagEmptyStrategy, "condition_set", "strategy has no conditions"))
+	}
+	chain, fires := s.validateChain(s.BaseConditionSet, "condition_set")
+	res = append(res, chain...)
+	if !fires && !res.HasErrors() {
+		res = append(res, errorDiag(DiagNeverFires, "condition_set",
+			"no branch can fire an action other than %s", ActionWaitTime))
+	}
+	return res
+}
+
+// validateChain checks the branches of the Else chain starting at the path,
+// fires is true when a branch can fire an action other than WAIT_TIME
+func (s Strategy) validateChain(first *ConditionSet, path string) (res Diagnostics, fires bool) {
+	// conditions of the branches checked so far, a branch is reached
+	// only when all of them are false
+	var previous []interface{}
+	for set := first; set != nil; set, path = set.Else, path+".else" {
+		if !isKnownAction(set.Action) {
+			res = append(res, errorDiag(DiagUnknownAction, path+".action", "unknown action %q", set.Action))
+		}
+		res = append(res, s.diagnoseAction(set, path)...)
+		if set.Op.O == "" && set.Op.Left == nil && set.Op.Right == nil {
+			res = append(res, errorDiag(DiagEmptyBranch, path+".op", "branch has no condition"))
+			continue
+		}
+		branchDiags := set.Op.diagnose(path + ".op")
+		res = append(res, branchDiags...)
+		if branchDiags.HasErrors() {
+			// such a branch is never true at run time
+			continue
+		}
+		switch {
+		case neverTrue([]interface{}{set.Op}):
+			res = append(res, warningDiag(DiagContradiction, path+".op",
+				"condition can never be true"))
+		case neverTrue(append([]interface{}{set.Op}, negateAll(previous)...)):
+			res = append(res, warningDiag(DiagUnreachableBranch, path,
+				"branch is unreachable, the earlier branches are true whenever it is"))
+		default:
+			if set.Action != ActionWaitTime {
+				fires = true
+			}
+		}
+		previous = append(previous, set.Op)
+	}
+	return res, fires
+}
+
+// validatePhases checks the phases of a phased strategy: the names are unique, the transitions
+// lead to known phases and set declared vars, and every phase can be reached from the first one
+func (s Strategy) validatePhases() Diagnostics {
+	var res Diagnostics
+	if s.BaseConditionSet != nil {
+		res = append(res, errorDiag(DiagInvalidPhase, "condition_set",
+			"a phased strategy keeps its conditions in the phases"))
+	}
+	declared := map[string]bool{}
+	for i, p := range s.Phases {
+		path := fmt.Sprintf("phases[%d].name", i)
+		switch {
+		case p.Name == "":
+			res = append(res, errorDiag(DiagInvalidPhase, path, "phase has no name"))
+		case declared[p.Name]:
+			res = append(res, errorDiag(DiagInvalidPhase, path, "phase %q is declared twice", p.Name))
+		}
+		declared[p.Name] = true
+	}
+
+	fires := false
+	for i, p := range s.Phases {
+		path := fmt.Sprintf("phases[%d]", i)
+		for j, t := range p.Transitions {
+			tPath := fmt.Sprintf("%s.transitions[%d]", path, j)
+			if !declared[t.To] {
+				res = append(res, errorDiag(DiagUnknownPhase, tPath+".to", "unknown phase %q", t.To))
+			}
+			for _, name := range sortedNames(t.Set) {
+				if _, ok := s.Vars[name]; !ok {
+					res = append(res, errorDiag(DiagUnboundVar, tPath+".set",
+						"var %q is not declared in vars", name))
+				}
+			}
+			if t.Op.O == "" && t.Op.Left == nil && t.Op.Right == nil {
+				res = append(res, errorDiag(DiagEmptyBranch, tPath+".op", "transition has no condition"))
+				continue
+			}
+			res = append(res, t.Op.diagnose(tPath+".op")...)
+		}
+		chain, chainFires := s.validateChain(p.ConditionSet, path+".condition_set")
+		res = append(res, chain...)
+		fires = fires || chainFires
+	}
+
+	reached := map[string]bool{s.Phases[0].Name: true}
+	for queue := []string{s.Phases[0].Name}; len(queue) > 0; queue = queue[1:] {
+		i, _ := s.phaseIndex(queue[0])
+		for _, t := range s.Phases[i].Transitions {
+			if declared[t.To] && !reached[t.To] {
+				reached[t.To] = true
+				queue = append(queue, t.To)
+			}
+		}
+	}
+	for i, p := range s.Phases {
+		if !reached[p.Name] {
+			res = append(res, warningDiag(DiagUnreachablePhase, fmt.Sprintf("phases[%d]", i),
+				"phase %q is unreachable, no transition leads to it", p.Name))
+		}
+	}
+
+	if !fires && !res.HasErrors() {
+		res = append(res, errorDiag(DiagNeverFires, "phases",
+			"no branch of the phases can fire an action other than %s", ActionWaitTime))
+	}
+	return res
+}
+
+// diagnoseAction checks the delay of BET_AFTER and the message of NOTIFY
+func (s Strategy) diagnoseAction(set *ConditionSet, path string) Diagnostics {
+	var res Diagnostics
+	switch set.Action {
+	case ActionBetAfter:
+		switch {
+		case set.Delay <= 0:
+			res = append(res, errorDiag(DiagInvalidDelay, path+".delay",
+				"%s delay must be a positive number of seconds, got %d", ActionBetAfter, set.Delay))
+		case !s.StepBased() && set.Delay >= s.N:
+			// the next tick would come before the bet
+			res = append(res, errorDiag(DiagInvalidDelay, path+".delay",
+				"%s delay must be less than the granularity %d seconds, got %d",
+				ActionBetAfter, s.N, set.Delay))
+		}
+	case ActionNotify:
+		if strings.TrimSpace(set.Message) == "" {
+			res = append(res, errorDiag(DiagMissingMessage, path+".message", "%s needs a message", ActionNotify))
+		}
+		for _, name := range unknownMessageParams(set.Message) {
+			res = append(res, errorDiag(DiagUnknownParam, path+".message",
+				"unknown param %q in the message", name))
+		}
+	}
+	return res
+}
+
+func isKnownAction(a Action) bool {
+	for _, known := range knownActions {
+		if a == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Operator) diagnose(path string) Diagnostics {
+	switch o.O {
+	case UnOpNone:
+		return diagnoseNode(o.Left, path+".left")
+	case UnOpAnd, UnOpOr:
+		res := diagnoseNode(o.Left, path+".left")
+		return append(res, diagnoseNode(o.Right, path+".right")...)
+	}
+	return Diagnostics{errorDiag(DiagUnknownOperator, path+".o", "unknown operator %q", o.O)}
+}
+
+func diagnoseNode(node interface{}, path string) Diagnostics {
+	switch n := node.(type) {
+	case Operator:
+		return n.diagnose(path)
+	case Condition:
+		return n.diagnose(path)
+	case nil:
+		return Diagnostics{errorDiag(DiagMissingOperand, path, "operator has no operand")}
+	}
+	return Diagnostics{errorDiag(DiagMissingOperand, path, "unsupported operand %T", node)}
+}
+
+// diagnose type checks the condition, the condition is never true at run time
+// when an error is reported
+func (r Condition) diagnose(path string) Diagnostics {
+	var res Diagnostics
+	if r.M != "" && r.M != ModNot {
+		res = append(res, errorDiag(DiagUnknownModifier, path+".modifier", "unknown modifier %q", r.M))
+	}
+	leftPath := path + ".param"
+	if r.Expr != nil {
+		leftPath = path + ".expr"
+	}
+	v1, err := r.left().sample()
+	if err != nil {
+		return append(res, expressionDiag(leftPath, err))
+	}
+	t1, ok := TypeOfValue(v1)
+	if !ok {
+		return append(res, errorDiag(DiagMissingOperand, path, "condition has no param"))
+	}
+	if t1 == TypeFlag && (r.Op == OpNone || r.Val.IsEmpty) {
+		return res
+	}
+	if r.Op == OpNone {
+		return append(res, errorDiag(DiagMissingComparison, path+".op",
+			"%s is a %s and needs a comparison", r.describeLeft(), t1))
+	}
+	if _, ok := LookupOperation(string(r.Op)); !ok {
+		return append(res, errorDiag(DiagUnknownOperation, path+".op", "unknown operation %q", r.Op))
+	}
+	v2, err := r.Val.sample()
+	if err != nil {
+		return append(res, expressionDiag(path+".val", err))
+	}
+	t2, ok := TypeOfValue(v2)
+	if !ok {
+		return append(res, errorDiag(DiagMissingValue, path+".val",
+			"%s is compared with nothing", r.describeLeft()))
+	}
+	if _, err = compareValues(r.Op, v1, v2); err != nil {
+		return append(res, errorDiag(DiagTypeMismatch, path, "%s %s: %s", r.describeLeft(), r.Op, err))
+	}
+	if (t1 == TypeDuration) != (t2 == TypeDuration) {
+		res = append(res, warningDiag(DiagImplicitConversion, path,
+			"%s (%s) is compared with a %s, numbers are counted as seconds", r.describeLeft(), t1, t2))
+	}
+	return res
+}
+
+func expressionDiag(path string, err error) Diagnostic {
+	if errors.Is(err, errUnknownParam) {
+		return errorDiag(DiagUnknownParam, path, "%s", err)
+	}
+	if errors.Is(err, errUnboundVar) {
+		return errorDiag(DiagUnboundVar, path, "%s", err)
+	}
+	if errors.Is(err, errInvalidAggregate) {
+		return errorDiag(DiagInvalidAggregate, path, "%s", err)
+	}
+	return errorDiag(DiagInvalidExpression, path, "%s", err)
+}
+
+// left returns the left operand as an expression
+func (r Condition) left() Expression {
+	if r.Expr != nil {
+		return *r.Expr
+	}
+	return Expression{IsParam: true, PName: r.Param}
+}
+
+func (r Condition) describeLeft() string {
+	if r.Expr != nil {
+		return "expression"
+	}
+	return string(r.Param)
+}
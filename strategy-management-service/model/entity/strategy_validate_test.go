@@ -0,0 +1,285 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// diag is the part of a diagnostic the tests compare, the messages are checked separately
+type diag struct {
+	severity severity
+	code     string
+	path     string
+}
+
+func constant(v interface{}) Expression {
+	return Expression{IsConst: true, Value: v}
+}
+
+func compare(p paramName, op operation, v interface{}) Condition {
+	return Condition{Param: p, Op: op, Val: constant(v)}
+}
+
+func when(node interface{}) Operator {
+	return Operator{O: UnOpNone, Left: node}
+}
+
+func and(left, right interface{}) Operator {
+	return Operator{O: UnOpAnd, Left: left, Right: right}
+}
+
+func rule(op Operator, action Action) *ConditionSet {
+	return &ConditionSet{Op: op, Action: action}
+}
+
+func TestStrategyValidate(t *testing.T) {
+	priceAbove := when(compare(ParamCurrentPrice, OpGreater, 100.0))
+	tests := []struct {
+		name     string
+		strategy Strategy
+		want     []diag
+	}{
+		{
+			name:     "valid strategy",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(priceAbove, ActionBet)},
+		},
+		{
+			name: "declared var",
+			strategy: Strategy{N: 5, Vars: map[string]float64{"margin": 50}, BaseConditionSet: rule(
+				when(Condition{Param: ParamCurrentPrice, Op: OpGreater, Val: Expression{IsVar: true, VName: "margin"}}),
+				ActionBet)},
+		},
+		{
+			name:     "no seconds between the evaluations",
+			strategy: Strategy{N: 0, BaseConditionSet: rule(priceAbove, ActionBet)},
+			want:     []diag{{SeverityError, DiagInvalidGranularity, "granularity"}},
+		},
+		{
+			name:     "no steps between the evaluations",
+			strategy: Strategy{R: RepeaterFENS, N: -1, BaseConditionSet: rule(priceAbove, ActionBet)},
+			want:     []diag{{SeverityError, DiagInvalidGranularity, "granularity"}},
+		},
+		{
+			name:     "unknown repeater",
+			strategy: Strategy{R: "ForEachHour", N: 5, BaseConditionSet: rule(priceAbove, ActionBet)},
+			want:     []diag{{SeverityWarning, DiagUnknownRepeater, "repeater"}},
+		},
+		{
+			name:     "no conditions",
+			strategy: Strategy{N: 5},
+			want:     []diag{{SeverityError, DiagEmptyStrategy, "condition_set"}},
+		},
+		{
+			name:     "branch without a condition",
+			strategy: Strategy{N: 5, BaseConditionSet: &ConditionSet{Action: ActionBet}},
+			want:     []diag{{SeverityError, DiagEmptyBranch, "condition_set.op"}},
+		},
+		{
+			name:     "unknown action",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(priceAbove, "JUMP")},
+			want:     []diag{{SeverityError, DiagUnknownAction, "condition_set.action"}},
+		},
+		{
+			name: "unknown operator",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(Operator{O: "XOR",
+				Left: compare(ParamCurrentPrice, OpGreater, 100.0), Right: compare(ParamCurrentPrice, OpLess, 200.0)},
+				ActionBet)},
+			want: []diag{{SeverityError, DiagUnknownOperator, "condition_set.op.o"}},
+		},
+		{
+			name: "operator without an operand",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(Operator{O: UnOpAnd,
+				Left: compare(ParamCurrentPrice, OpGreater, 100.0)}, ActionBet)},
+			want: []diag{{SeverityError, DiagMissingOperand, "condition_set.op.right"}},
+		},
+		{
+			name: "condition without a param",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(Condition{Expr: &Expression{}, Op: OpGreater,
+				Val: constant(1)}), ActionBet)},
+			want: []diag{{SeverityError, DiagMissingOperand, "condition_set.op.left"}},
+		},
+		{
+			name: "unknown modifier",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(Condition{M: "MAYBE", Param: ParamCurrentPrice,
+				Op: OpGreater, Val: constant(100.0)}), ActionBet)},
+			want: []diag{{SeverityError, DiagUnknownModifier, "condition_set.op.left.modifier"}},
+		},
+		{
+			name:     "unknown param",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(compare("price", OpGreater, 100.0)), ActionBet)},
+			want:     []diag{{SeverityError, DiagUnknownParam, "condition_set.op.left.param"}},
+		},
+		{
+			name: "undeclared var",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(Condition{Param: ParamCurrentPrice, Op: OpGreater,
+				Val: Expression{IsVar: true, VName: "margin"}}), ActionBet)},
+			want: []diag{{SeverityError, DiagUnboundVar, "condition_set.op.left.val"}},
+		},
+		{
+			name:     "unknown operation",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(compare(ParamCurrentPrice, "=>", 100.0)), ActionBet)},
+			want:     []diag{{SeverityError, DiagUnknownOperation, "condition_set.op.left.op"}},
+		},
+		{
+			name:     "number without a comparison",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(compare(ParamCurrentPrice, OpNone, 100.0)), ActionBet)},
+			want:     []diag{{SeverityError, DiagMissingComparison, "condition_set.op.left.op"}},
+		},
+		{
+			name: "comparison without a value",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(Condition{Param: ParamCurrentPrice,
+				Op: OpGreater}), ActionBet)},
+			want: []diag{{SeverityError, DiagMissingValue, "condition_set.op.left.val"}},
+		},
+		{
+			name: "invalid expression",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(Condition{
+				Expr: &Expression{IsCalculable: true, Fn: FnAdd, Args: []Expression{
+					{IsParam: true, PName: ParamCurrentPrice}, {IsParam: true, PName: ParamTimeTillEnd}}},
+				Op: OpGreater, Val: constant(100.0)}), ActionBet)},
+			want: []diag{{SeverityError, DiagInvalidExpression, "condition_set.op.left.expr"}},
+		},
+		{
+			name: "flag compared with a number",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(compare(ParamIsOnAdditionalPurchase,
+				OpEquals, 1)), ActionBet)},
+			want: []diag{{SeverityError, DiagTypeMismatch, "condition_set.op.left"}},
+		},
+		{
+			name:     "duration compared with a number",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(compare(ParamTimeTillEnd, OpLess, 60)), ActionBet)},
+			want:     []diag{{SeverityWarning, DiagImplicitConversion, "condition_set.op.left"}},
+		},
+		{
+			name: "contradiction",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(and(compare(ParamCurrentPrice, OpGreater, 100.0),
+				compare(ParamCurrentPrice, OpLess, 50.0)), ActionBet)},
+			want: []diag{
+				{SeverityWarning, DiagContradiction, "condition_set.op"},
+				{SeverityError, DiagNeverFires, "condition_set"},
+			},
+		},
+		{
+			name: "branch covered by the earlier one",
+			strategy: Strategy{N: 5, BaseConditionSet: &ConditionSet{Op: priceAbove, Action: ActionBet,
+				Else: rule(when(compare(ParamCurrentPrice, OpGreater, 200.0)), ActionStop)}},
+			want: []diag{{SeverityWarning, DiagUnreachableBranch, "condition_set.else"}},
+		},
+		{
+			name:     "waiting only",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(priceAbove, ActionWaitTime)},
+			want:     []diag{{SeverityError, DiagNeverFires, "condition_set"}},
+		},
+		{
+			name:     "bet without a delay",
+			strategy: Strategy{N: 5, BaseConditionSet: &ConditionSet{Op: priceAbove, Action: ActionBetAfter}},
+			want:     []diag{{SeverityError, DiagInvalidDelay, "condition_set.delay"}},
+		},
+		{
+			name: "bet after the next tick",
+			strategy: Strategy{N: 5, BaseConditionSet: &ConditionSet{Op: priceAbove, Action: ActionBetAfter,
+				Delay: 5}},
+			want: []diag{{SeverityError, DiagInvalidDelay, "condition_set.delay"}},
+		},
+		{
+			name: "long delay on the steps",
+			strategy: Strategy{R: RepeaterFES, N: 1, BaseConditionSet: &ConditionSet{Op: priceAbove,
+				Action: ActionBetAfter, Delay: 30}},
+		},
+		{
+			name: "notification without a message",
+			strategy: Strategy{N: 5, BaseConditionSet: &ConditionSet{Op: priceAbove, Action: ActionNotify,
+				Message: " "}},
+			want: []diag{{SeverityError, DiagMissingMessage, "condition_set.message"}},
+		},
+		{
+			name: "unknown param in the message",
+			strategy: Strategy{N: 5, BaseConditionSet: &ConditionSet{Op: priceAbove, Action: ActionNotify,
+				Message: "price is {currentPrice}, mine is {myPrice}"}},
+			want: []diag{{SeverityError, DiagUnknownParam, "condition_set.message"}},
+		},
+		{
+			name: "negative jitter",
+			strategy: Strategy{N: 5, Jitter: &Jitter{Tick: -time.Second},
+				BaseConditionSet: rule(priceAbove, ActionBet)},
+			want: []diag{{SeverityError, DiagInvalidJitter, "jitter"}},
+		},
+		{
+			name: "bet delays swapped",
+			strategy: Strategy{N: 5, Jitter: &Jitter{BetMin: 3 * time.Second, BetMax: time.Second},
+				BaseConditionSet: rule(priceAbove, ActionBet)},
+			want: []diag{{SeverityError, DiagInvalidJitter, "jitter.bet_delay_max"}},
+		},
+		{
+			name: "tick jitter longer than the granularity",
+			strategy: Strategy{N: 5, Jitter: &Jitter{Tick: 5 * time.Second},
+				BaseConditionSet: rule(priceAbove, ActionBet)},
+			want: []diag{{SeverityError, DiagInvalidJitter, "jitter.tick"}},
+		},
+		{
+			name: "tick jitter on the steps",
+			strategy: Strategy{R: RepeaterFENS, N: 2, Jitter: &Jitter{Tick: time.Second},
+				BaseConditionSet: rule(priceAbove, ActionBet)},
+			want: []diag{{SeverityWarning, DiagInvalidJitter, "jitter.tick"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []diag
+			for _, d := range tt.strategy.Validate() {
+				got = append(got, diag{d.Severity, d.Code, d.Path})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrategyValidateMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		want     string
+	}{
+		{
+			name:     "granularity",
+			strategy: Strategy{N: -2, BaseConditionSet: rule(when(compare(ParamCurrentPrice, OpGreater, 1.0)), ActionBet)},
+			want:     "granularity: granularity must be a positive number of seconds, got -2",
+		},
+		{
+			name: "type mismatch",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(compare(ParamIsOnAdditionalPurchase,
+				OpEquals, 1)), ActionBet)},
+			want: "condition_set.op.left: isOnAdditionalPurchase ==: cannot compare flag with integer",
+		},
+		{
+			name:     "unknown param",
+			strategy: Strategy{N: 5, BaseConditionSet: rule(when(compare("price", OpGreater, 1.0)), ActionBet)},
+			want:     `condition_set.op.left.param: unknown param "price"`,
+		},
+		{
+			name: "two errors",
+			strategy: Strategy{N: 5, BaseConditionSet: &ConditionSet{Op: when(compare(ParamCurrentPrice, "=>", 1.0)),
+				Action: ActionBetAfter}},
+			want: "condition_set.delay: BET_AFTER delay must be a positive number of seconds, got 0; " +
+				`condition_set.op.left.op: unknown operation "=>"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.strategy.Validate().Err()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Err() = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsErrLeavesOutWarnings(t *testing.T) {
+	diags := Diagnostics{warningDiag(DiagImplicitConversion, "condition_set.op.left", "seconds")}
+	if diags.HasErrors() || diags.Err() != nil {
+		t.Errorf("HasErrors() = %t, Err() = %v, want no errors", diags.HasErrors(), diags.Err())
+	}
+}
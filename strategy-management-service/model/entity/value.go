@@ -0,0 +1,139 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+//VALUE TYPES
+// flag, integer, number, duration
+type valueType string
+
+const (
+	TypeFlag     valueType = "flag"
+	TypeInteger  valueType = "integer"
+	TypeNumber   valueType = "number"
+	TypeDuration valueType = "duration"
+)
+
+// TypeOfValue returns the type of a param or constant value.
+// Go int constants (e.g. Value: 0 in a strategy literal) are integers.
+func TypeOfValue(v interface{}) (valueType, bool) {
+	switch v.(type) {
+	case bool:
+		return TypeFlag, true
+	case int, int64:
+		return TypeInteger, true
+	case float64:
+		return TypeNumber, true
+	case time.Duration:
+		return TypeDuration, true
+	}
+	return "", false
+}
+
+// ConvertValue converts the value to the given type:
+//
+//	integer  -> number                      exact
+//	number   -> integer                     only whole numbers
+//	integer, number -> duration             counted in seconds
+//	duration -> integer, number             whole or fractional seconds
+//
+// Flags are never converted.
+func ConvertValue(v interface{}, to valueType) (interface{}, error) {
+	from, ok := TypeOfValue(v)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value %v (%T)", v, v)
+	}
+	if i, ok := v.(int); ok {
+		v = int64(i)
+	}
+	if from == to {
+		return v, nil
+	}
+	switch from {
+	case TypeInteger:
+		i := v.(int64)
+		switch to {
+		case TypeNumber:
+			return float64(i), nil
+		case TypeDuration:
+			return time.Duration(i) * time.Second, nil
+		}
+	case TypeNumber:
+		f := v.(float64)
+		switch to {
+		case TypeInteger:
+			if f == float64(int64(f)) {
+				return int64(f), nil
+			}
+			return nil, fmt.Errorf("%v is not a whole number", f)
+		case TypeDuration:
+			return time.Duration(f * float64(time.Second)), nil
+		}
+	case TypeDuration:
+		d := v.(time.Duration)
+		switch to {
+		case TypeInteger:
+			if d%time.Second == 0 {
+				return int64(d / time.Second), nil
+			}
+			return nil, fmt.Errorf("%s is not a whole number of seconds", d)
+		case TypeNumber:
+			return d.Seconds(), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %s to %s", from, to)
+}
+
+// commonType is the type both operands of a comparison are converted to
+func commonType(a, b valueType) (valueType, bool) {
+	switch {
+	case a == b:
+		return a, true
+	case a == TypeFlag || b == TypeFlag:
+		return "", false
+	case a == TypeDuration || b == TypeDuration:
+		return TypeDuration, true
+	}
+	return TypeNumber, true
+}
+
+// compareValues performs the operation converting both values to their common type
+func compareValues(op operation, v1, v2 interface{}) (bool, error) {
+	t1, ok1 := TypeOfValue(v1)
+	t2, ok2 := TypeOfValue(v2)
+	if !ok1 || !ok2 {
+		return false, fmt.Errorf("cannot compare %v with %v", v1, v2)
+	}
+	t, ok := commonType(t1, t2)
+	if !ok {
+		return false, fmt.Errorf("cannot compare %s with %s", t1, t2)
+	}
+	c1, err := ConvertValue(v1, t)
+	if err != nil {
+		return false, err
+	}
+	c2, err := ConvertValue(v2, t)
+	if err != nil {
+		return false, err
+	}
+	r := Condition{Op: op}
+	switch t {
+	case TypeFlag:
+		switch op {
+		case OpEquals:
+			return c1.(bool) == c2.(bool), nil
+		case OpNotEq:
+			return c1.(bool) != c2.(bool), nil
+		}
+		return false, fmt.Errorf("flags can only be compared with %s and %s", OpEquals, OpNotEq)
+	case TypeInteger:
+		return r.PerformOperationInt64(c1.(int64), c2.(int64)), nil
+	case TypeNumber:
+		return r.PerformOperationFloat64(c1.(float64), c2.(float64)), nil
+	case TypeDuration:
+		return r.PerformOperationDuration(c1.(time.Duration), c2.(time.Duration)), nil
+	}
+	return false, fmt.Errorf("cannot compare %s values", t)
+}
@@ -0,0 +1,188 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		to      valueType
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "int is an integer", v: 3, to: TypeInteger, want: int64(3)},
+		{name: "int64 to number", v: int64(3), to: TypeNumber, want: float64(3)},
+		{name: "int to number", v: 3, to: TypeNumber, want: float64(3)},
+		{name: "integer to duration", v: int64(90), to: TypeDuration, want: 90 * time.Second},
+		{name: "int to duration", v: -2, to: TypeDuration, want: -2 * time.Second},
+		{name: "whole number to integer", v: 4.0, to: TypeInteger, want: int64(4)},
+		{name: "fractional number to integer", v: 4.5, to: TypeInteger, wantErr: true},
+		{name: "number to duration", v: 1.5, to: TypeDuration, want: 1500 * time.Millisecond},
+		{name: "duration to integer", v: time.Minute, to: TypeInteger, want: int64(60)},
+		{name: "fractional duration to integer", v: 1500 * time.Millisecond, to: TypeInteger, wantErr: true},
+		{name: "duration to number", v: 1500 * time.Millisecond, to: TypeNumber, want: 1.5},
+		{name: "flag stays a flag", v: true, to: TypeFlag, want: true},
+		{name: "flag to integer", v: true, to: TypeInteger, wantErr: true},
+		{name: "integer to flag", v: int64(1), to: TypeFlag, wantErr: true},
+		{name: "unsupported value", v: "5", to: TypeNumber, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertValue(tt.v, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertValue() error = %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertValue() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionEvaluateTypedConstants(t *testing.T) {
+	state := CurrentSessionState{
+		IsOnAdditionalPurchase: true,
+		CurrentStepNumber:      3,
+		CurrentPrice:           250,
+		TimeTillEnd:            90 * time.Second,
+	}
+	tests := []struct {
+		name string
+		cond Condition
+		want bool
+	}{
+		{
+			name: "integer with int",
+			cond: Condition{Param: ParamCurrentStepNumber, Op: OpEquals, Val: Expression{IsConst: true, Value: 3}},
+			want: true,
+		},
+		{
+			name: "integer with number",
+			cond: Condition{Param: ParamCurrentStepNumber, Op: OpLess, Val: Expression{IsConst: true, Value: 3.5}},
+			want: true,
+		},
+		{
+			name: "number with int",
+			cond: Condition{Param: ParamCurrentPrice, Op: OpGreater, Val: Expression{IsConst: true, Value: 0}},
+			want: true,
+		},
+		{
+			name: "number with int64",
+			cond: Condition{Param: ParamCurrentPrice, Op: OpGreaterOrEq, Val: Expression{IsConst: true, Value: int64(250)}},
+			want: true,
+		},
+		{
+			name: "duration with duration",
+			cond: Condition{Param: ParamTimeTillEnd, Op: OpLess, Val: Expression{IsConst: true, Value: 2 * time.Minute}},
+			want: true,
+		},
+		{
+			name: "duration with seconds",
+			cond: Condition{Param: ParamTimeTillEnd, Op: OpEquals, Val: Expression{IsConst: true, Value: 90}},
+			want: true,
+		},
+		{
+			name: "duration with fractional seconds",
+			cond: Condition{Param: ParamTimeTillEnd, Op: OpGreater, Val: Expression{IsConst: true, Value: 89.5}},
+			want: true,
+		},
+		{
+			name: "flag alone",
+			cond: Condition{Param: ParamIsOnAdditionalPurchase, Op: OpNone, Val: Expression{IsEmpty: true}},
+			want: true,
+		},
+		{
+			name: "flag compared with a flag",
+			cond: Condition{Param: ParamIsOnAdditionalPurchase, Op: OpNotEq, Val: Expression{IsConst: true, Value: true}},
+			want: false,
+		},
+		{
+			name: "not flag",
+			cond: Condition{M: ModNot, Param: ParamIsOnAdditionalPurchase, Op: OpNone, Val: Expression{IsEmpty: true}},
+			want: false,
+		},
+		{
+			name: "not comparison",
+			cond: Condition{M: ModNot, Param: ParamCurrentPrice, Op: OpLess, Val: Expression{IsConst: true, Value: 100}},
+			want: true,
+		},
+		{
+			name: "flag compared with a number",
+			cond: Condition{Param: ParamIsOnAdditionalPurchase, Op: OpEquals, Val: Expression{IsConst: true, Value: 1}},
+			want: false,
+		},
+		{
+			name: "not is false when the types do not match",
+			cond: Condition{M: ModNot, Param: ParamIsOnAdditionalPurchase, Op: OpEquals,
+				Val: Expression{IsConst: true, Value: 1}},
+			want: false,
+		},
+		{
+			name: "not is false for an unknown param",
+			cond: Condition{M: ModNot, Param: "unknown", Op: OpGreater, Val: Expression{IsConst: true, Value: 1}},
+			want: false,
+		},
+		{
+			name: "greater flag",
+			cond: Condition{Param: ParamIsOnAdditionalPurchase, Op: OpGreater, Val: Expression{IsConst: true, Value: false}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.Evaluate(state); got != tt.want {
+				t.Errorf("Evaluate() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperationMirrorAndNegate(t *testing.T) {
+	values := []float64{1, 2, 3}
+	for _, op := range knownOperations {
+		for _, a := range values {
+			for _, b := range values {
+				res, _ := compareValues(op, a, b)
+				if mirrored, _ := compareValues(op.Mirror(), b, a); mirrored != res {
+					t.Errorf("%v %s %v = %t, mirrored %v %s %v = %t", a, op, b, res, b, op.Mirror(), a, mirrored)
+				}
+				if negated, _ := compareValues(op.Negate(), a, b); negated == res {
+					t.Errorf("%v %s %v = %t, negated %v %s %v = %t", a, op, b, res, a, op.Negate(), b, negated)
+				}
+			}
+		}
+	}
+}
+
+func TestExpressionJSONKeepsValueType(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "flag", value: true},
+		{name: "int", value: 3},
+		{name: "int64", value: int64(3)},
+		{name: "number", value: 3.0},
+		{name: "duration", value: 90 * time.Second},
+		{name: "negative duration", value: -1500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(Expression{IsConst: true, Value: tt.value})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var decoded Expression
+			if err = json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if decoded.Value != tt.value {
+				t.Errorf("decoded value = %v (%T), want %v (%T)", decoded.Value, decoded.Value, tt.value, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package response
+
+type StrategyCreated struct {
+	Msg string `json:"msg"`
+	Id  int64  `json:"strategy_id"`
+}
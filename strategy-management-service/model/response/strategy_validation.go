@@ -0,0 +1,8 @@
+package response
+
+import "main/model/entity"
+
+type StrategyValidation struct {
+	Valid       bool                `json:"valid"`
+	Diagnostics []entity.Diagnostic `json:"diagnostics"`
+}
@@ -0,0 +1,12 @@
+package repository
+
+import "context"
+
+// BetListener receives the notifications about the bets made in the subscribed sessions
+type BetListener interface {
+	Subscribe(ctx context.Context, sessionId int64) error
+	Unsubscribe(ctx context.Context, sessionId int64) error
+	// Bets delivers the session ID of every new bet, it is closed with the listener
+	Bets() <-chan int64
+	Close() error
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"main/model/entity"
+	"strconv"
+	"time"
+)
+
+const (
+	TagInsProposal       = "INSERT BET PROPOSAL"
+	TagGetProposal       = "GET BET PROPOSAL"
+	TagGetProposals      = "GET BET PROPOSALS"
+	TagUpdProposalStatus = "UPDATE BET PROPOSAL STATUS"
+	TagExpireProposals   = "EXPIRE BET PROPOSALS"
+	TagListenProposals   = "LISTEN BET PROPOSALS"
+)
+
+// ProposalChannelPrefix is followed by the account ID in the name of the Postgres channel
+// notified with the ID of every bet proposal made for the account
+const ProposalChannelPrefix = "account_proposals_"
+
+func ProposalChannel(accountId int64) string {
+	return ProposalChannelPrefix + strconv.FormatInt(accountId, 10)
+}
+
+type BetProposalRepository interface {
+	// InsertProposal saves the proposal and notifies the channel of its account
+	InsertProposal(ctx context.Context, proposal entity.BetProposal) (int64, error)
+	GetProposalById(ctx context.Context, id int64) (entity.BetProposal, error)
+	// GetProposals returns the proposals matching the filter, the latest first
+	GetProposals(ctx context.Context, filter entity.BetProposalFilter) ([]*entity.BetProposal, error)
+	// UpdateProposalStatus moves the proposal from the status to the new one,
+	// ErrNotFound is returned when the proposal is not in the status any more
+	UpdateProposalStatus(ctx context.Context, id int64, from entity.ProposalStatus, to entity.ProposalStatus,
+		at time.Time, message string) error
+	// ExpireProposals marks the pending proposals which have expired by the time as expired
+	ExpireProposals(ctx context.Context, at time.Time) error
+	// ListenProposals delivers the ID of every proposal made for the account from now on,
+	// the channel is closed once ctx is done
+	ListenProposals(ctx context.Context, accountId int64) (<-chan int64, error)
+}
@@ -7,17 +7,18 @@ import (
 )
 
 const (
-	TagMakeBet                   = "MAKE BET"
-	TagGetBetById                = "GET BET BY ID"
-	TagCountBetsBySessionAndUser = "COUNT BETS BY SESSION AND USER"
-	TagGetDeltaBetTimeForUser    = "GET DELTA BET TIME FOR USER"
-	TagCountSessionParticipants  = "COUNT SESSION PARTICIPANTS"
+	TagMakeBet             = "MAKE BET"
+	TagGetBetById          = "GET BET BY ID"
+	TagCountProfileBotBets = "COUNT PROFILE BOT BETS"
+	TagTryBets             = "TRY BETS"
 )
 
 type BetRepository interface {
 	MakeBet(context.Context, entity.BetData) (int64, error)
+	// TryBets returns the session and the bets as they would be made, nothing is saved
+	TryBets(ctx context.Context, trial entity.BetTrial) (entity.BetTrialResult, error)
 	GetBetBySessionId(ctx context.Context, sessionId int64) ([]*entity.Bet, error)
-	CountBetsBySessionAndUser(ctx context.Context, sessionId int64, profileID int64) (int64, error)
-	GetDeltaBetTimeForUser(ctx context.Context, sessionId int64, profileID int64) (time.Duration, error)
-	CountSessionParticipants(ctx context.Context, sessionId int64) (int64, error)
+	// CountProfileBotBets counts the bot bets made since the time for the users
+	// the runners launched by the accounts of the profile bet for
+	CountProfileBotBets(ctx context.Context, profileId int64, since time.Time) (int64, error)
 }
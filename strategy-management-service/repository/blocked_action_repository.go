@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"main/model/entity"
+)
+
+const (
+	TagInsBlockedAction  = "INSERT BLOCKED ACTION"
+	TagGetBlockedActions = "GET BLOCKED ACTIONS"
+)
+
+type BlockedActionRepository interface {
+	InsertBlockedAction(ctx context.Context, action entity.BlockedAction) (int64, error)
+	// GetBlockedActions returns the blocked actions of a runner, the latest first
+	GetBlockedActions(ctx context.Context, filter entity.BlockedActionFilter) ([]*entity.BlockedAction, error)
+}
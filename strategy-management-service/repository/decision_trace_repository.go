@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"main/model/entity"
+)
+
+const (
+	TagInsTrace  = "INSERT DECISION TRACE"
+	TagGetTraces = "GET DECISION TRACES"
+)
+
+type DecisionTraceRepository interface {
+	InsertTrace(ctx context.Context, trace entity.DecisionTrace) (int64, error)
+	// GetTraces returns the traces of a runner, the latest first
+	GetTraces(ctx context.Context, filter entity.DecisionTraceFilter) ([]*entity.DecisionTrace, error)
+}
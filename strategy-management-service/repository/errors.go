@@ -0,0 +1,10 @@
+package repository
+
+import "errors"
+
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrUnsupported is returned by the repositories which cannot perform the operation, e.g. the ones
+// on top of an API without the endpoint
+var ErrUnsupported = errors.New("operation is not supported")
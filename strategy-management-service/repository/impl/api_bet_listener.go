@@ -0,0 +1,95 @@
+package impl
+
+import (
+	"context"
+	"main/client"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"sync"
+	"time"
+)
+
+const (
+	betNotificationsBuffer = 100
+	// betStreamRetry is how long the listener waits to reconnect a broken stream of bets
+	betStreamRetry = 5 * time.Second
+)
+
+// NewApiBetListener listens to the stream of bets of the tender service and reconnects it when it breaks,
+// the bets made while it is broken are missed and the runners fall back to polling for them
+func NewApiBetListener(ctx context.Context, tender *client.TenderClient) repository.BetListener {
+	ctx, cancel := context.WithCancel(ctx)
+	l := &apiBetListener{
+		tender:   tender,
+		cancel:   cancel,
+		sessions: map[int64]bool{},
+		bets:     make(chan int64, betNotificationsBuffer),
+	}
+	go l.listen(ctx)
+	return l
+}
+
+// apiBetListener holds a single stream of all the bets, the bets of the sessions
+// which are not subscribed to are dropped
+type apiBetListener struct {
+	tender *client.TenderClient
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	sessions map[int64]bool
+	bets     chan int64
+}
+
+func (a *apiBetListener) listen(ctx context.Context) {
+	defer close(a.bets)
+	for {
+		err := a.tender.StreamBets(ctx, func(bet *entity.Bet) {
+			if !a.subscribed(bet.QuotationSessionID) {
+				return
+			}
+			select {
+			case a.bets <- bet.QuotationSessionID:
+			case <-ctx.Done():
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		logging.ErrorFormat("Stream of bets is broken, reconnecting in %s: %s", betStreamRetry, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(betStreamRetry):
+		}
+	}
+}
+
+func (a *apiBetListener) subscribed(sessionId int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sessions[sessionId]
+}
+
+func (a *apiBetListener) Subscribe(ctx context.Context, sessionId int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessions[sessionId] = true
+	return nil
+}
+
+func (a *apiBetListener) Unsubscribe(ctx context.Context, sessionId int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, sessionId)
+	return nil
+}
+
+func (a *apiBetListener) Bets() <-chan int64 {
+	return a.bets
+}
+
+func (a *apiBetListener) Close() error {
+	a.cancel()
+	return nil
+}
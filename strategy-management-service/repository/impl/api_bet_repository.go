@@ -0,0 +1,94 @@
+package impl
+
+import (
+	"context"
+	"main/client"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+	"time"
+)
+
+// NewApiBetRepository makes and reads the bets through the API of the tender service, so that the bets
+// follow its auction rules. runnerRepo finds the users the runners of a profile bet for
+func NewApiBetRepository(ctx context.Context, tender *client.TenderClient,
+	runnerRepo repository.StrategyRunnerRepository) repository.BetRepository {
+	return apiBetRepository{
+		tender:     tender,
+		runnerRepo: runnerRepo,
+	}
+}
+
+type apiBetRepository struct {
+	tender     *client.TenderClient
+	runnerRepo repository.StrategyRunnerRepository
+}
+
+func (a apiBetRepository) MakeBet(ctx context.Context, data entity.BetData) (int64, error) {
+	var id int64
+	err := utils.RunWithProfiler(repository.TagMakeBet, func() error {
+		var err error
+		id, err = a.tender.MakeBet(ctx, data)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (a apiBetRepository) TryBets(ctx context.Context, trial entity.BetTrial) (entity.BetTrialResult, error) {
+	var res entity.BetTrialResult
+	err := utils.RunWithProfiler(repository.TagTryBets, func() error {
+		var err error
+		res, err = a.tender.TryBets(ctx, trial)
+		if err != nil {
+			logging.ErrorFormat("Error trying bets in session %d: %s", trial.Session.ID, err)
+		}
+		return err
+	})
+	return res, err
+}
+
+func (a apiBetRepository) GetBetBySessionId(ctx context.Context, sessionId int64) ([]*entity.Bet, error) {
+	var res []*entity.Bet
+	err := utils.RunWithProfiler(repository.TagGetBetById, func() error {
+		var err error
+		res, err = a.tender.GetSessionBets(ctx, sessionId)
+		if err != nil {
+			logging.ErrorFormat("Error selecting bets by session id: %s", err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a apiBetRepository) CountProfileBotBets(ctx context.Context, profileId int64,
+	since time.Time) (int64, error) {
+	users, err := a.runnerRepo.GetProfileUsers(ctx, profileId)
+	if err != nil {
+		return -1, err
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+	var res int
+	err = utils.RunWithProfiler(repository.TagCountProfileBotBets, func() error {
+		bot := true
+		bets, err := a.tender.GetBets(ctx, entity.BetFilter{ProviderIds: users, Bot: &bot, Since: since})
+		if err != nil {
+			logging.ErrorFormat("Error counting bot bets of profile %d: %s", profileId, err)
+			return err
+		}
+		res = len(bets)
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	return int64(res), nil
+}
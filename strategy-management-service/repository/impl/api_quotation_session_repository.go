@@ -0,0 +1,95 @@
+package impl
+
+import (
+	"context"
+	"main/client"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+)
+
+// NewApiQuotationSessionRepository reads the sessions through the API of the tender service
+func NewApiQuotationSessionRepository(ctx context.Context,
+	tender *client.TenderClient) repository.QuotationSessionRepository {
+	return apiQuotationSessionRepository{
+		tender: tender,
+	}
+}
+
+type apiQuotationSessionRepository struct {
+	tender *client.TenderClient
+}
+
+func (a apiQuotationSessionRepository) NewQuotationSession(ctx context.Context,
+	quotationSession entity.QuotationSession) (int64, error) {
+	data := entity.QuotationSessionData{
+		Name:               quotationSession.Name,
+		CreatorId:          quotationSession.CreatorId,
+		SessionDuration:    quotationSession.SessionDuration,
+		StartPrice:         quotationSession.StartPrice,
+		SessionStepPercent: quotationSession.SessionStepPercent,
+	}
+	for _, product := range quotationSession.Products {
+		data.Products = append(data.Products, &entity.ProductJournalData{
+			ProductId: product.ProductId,
+			Count:     product.Count,
+		})
+	}
+	var id int64
+	err := utils.RunWithProfiler(repository.TagInsQS, func() error {
+		var err error
+		id, err = a.tender.NewSession(ctx, data)
+		if err != nil {
+			logging.ErrorFormat("Cannot create session: %s", err)
+		}
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (a apiQuotationSessionRepository) GetSessionsByStatus(ctx context.Context,
+	status entity.SessionStatus) ([]*entity.QuotationSession, error) {
+	var res []*entity.QuotationSession
+	err := utils.RunWithProfiler(repository.TagGetQSByStatus, func() error {
+		var err error
+		res, err = a.tender.GetSessionsByStatus(ctx, status)
+		if err != nil {
+			logging.ErrorFormat("Cannot Get session by status %s: %s", status, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a apiQuotationSessionRepository) GetSessionById(ctx context.Context,
+	sessionId int64) (entity.QuotationSession, error) {
+	var res entity.QuotationSession
+	err := utils.RunWithProfiler(repository.TagGetQSById, func() error {
+		var err error
+		res, err = a.tender.GetSession(ctx, sessionId)
+		if err != nil {
+			logging.ErrorFormat("Cannot Get session by id %d: %s", sessionId, err)
+		}
+		return err
+	})
+	return res, err
+}
+
+// UpdateQuotationSession is not available: the sessions are only changed by the bets and the tender service itself
+func (a apiQuotationSessionRepository) UpdateQuotationSession(ctx context.Context,
+	quotationSession entity.QuotationSession) error {
+	return repository.ErrUnsupported
+}
+
+// DeleteQuotationSession is not available, the tender service has no endpoint for it
+func (a apiQuotationSessionRepository) DeleteQuotationSession(ctx context.Context,
+	quotationSession entity.QuotationSession) error {
+	return repository.ErrUnsupported
+}
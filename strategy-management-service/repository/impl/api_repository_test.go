@@ -0,0 +1,172 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"main/client"
+	"main/model/entity"
+	"main/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type serviceToken struct{}
+
+func (serviceToken) Token() (string, error) {
+	return "service-token", nil
+}
+
+// tenderApi returns the client of a tender service answering with handler
+func tenderApi(t *testing.T, handler http.HandlerFunc) *client.TenderClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer service-token" {
+			t.Errorf("%s %s: Authorization = %q", r.Method, r.URL.Path, got)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return client.NewTenderClient(server.URL, serviceToken{})
+}
+
+// profileUsers is a runner repository knowing the users of the profiles only
+type profileUsers struct {
+	repository.StrategyRunnerRepository
+	users map[int64][]int64
+}
+
+func (p profileUsers) GetProfileUsers(ctx context.Context, profileId int64) ([]int64, error) {
+	return p.users[profileId], nil
+}
+
+func TestApiBetRepositoryMakeBet(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantId  int64
+		wantErr error
+	}{
+		{name: "bet made", status: http.StatusCreated, body: `{"bet_id":12}`, wantId: 12},
+		{
+			name:    "bet refused",
+			status:  http.StatusConflict,
+			body:    `{"code":409,"error_code":"price_floor","message":"cannot make bet"}`,
+			wantId:  -1,
+			wantErr: entity.ErrPriceFloor,
+		},
+		{
+			name:    "session not active",
+			status:  http.StatusConflict,
+			body:    `{"code":409,"error_code":"session_not_active","message":"cannot make bet"}`,
+			wantId:  -1,
+			wantErr: entity.ErrSessionNotActive,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tender := tenderApi(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/api/v1/bets" {
+					t.Errorf("request = %s %s", r.Method, r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+			bets := NewApiBetRepository(context.Background(), tender, profileUsers{})
+			id, err := bets.MakeBet(context.Background(), entity.BetData{QuotationSessionID: 7, ProviderId: 3,
+				MinPrice: 500})
+			if id != tt.wantId || !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("MakeBet() = %d, %v, want %d, %v", id, err, tt.wantId, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApiBetRepositoryCountProfileBotBets(t *testing.T) {
+	since := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	requests := 0
+	tender := tenderApi(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		query := r.URL.Query()
+		if got := query["provider_id"]; len(got) != 2 || got[0] != "3" || got[1] != "4" ||
+			query.Get("bot") != "true" || query.Get("since") != "2022-06-01T00:00:00Z" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		_, _ = io.WriteString(w, `[{"id":1,"provider_id":3,"bot":true},{"id":2,"provider_id":4,"bot":true}]`)
+	})
+	bets := NewApiBetRepository(context.Background(), tender, profileUsers{users: map[int64][]int64{1: {3, 4}}})
+
+	count, err := bets.CountProfileBotBets(context.Background(), 1, since)
+	if err != nil || count != 2 {
+		t.Errorf("CountProfileBotBets() = %d, %v, want 2", count, err)
+	}
+	// a profile without runners does not ask the tender service
+	count, err = bets.CountProfileBotBets(context.Background(), 2, since)
+	if err != nil || count != 0 {
+		t.Errorf("CountProfileBotBets() of a profile without runners = %d, %v, want 0", count, err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests, want 1", requests)
+	}
+}
+
+func TestApiQuotationSessionRepository(t *testing.T) {
+	tender := tenderApi(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/sessions/7":
+			_, _ = io.WriteString(w, `{"id":7,"status":"ACTIVE","last_bet_id":2,"last_bet":{"id":2,"bet_number":1}}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, `{"code":500,"message":"pg: no rows in result set"}`)
+		}
+	})
+	sessions := NewApiQuotationSessionRepository(context.Background(), tender)
+
+	session, err := sessions.GetSessionById(context.Background(), 7)
+	if err != nil || session.Status != entity.StatusActive || session.CurrentStep() != 1 {
+		t.Errorf("GetSessionById() = %+v, %v", session, err)
+	}
+	var apiErr *client.APIError
+	if _, err = sessions.GetSessionById(context.Background(), 8); !errors.As(err, &apiErr) ||
+		apiErr.Code != http.StatusInternalServerError {
+		t.Errorf("GetSessionById() of a missing session error = %v, want the error response", err)
+	}
+	if err = sessions.UpdateQuotationSession(context.Background(), session); !errors.Is(err, repository.ErrUnsupported) {
+		t.Errorf("UpdateQuotationSession() error = %v, want %v", err, repository.ErrUnsupported)
+	}
+}
+
+func TestApiBetListener(t *testing.T) {
+	subscribed := make(chan struct{})
+	tender := tenderApi(t, func(w http.ResponseWriter, r *http.Request) {
+		<-subscribed
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "event:bet\ndata:{\"id\":1,\"quotation_session_id\":8}\n\n"+
+			"event:bet\ndata:{\"id\":2,\"quotation_session_id\":7}\n\n")
+		w.(http.Flusher).Flush()
+		// the stream stays open until the listener is closed
+		<-r.Context().Done()
+	})
+	listener := NewApiBetListener(context.Background(), tender)
+	if err := listener.Subscribe(context.Background(), 7); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	close(subscribed)
+
+	select {
+	case sessionId := <-listener.Bets():
+		if sessionId != 7 {
+			t.Errorf("bet of session %d, want only the subscribed session 7", sessionId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no bet of the subscribed session")
+	}
+	if err := listener.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	for range listener.Bets() {
+		t.Errorf("bet received after the listener is closed")
+	}
+}
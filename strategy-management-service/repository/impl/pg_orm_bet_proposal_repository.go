@@ -0,0 +1,225 @@
+package impl
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+	"strconv"
+	"time"
+)
+
+func NewPgOrmBetProposalRepository(ctx context.Context, db *pg.DB) repository.BetProposalRepository {
+	return pgOrmBetProposalRepository{
+		pgOrm: db,
+	}
+}
+
+type pgOrmBetProposalRepository struct {
+	pgOrm *pg.DB
+}
+
+func (p pgOrmBetProposalRepository) InsertProposal(ctx context.Context, proposal entity.BetProposal) (int64, error) {
+	err := utils.RunWithProfiler(repository.TagInsProposal, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Insert bet proposal transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&proposal).Returning("id").Insert()
+		if err != nil {
+			logging.ErrorFormat("Cannot Insert bet proposal of runner session-%d-user-%d: %s",
+				proposal.QuotationSessionId, proposal.UserId, err.Error())
+			return err
+		}
+		_, err = tx.Exec("SELECT pg_notify(?, ?)", repository.ProposalChannel(proposal.AccountId),
+			strconv.FormatInt(proposal.ID, 10))
+		if err != nil {
+			logging.ErrorFormat("Cannot notify account %d: %s", proposal.AccountId, err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	return proposal.ID, nil
+}
+
+func (p pgOrmBetProposalRepository) GetProposalById(ctx context.Context, id int64) (entity.BetProposal, error) {
+	res := entity.BetProposal{}
+	err := utils.RunWithProfiler(repository.TagGetProposal, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get bet proposal transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.Model(&res).Where("id = ?0", id).Select()
+		if err == pg.ErrNoRows {
+			return repository.ErrNotFound
+		}
+		if err != nil {
+			logging.ErrorFormat("Error selecting bet proposal by id: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (p pgOrmBetProposalRepository) GetProposals(ctx context.Context,
+	filter entity.BetProposalFilter) ([]*entity.BetProposal, error) {
+	var res []*entity.BetProposal
+	err := utils.RunWithProfiler(repository.TagGetProposals, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get bet proposals transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		query := tx.Model(&res)
+		if filter.AccountId != 0 {
+			query = query.Where("account_id = ?", filter.AccountId)
+		}
+		if filter.QuotationSessionId != 0 {
+			query = query.Where("quotation_session_id = ?", filter.QuotationSessionId)
+		}
+		if filter.Status != "" {
+			query = query.Where("status = ?", filter.Status)
+		}
+		err = query.Order("id DESC").Limit(filter.Limit).Offset(filter.Offset).Select()
+		if err != nil {
+			logging.ErrorFormat("Error selecting bet proposals: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p pgOrmBetProposalRepository) UpdateProposalStatus(ctx context.Context, id int64,
+	from entity.ProposalStatus, to entity.ProposalStatus, at time.Time, message string) error {
+	return utils.RunWithProfiler(repository.TagUpdProposalStatus, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Update bet proposal status transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Model(&entity.BetProposal{}).
+			Set("status = ?", to).
+			Set("decided_at = ?", at).
+			Set("message = ?", message).
+			Where("id = ?", id).
+			Where("status = ?", from).Update()
+		if err != nil {
+			logging.ErrorFormat("Error updating status of bet proposal %d: %s", id, err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
+
+func (p pgOrmBetProposalRepository) ExpireProposals(ctx context.Context, at time.Time) error {
+	return utils.RunWithProfiler(repository.TagExpireProposals, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Expire bet proposals transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&entity.BetProposal{}).
+			Set("status = ?", entity.ProposalExpired).
+			Set("decided_at = expires_at").
+			Set("message = ?", "not decided in time").
+			Where("status = ?", entity.ProposalPending).
+			Where("expires_at <= ?", at).Update()
+		if err != nil {
+			logging.ErrorFormat("Error expiring bet proposals: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
+
+func (p pgOrmBetProposalRepository) ListenProposals(ctx context.Context, accountId int64) (<-chan int64, error) {
+	listener := p.pgOrm.Listen(ctx)
+	err := utils.RunWithProfiler(repository.TagListenProposals, func() error {
+		if err := listener.Listen(ctx, repository.ProposalChannel(accountId)); err != nil {
+			logging.ErrorFormat("Cannot listen to the bet proposals of account %d: %s", accountId, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	ids := make(chan int64)
+	go func() {
+		defer close(ids)
+		defer listener.Close()
+		notifications := listener.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n, ok := <-notifications:
+				if !ok {
+					return
+				}
+				id, err := strconv.ParseInt(n.Payload, 10, 64)
+				if err != nil {
+					logging.ErrorFormat("Unexpected bet proposal notification %s", n.Payload)
+					continue
+				}
+				select {
+				case ids <- id:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ids, nil
+}
@@ -0,0 +1,84 @@
+package impl
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+)
+
+func NewPgOrmBlockedActionRepository(ctx context.Context,
+	db *pg.DB) repository.BlockedActionRepository {
+	return pgOrmBlockedActionRepository{
+		pgOrm: db,
+	}
+}
+
+type pgOrmBlockedActionRepository struct {
+	pgOrm *pg.DB
+}
+
+func (p pgOrmBlockedActionRepository) InsertBlockedAction(ctx context.Context, action entity.BlockedAction) (int64, error) {
+	err := utils.RunWithProfiler(repository.TagInsBlockedAction, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Insert blocked action transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&action).Returning("id").Insert()
+		if err != nil {
+			logging.ErrorFormat("Cannot Insert blocked action of runner session-%d-user-%d: %s",
+				action.QuotationSessionId, action.UserId, err.Error())
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	return action.ID, nil
+}
+
+func (p pgOrmBlockedActionRepository) GetBlockedActions(ctx context.Context,
+	filter entity.BlockedActionFilter) ([]*entity.BlockedAction, error) {
+	var res []*entity.BlockedAction
+	err := utils.RunWithProfiler(repository.TagGetBlockedActions, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get blocked actions transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		query := tx.Model(&res).
+			Where("quotation_session_id = ?", filter.QuotationSessionId).
+			Where("user_id = ?", filter.UserId)
+		if filter.AccountId != 0 {
+			query = query.Where("account_id = ?", filter.AccountId)
+		}
+		err = query.Order("id DESC").Limit(filter.Limit).Offset(filter.Offset).Select()
+		if err != nil {
+			logging.ErrorFormat("Error selecting blocked actions: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
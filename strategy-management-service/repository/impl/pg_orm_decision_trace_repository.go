@@ -0,0 +1,84 @@
+package impl
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+)
+
+func NewPgOrmDecisionTraceRepository(ctx context.Context,
+	db *pg.DB) repository.DecisionTraceRepository {
+	return pgOrmDecisionTraceRepository{
+		pgOrm: db,
+	}
+}
+
+type pgOrmDecisionTraceRepository struct {
+	pgOrm *pg.DB
+}
+
+func (p pgOrmDecisionTraceRepository) InsertTrace(ctx context.Context, trace entity.DecisionTrace) (int64, error) {
+	err := utils.RunWithProfiler(repository.TagInsTrace, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Insert decision trace transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&trace).Returning("id").Insert()
+		if err != nil {
+			logging.ErrorFormat("Cannot Insert decision trace of runner session-%d-user-%d: %s",
+				trace.QuotationSessionId, trace.UserId, err.Error())
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	return trace.ID, nil
+}
+
+func (p pgOrmDecisionTraceRepository) GetTraces(ctx context.Context,
+	filter entity.DecisionTraceFilter) ([]*entity.DecisionTrace, error) {
+	var res []*entity.DecisionTrace
+	err := utils.RunWithProfiler(repository.TagGetTraces, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get decision traces transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		query := tx.Model(&res).
+			Where("quotation_session_id = ?", filter.QuotationSessionId).
+			Where("user_id = ?", filter.UserId)
+		if filter.AccountId != 0 {
+			query = query.Where("account_id = ?", filter.AccountId)
+		}
+		err = query.Order("id DESC").Limit(filter.Limit).Offset(filter.Offset).Select()
+		if err != nil {
+			logging.ErrorFormat("Error selecting decision traces: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,76 @@
+package impl
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+)
+
+func NewPgOrmOrgLimitsRepository(ctx context.Context, db *pg.DB) repository.OrgLimitsRepository {
+	return pgOrmOrgLimitsRepository{
+		pgOrm: db,
+	}
+}
+
+type pgOrmOrgLimitsRepository struct {
+	pgOrm *pg.DB
+}
+
+func (p pgOrmOrgLimitsRepository) GetLimits(ctx context.Context, profileId int64) (entity.OrgRiskLimits, error) {
+	res := entity.OrgRiskLimits{}
+	err := utils.RunWithProfiler(repository.TagGetOrgLimits, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get org risk limits transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.Model(&res).Where("profile_id = ?", profileId).Select()
+		if err == pg.ErrNoRows {
+			return repository.ErrNotFound
+		}
+		if err != nil {
+			logging.ErrorFormat("Error selecting risk limits of profile %d: %s", profileId, err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (p pgOrmOrgLimitsRepository) PutLimits(ctx context.Context, limits entity.OrgRiskLimits) error {
+	return utils.RunWithProfiler(repository.TagPutOrgLimits, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Put org risk limits transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&limits).
+			OnConflict("(profile_id) DO UPDATE").
+			Set("max_runners = EXCLUDED.max_runners").
+			Set("max_bets_per_day = EXCLUDED.max_bets_per_day").
+			Set("max_total_discount = EXCLUDED.max_total_discount").
+			Insert()
+		if err != nil {
+			logging.ErrorFormat("Error saving risk limits of profile %d: %s", limits.ProfileId, err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,139 @@
+package impl
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+	"time"
+)
+
+func NewPgOrmPortfolioRepository(ctx context.Context,
+	db *pg.DB) repository.PortfolioRepository {
+	return pgOrmPortfolioRepository{
+		pgOrm: db,
+	}
+}
+
+type pgOrmPortfolioRepository struct {
+	pgOrm *pg.DB
+}
+
+func (p pgOrmPortfolioRepository) InsertPortfolio(ctx context.Context, portfolio entity.Portfolio) (int64, error) {
+	err := utils.RunWithProfiler(repository.TagInsPortfolio, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Insert portfolio transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&portfolio).Returning("id").Insert()
+		if err != nil {
+			logging.ErrorFormat("Cannot Insert portfolio of user %d: %s", portfolio.UserId, err.Error())
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	return portfolio.ID, nil
+}
+
+func (p pgOrmPortfolioRepository) GetPortfolioById(ctx context.Context, id int64) (entity.Portfolio, error) {
+	res := entity.Portfolio{}
+	err := utils.RunWithProfiler(repository.TagGetPortfolio, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get portfolio transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.Model(&res).Where("id = ?0", id).Select()
+		if err == pg.ErrNoRows {
+			return repository.ErrNotFound
+		}
+		if err != nil {
+			logging.ErrorFormat("Error selecting portfolio by id: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (p pgOrmPortfolioRepository) GetPortfoliosByStatus(ctx context.Context,
+	status entity.RunnerStatus) ([]*entity.Portfolio, error) {
+	var res []*entity.Portfolio
+	err := utils.RunWithProfiler(repository.TagGetPortfolioByStatus, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get portfolios by status transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.Model(&res).Where("status = ?", status).Order("id ASC").Select()
+		if err != nil {
+			logging.ErrorFormat("Error selecting portfolios by status: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p pgOrmPortfolioRepository) UpdatePortfolioStatus(ctx context.Context, id int64,
+	status entity.RunnerStatus, at time.Time) error {
+	return utils.RunWithProfiler(repository.TagUpdPortfolioStatus, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Update portfolio status transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		query := tx.Model(&entity.Portfolio{}).
+			Set("status = ?", status).
+			Where("id = ?", id)
+		if status == entity.RunnerStopped || status == entity.RunnerFinished {
+			query = query.Set("stopped_at = ?", at)
+		}
+		res, err := query.Update()
+		if err != nil {
+			logging.ErrorFormat("Error updating status of portfolio %d: %s", id, err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package impl
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+	"strconv"
+)
+
+func NewPgOrmRunnerNotificationRepository(ctx context.Context,
+	db *pg.DB) repository.RunnerNotificationRepository {
+	return pgOrmRunnerNotificationRepository{
+		pgOrm: db,
+	}
+}
+
+type pgOrmRunnerNotificationRepository struct {
+	pgOrm *pg.DB
+}
+
+func (p pgOrmRunnerNotificationRepository) InsertNotification(ctx context.Context,
+	notification entity.RunnerNotification) (int64, error) {
+	err := utils.RunWithProfiler(repository.TagInsNotification, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Insert runner notification transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&notification).Returning("id").Insert()
+		if err != nil {
+			logging.ErrorFormat("Cannot Insert notification of runner session-%d-user-%d: %s",
+				notification.QuotationSessionId, notification.UserId, err.Error())
+			return err
+		}
+		_, err = tx.Exec("SELECT pg_notify(?, ?)", repository.NotificationChannel(notification.AccountId),
+			strconv.FormatInt(notification.ID, 10))
+		if err != nil {
+			logging.ErrorFormat("Cannot notify account %d: %s", notification.AccountId, err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	return notification.ID, nil
+}
+
+func (p pgOrmRunnerNotificationRepository) GetNotifications(ctx context.Context,
+	filter entity.RunnerNotificationFilter) ([]*entity.RunnerNotification, error) {
+	var res []*entity.RunnerNotification
+	err := utils.RunWithProfiler(repository.TagGetNotifications, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get runner notifications transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		query := tx.Model(&res)
+		if filter.AccountId != 0 {
+			query = query.Where("account_id = ?", filter.AccountId)
+		}
+		if filter.QuotationSessionId != 0 {
+			query = query.Where("quotation_session_id = ?", filter.QuotationSessionId)
+		}
+		err = query.Order("id DESC").Limit(filter.Limit).Offset(filter.Offset).Select()
+		if err != nil {
+			logging.ErrorFormat("Error selecting runner notifications: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,165 @@
+package impl
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+)
+
+func NewPgOrmStrategyRepository(ctx context.Context,
+	db *pg.DB) repository.StrategyRepository {
+	return pgOrmStrategyRepository{
+		pgOrm: db,
+	}
+}
+
+type pgOrmStrategyRepository struct {
+	pgOrm *pg.DB
+}
+
+func (p pgOrmStrategyRepository) InsertStrategy(ctx context.Context, strategy entity.CustomStrategy) (int64, error) {
+	err := utils.RunWithProfiler(repository.TagInsStr, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Insert strategy transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&strategy).Returning("id").Insert()
+		if err != nil {
+			logging.ErrorFormat("Cannot Insert strategy %s: %s", strategy.Name,
+				err.Error())
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	return strategy.ID, nil
+}
+
+func (p pgOrmStrategyRepository) UpdateStrategy(ctx context.Context, strategy entity.CustomStrategy) error {
+	err := utils.RunWithProfiler(repository.TagUpdStr, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Update strategy transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&strategy).WherePK().Update()
+		if err != nil {
+			logging.ErrorFormat("Error updating strategy %d: %s", strategy.ID, err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p pgOrmStrategyRepository) DeleteStrategy(ctx context.Context, id int64) error {
+	err := utils.RunWithProfiler(repository.TagDelStr, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Delete strategy transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Model(&entity.CustomStrategy{}).Where("id = ?0", id).Delete()
+		if err != nil {
+			logging.ErrorFormat("Error deleting strategy %d: %s", id, err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p pgOrmStrategyRepository) GetStrategyById(ctx context.Context, id int64) (*entity.CustomStrategy, error) {
+	res := &entity.CustomStrategy{}
+	err := utils.RunWithProfiler(repository.TagGetStrById, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get Strategy By Id transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.Model(res).Where("id = ?0", id).Select()
+		if err == pg.ErrNoRows {
+			return repository.ErrNotFound
+		}
+		if err != nil {
+			logging.ErrorFormat("Error selecting strategy by id: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p pgOrmStrategyRepository) GetStrategiesByOwner(ctx context.Context, ownerId int64) ([]*entity.CustomStrategy, error) {
+	var res []*entity.CustomStrategy
+	err := utils.RunWithProfiler(repository.TagGetStrsByOwner, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get Strategies By Owner transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.Model(&res).Where("owner_id = ?0", ownerId).Order("id ASC").Select()
+		if err != nil {
+			logging.ErrorFormat("Error selecting strategies by owner: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,267 @@
+package impl
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+	"time"
+)
+
+func NewPgOrmStrategyRunnerRepository(ctx context.Context,
+	db *pg.DB) repository.StrategyRunnerRepository {
+	return pgOrmStrategyRunnerRepository{
+		pgOrm: db,
+	}
+}
+
+type pgOrmStrategyRunnerRepository struct {
+	pgOrm *pg.DB
+}
+
+func (p pgOrmStrategyRunnerRepository) InsertRunner(ctx context.Context, runner entity.StrategyRunner) (int64, error) {
+	err := utils.RunWithProfiler(repository.TagInsRunner, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Insert runner transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Model(&runner).Returning("id").Insert()
+		if err != nil {
+			logging.ErrorFormat("Cannot Insert runner session-%d-user-%d: %s",
+				runner.QuotationSessionId, runner.UserId, err.Error())
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+	return runner.ID, nil
+}
+
+func (p pgOrmStrategyRunnerRepository) UpdateRunnerProgress(ctx context.Context, id int64, tick int64,
+	betsMade int64, lastStep int64, at time.Time) error {
+	return utils.RunWithProfiler(repository.TagUpdRunnerProgress, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Update runner progress transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Model(&entity.StrategyRunner{}).
+			Set("last_tick = ?", tick).
+			Set("last_tick_at = ?", at).
+			Set("bets_made = ?", betsMade).
+			Set("last_step = ?", lastStep).
+			Where("id = ?", id).Update()
+		if err != nil {
+			logging.ErrorFormat("Error updating progress of runner %d: %s", id, err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
+
+func (p pgOrmStrategyRunnerRepository) StartRunner(ctx context.Context, id int64, betsMade int64,
+	lastStep int64, at time.Time) error {
+	return utils.RunWithProfiler(repository.TagStartRunner, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Start runner transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Model(&entity.StrategyRunner{}).
+			Set("status = ?", entity.RunnerRunning).
+			Set("started_at = ?", at).
+			Set("bets_made = ?", betsMade).
+			Set("last_step = ?", lastStep).
+			Where("id = ?", id).
+			Where("status = ?", entity.RunnerScheduled).Update()
+		if err != nil {
+			logging.ErrorFormat("Error starting runner %d: %s", id, err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
+
+func (p pgOrmStrategyRunnerRepository) UpdateRunnerPhase(ctx context.Context, id int64,
+	phase entity.PhaseState) error {
+	return utils.RunWithProfiler(repository.TagUpdRunnerPhase, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Update runner phase transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Model(&entity.StrategyRunner{}).
+			Set("phase = ?", phase).
+			Where("id = ?", id).Update()
+		if err != nil {
+			logging.ErrorFormat("Error updating phase of runner %d: %s", id, err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
+
+func (p pgOrmStrategyRunnerRepository) UpdateRunnerStatus(ctx context.Context, id int64,
+	status entity.RunnerStatus, at time.Time) error {
+	return utils.RunWithProfiler(repository.TagUpdRunnerStatus, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Update runner status transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		query := tx.Model(&entity.StrategyRunner{}).
+			Set("status = ?", status).
+			Where("id = ?", id)
+		if status == entity.RunnerStopped || status == entity.RunnerFinished || status == entity.RunnerCancelled {
+			query = query.Set("stopped_at = ?", at)
+		}
+		res, err := query.Update()
+		if err != nil {
+			logging.ErrorFormat("Error updating status of runner %d: %s", id, err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
+
+func (p pgOrmStrategyRunnerRepository) FinishRunner(ctx context.Context, id int64,
+	outcome entity.RunnerOutcome, at time.Time) error {
+	return utils.RunWithProfiler(repository.TagFinishRunner, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Finish runner transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Model(&entity.StrategyRunner{}).
+			Set("status = ?", entity.RunnerFinished).
+			Set("stopped_at = ?", at).
+			Set("outcome = ?", outcome).
+			Where("id = ?", id).
+			Update()
+		if err != nil {
+			logging.ErrorFormat("Error finishing runner %d: %s", id, err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return repository.ErrNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+}
+
+func (p pgOrmStrategyRunnerRepository) GetRunnersByStatus(ctx context.Context,
+	status entity.RunnerStatus) ([]*entity.StrategyRunner, error) {
+	var res []*entity.StrategyRunner
+	err := utils.RunWithProfiler(repository.TagGetRunnerByStatus, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get runners by status transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.Model(&res).Where("status = ?", status).Order("id ASC").Select()
+		if err != nil {
+			logging.ErrorFormat("Error selecting runners by status: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p pgOrmStrategyRunnerRepository) GetProfileUsers(ctx context.Context, profileId int64) ([]int64, error) {
+	var res []int64
+	err := utils.RunWithProfiler(repository.TagGetProfileUsers, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get profile users transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		err = tx.Model((*entity.StrategyRunner)(nil)).ColumnExpr("DISTINCT user_id").
+			Where("profile_id = ?", profileId).Select(&res)
+		if err != nil {
+			logging.ErrorFormat("Error selecting users of profile %d: %s", profileId, err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"main/model/entity"
+)
+
+const (
+	TagGetOrgLimits = "GET ORG RISK LIMITS"
+	TagPutOrgLimits = "PUT ORG RISK LIMITS"
+)
+
+type OrgLimitsRepository interface {
+	// GetLimits returns the limits of the profile, ErrNotFound when none are set
+	GetLimits(ctx context.Context, profileId int64) (entity.OrgRiskLimits, error)
+	// PutLimits sets the limits of the profile
+	PutLimits(ctx context.Context, limits entity.OrgRiskLimits) error
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"main/model/entity"
+	"time"
+)
+
+const (
+	TagInsPortfolio         = "INSERT PORTFOLIO"
+	TagGetPortfolio         = "GET PORTFOLIO"
+	TagGetPortfolioByStatus = "GET PORTFOLIOS BY STATUS"
+	TagUpdPortfolioStatus   = "UPDATE PORTFOLIO STATUS"
+)
+
+type PortfolioRepository interface {
+	InsertPortfolio(ctx context.Context, portfolio entity.Portfolio) (int64, error)
+	// GetPortfolioById returns ErrNotFound when there is no such portfolio
+	GetPortfolioById(ctx context.Context, id int64) (entity.Portfolio, error)
+	GetPortfoliosByStatus(ctx context.Context, status entity.RunnerStatus) ([]*entity.Portfolio, error)
+	// UpdatePortfolioStatus changes the status, at is saved as the stop time of a stopped or finished portfolio
+	UpdatePortfolioStatus(ctx context.Context, id int64, status entity.RunnerStatus, at time.Time) error
+}
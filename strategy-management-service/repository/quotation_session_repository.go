@@ -10,6 +10,7 @@ const (
 	TagUpdQS         = "UPDATE SESSION"
 	TagDelQS         = "DELETE SESSION"
 	TagGetQSByStatus = "GET SESSION BY STATUS"
+	TagGetQSById     = "GET SESSION BY ID"
 )
 
 type QuotationSessionRepository interface {
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"main/model/entity"
+	"strconv"
+)
+
+const (
+	TagInsNotification  = "INSERT RUNNER NOTIFICATION"
+	TagGetNotifications = "GET RUNNER NOTIFICATIONS"
+)
+
+// NotificationChannelPrefix is followed by the account ID in the name of the Postgres channel
+// notified with the ID of every notification sent to the account
+const NotificationChannelPrefix = "account_notifications_"
+
+func NotificationChannel(accountId int64) string {
+	return NotificationChannelPrefix + strconv.FormatInt(accountId, 10)
+}
+
+type RunnerNotificationRepository interface {
+	// InsertNotification saves the notification and notifies the channel of its account
+	InsertNotification(ctx context.Context, notification entity.RunnerNotification) (int64, error)
+	// GetNotifications returns the notifications matching the filter, the latest first
+	GetNotifications(ctx context.Context, filter entity.RunnerNotificationFilter) ([]*entity.RunnerNotification, error)
+}
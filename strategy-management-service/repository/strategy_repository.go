@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"main/model/entity"
+)
+
+const (
+	TagInsStr         = "INSERT STRATEGY"
+	TagUpdStr         = "UPDATE STRATEGY"
+	TagDelStr         = "DELETE STRATEGY"
+	TagGetStrById     = "GET STRATEGY BY ID"
+	TagGetStrsByOwner = "GET STRATEGIES BY OWNER"
+)
+
+type StrategyRepository interface {
+	InsertStrategy(ctx context.Context, strategy entity.CustomStrategy) (int64, error)
+	UpdateStrategy(ctx context.Context, strategy entity.CustomStrategy) error
+	DeleteStrategy(ctx context.Context, id int64) error
+	GetStrategyById(ctx context.Context, id int64) (*entity.CustomStrategy, error)
+	GetStrategiesByOwner(ctx context.Context, ownerId int64) ([]*entity.CustomStrategy, error)
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"main/model/entity"
+	"time"
+)
+
+const (
+	TagInsRunner         = "INSERT RUNNER"
+	TagUpdRunnerProgress = "UPDATE RUNNER PROGRESS"
+	TagUpdRunnerStatus   = "UPDATE RUNNER STATUS"
+	TagGetRunnerByStatus = "GET RUNNERS BY STATUS"
+	TagFinishRunner      = "FINISH RUNNER"
+	TagUpdRunnerPhase    = "UPDATE RUNNER PHASE"
+	TagStartRunner       = "START RUNNER"
+	TagGetProfileUsers   = "GET PROFILE USERS"
+)
+
+type StrategyRunnerRepository interface {
+	InsertRunner(ctx context.Context, runner entity.StrategyRunner) (int64, error)
+	// UpdateRunnerProgress saves the last tick made at the given time, the number of bets made so far
+	// and the last step evaluated
+	UpdateRunnerProgress(ctx context.Context, id int64, tick int64, betsMade int64, lastStep int64, at time.Time) error
+	// StartRunner marks a scheduled runner as running since the given time with the bets made
+	// and the last step handled on the start
+	StartRunner(ctx context.Context, id int64, betsMade int64, lastStep int64, at time.Time) error
+	// UpdateRunnerStatus changes the status, at is saved as the stop time of a stopped, finished
+	// or cancelled runner
+	UpdateRunnerStatus(ctx context.Context, id int64, status entity.RunnerStatus, at time.Time) error
+	// UpdateRunnerPhase saves the current phase of a runner of a phased strategy
+	UpdateRunnerPhase(ctx context.Context, id int64, phase entity.PhaseState) error
+	// FinishRunner marks the runner as finished at the given time with the outcome of the session
+	FinishRunner(ctx context.Context, id int64, outcome entity.RunnerOutcome, at time.Time) error
+	GetRunnersByStatus(ctx context.Context, status entity.RunnerStatus) ([]*entity.StrategyRunner, error)
+	// GetProfileUsers returns the users the runners launched by the accounts of the profile bet for
+	GetProfileUsers(ctx context.Context, profileId int64) ([]int64, error)
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"fmt"
+	"main/model/entity"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Backtest runs the strategy against the recorded bets of finished sessions
+func (s *StrategyService) Backtest(params entity.BacktestParams) ([]entity.BacktestResult, error) {
+	strategy, _, err := s.resolveStrategy(params.StrategyParams)
+	if err != nil {
+		return nil, err
+	}
+	if err = strategy.Validate().Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidStrategy, err)
+	}
+	sessions, err := s.backtestSessions(params)
+	if err != nil {
+		return nil, err
+	}
+	base := entity.CurrentSessionState{
+		UserId:          params.UserId,
+		MinimalPrice:    params.MinimalPrice,
+		AcceptablePrice: params.AcceptablePrice,
+		PreferablePrice: params.PreferablePrice,
+	}
+	// the sessions are replayed in order, so the seed reproduces the jitter of each one
+	rnd := rand.New(rand.NewSource(params.Seed))
+	res := make([]entity.BacktestResult, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Status != entity.StatusFinished {
+			res = append(res, entity.BacktestResult{
+				SessionId:   session.ID,
+				SessionName: session.Name,
+				Status:      session.Status,
+				Error:       fmt.Sprintf("session is %s, only finished sessions are replayed", session.Status),
+			})
+			continue
+		}
+		bets, err := s.betRepo.GetBetBySessionId(s.ctx, session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get bets of session %d: %w", session.ID, err)
+		}
+		try := func(trial entity.BetTrial) (entity.BetTrialResult, error) {
+			return s.betRepo.TryBets(s.ctx, trial)
+		}
+		res = append(res, backtest(try, strategy, base, params.MaxBets, !params.NoInitialBet, rnd, *session, bets))
+	}
+	return res, nil
+}
+
+func (s *StrategyService) backtestSessions(params entity.BacktestParams) ([]*entity.QuotationSession, error) {
+	ids := params.SessionIds
+	if params.QuotationSessionId != 0 {
+		ids = append([]int64{params.QuotationSessionId}, ids...)
+	}
+	if len(ids) == 0 {
+		return s.quotationSessionRepo.GetSessionsByStatus(s.ctx, entity.StatusFinished)
+	}
+	sessions := make([]*entity.QuotationSession, 0, len(ids))
+	for _, id := range ids {
+		session, err := s.quotationSessionRepo.GetSessionById(s.ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get session %d: %w", id, err)
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, nil
+}
+
+// betTrier tries bets by the auction rules of the tender service
+type betTrier func(trial entity.BetTrial) (entity.BetTrialResult, error)
+
+// backtest replays the recorded bets of a finished session on a simulated clock.
+// The runner of base.UserId makes the initial bet unless initialBet is false and then decides
+// each S.N seconds like StrategyJob does, both runner and recorded bets are tried by the auction rules.
+// With a step based repeater the runner looks for new steps as often as a polling StrategyJob
+// and decides once per step due.
+// The runner bets are limited by the minimal price and maxBets as by the bet guard.
+// The jitter of the strategy is drawn from rnd.
+// After STOP the recorded bets are replayed without the runner.
+func backtest(try betTrier, strategy entity.Strategy, base entity.CurrentSessionState, maxBets int64, initialBet bool,
+	rnd entity.Random, recorded entity.QuotationSession, bets []*entity.Bet) entity.BacktestResult {
+
+	res := entity.BacktestResult{
+		SessionId:     recorded.ID,
+		SessionName:   recorded.Name,
+		RecordedPrice: recorded.CurrentPrice,
+	}
+	if recorded.LastBet != nil {
+		res.RecordedWinnerId = recorded.LastBet.ProviderId
+	}
+
+	bets = sortedBets(bets)
+	var others []*entity.Bet
+	for _, b := range bets {
+		if b.ProviderId != base.UserId {
+			others = append(others, b)
+		}
+	}
+
+	duration, err := originalDuration(try, recorded, bets)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	session := replaySession(recorded, duration)
+	var history []*entity.Bet
+	// apply tries the bets on the session and goes on with the session after them,
+	// nil once a trial has failed
+	apply := func(trial []entity.TrialBet, until time.Time) []entity.TrialBetResult {
+		if err != nil {
+			return nil
+		}
+		var tried entity.BetTrialResult
+		if tried, err = try(entity.BetTrial{Session: session, Bets: trial, Until: until}); err != nil {
+			return nil
+		}
+		session = tried.Session
+		for i := range tried.Bets {
+			if tried.Bets[i].Placed {
+				history = append(history, &tried.Bets[i].Bet)
+			}
+		}
+		return tried.Bets
+	}
+	runnerBet := func(at time.Time) {
+		if maxBets > 0 && int64(res.MyBets) >= maxBets {
+			res.BlockedBets++
+			return
+		}
+		for _, tried := range apply([]entity.TrialBet{
+			{ProviderId: base.UserId, Time: at, Bot: true, MinPrice: base.MinimalPrice},
+		}, time.Time{}) {
+			if tried.Placed {
+				res.MyBets++
+			} else if tried.ErrorCode == entity.CodePriceFloor {
+				res.BlockedBets++
+			}
+		}
+	}
+
+	interval := func() time.Duration {
+		if strategy.StepBased() {
+			return stepPolling
+		}
+		return time.Duration(strategy.N)*time.Second + strategy.Jitter.TickOffset(rnd)
+	}
+	phase := strategy.InitialPhase()
+	now := session.StartTime
+	if initialBet {
+		runnerBet(now)
+	}
+	// the step based repeaters evaluate the steps after the initial bet
+	var lastStep int64
+	if session.LastBet != nil {
+		lastStep = int64(session.LastBet.BetNumber)
+	}
+	next := 0
+	// replay makes the recorded bets up to the time, false once the session is over
+	replay := func(until time.Time) bool {
+		var trial []entity.TrialBet
+		for ; next < len(others) && !others[next].Time.After(until); next++ {
+			trial = append(trial, entity.TrialBet{ProviderId: others[next].ProviderId, Time: others[next].Time})
+		}
+		// without bets the session only changes once it is over
+		if len(trial) > 0 || session.Status == entity.StatusActive && session.EndTime().Before(until) {
+			for _, tried := range apply(trial, until) {
+				if tried.Placed {
+					res.ReplayedBets++
+				} else {
+					res.SkippedBets++
+				}
+			}
+		}
+		return err == nil && session.Status == entity.StatusActive
+	}
+	// WAIT_NEXT_STEP skips the decisions until a bet newer than awaitedStep is made
+	awaiting := false
+	var awaitedStep int64
+	for session.Status == entity.StatusActive {
+		if !replay(now) {
+			break
+		}
+		if awaiting && session.LastBet != nil && int64(session.LastBet.BetNumber) > awaitedStep {
+			awaiting = false
+		}
+		if res.Stopped || awaiting {
+			now = now.Add(interval())
+			continue
+		}
+		if strategy.StepBased() {
+			var current int64
+			if session.LastBet != nil {
+				current = int64(session.LastBet.BetNumber)
+			}
+			if !strategy.StepDue(lastStep, current) {
+				now = now.Add(interval())
+				continue
+			}
+			lastStep = current
+		}
+		state := sessionState(base, session, history, now)
+		decision := strategy.Decide(phase, state)
+		phase = decision.Phase
+		switch decision.Action {
+		case entity.ActionBet, entity.ActionBetAfter:
+			delay := decision.Delay + strategy.Jitter.BetDelay(rnd, state.TimeTillEnd-decision.Delay)
+			if at := now.Add(delay); replay(at) {
+				runnerBet(at)
+			}
+		case entity.ActionNotify:
+			res.Notifications++
+		case entity.ActionStop:
+			res.Stopped = true
+		case entity.ActionWaitNextStep:
+			awaiting = true
+			awaitedStep = state.CurrentStepNumber
+		}
+		now = now.Add(interval())
+	}
+	res.SkippedBets += len(others) - next
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	res.FinalPrice = session.CurrentPrice
+	res.Status = session.Status
+	res.EndTime = session.EndTime()
+	if session.LastBet != nil {
+		res.WinnerId = session.LastBet.ProviderId
+		res.Won = res.WinnerId == base.UserId
+	}
+	return res
+}
+
+// replaySession returns the session as it was before the first bet
+func replaySession(recorded entity.QuotationSession, duration int) entity.QuotationSession {
+	session := recorded
+	session.Status = entity.StatusActive
+	session.CurrentPrice = session.StartPrice
+	session.SessionDuration = duration
+	session.IsInAdditionalPurchase = false
+	session.LastBetId = 0
+	session.LastBet = nil
+	return session
+}
+
+// originalDuration finds the session duration before the additional purchase
+// prolonged it, i.e. the shortest one giving the recorded duration after the recorded bets.
+// Each prolongation adds the additional purchase window, so the durations are tried
+// down from the recorded one by the window.
+func originalDuration(try betTrier, recorded entity.QuotationSession, bets []*entity.Bet) (int, error) {
+	trial := make([]entity.TrialBet, 0, len(bets))
+	for _, b := range bets {
+		trial = append(trial, entity.TrialBet{ProviderId: b.ProviderId, Time: b.Time})
+	}
+	window := int(entity.AdditionalPurchaseDuration / time.Minute)
+	duration := recorded.SessionDuration
+	for shorter := duration - window; shorter > 0; shorter -= window {
+		res, err := try(entity.BetTrial{Session: replaySession(recorded, shorter), Bets: trial})
+		if err != nil {
+			return 0, err
+		}
+		if res.Session.SessionDuration != recorded.SessionDuration {
+			break
+		}
+		duration = shorter
+	}
+	return duration, nil
+}
+
+func sortedBets(bets []*entity.Bet) []*entity.Bet {
+	res := append([]*entity.Bet(nil), bets...)
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Time.Equal(res[j].Time) {
+			return res[i].BetNumber < res[j].BetNumber
+		}
+		return res[i].Time.Before(res[j].Time)
+	})
+	return res
+}
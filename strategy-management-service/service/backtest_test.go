@@ -0,0 +1,172 @@
+package service
+
+import (
+	"main/dsl"
+	"main/model/entity"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// recordedSession is a finished session of 10% steps from 1000 with the bets of the providers at the minutes
+func recordedSession(duration int, bets ...recordedBet) (entity.QuotationSession, []*entity.Bet) {
+	session := entity.QuotationSession{
+		ID:                 7,
+		Status:             entity.StatusFinished,
+		StartPrice:         1000,
+		SessionStepPercent: 10,
+		StartTime:          testStart,
+		SessionDuration:    duration,
+	}
+	history := make([]*entity.Bet, 0, len(bets))
+	for i, b := range bets {
+		history = append(history, &entity.Bet{
+			ID:                 int64(i + 1),
+			QuotationSessionID: session.ID,
+			ProviderId:         b.providerId,
+			Time:               testStart.Add(b.minute * time.Minute),
+			NewPrice:           1000 - float64(i+1)*100,
+			BetNumber:          i,
+		})
+	}
+	session.CurrentPrice = 1000 - float64(len(bets))*100
+	if len(history) > 0 {
+		session.LastBet = history[len(history)-1]
+	}
+	return session, history
+}
+
+type recordedBet struct {
+	providerId int64
+	minute     time.Duration
+}
+
+// plainHistory is a session of 60 minutes without additional purchase
+func plainHistory() (entity.QuotationSession, []*entity.Bet) {
+	return recordedSession(60, recordedBet{1, 10}, recordedBet{2, 20}, recordedBet{1, 30})
+}
+
+// prolongedHistory is a session of 60 minutes prolonged twice by the additional purchase
+func prolongedHistory() (entity.QuotationSession, []*entity.Bet) {
+	return recordedSession(70, recordedBet{1, 57}, recordedBet{2, 63})
+}
+
+// rules tries the bets like the tender service
+func rules(trial entity.BetTrial) (entity.BetTrialResult, error) {
+	return tenderRules(trial), nil
+}
+
+func parsedStrategy(t *testing.T, src string) entity.Strategy {
+	t.Helper()
+	strategy, err := dsl.ParseStrategy(src)
+	if err != nil {
+		t.Fatalf("ParseStrategy() error = %v", err)
+	}
+	return strategy
+}
+
+func TestOriginalDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		history func() (entity.QuotationSession, []*entity.Bet)
+		want    int
+	}{
+		{name: "without additional purchase", history: plainHistory, want: 60},
+		{name: "prolonged by the additional purchase", history: prolongedHistory, want: 60},
+		{name: "without bets", history: func() (entity.QuotationSession, []*entity.Bet) {
+			return recordedSession(60)
+		}, want: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, bets := tt.history()
+			got, err := originalDuration(rules, session, bets)
+			if err != nil || got != tt.want {
+				t.Errorf("originalDuration() = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	const outbid = "EVERY 60s\nIF currentWinnerId != myId THEN BET\nELSE WAIT_TIME"
+	tests := []struct {
+		name       string
+		strategy   string
+		history    func() (entity.QuotationSession, []*entity.Bet)
+		maxBets    int64
+		initialBet bool
+		want       entity.BacktestResult
+	}{
+		{
+			name:       "initial bet and outbidding",
+			strategy:   outbid,
+			history:    plainHistory,
+			initialBet: true,
+			want: entity.BacktestResult{FinalPrice: 300, WinnerId: 5, Won: true, MyBets: 4, ReplayedBets: 3,
+				EndTime: testStart.Add(time.Hour)},
+		},
+		{
+			name:     "outbidding without the initial bet",
+			strategy: "EVERY 60s\nIF currentWinnerId != myId AND currentWinnerId != 0 THEN BET\nELSE WAIT_TIME",
+			history:  plainHistory,
+			want: entity.BacktestResult{FinalPrice: 400, WinnerId: 5, Won: true, MyBets: 3, ReplayedBets: 3,
+				EndTime: testStart.Add(time.Hour)},
+		},
+		{
+			// the runner keeps deciding to bet each minute from the bet of provider 2 until the end
+			name:       "max bets made",
+			strategy:   outbid,
+			history:    plainHistory,
+			maxBets:    2,
+			initialBet: true,
+			want: entity.BacktestResult{FinalPrice: 500, WinnerId: 1, MyBets: 2, ReplayedBets: 3, BlockedBets: 41,
+				EndTime: testStart.Add(time.Hour)},
+		},
+		{
+			name:       "stopped by the strategy",
+			strategy:   "EVERY 60s\nIF currentWinnerId != myId THEN STOP\nELSE WAIT_TIME",
+			history:    plainHistory,
+			initialBet: true,
+			want: entity.BacktestResult{FinalPrice: 600, WinnerId: 1, MyBets: 1, ReplayedBets: 3, Stopped: true,
+				EndTime: testStart.Add(time.Hour)},
+		},
+		{
+			name:       "prolonged by the additional purchase",
+			strategy:   outbid,
+			history:    prolongedHistory,
+			initialBet: true,
+			want: entity.BacktestResult{FinalPrice: 500, WinnerId: 5, Won: true, MyBets: 3, ReplayedBets: 2,
+				EndTime: testStart.Add(70 * time.Minute)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorded, bets := tt.history()
+			base := entity.CurrentSessionState{UserId: 5}
+			got := backtest(rules, parsedStrategy(t, tt.strategy), base, tt.maxBets, tt.initialBet,
+				rand.New(rand.NewSource(1)), recorded, bets)
+
+			want := tt.want
+			want.SessionId = recorded.ID
+			want.RecordedPrice = recorded.CurrentPrice
+			want.RecordedWinnerId = recorded.LastBet.ProviderId
+			want.Status = entity.StatusFinished
+			if got != want {
+				t.Errorf("backtest() = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestBacktestTrialError(t *testing.T) {
+	recorded, bets := plainHistory()
+	failing := func(trial entity.BetTrial) (entity.BetTrialResult, error) {
+		return entity.BetTrialResult{}, entity.ErrSessionNotActive
+	}
+	got := backtest(failing, parsedStrategy(t, "EVERY 60s\nIF currentWinnerId != myId THEN BET\nELSE WAIT_TIME"),
+		entity.CurrentSessionState{UserId: 5}, 0, true, rand.New(rand.NewSource(1)), recorded, bets)
+	if got.Error == "" || got.MyBets != 0 {
+		t.Errorf("backtest() = %+v, want the trial error", got)
+	}
+}
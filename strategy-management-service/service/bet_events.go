@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"sync"
+	"time"
+)
+
+// betEventsFallback is how often a runner whose strategy does not depend on time
+// is evaluated without a bet notification, in case a notification is lost
+const betEventsFallback = 30 * time.Second
+
+// betEvents subscribes to the bets of the sessions with registered runners
+// and wakes the runners of the session on every new bet
+type betEvents struct {
+	mu       sync.Mutex
+	listener repository.BetListener
+	// number of the registered runners by session
+	sessions map[int64]int
+}
+
+func newBetEvents() *betEvents {
+	return &betEvents{
+		sessions: map[int64]int{},
+	}
+}
+
+// start subscribes to the sessions watched so far and wakes the runners of the notified sessions
+// until the listener is closed
+func (e *betEvents) start(listener repository.BetListener, runners *runnerRegistry) {
+	e.mu.Lock()
+	e.listener = listener
+	for sessionId := range e.sessions {
+		e.subscribe(sessionId)
+	}
+	e.mu.Unlock()
+
+	go func() {
+		for sessionId := range listener.Bets() {
+			for _, job := range runners.list(entity.RunnerFilter{QuotationSessionId: sessionId}) {
+				job.wake()
+			}
+		}
+		logging.Info("Bet listener is closed, runners fall back to polling")
+		e.mu.Lock()
+		e.listener = nil
+		e.mu.Unlock()
+	}()
+}
+
+// listening reports whether the runners are woken by the notifications
+func (e *betEvents) listening() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.listener != nil
+}
+
+func (e *betEvents) watch(sessionId int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sessions[sessionId]++
+	if e.sessions[sessionId] == 1 {
+		e.subscribe(sessionId)
+	}
+}
+
+func (e *betEvents) unwatch(sessionId int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sessions[sessionId]--
+	if e.sessions[sessionId] > 0 {
+		return
+	}
+	delete(e.sessions, sessionId)
+	if e.listener != nil {
+		if err := e.listener.Unsubscribe(context.Background(), sessionId); err != nil {
+			logging.ErrorFormat("Cannot unsubscribe from the bets of session %d: %s", sessionId, err)
+		}
+	}
+}
+
+func (e *betEvents) subscribe(sessionId int64) {
+	if e.listener == nil {
+		return
+	}
+	// the runners still poll when the subscription fails
+	if err := e.listener.Subscribe(context.Background(), sessionId); err != nil {
+		logging.ErrorFormat("Cannot subscribe to the bets of session %d: %s", sessionId, err)
+	}
+}
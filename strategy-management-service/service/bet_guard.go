@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"main/logging"
+	"main/model/entity"
+)
+
+// ErrBetBlocked is returned for the bets refused by the guard, the refusals are saved as blocked actions
+var ErrBetBlocked = errors.New("bet is blocked")
+
+// bet makes a bot bet for the runner unless the bet breaks the limits of the runner:
+// the price it results in is below the minimal price or the max number of bets is reached.
+// Nothing is made when the session is over
+func (j *StrategyJob) bet(tick int64, session *entity.QuotationSession) error {
+	s := j.ParentService
+	if j.portfolio != nil {
+		// the other runners of the portfolio cannot take the capacity meanwhile
+		j.portfolio.betMu.Lock()
+		defer j.portfolio.betMu.Unlock()
+	}
+	_, checked, blocked, err := j.checkBet(tick, session)
+	if err != nil || checked == nil {
+		return err
+	}
+
+	err = s.MakeBet(j.QuotationSessionId, j.UserId, j.MinimalPrice)
+	if errors.Is(err, entity.ErrPriceFloor) {
+		// the price has changed since the check
+		blocked.Reason = entity.BlockPriceFloor
+		blocked.Message = err.Error()
+		return s.block(blocked)
+	}
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.betsMade++
+	j.mu.Unlock()
+	return nil
+}
+
+// checkBet tries the bet on the session by the auction rules of the tender service, the session is read
+// now when nil. The bets breaking the limits of the runner are blocked. It returns the session, the bet
+// as it would be made, nil when the session is over, and the blocked action to save when the bet
+// is refused later
+func (j *StrategyJob) checkBet(tick int64, current *entity.QuotationSession) (entity.QuotationSession, *entity.Bet,
+	entity.BlockedAction, error) {
+	s := j.ParentService
+	if current == nil {
+		session, err := s.quotationSessionRepo.GetSessionById(s.ctx, j.QuotationSessionId)
+		if err != nil {
+			return session, nil, entity.BlockedAction{}, err
+		}
+		current = &session
+	}
+	session := *current
+	betsMade := j.countBets()
+	blocked := entity.BlockedAction{
+		QuotationSessionId: j.QuotationSessionId,
+		UserId:             j.UserId,
+		AccountId:          j.AccountId,
+		RunnerId:           j.Info().RunnerId,
+		Tick:               tick,
+		Time:               s.clock.Now(),
+		Action:             entity.ActionBet,
+		MinimalPrice:       j.MinimalPrice,
+		BetsMade:           betsMade,
+		MaxBets:            j.maxBets,
+	}
+	// the bet is only tried on a copy of the session to get the new price
+	trial, err := s.betRepo.TryBets(s.ctx, entity.BetTrial{
+		Session: session,
+		Bets:    []entity.TrialBet{{ProviderId: j.UserId, Time: blocked.Time, Bot: true}},
+	})
+	if err != nil {
+		return session, nil, blocked, err
+	}
+	if len(trial.Bets) != 1 {
+		return session, nil, blocked, fmt.Errorf("bet trial returned %d bets instead of 1", len(trial.Bets))
+	}
+	if err = entity.BetError(trial.Bets[0].ErrorCode); err != nil {
+		return session, nil, blocked, err
+	}
+	placed, bet := trial.Bets[0].Placed, trial.Bets[0].Bet
+	if placed {
+		blocked.Price = bet.NewPrice
+	}
+	switch {
+	case j.maxBets > 0 && betsMade >= j.maxBets:
+		blocked.Reason = entity.BlockMaxBets
+		blocked.Message = fmt.Sprintf("runner has made %d bets of %d", betsMade, j.maxBets)
+		return session, nil, blocked, s.block(blocked)
+	case placed && bet.NewPrice < j.MinimalPrice:
+		blocked.Reason = entity.BlockPriceFloor
+		blocked.Message = fmt.Sprintf("bet would make the price %.2f, below the minimal price %.2f",
+			bet.NewPrice, j.MinimalPrice)
+		return session, nil, blocked, s.block(blocked)
+	}
+	if !placed {
+		return session, nil, blocked, nil
+	}
+	limited, err := s.checkOrgBet(j, session, bet.NewPrice, &blocked)
+	if err != nil {
+		return session, nil, blocked, err
+	}
+	if limited {
+		return session, nil, blocked, s.block(blocked)
+	}
+	withdrawn, err := s.checkPortfolioBet(j, &blocked)
+	if err != nil {
+		return session, nil, blocked, err
+	}
+	if withdrawn {
+		return session, nil, blocked, s.block(blocked)
+	}
+	return session, &bet, blocked, nil
+}
+
+func (j *StrategyJob) countBets() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.betsMade
+}
+
+// block saves the blocked action and returns the error describing it
+func (s *StrategyService) block(action entity.BlockedAction) error {
+	logging.InfoFormat("Runner session-%d-user-%d: %s blocked, %s", action.QuotationSessionId,
+		action.UserId, action.Action, action.Message)
+	if _, err := s.blockedRepo.InsertBlockedAction(s.ctx, action); err != nil {
+		logging.ErrorFormat("Cannot save blocked action of runner session-%d-user-%d: %s",
+			action.QuotationSessionId, action.UserId, err)
+	}
+	return fmt.Errorf("%w: %s", ErrBetBlocked, action.Message)
+}
+
+// GetBlockedActions returns the actions of a runner refused by the bet guard, the latest first
+func (s *StrategyService) GetBlockedActions(filter entity.BlockedActionFilter) ([]*entity.BlockedAction, error) {
+	return s.blockedRepo.GetBlockedActions(s.ctx, filter)
+}
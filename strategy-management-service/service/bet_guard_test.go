@@ -0,0 +1,235 @@
+package service
+
+import (
+	"errors"
+	"main/model/entity"
+	"main/utils"
+	"testing"
+	"time"
+)
+
+// guardedSession is led by user 9, a bet of another user makes the price 700
+func guardedSession() entity.QuotationSession {
+	return entity.QuotationSession{
+		ID:                 1,
+		Status:             entity.StatusActive,
+		StartPrice:         1000,
+		CurrentPrice:       800,
+		SessionStepPercent: 10,
+		StartTime:          testStart.Add(-10 * time.Minute),
+		SessionDuration:    60,
+		LastBet:            &entity.Bet{ProviderId: 9},
+		Products:           []*entity.ProductJournal{{Count: 1}},
+	}
+}
+
+// withPortfolio binds the job to a portfolio of one product unit, which user 5 already takes by leading session 2
+func withPortfolio(s *StrategyService, job *StrategyJob) {
+	run := &portfolioRun{
+		Portfolio: entity.Portfolio{ID: 3, UserId: 5, Params: entity.PortfolioParams{
+			UserId:    5,
+			MaxVolume: 1,
+			Sessions: []entity.StrategyParams{
+				{QuotationSessionId: 1, MinimalPrice: 100},
+				{QuotationSessionId: 2, MinimalPrice: 100},
+			},
+		}},
+		quit:    make(chan bool),
+		pending: map[int64]bool{},
+	}
+	led := guardedSession()
+	led.ID = 2
+	led.LastBet = &entity.Bet{ProviderId: 5}
+	s.quotationSessionRepo.(*memorySessions).put(led)
+	other := testJob(s, 2, 5, 1)
+	for _, j := range []*StrategyJob{job, other} {
+		j.PortfolioId = run.ID
+		j.portfolio = run
+		_ = s.runners.add(j)
+	}
+	s.portfolios.add(run)
+}
+
+func TestBetGuard(t *testing.T) {
+	tests := []struct {
+		name         string
+		session      func(*entity.QuotationSession)
+		minimalPrice float64
+		maxBets      int64
+		betsMade     int64
+		limits       entity.OrgRiskLimits
+		botBets      int64
+		// the tender service refuses the bet after the check
+		refuse    error
+		portfolio bool
+		wantErr   error
+		// reason of the blocked action saved, none when empty
+		wantReason string
+		wantBet    bool
+	}{
+		{name: "bet made", minimalPrice: 500, wantBet: true},
+		{name: "bet to the minimal price", minimalPrice: 700, wantBet: true},
+		{
+			name:         "below the minimal price",
+			minimalPrice: 750,
+			wantErr:      ErrBetBlocked,
+			wantReason:   entity.BlockPriceFloor,
+		},
+		{
+			name:       "max bets made",
+			maxBets:    2,
+			betsMade:   2,
+			wantErr:    ErrBetBlocked,
+			wantReason: entity.BlockMaxBets,
+		},
+		{name: "bets left", maxBets: 3, betsMade: 2, wantBet: true},
+		{
+			name:       "bets of the organisation made today",
+			limits:     entity.OrgRiskLimits{ProfileId: 1, MaxBetsPerDay: 3},
+			botBets:    3,
+			wantErr:    ErrBetBlocked,
+			wantReason: entity.BlockOrgBetsPerDay,
+		},
+		{
+			name:       "discount of the organisation",
+			limits:     entity.OrgRiskLimits{ProfileId: 1, MaxTotalDiscount: 250},
+			wantErr:    ErrBetBlocked,
+			wantReason: entity.BlockOrgDiscount,
+		},
+		{
+			name:       "capacity of the portfolio taken",
+			portfolio:  true,
+			wantErr:    ErrBetBlocked,
+			wantReason: entity.BlockPortfolioWithdrawn,
+		},
+		{
+			name:         "price changed since the check",
+			minimalPrice: 500,
+			refuse:       entity.ErrPriceFloor,
+			wantErr:      ErrBetBlocked,
+			wantReason:   entity.BlockPriceFloor,
+		},
+		{
+			name:    "repeated bet",
+			session: func(s *entity.QuotationSession) { s.LastBet = &entity.Bet{ProviderId: 5} },
+			wantErr: entity.ErrRepeatedBet,
+		},
+		{
+			name:    "session not active",
+			session: func(s *entity.QuotationSession) { s.Status = entity.StatusFinished },
+			wantErr: entity.ErrSessionNotActive,
+		},
+		{
+			name:    "session over",
+			session: func(s *entity.QuotationSession) { s.StartTime = testStart.Add(-2 * time.Hour) },
+			wantErr: entity.ErrSessionNotActive,
+		},
+		{
+			name: "additional purchase over",
+			session: func(s *entity.QuotationSession) {
+				s.StartTime = testStart.Add(-time.Hour)
+				s.IsInAdditionalPurchase = true
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := guardedSession()
+			if tt.session != nil {
+				tt.session(&session)
+			}
+			bets := &memoryBets{botBets: map[int64]int64{1: tt.botBets}, refuse: tt.refuse}
+			blocked := &memoryBlocked{}
+			s := testService(testRepos{
+				sessions: newMemorySessions(session),
+				bets:     bets,
+				blocked:  blocked,
+				limits:   newMemoryLimits(tt.limits),
+			}, utils.NewFakeClock(testStart))
+			job := testJob(s, session.ID, 5, 1)
+			job.MinimalPrice = tt.minimalPrice
+			job.maxBets = tt.maxBets
+			job.betsMade = tt.betsMade
+			if tt.portfolio {
+				withPortfolio(s, job)
+			} else {
+				_ = s.runners.add(job)
+			}
+
+			err := job.bet(4, &session)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("bet() error = %v, want %v", err, tt.wantErr)
+			}
+			made := bets.madeBets()
+			if tt.wantBet {
+				want := entity.BetData{QuotationSessionID: 1, ProviderId: 5, Bot: true, MinPrice: tt.minimalPrice}
+				if len(made) != 1 || made[0] != want {
+					t.Errorf("bets made = %+v, want %+v", made, want)
+				}
+				if job.countBets() != tt.betsMade+1 {
+					t.Errorf("runner has made %d bets, want %d", job.countBets(), tt.betsMade+1)
+				}
+			} else {
+				if len(made) != 0 {
+					t.Errorf("bets made = %+v, want none", made)
+				}
+				if job.countBets() != tt.betsMade {
+					t.Errorf("runner has made %d bets, want %d", job.countBets(), tt.betsMade)
+				}
+			}
+
+			actions := blocked.saved()
+			if tt.wantReason == "" {
+				if len(actions) != 0 {
+					t.Errorf("blocked actions = %+v, want none", actions)
+				}
+				return
+			}
+			if len(actions) != 1 {
+				t.Fatalf("blocked actions = %+v, want one", actions)
+			}
+			action := actions[0]
+			if string(action.Reason) != tt.wantReason || action.Action != entity.ActionBet || action.Tick != 4 ||
+				action.QuotationSessionId != 1 || action.UserId != 5 || !action.Time.Equal(testStart) ||
+				action.MinimalPrice != tt.minimalPrice || action.BetsMade != tt.betsMade ||
+				action.MaxBets != tt.maxBets || action.Message == "" {
+				t.Errorf("blocked action = %+v, want reason %s", action, tt.wantReason)
+			}
+			if action.Price != 700 {
+				t.Errorf("blocked action price = %.2f, want the price of the bet 700", action.Price)
+			}
+		})
+	}
+}
+
+func TestCheckBetReadsSession(t *testing.T) {
+	sessions := newMemorySessions(guardedSession())
+	bets := &memoryBets{}
+	s := testService(testRepos{sessions: sessions, bets: bets, limits: newMemoryLimits()},
+		utils.NewFakeClock(testStart))
+	job := testJob(s, 1, 5, 1)
+
+	session, bet, _, err := job.checkBet(2, nil)
+	if err != nil {
+		t.Fatalf("checkBet() error = %v", err)
+	}
+	if sessions.reads != 1 || session.ID != 1 {
+		t.Errorf("session %d read %d times, want session 1 read once", session.ID, sessions.reads)
+	}
+	if bet == nil || bet.NewPrice != 700 {
+		t.Errorf("checkBet() bet = %+v, want the price 700", bet)
+	}
+	if made := bets.madeBets(); len(made) != 0 {
+		t.Errorf("bets made by the check = %+v", made)
+	}
+
+	// the session of the tick is not read again
+	current := guardedSession()
+	current.CurrentPrice = 900
+	if _, bet, _, err = job.checkBet(3, &current); err != nil || bet == nil || bet.NewPrice != 800 {
+		t.Errorf("checkBet() on the session of the tick = %+v, %v, want the price 800", bet, err)
+	}
+	if sessions.reads != 1 {
+		t.Errorf("session read %d times, want once", sessions.reads)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"time"
+)
+
+// defaultProposalTTL is how long a bet proposal waits for the approval when the runner params do not set it
+const defaultProposalTTL = time.Minute
+
+var (
+	ErrProposalAccessDenied = errors.New("bet proposal belongs to another account")
+	ErrProposalDecided      = errors.New("bet proposal is already decided")
+	ErrProposalStale        = errors.New("bet proposal is stale")
+	ErrInvalidDecision      = errors.New("bet proposal can only be APPROVED or REJECTED")
+)
+
+// placeBet makes the bet of an automatic runner and proposes it for a semi-automatic one,
+// the bet is checked on the session, read now when nil
+func (j *StrategyJob) placeBet(tick int64, session *entity.QuotationSession) error {
+	if j.semiAutomatic {
+		return j.propose(tick, session)
+	}
+	return j.bet(tick, session)
+}
+
+// propose asks the account which launched the runner to approve the bet instead of making it.
+// The runner proposes once per step: while its proposal of the current step waits for the approval
+// no new one is made, the pending proposal of an older step is expired
+func (j *StrategyJob) propose(tick int64, current *entity.QuotationSession) error {
+	s := j.ParentService
+	session, bet, _, err := j.checkBet(tick, current)
+	if err != nil || bet == nil {
+		return err
+	}
+	now := s.clock.Now()
+	if p := j.proposal; p != nil {
+		if p.Step == session.CurrentStep() && now.Before(p.ExpiresAt) {
+			logging.DebugFormat("Runner session-%d-user-%d: bet proposal %d is still pending",
+				j.QuotationSessionId, j.UserId, p.ID)
+			return nil
+		}
+		if p.Step != session.CurrentStep() {
+			// an error only means the proposal is decided already
+			_ = s.proposalRepo.UpdateProposalStatus(s.ctx, p.ID, entity.ProposalPending, entity.ProposalExpired,
+				now, "the session has moved on")
+		}
+	}
+	proposal := entity.BetProposal{
+		QuotationSessionId: j.QuotationSessionId,
+		UserId:             j.UserId,
+		AccountId:          j.AccountId,
+		RunnerId:           j.Info().RunnerId,
+		Tick:               tick,
+		Step:               session.CurrentStep(),
+		CurrentPrice:       session.CurrentPrice,
+		NewPrice:           bet.NewPrice,
+		Status:             entity.ProposalPending,
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(j.proposalTTL),
+	}
+	proposal.ID, err = s.proposalRepo.InsertProposal(s.ctx, proposal)
+	if err != nil {
+		return err
+	}
+	logging.InfoFormat("Runner session-%d-user-%d proposes bet %d: %.2f -> %.2f until %s", j.QuotationSessionId,
+		j.UserId, proposal.ID, proposal.CurrentPrice, proposal.NewPrice, proposal.ExpiresAt.Format(time.RFC3339))
+	j.proposal = &proposal
+	return nil
+}
+
+// GetProposals returns the bet proposals of the semi-automatic runners, the latest first
+func (s *StrategyService) GetProposals(filter entity.BetProposalFilter) ([]*entity.BetProposal, error) {
+	if err := s.proposalRepo.ExpireProposals(s.ctx, s.clock.Now()); err != nil {
+		return nil, err
+	}
+	return s.proposalRepo.GetProposals(s.ctx, filter)
+}
+
+// WatchProposals delivers the bet proposals made for the account from now on,
+// the channel is closed once ctx is done
+func (s *StrategyService) WatchProposals(ctx context.Context, accountId int64) (<-chan entity.BetProposal, error) {
+	ids, err := s.proposalRepo.ListenProposals(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	res := make(chan entity.BetProposal)
+	go func() {
+		defer close(res)
+		for id := range ids {
+			proposal, err := s.proposalRepo.GetProposalById(s.ctx, id)
+			if err != nil {
+				logging.ErrorFormat("Cannot get bet proposal %d of account %d: %s", id, accountId, err)
+				continue
+			}
+			select {
+			case res <- proposal:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return res, nil
+}
+
+// GetProposal returns the bet proposal, accountId is the account which launched the runner, any account when 0
+func (s *StrategyService) GetProposal(accountId int64, id int64) (entity.BetProposal, error) {
+	if err := s.proposalRepo.ExpireProposals(s.ctx, s.clock.Now()); err != nil {
+		return entity.BetProposal{}, err
+	}
+	proposal, err := s.proposalRepo.GetProposalById(s.ctx, id)
+	if err != nil {
+		return proposal, err
+	}
+	if accountId != 0 && proposal.AccountId != accountId {
+		return entity.BetProposal{}, ErrProposalAccessDenied
+	}
+	return proposal, nil
+}
+
+// DecideProposal approves or rejects the pending bet proposal. The approved bet is made only when
+// the proposal has not expired, its runner is still running and nobody has bet since it was made,
+// otherwise the proposal is stale and expires
+func (s *StrategyService) DecideProposal(accountId int64, id int64,
+	status entity.ProposalStatus) (entity.BetProposal, error) {
+	if status != entity.ProposalApproved && status != entity.ProposalRejected {
+		return entity.BetProposal{}, ErrInvalidDecision
+	}
+	proposal, err := s.GetProposal(accountId, id)
+	if err != nil {
+		return proposal, err
+	}
+	if proposal.Status != entity.ProposalPending {
+		return proposal, fmt.Errorf("%w: %s", ErrProposalDecided, proposal.Status)
+	}
+	if status == entity.ProposalRejected {
+		return s.moveProposal(proposal, entity.ProposalRejected, "")
+	}
+
+	job := s.runners.get(proposal.QuotationSessionId, proposal.UserId)
+	if job == nil || job.Info().RunnerId != proposal.RunnerId || job.isPaused() {
+		return s.expireProposal(proposal, "the runner is not running")
+	}
+	session, err := s.quotationSessionRepo.GetSessionById(s.ctx, proposal.QuotationSessionId)
+	if err != nil {
+		return proposal, err
+	}
+	if session.CurrentStep() != proposal.Step {
+		return s.expireProposal(proposal, "the session has moved on")
+	}
+	if proposal, err = s.moveProposal(proposal, entity.ProposalApproved, ""); err != nil {
+		return proposal, err
+	}
+	if err = job.bet(proposal.Tick, &session); err != nil {
+		failed, _ := s.moveProposal(proposal, entity.ProposalFailed, err.Error())
+		return failed, err
+	}
+	logging.InfoFormat("Bet proposal %d of runner session-%d-user-%d is approved", proposal.ID,
+		proposal.QuotationSessionId, proposal.UserId)
+	return proposal, nil
+}
+
+func (s *StrategyService) expireProposal(proposal entity.BetProposal, message string) (entity.BetProposal, error) {
+	proposal, err := s.moveProposal(proposal, entity.ProposalExpired, message)
+	if err != nil {
+		return proposal, err
+	}
+	return proposal, fmt.Errorf("%w: %s", ErrProposalStale, message)
+}
+
+// moveProposal changes the status of the proposal unless it has been changed meanwhile
+func (s *StrategyService) moveProposal(proposal entity.BetProposal, status entity.ProposalStatus,
+	message string) (entity.BetProposal, error) {
+	now := s.clock.Now()
+	err := s.proposalRepo.UpdateProposalStatus(s.ctx, proposal.ID, proposal.Status, status, now, message)
+	if errors.Is(err, repository.ErrNotFound) {
+		return proposal, ErrProposalDecided
+	}
+	if err != nil {
+		return proposal, err
+	}
+	proposal.Status = status
+	proposal.DecidedAt = now
+	proposal.Message = message
+	return proposal, nil
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"main/model/entity"
+	"main/utils"
+	"sync"
+	"testing"
+	"time"
+)
+
+// proposalFixture is a semi-automatic runner of account 10 in the guarded session 1, its proposals wait a minute
+type proposalFixture struct {
+	s         *StrategyService
+	job       *StrategyJob
+	sessions  *memorySessions
+	bets      *memoryBets
+	proposals *memoryProposals
+	clock     *utils.FakeClock
+}
+
+func newProposalFixture() proposalFixture {
+	f := proposalFixture{
+		sessions:  newMemorySessions(guardedSession()),
+		bets:      &memoryBets{},
+		proposals: &memoryProposals{},
+		clock:     utils.NewFakeClock(testStart),
+	}
+	f.s = testService(testRepos{
+		sessions: f.sessions,
+		bets:     f.bets,
+		blocked:  &memoryBlocked{},
+		proposal: f.proposals,
+		limits:   newMemoryLimits(),
+	}, f.clock)
+	f.job = testJob(f.s, 1, 5, 1)
+	f.job.AccountId = 10
+	f.job.RunnerId = 7
+	f.job.semiAutomatic = true
+	f.job.proposalTTL = time.Minute
+	_ = f.s.runners.add(f.job)
+	return f
+}
+
+// propose makes the runner propose the bet on the current session
+func (f proposalFixture) propose(t *testing.T, tick int64) {
+	t.Helper()
+	if err := f.job.placeBet(tick, nil); err != nil {
+		t.Fatalf("placeBet() error = %v", err)
+	}
+}
+
+// outbid makes provider 8 bet in the session
+func (f proposalFixture) outbid() {
+	session := guardedSession()
+	session.CurrentPrice = 700
+	session.LastBet = &entity.Bet{ProviderId: 8, BetNumber: 1}
+	f.sessions.put(session)
+}
+
+func TestPropose(t *testing.T) {
+	f := newProposalFixture()
+	f.propose(t, 1)
+	proposals := f.proposals.saved()
+	if len(proposals) != 1 {
+		t.Fatalf("proposals = %+v, want one", proposals)
+	}
+	want := entity.BetProposal{ID: 1, QuotationSessionId: 1, UserId: 5, AccountId: 10, RunnerId: 7, Tick: 1,
+		CurrentPrice: 800, NewPrice: 700, Status: entity.ProposalPending, CreatedAt: testStart,
+		ExpiresAt: testStart.Add(time.Minute)}
+	if proposals[0] != want {
+		t.Errorf("proposal = %+v\nwant %+v", proposals[0], want)
+	}
+	if made := f.bets.madeBets(); len(made) != 0 {
+		t.Errorf("bets made = %+v, want none before the approval", made)
+	}
+
+	// the pending proposal of the step is not made again
+	f.clock.Advance(30 * time.Second)
+	f.propose(t, 2)
+	if proposals = f.proposals.saved(); len(proposals) != 1 {
+		t.Errorf("proposals = %+v, want the pending one only", proposals)
+	}
+
+	// nor is the expired one made again, the account has not decided in time
+	f.clock.Advance(30 * time.Second)
+	f.propose(t, 3)
+	if proposals = f.proposals.saved(); len(proposals) != 2 || proposals[1].Tick != 3 || proposals[1].Step != 0 {
+		t.Fatalf("proposals = %+v, want a new one of the step", proposals)
+	}
+
+	// the pending proposal of the older step expires
+	f.outbid()
+	f.propose(t, 4)
+	proposals = f.proposals.saved()
+	if len(proposals) != 3 {
+		t.Fatalf("proposals = %+v, want a new one of the next step", proposals)
+	}
+	if old := proposals[1]; old.Status != entity.ProposalExpired || old.Message != "the session has moved on" {
+		t.Errorf("proposal of the older step = %+v, want expired", old)
+	}
+	if p := proposals[2]; p.Step != 1 || p.CurrentPrice != 700 || p.NewPrice != 600 ||
+		p.Status != entity.ProposalPending {
+		t.Errorf("proposal of the next step = %+v", p)
+	}
+}
+
+func TestDecideProposal(t *testing.T) {
+	tests := []struct {
+		name      string
+		accountId int64
+		status    entity.ProposalStatus
+		// changes the state after the proposal is made
+		before  func(f proposalFixture)
+		wantErr error
+		// status of the proposal once decided, pending when empty
+		wantStatus entity.ProposalStatus
+		wantBet    bool
+	}{
+		{name: "approved", accountId: 10, status: entity.ProposalApproved, wantStatus: entity.ProposalApproved,
+			wantBet: true},
+		{name: "approved by an admin", status: entity.ProposalApproved, wantStatus: entity.ProposalApproved,
+			wantBet: true},
+		{name: "rejected", accountId: 10, status: entity.ProposalRejected, wantStatus: entity.ProposalRejected},
+		{name: "invalid decision", accountId: 10, status: entity.ProposalExpired, wantErr: ErrInvalidDecision},
+		{name: "another account", accountId: 20, status: entity.ProposalApproved, wantErr: ErrProposalAccessDenied},
+		{
+			name:       "not decided in time",
+			accountId:  10,
+			status:     entity.ProposalApproved,
+			before:     func(f proposalFixture) { f.clock.Advance(time.Minute) },
+			wantErr:    ErrProposalDecided,
+			wantStatus: entity.ProposalExpired,
+		},
+		{
+			name:       "session moved on",
+			accountId:  10,
+			status:     entity.ProposalApproved,
+			before:     func(f proposalFixture) { f.outbid() },
+			wantErr:    ErrProposalStale,
+			wantStatus: entity.ProposalExpired,
+		},
+		{
+			name:       "runner stopped",
+			accountId:  10,
+			status:     entity.ProposalApproved,
+			before:     func(f proposalFixture) { f.s.runners.remove(1, 5) },
+			wantErr:    ErrProposalStale,
+			wantStatus: entity.ProposalExpired,
+		},
+		{
+			name:       "runner paused",
+			accountId:  10,
+			status:     entity.ProposalApproved,
+			before:     func(f proposalFixture) { f.job.setPaused(true) },
+			wantErr:    ErrProposalStale,
+			wantStatus: entity.ProposalExpired,
+		},
+		{
+			name:      "runner launched again",
+			accountId: 10,
+			status:    entity.ProposalApproved,
+			before: func(f proposalFixture) {
+				f.job.mu.Lock()
+				f.job.RunnerId = 8
+				f.job.mu.Unlock()
+			},
+			wantErr:    ErrProposalStale,
+			wantStatus: entity.ProposalExpired,
+		},
+		{
+			name:       "bet refused",
+			accountId:  10,
+			status:     entity.ProposalApproved,
+			before:     func(f proposalFixture) { f.bets.refuse = entity.ErrPriceFloor },
+			wantErr:    ErrBetBlocked,
+			wantStatus: entity.ProposalFailed,
+		},
+		{
+			name:      "rejected meanwhile",
+			accountId: 10,
+			status:    entity.ProposalApproved,
+			before: func(f proposalFixture) {
+				f.proposals.meanwhile = func() {
+					_ = f.proposals.UpdateProposalStatus(context.Background(), 1, entity.ProposalPending,
+						entity.ProposalRejected, testStart, "")
+				}
+			},
+			wantErr:    ErrProposalDecided,
+			wantStatus: entity.ProposalRejected,
+		},
+		{
+			name:      "expired meanwhile",
+			accountId: 10,
+			status:    entity.ProposalRejected,
+			before: func(f proposalFixture) {
+				f.proposals.meanwhile = func() {
+					_ = f.proposals.ExpireProposals(context.Background(), testStart.Add(time.Minute))
+				}
+			},
+			wantErr:    ErrProposalDecided,
+			wantStatus: entity.ProposalExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newProposalFixture()
+			f.propose(t, 1)
+			if tt.before != nil {
+				tt.before(f)
+			}
+
+			_, err := f.s.DecideProposal(tt.accountId, 1, tt.status)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("DecideProposal() error = %v, want %v", err, tt.wantErr)
+			}
+			wantStatus := tt.wantStatus
+			if wantStatus == "" {
+				wantStatus = entity.ProposalPending
+			}
+			if got := f.proposals.saved()[0]; got.Status != wantStatus {
+				t.Errorf("proposal status = %s, want %s", got.Status, wantStatus)
+			}
+			if made := f.bets.madeBets(); (len(made) != 0) != tt.wantBet {
+				t.Errorf("bets made = %+v, want a bet %t", made, tt.wantBet)
+			}
+		})
+	}
+}
+
+func TestDecideProposalOnce(t *testing.T) {
+	f := newProposalFixture()
+	f.propose(t, 1)
+
+	const decisions = 8
+	var wg sync.WaitGroup
+	errs := make([]error, decisions)
+	for i := 0; i < decisions; i++ {
+		status := entity.ProposalStatus(entity.ProposalApproved)
+		if i%2 == 1 {
+			status = entity.ProposalRejected
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = f.s.DecideProposal(10, 1, status)
+		}(i)
+	}
+	wg.Wait()
+
+	var decided int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			decided++
+		case !errors.Is(err, ErrProposalDecided):
+			t.Errorf("DecideProposal() error = %v, want %v", err, ErrProposalDecided)
+		}
+	}
+	if decided != 1 {
+		t.Fatalf("proposal decided %d times, want once", decided)
+	}
+	status := f.proposals.saved()[0].Status
+	if made := f.bets.madeBets(); (status == entity.ProposalApproved) != (len(made) == 1) || len(made) > 1 {
+		t.Errorf("proposal is %s with the bets made %+v", status, made)
+	}
+}
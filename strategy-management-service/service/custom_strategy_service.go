@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/dsl"
+	"main/model/entity"
+	"main/repository"
+	"time"
+)
+
+var (
+	ErrStrategyAccessDenied = errors.New("strategy belongs to another account")
+	ErrRunnerAccessDenied   = errors.New("runner was launched by another account")
+	ErrInvalidStrategy      = errors.New("invalid strategy")
+)
+
+func NewCustomStrategyService(ctx context.Context,
+	sRepo repository.StrategyRepository) CustomStrategyService {
+	return CustomStrategyService{
+		strategyRepo: sRepo,
+		ctx:          ctx,
+	}
+}
+
+type CustomStrategyService struct {
+	strategyRepo repository.StrategyRepository
+	ctx          context.Context
+}
+
+func (s CustomStrategyService) CreateStrategy(accountId int64, data entity.CustomStrategyData) (int64, error) {
+	strategy, err := compileStrategy(data)
+	if err != nil {
+		return -1, err
+	}
+	now := time.Now()
+	return s.strategyRepo.InsertStrategy(s.ctx, entity.CustomStrategy{
+		Name:      data.Name,
+		OwnerId:   accountId,
+		Source:    data.Source,
+		Strategy:  strategy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+func (s CustomStrategyService) ListStrategies(accountId int64) ([]*entity.CustomStrategy, error) {
+	return s.strategyRepo.GetStrategiesByOwner(s.ctx, accountId)
+}
+
+func (s CustomStrategyService) GetStrategy(accountId int64, id int64) (*entity.CustomStrategy, error) {
+	strategy, err := s.strategyRepo.GetStrategyById(s.ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if strategy.OwnerId != accountId {
+		return nil, ErrStrategyAccessDenied
+	}
+	return strategy, nil
+}
+
+func (s CustomStrategyService) UpdateStrategy(accountId int64, id int64, data entity.CustomStrategyData) error {
+	stored, err := s.GetStrategy(accountId, id)
+	if err != nil {
+		return err
+	}
+	strategy, err := compileStrategy(data)
+	if err != nil {
+		return err
+	}
+	stored.Name = data.Name
+	stored.Source = data.Source
+	stored.Strategy = strategy
+	stored.UpdatedAt = time.Now()
+	return s.strategyRepo.UpdateStrategy(s.ctx, *stored)
+}
+
+func (s CustomStrategyService) DeleteStrategy(accountId int64, id int64) error {
+	if _, err := s.GetStrategy(accountId, id); err != nil {
+		return err
+	}
+	return s.strategyRepo.DeleteStrategy(s.ctx, id)
+}
+
+// ValidateStrategy builds the strategy without saving it and reports all problems found
+func (s CustomStrategyService) ValidateStrategy(data entity.CustomStrategyData) entity.Diagnostics {
+	strategy, err := buildStrategy(data)
+	if err != nil {
+		return entity.Diagnostics{buildDiagnostic(err)}
+	}
+	return strategy.Validate()
+}
+
+// compileStrategy builds the strategy tree from the rule language source
+// or takes the tree as is, a strategy with validation errors is rejected
+func compileStrategy(data entity.CustomStrategyData) (entity.Strategy, error) {
+	if data.Name == "" {
+		return entity.Strategy{}, fmt.Errorf("%w: name is required", ErrInvalidStrategy)
+	}
+	strategy, err := buildStrategy(data)
+	if err != nil {
+		return entity.Strategy{}, fmt.Errorf("%w: %s", ErrInvalidStrategy, err)
+	}
+	if err := strategy.Validate().Err(); err != nil {
+		return entity.Strategy{}, fmt.Errorf("%w: %s", ErrInvalidStrategy, err)
+	}
+	return strategy, nil
+}
+
+var errNoStrategy = errors.New("either source or strategy tree is required")
+
+func buildStrategy(data entity.CustomStrategyData) (entity.Strategy, error) {
+	if data.Source != "" {
+		return dsl.ParseStrategy(data.Source)
+	}
+	if data.Strategy == nil {
+		return entity.Strategy{}, errNoStrategy
+	}
+	strategy := *data.Strategy
+	// the number of steps of ForEachNStep has no default
+	if strategy.N <= 0 && !strategy.StepBased() {
+		strategy.N = dsl.DefaultGranularity
+	}
+	return strategy, nil
+}
+
+func buildDiagnostic(err error) entity.Diagnostic {
+	if errors.Is(err, errNoStrategy) {
+		return entity.Diagnostic{
+			Severity: entity.SeverityError,
+			Code:     entity.DiagEmptyStrategy,
+			Message:  err.Error(),
+		}
+	}
+	d := entity.Diagnostic{
+		Severity: entity.SeverityError,
+		Code:     entity.DiagSyntax,
+		Path:     "source",
+		Message:  err.Error(),
+	}
+	var parseErr *dsl.ParseError
+	if errors.As(err, &parseErr) {
+		d.Message = parseErr.Message
+		d.Line = parseErr.Line
+		d.Column = parseErr.Column
+	}
+	return d
+}
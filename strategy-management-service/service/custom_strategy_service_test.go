@@ -0,0 +1,72 @@
+package service
+
+import (
+	"main/dsl"
+	"main/model/entity"
+	"testing"
+)
+
+func TestBuildDiagnostic(t *testing.T) {
+	tests := []struct {
+		name string
+		data entity.CustomStrategyData
+		want entity.Diagnostic
+	}{
+		{
+			name: "syntax error",
+			data: entity.CustomStrategyData{Source: "EVERY 5s\nIF currentPrice ! 5 THEN BET"},
+			want: entity.Diagnostic{
+				Severity: entity.SeverityError,
+				Code:     entity.DiagSyntax,
+				Path:     "source",
+				Message:  `unexpected character '!', did you mean "!="?`,
+				Line:     2,
+				Column:   17,
+			},
+		},
+		{
+			name: "no strategy",
+			data: entity.CustomStrategyData{},
+			want: entity.Diagnostic{
+				Severity: entity.SeverityError,
+				Code:     entity.DiagEmptyStrategy,
+				Message:  errNoStrategy.Error(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildStrategy(tt.data)
+			if err == nil {
+				t.Fatal("buildStrategy() error = nil")
+			}
+			if got := buildDiagnostic(err); got != tt.want {
+				t.Errorf("buildDiagnostic() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildStrategyDefaultGranularity(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy entity.Strategy
+		want     int
+	}{
+		{name: "seconds without granularity", strategy: entity.Strategy{}, want: dsl.DefaultGranularity},
+		{name: "seconds", strategy: entity.Strategy{N: 30}, want: 30},
+		{name: "steps without granularity", strategy: entity.Strategy{R: entity.RepeaterFENS}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := tt.strategy
+			got, err := buildStrategy(entity.CustomStrategyData{Strategy: &strategy})
+			if err != nil {
+				t.Fatalf("buildStrategy() error = %v", err)
+			}
+			if got.N != tt.want {
+				t.Errorf("granularity = %d, want %d", got.N, tt.want)
+			}
+		})
+	}
+}
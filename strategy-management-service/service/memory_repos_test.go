@@ -0,0 +1,466 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// testStart is the time the fake clocks of the tests start at
+var testStart = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// memoryAccounts knows the organisation of each account
+type memoryAccounts struct {
+	repository.AccountRepository
+	profiles map[int64]int64
+}
+
+func (m memoryAccounts) FindById(ctx context.Context, accountId int64) (*entity.Account, error) {
+	profileId, ok := m.profiles[accountId]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &entity.Account{Id: accountId, ProfileID: profileId}, nil
+}
+
+type memoryLimits struct {
+	mu     sync.Mutex
+	limits map[int64]entity.OrgRiskLimits
+}
+
+func newMemoryLimits(limits ...entity.OrgRiskLimits) *memoryLimits {
+	m := &memoryLimits{limits: map[int64]entity.OrgRiskLimits{}}
+	for _, l := range limits {
+		m.limits[l.ProfileId] = l
+	}
+	return m
+}
+
+func (m *memoryLimits) GetLimits(ctx context.Context, profileId int64) (entity.OrgRiskLimits, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	limits, ok := m.limits[profileId]
+	if !ok {
+		return limits, repository.ErrNotFound
+	}
+	return limits, nil
+}
+
+func (m *memoryLimits) PutLimits(ctx context.Context, limits entity.OrgRiskLimits) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits[limits.ProfileId] = limits
+	return nil
+}
+
+// memorySessions is the tender service keeping the sessions in memory
+type memorySessions struct {
+	repository.QuotationSessionRepository
+	mu       sync.Mutex
+	sessions map[int64]entity.QuotationSession
+	reads    int
+	// called on the next read before the session is read
+	meanwhile func()
+}
+
+func newMemorySessions(sessions ...entity.QuotationSession) *memorySessions {
+	m := &memorySessions{sessions: map[int64]entity.QuotationSession{}}
+	for _, s := range sessions {
+		m.sessions[s.ID] = s
+	}
+	return m
+}
+
+func (m *memorySessions) GetSessionById(ctx context.Context, id int64) (entity.QuotationSession, error) {
+	m.mu.Lock()
+	meanwhile := m.meanwhile
+	m.meanwhile = nil
+	m.mu.Unlock()
+	if meanwhile != nil {
+		meanwhile()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reads++
+	session, ok := m.sessions[id]
+	if !ok {
+		return session, fmt.Errorf("session %d: %w", id, repository.ErrNotFound)
+	}
+	return session, nil
+}
+
+func (m *memorySessions) put(session entity.QuotationSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = session
+}
+
+// memoryBets counts the bot bets of the organisations and records the bets made
+type memoryBets struct {
+	repository.BetRepository
+	mu sync.Mutex
+	// bot bets of the organisations made today
+	botBets map[int64]int64
+	// the time the bot bets were last counted since
+	countedSince time.Time
+	made         []entity.BetData
+	// the tender service refuses the bets made with it, when set
+	refuse error
+	// bets of the sessions
+	history map[int64][]*entity.Bet
+}
+
+func (m *memoryBets) GetBetBySessionId(ctx context.Context, sessionId int64) ([]*entity.Bet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.history[sessionId], nil
+}
+
+func (m *memoryBets) CountProfileBotBets(ctx context.Context, profileId int64, since time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.countedSince = since
+	return m.botBets[profileId], nil
+}
+
+func (m *memoryBets) MakeBet(ctx context.Context, bet entity.BetData) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.refuse != nil {
+		return -1, m.refuse
+	}
+	m.made = append(m.made, bet)
+	return int64(len(m.made)), nil
+}
+
+// TryBets applies the auction rules of the tender service, see tenderRules
+func (m *memoryBets) TryBets(ctx context.Context, trial entity.BetTrial) (entity.BetTrialResult, error) {
+	return tenderRules(trial), nil
+}
+
+// tenderRules tries the bets like the trial endpoint of the tender service does
+func tenderRules(trial entity.BetTrial) entity.BetTrialResult {
+	session := trial.Session
+	expire := func(now time.Time) {
+		if session.Status != entity.StatusActive || !session.EndTime().Before(now) {
+			return
+		}
+		session.Status = entity.StatusFinished
+		if session.LastBet == nil {
+			session.Status = entity.StatusDidntTakePlace
+		}
+	}
+	res := entity.BetTrialResult{}
+	for _, b := range trial.Bets {
+		expire(b.Time)
+		bet := entity.Bet{QuotationSessionID: session.ID, ProviderId: b.ProviderId, Time: b.Time, Bot: b.Bot}
+		newPrice := math.Max(session.CurrentPrice-session.StepSize(), 0)
+		end := session.EndTime()
+		code := ""
+		switch {
+		case session.Status != entity.StatusActive:
+			code = entity.CodeSessionNotActive
+		case session.IsInAdditionalPurchase && !end.After(b.Time):
+			session.Status = entity.StatusFinished
+		case session.LastBet != nil && session.LastBet.ProviderId == b.ProviderId:
+			code = entity.CodeRepeatedBet
+		case newPrice < b.MinPrice:
+			code = entity.CodePriceFloor
+		default:
+			if newPrice == 0 {
+				session.Status = entity.StatusFinished
+			}
+			bet.NewPrice = newPrice
+			if session.LastBet != nil {
+				bet.BetNumber = session.LastBet.BetNumber + 1
+			}
+			session.CurrentPrice = newPrice
+			session.LastBet = &bet
+			if left := end.Sub(b.Time); session.IsInAdditionalPurchase && left <= entity.AdditionalPurchaseDuration ||
+				session.Status == entity.StatusActive && left > 0 && left < entity.AdditionalPurchaseDuration {
+				session.IsInAdditionalPurchase = true
+				session.SessionDuration += int(entity.AdditionalPurchaseDuration / time.Minute)
+			}
+			res.Bets = append(res.Bets, entity.TrialBetResult{Bet: bet, Placed: true})
+			continue
+		}
+		res.Bets = append(res.Bets, entity.TrialBetResult{Bet: bet, ErrorCode: code})
+	}
+	if !trial.Until.IsZero() {
+		expire(trial.Until)
+	}
+	res.Session = session
+	return res
+}
+
+func (m *memoryBets) madeBets() []entity.BetData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]entity.BetData(nil), m.made...)
+}
+
+type memoryBlocked struct {
+	repository.BlockedActionRepository
+	mu      sync.Mutex
+	actions []entity.BlockedAction
+}
+
+func (m *memoryBlocked) InsertBlockedAction(ctx context.Context, action entity.BlockedAction) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.actions = append(m.actions, action)
+	return int64(len(m.actions)), nil
+}
+
+func (m *memoryBlocked) saved() []entity.BlockedAction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]entity.BlockedAction(nil), m.actions...)
+}
+
+type memoryTraces struct {
+	repository.DecisionTraceRepository
+	mu     sync.Mutex
+	traces []entity.DecisionTrace
+}
+
+func (m *memoryTraces) InsertTrace(ctx context.Context, trace entity.DecisionTrace) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.traces = append(m.traces, trace)
+	return int64(len(m.traces)), nil
+}
+
+func (m *memoryTraces) saved() []entity.DecisionTrace {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]entity.DecisionTrace(nil), m.traces...)
+}
+
+// memoryRunners keeps the stored runners, the runners changed are sent to updates when set
+type memoryRunners struct {
+	repository.StrategyRunnerRepository
+	mu      sync.Mutex
+	runners map[int64]entity.StrategyRunner
+	updates chan entity.StrategyRunner
+}
+
+func newMemoryRunners(runners ...entity.StrategyRunner) *memoryRunners {
+	m := &memoryRunners{runners: map[int64]entity.StrategyRunner{}}
+	for _, r := range runners {
+		m.runners[r.ID] = r
+	}
+	return m
+}
+
+func (m *memoryRunners) InsertRunner(ctx context.Context, runner entity.StrategyRunner) (int64, error) {
+	m.mu.Lock()
+	for id := range m.runners {
+		if id > runner.ID {
+			runner.ID = id
+		}
+	}
+	runner.ID++
+	m.runners[runner.ID] = runner
+	m.mu.Unlock()
+	m.updated(runner)
+	return runner.ID, nil
+}
+
+func (m *memoryRunners) UpdateRunnerProgress(ctx context.Context, id int64, tick int64, betsMade int64,
+	lastStep int64, at time.Time) error {
+	return m.update(id, func(r *entity.StrategyRunner) {
+		r.LastTick, r.BetsMade, r.LastStep, r.LastTickAt = tick, betsMade, lastStep, at
+	})
+}
+
+func (m *memoryRunners) StartRunner(ctx context.Context, id int64, betsMade int64, lastStep int64,
+	at time.Time) error {
+	return m.update(id, func(r *entity.StrategyRunner) {
+		r.Status, r.BetsMade, r.LastStep, r.StartedAt = entity.RunnerRunning, betsMade, lastStep, at
+	})
+}
+
+func (m *memoryRunners) UpdateRunnerStatus(ctx context.Context, id int64, status entity.RunnerStatus,
+	at time.Time) error {
+	return m.update(id, func(r *entity.StrategyRunner) {
+		r.Status = status
+		if status != entity.RunnerRunning && status != entity.RunnerPaused {
+			r.StoppedAt = at
+		}
+	})
+}
+
+func (m *memoryRunners) UpdateRunnerPhase(ctx context.Context, id int64, phase entity.PhaseState) error {
+	return m.update(id, func(r *entity.StrategyRunner) {
+		r.Phase = &phase
+	})
+}
+
+func (m *memoryRunners) FinishRunner(ctx context.Context, id int64, outcome entity.RunnerOutcome,
+	at time.Time) error {
+	return m.update(id, func(r *entity.StrategyRunner) {
+		r.Status, r.Outcome, r.StoppedAt = entity.RunnerFinished, &outcome, at
+	})
+}
+
+func (m *memoryRunners) GetRunnersByStatus(ctx context.Context, status entity.RunnerStatus) (
+	[]*entity.StrategyRunner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []*entity.StrategyRunner
+	for _, r := range m.runners {
+		if r.Status == status {
+			runner := r
+			res = append(res, &runner)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (m *memoryRunners) update(id int64, change func(r *entity.StrategyRunner)) error {
+	m.mu.Lock()
+	runner, ok := m.runners[id]
+	if !ok {
+		m.mu.Unlock()
+		return repository.ErrNotFound
+	}
+	change(&runner)
+	m.runners[id] = runner
+	m.mu.Unlock()
+	m.updated(runner)
+	return nil
+}
+
+func (m *memoryRunners) updated(runner entity.StrategyRunner) {
+	if m.updates != nil {
+		m.updates <- runner
+	}
+}
+
+func (m *memoryRunners) runner(id int64) entity.StrategyRunner {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.runners[id]
+}
+
+type memoryPortfolios struct {
+	repository.PortfolioRepository
+	mu       sync.Mutex
+	statuses map[int64]entity.RunnerStatus
+}
+
+func (m *memoryPortfolios) UpdatePortfolioStatus(ctx context.Context, id int64, status entity.RunnerStatus,
+	at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statuses[id] = status
+	return nil
+}
+
+// GetPortfoliosByStatus finds no portfolio, only their statuses are kept
+func (m *memoryPortfolios) GetPortfoliosByStatus(ctx context.Context, status entity.RunnerStatus) (
+	[]*entity.Portfolio, error) {
+	return nil, nil
+}
+
+// memoryProposals changes the statuses of the proposals like the database does: only from the status expected
+type memoryProposals struct {
+	repository.BetProposalRepository
+	mu        sync.Mutex
+	proposals []entity.BetProposal
+	// called before a status is changed, the decisions made meanwhile by others are made with it
+	meanwhile func()
+}
+
+func (m *memoryProposals) InsertProposal(ctx context.Context, proposal entity.BetProposal) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	proposal.ID = int64(len(m.proposals) + 1)
+	m.proposals = append(m.proposals, proposal)
+	return proposal.ID, nil
+}
+
+func (m *memoryProposals) GetProposalById(ctx context.Context, id int64) (entity.BetProposal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > int64(len(m.proposals)) {
+		return entity.BetProposal{}, repository.ErrNotFound
+	}
+	return m.proposals[id-1], nil
+}
+
+func (m *memoryProposals) UpdateProposalStatus(ctx context.Context, id int64, from entity.ProposalStatus,
+	to entity.ProposalStatus, at time.Time, message string) error {
+	m.mu.Lock()
+	meanwhile := m.meanwhile
+	m.meanwhile = nil
+	m.mu.Unlock()
+	if meanwhile != nil {
+		meanwhile()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > int64(len(m.proposals)) || m.proposals[id-1].Status != from {
+		return repository.ErrNotFound
+	}
+	p := &m.proposals[id-1]
+	p.Status, p.DecidedAt, p.Message = to, at, message
+	return nil
+}
+
+func (m *memoryProposals) ExpireProposals(ctx context.Context, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.proposals {
+		p := &m.proposals[i]
+		if p.Status == entity.ProposalPending && !p.ExpiresAt.After(at) {
+			p.Status, p.DecidedAt, p.Message = entity.ProposalExpired, p.ExpiresAt, "not decided in time"
+		}
+	}
+	return nil
+}
+
+func (m *memoryProposals) saved() []entity.BetProposal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]entity.BetProposal(nil), m.proposals...)
+}
+
+// testRepos are the repositories of the service under test, the ones not given are nil
+type testRepos struct {
+	sessions *memorySessions
+	bets     repository.BetRepository
+	runners  repository.StrategyRunnerRepository
+	blocked  repository.BlockedActionRepository
+	notices  repository.RunnerNotificationRepository
+	proposal repository.BetProposalRepository
+	accounts repository.AccountRepository
+	limits   repository.OrgLimitsRepository
+	pfRepo   repository.PortfolioRepository
+	traces   repository.DecisionTraceRepository
+}
+
+// testService returns a service instance of its own on the repositories
+func testService(repos testRepos, clock utils.Clock) *StrategyService {
+	var sessions repository.QuotationSessionRepository
+	if repos.sessions != nil {
+		sessions = repos.sessions
+	}
+	return NewStrategyService(context.Background(), sessions, repos.bets, nil, repos.traces, repos.runners,
+		repos.blocked, repos.notices, repos.proposal, repos.accounts, repos.limits, repos.pfRepo, clock)
+}
+
+// testJob returns a job of the service, the user bets in the session for the organisation
+func testJob(s *StrategyService, sessionId int64, userId int64, profileId int64) *StrategyJob {
+	job := &StrategyJob{QuotationSessionId: sessionId, ProfileId: profileId, ParentService: s}
+	job.UserId = userId
+	return job
+}
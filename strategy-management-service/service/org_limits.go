@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"time"
+)
+
+var (
+	ErrOrgAccessDenied = errors.New("organisation of another account")
+	ErrInvalidLimits   = errors.New("risk limits cannot be negative")
+	ErrOrgRunnerLimit  = errors.New("organisation runs the maximum number of runners")
+)
+
+// profileOf returns the organisation of the account
+func (s *StrategyService) profileOf(accountId int64) (int64, error) {
+	account, err := s.accountRepo.FindById(s.ctx, accountId)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get account %d: %w", accountId, err)
+	}
+	return account.ProfileID, nil
+}
+
+// checkOrgAccess fails unless the account belongs to the organisation, accountId is 0 for admins
+func (s *StrategyService) checkOrgAccess(accountId int64, profileId int64) error {
+	if accountId == 0 {
+		return nil
+	}
+	own, err := s.profileOf(accountId)
+	if err != nil {
+		return err
+	}
+	if own != profileId {
+		return ErrOrgAccessDenied
+	}
+	return nil
+}
+
+// orgLimits returns the limits of the organisation, no limits when none are set
+func (s *StrategyService) orgLimits(profileId int64) (entity.OrgRiskLimits, error) {
+	limits, err := s.limitsRepo.GetLimits(s.ctx, profileId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return entity.OrgRiskLimits{ProfileId: profileId}, nil
+	}
+	return limits, err
+}
+
+// register adds the job to the registry unless its organisation runs the maximum number of runners
+func (s *StrategyService) register(job *StrategyJob) error {
+	s.orgLaunch.Lock()
+	defer s.orgLaunch.Unlock()
+	if err := s.checkOrgRunners(job.ProfileId); err != nil {
+		return err
+	}
+	return s.runners.add(job)
+}
+
+// checkOrgRunners fails when the organisation already runs the maximum number of runners
+func (s *StrategyService) checkOrgRunners(profileId int64) error {
+	limits, err := s.orgLimits(profileId)
+	if err != nil {
+		return err
+	}
+	running := len(s.runners.list(entity.RunnerFilter{ProfileId: profileId}))
+	if limits.MaxRunners > 0 && running >= limits.MaxRunners {
+		return fmt.Errorf("%w: %d of %d", ErrOrgRunnerLimit, running, limits.MaxRunners)
+	}
+	return nil
+}
+
+// checkOrgBet reports whether the bet of the job resulting in newPrice breaks a limit of its organisation:
+// the runners of the organisation have made the maximum number of bot bets today, or the discounts of
+// the sessions they lead would exceed the maximum with the bet. The reason is set to blocked
+func (s *StrategyService) checkOrgBet(job *StrategyJob, session entity.QuotationSession, newPrice float64,
+	blocked *entity.BlockedAction) (bool, error) {
+	limits, err := s.orgLimits(job.ProfileId)
+	if err != nil {
+		return false, err
+	}
+	if limits.MaxBetsPerDay > 0 {
+		now := s.clock.Now().UTC()
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		made, err := s.betRepo.CountProfileBotBets(s.ctx, job.ProfileId, day)
+		if err != nil {
+			return false, err
+		}
+		if made >= limits.MaxBetsPerDay {
+			blocked.Reason = entity.BlockOrgBetsPerDay
+			blocked.Message = fmt.Sprintf("runners of the organisation have made %d bets of %d today",
+				made, limits.MaxBetsPerDay)
+			return true, nil
+		}
+	}
+	if limits.MaxTotalDiscount > 0 {
+		total, err := s.orgDiscount(job.ProfileId, session.ID)
+		if err != nil {
+			return false, err
+		}
+		total += session.StartPrice - newPrice
+		if total > limits.MaxTotalDiscount {
+			blocked.Reason = entity.BlockOrgDiscount
+			blocked.Message = fmt.Sprintf("bet would make the total discount of the organisation %.2f, "+
+				"above the maximum %.2f", total, limits.MaxTotalDiscount)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// orgDiscount sums the discounts of the active sessions the runners of the organisation lead,
+// except the given session
+func (s *StrategyService) orgDiscount(profileId int64, except int64) (float64, error) {
+	// users of the runners by session
+	users := map[int64]map[int64]bool{}
+	for _, job := range s.runners.list(entity.RunnerFilter{ProfileId: profileId}) {
+		if job.QuotationSessionId == except {
+			continue
+		}
+		if users[job.QuotationSessionId] == nil {
+			users[job.QuotationSessionId] = map[int64]bool{}
+		}
+		users[job.QuotationSessionId][job.UserId] = true
+	}
+	var total float64
+	for sessionId, ids := range users {
+		session, err := s.quotationSessionRepo.GetSessionById(s.ctx, sessionId)
+		if err != nil {
+			return 0, err
+		}
+		if session.Status == entity.StatusActive && session.LastBet != nil && ids[session.LastBet.ProviderId] {
+			total += session.StartPrice - session.CurrentPrice
+		}
+	}
+	return total, nil
+}
+
+// GetOrgLimits returns the risk limits of the organisation, accountId is the account of the request,
+// any organisation when 0
+func (s *StrategyService) GetOrgLimits(accountId int64, profileId int64) (entity.OrgRiskLimits, error) {
+	if err := s.checkOrgAccess(accountId, profileId); err != nil {
+		return entity.OrgRiskLimits{}, err
+	}
+	return s.orgLimits(profileId)
+}
+
+// PutOrgLimits sets the risk limits of the organisation, they apply to the next launches and bets
+func (s *StrategyService) PutOrgLimits(accountId int64, limits entity.OrgRiskLimits) error {
+	if err := s.checkOrgAccess(accountId, limits.ProfileId); err != nil {
+		return err
+	}
+	if !limits.Valid() {
+		return ErrInvalidLimits
+	}
+	return s.limitsRepo.PutLimits(s.ctx, limits)
+}
+
+// StopOrganization stops every runner launched by the accounts of the organisation,
+// it returns the runners stopped
+func (s *StrategyService) StopOrganization(accountId int64, profileId int64) ([]entity.RunnerInfo, error) {
+	if err := s.checkOrgAccess(accountId, profileId); err != nil {
+		return nil, err
+	}
+	res := []entity.RunnerInfo{}
+	for _, job := range s.runners.list(entity.RunnerFilter{ProfileId: profileId}) {
+		// a runner which has stopped meanwhile is skipped
+		if err := s.StopRunner(0, job.QuotationSessionId, job.UserId); err != nil {
+			continue
+		}
+		info := job.Info()
+		info.Status = entity.RunnerStopped
+		res = append(res, info)
+	}
+	logging.InfoFormat("Stopped %d runners of organisation %d", len(res), profileId)
+	return res, nil
+}
+
+// PauseOrganization pauses every runner launched by the accounts of the organisation
+func (s *StrategyService) PauseOrganization(accountId int64, profileId int64) ([]entity.RunnerInfo, error) {
+	return s.setOrgPaused(accountId, profileId, true)
+}
+
+// ResumeOrganization resumes every paused runner of the organisation
+func (s *StrategyService) ResumeOrganization(accountId int64, profileId int64) ([]entity.RunnerInfo, error) {
+	return s.setOrgPaused(accountId, profileId, false)
+}
+
+func (s *StrategyService) setOrgPaused(accountId int64, profileId int64, paused bool) ([]entity.RunnerInfo, error) {
+	if err := s.checkOrgAccess(accountId, profileId); err != nil {
+		return nil, err
+	}
+	res := []entity.RunnerInfo{}
+	for _, job := range s.runners.list(entity.RunnerFilter{ProfileId: profileId}) {
+		if job.isPaused() == paused {
+			continue
+		}
+		info, err := s.setPaused(0, job.QuotationSessionId, job.UserId, paused)
+		if err != nil {
+			continue
+		}
+		res = append(res, info)
+	}
+	logging.InfoFormat("Set paused - %t for %d runners of organisation %d", paused, len(res), profileId)
+	return res, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"errors"
+	"main/model/entity"
+	"main/utils"
+	"testing"
+	"time"
+)
+
+func TestPutOrgLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		accountId int64
+		limits    entity.OrgRiskLimits
+		wantErr   error
+	}{
+		{name: "admin", accountId: 0, limits: entity.OrgRiskLimits{ProfileId: 1, MaxRunners: 2}},
+		{name: "account of the organisation", accountId: 10, limits: entity.OrgRiskLimits{ProfileId: 1, MaxRunners: 2}},
+		{
+			name:      "account of another organisation",
+			accountId: 20,
+			limits:    entity.OrgRiskLimits{ProfileId: 1, MaxRunners: 2},
+			wantErr:   ErrOrgAccessDenied,
+		},
+		{
+			name:    "negative limit",
+			limits:  entity.OrgRiskLimits{ProfileId: 1, MaxBetsPerDay: -1},
+			wantErr: ErrInvalidLimits,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := newMemoryLimits()
+			s := testService(testRepos{
+				accounts: memoryAccounts{profiles: map[int64]int64{10: 1, 20: 2}},
+				limits:   limits,
+			}, utils.NewFakeClock(testStart))
+
+			err := s.PutOrgLimits(tt.accountId, tt.limits)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("PutOrgLimits() error = %v, want %v", err, tt.wantErr)
+			}
+			got, err := s.GetOrgLimits(0, 1)
+			if err != nil {
+				t.Fatalf("GetOrgLimits() error = %v", err)
+			}
+			want := entity.OrgRiskLimits{ProfileId: 1}
+			if tt.wantErr == nil {
+				want = tt.limits
+			}
+			if got != want {
+				t.Errorf("limits = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetOrgLimitsAccess(t *testing.T) {
+	s := testService(testRepos{
+		accounts: memoryAccounts{profiles: map[int64]int64{10: 1, 20: 2}},
+		limits:   newMemoryLimits(entity.OrgRiskLimits{ProfileId: 1, MaxRunners: 3}),
+	}, utils.NewFakeClock(testStart))
+
+	if limits, err := s.GetOrgLimits(10, 1); err != nil || limits.MaxRunners != 3 {
+		t.Errorf("GetOrgLimits() of the own organisation = %+v, %v", limits, err)
+	}
+	if _, err := s.GetOrgLimits(20, 1); !errors.Is(err, ErrOrgAccessDenied) {
+		t.Errorf("GetOrgLimits() of another organisation error = %v, want %v", err, ErrOrgAccessDenied)
+	}
+	// no limits are no limit
+	if limits, err := s.GetOrgLimits(20, 2); err != nil || limits != (entity.OrgRiskLimits{ProfileId: 2}) {
+		t.Errorf("GetOrgLimits() without limits = %+v, %v", limits, err)
+	}
+}
+
+func TestRegisterMaxRunners(t *testing.T) {
+	s := testService(testRepos{
+		limits: newMemoryLimits(entity.OrgRiskLimits{ProfileId: 1, MaxRunners: 2}),
+	}, utils.NewFakeClock(testStart))
+	job := func(sessionId int64, profileId int64) *StrategyJob {
+		return testJob(s, sessionId, 5, profileId)
+	}
+
+	for _, sessionId := range []int64{1, 2} {
+		if err := s.register(job(sessionId, 1)); err != nil {
+			t.Fatalf("register() of runner %d error = %v", sessionId, err)
+		}
+	}
+	if err := s.register(job(3, 1)); !errors.Is(err, ErrOrgRunnerLimit) {
+		t.Errorf("register() above the limit error = %v, want %v", err, ErrOrgRunnerLimit)
+	}
+	// the limit is of the organisation only
+	if err := s.register(job(3, 2)); err != nil {
+		t.Errorf("register() of another organisation error = %v", err)
+	}
+	// a runner leaving frees its place
+	s.runners.remove(1, 5)
+	if err := s.register(job(4, 1)); err != nil {
+		t.Errorf("register() after a runner left error = %v", err)
+	}
+}
+
+func TestCheckOrgBet(t *testing.T) {
+	led := func(id int64, discount float64, leader int64) entity.QuotationSession {
+		return entity.QuotationSession{ID: id, Status: entity.StatusActive, StartPrice: 1000,
+			CurrentPrice: 1000 - discount, LastBet: &entity.Bet{ProviderId: leader}}
+	}
+	tests := []struct {
+		name    string
+		limits  entity.OrgRiskLimits
+		botBets int64
+		// sessions of the other runners of the organisation, its users are 5
+		others     []entity.QuotationSession
+		newPrice   float64
+		wantReason string
+	}{
+		{name: "no limits", limits: entity.OrgRiskLimits{ProfileId: 1}, botBets: 1000, newPrice: 100},
+		{
+			name:     "bets left today",
+			limits:   entity.OrgRiskLimits{ProfileId: 1, MaxBetsPerDay: 10},
+			botBets:  9,
+			newPrice: 900,
+		},
+		{
+			name:       "bets of the day made",
+			limits:     entity.OrgRiskLimits{ProfileId: 1, MaxBetsPerDay: 10},
+			botBets:    10,
+			newPrice:   900,
+			wantReason: entity.BlockOrgBetsPerDay,
+		},
+		{
+			name:     "discount within the limit",
+			limits:   entity.OrgRiskLimits{ProfileId: 1, MaxTotalDiscount: 600},
+			others:   []entity.QuotationSession{led(2, 300, 5)},
+			newPrice: 700,
+		},
+		{
+			name:       "discount above the limit",
+			limits:     entity.OrgRiskLimits{ProfileId: 1, MaxTotalDiscount: 500},
+			others:     []entity.QuotationSession{led(2, 300, 5)},
+			newPrice:   700,
+			wantReason: entity.BlockOrgDiscount,
+		},
+		{
+			name:   "sessions led by others are not counted",
+			limits: entity.OrgRiskLimits{ProfileId: 1, MaxTotalDiscount: 500},
+			others: []entity.QuotationSession{led(2, 300, 6), func() entity.QuotationSession {
+				ended := led(3, 300, 5)
+				ended.Status = entity.StatusFinished
+				return ended
+			}()},
+			newPrice: 700,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := led(1, 100, 5)
+			sessions := newMemorySessions(append(tt.others, session)...)
+			bets := &memoryBets{botBets: map[int64]int64{1: tt.botBets}}
+			clock := utils.NewFakeClock(testStart)
+			s := testService(testRepos{sessions: sessions, bets: bets, limits: newMemoryLimits(tt.limits)}, clock)
+			job := testJob(s, session.ID, 5, 1)
+			for _, other := range append(tt.others, session) {
+				if err := s.runners.add(testJob(s, other.ID, 5, 1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var blocked entity.BlockedAction
+			got, err := s.checkOrgBet(job, session, tt.newPrice, &blocked)
+			if err != nil {
+				t.Fatalf("checkOrgBet() error = %v", err)
+			}
+			if got != (tt.wantReason != "") || string(blocked.Reason) != tt.wantReason {
+				t.Errorf("checkOrgBet() = %t, reason %q, want %q", got, blocked.Reason, tt.wantReason)
+			}
+			if tt.limits.MaxBetsPerDay > 0 && !bets.countedSince.Equal(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("bot bets counted since %s, want the midnight UTC", bets.countedSince)
+			}
+		})
+	}
+}
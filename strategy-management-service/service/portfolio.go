@@ -0,0 +1,326 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"main/logging"
+	"main/model/entity"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPortfolioNotFound     = errors.New("portfolio not found")
+	ErrPortfolioAccessDenied = errors.New("portfolio of another account")
+)
+
+// portfolioRefresh is the period the running portfolios allocate their sessions with,
+// the bets of their runners are allocated right before they are made as well
+const portfolioRefresh = 5 * time.Second
+
+// portfolioRun is a running portfolio, its runners are the jobs of the registry bound to it
+type portfolioRun struct {
+	entity.Portfolio
+	quit chan bool
+	// serialises the bets of the runners, so that a bet is allocated and made at once
+	betMu sync.Mutex
+
+	// guards the fields below
+	mu sync.Mutex
+	// sessions the runners are being launched in, they are allocated as if their runners were running
+	pending  map[int64]bool
+	sessions []entity.PortfolioSession
+	usage    entity.PortfolioUsage
+}
+
+// portfolioRegistry keeps the running portfolios of the service instance, safe for concurrent use
+type portfolioRegistry struct {
+	mu   sync.RWMutex
+	runs map[int64]*portfolioRun
+}
+
+func (r *portfolioRegistry) add(run *portfolioRun) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs[run.ID] = run
+}
+
+func (r *portfolioRegistry) get(id int64) *portfolioRun {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.runs[id]
+}
+
+// remove unregisters the portfolio, it reports whether the portfolio was registered
+func (r *portfolioRegistry) remove(id int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.runs[id]
+	delete(r.runs, id)
+	return ok
+}
+
+// RunPortfolio launches a runner in each session of the portfolio. The sessions whose runners cannot
+// be launched are left out, the launch fails when none can be
+func (s *StrategyService) RunPortfolio(params entity.PortfolioParams) (entity.PortfolioInfo, error) {
+	if err := params.Validate(); err != nil {
+		return entity.PortfolioInfo{}, err
+	}
+	portfolio := entity.Portfolio{
+		UserId:    params.UserId,
+		AccountId: params.AccountId,
+		Params:    params,
+		Status:    entity.RunnerRunning,
+		StartedAt: s.clock.Now(),
+	}
+	id, err := s.portfolioRepo.InsertPortfolio(s.ctx, portfolio)
+	if err != nil {
+		return entity.PortfolioInfo{}, fmt.Errorf("cannot save portfolio of user %d: %w", params.UserId, err)
+	}
+	portfolio.ID = id
+	run := &portfolioRun{
+		Portfolio: portfolio,
+		quit:      make(chan bool),
+		pending:   map[int64]bool{},
+	}
+	for _, session := range params.Sessions {
+		run.pending[session.QuotationSessionId] = true
+	}
+	// registered first, the runners are bound to it when they are launched
+	s.portfolios.add(run)
+
+	var launched int
+	var failure error
+	for _, session := range params.Sessions {
+		session.UserId = params.UserId
+		session.AccountId = params.AccountId
+		session.PortfolioId = id
+		err = s.RunStrategyRunner(session)
+		run.launched(session.QuotationSessionId)
+		if err != nil {
+			logging.ErrorFormat("Cannot launch runner of portfolio %d in session %d: %s", id,
+				session.QuotationSessionId, err)
+			failure = fmt.Errorf("session %d: %w", session.QuotationSessionId, err)
+			continue
+		}
+		launched++
+	}
+	if launched == 0 {
+		s.portfolios.remove(id)
+		s.savePortfolioStatus(id, entity.RunnerStopped)
+		return entity.PortfolioInfo{}, failure
+	}
+	if err = s.allocate(run); err != nil {
+		logging.ErrorFormat("Cannot allocate sessions of portfolio %d: %s", id, err)
+	}
+	go s.superviseRun(run)
+	return run.info(), nil
+}
+
+// GetPortfolio returns the portfolio with the last allocation of its sessions, the sessions
+// of a stopped or finished portfolio are not listed. accountId is the account of the request,
+// any account when 0
+func (s *StrategyService) GetPortfolio(accountId int64, id int64) (entity.PortfolioInfo, error) {
+	if run := s.portfolios.get(id); run != nil {
+		if accountId != 0 && run.AccountId != accountId {
+			return entity.PortfolioInfo{}, ErrPortfolioAccessDenied
+		}
+		return run.info(), nil
+	}
+	stored, err := s.portfolioRepo.GetPortfolioById(s.ctx, id)
+	if err != nil {
+		return entity.PortfolioInfo{}, fmt.Errorf("%w: %d", ErrPortfolioNotFound, id)
+	}
+	if accountId != 0 && stored.AccountId != accountId {
+		return entity.PortfolioInfo{}, ErrPortfolioAccessDenied
+	}
+	return entity.PortfolioInfo{Portfolio: stored}, nil
+}
+
+// StopPortfolio stops the portfolio together with the runners of its sessions
+func (s *StrategyService) StopPortfolio(accountId int64, id int64) (entity.PortfolioInfo, error) {
+	run := s.portfolios.get(id)
+	if run == nil {
+		return entity.PortfolioInfo{}, fmt.Errorf("%w: %d", ErrPortfolioNotFound, id)
+	}
+	if accountId != 0 && run.AccountId != accountId {
+		return entity.PortfolioInfo{}, ErrPortfolioAccessDenied
+	}
+	// stopped meanwhile
+	if !s.portfolios.remove(id) {
+		return entity.PortfolioInfo{}, fmt.Errorf("%w: %d", ErrPortfolioNotFound, id)
+	}
+	close(run.quit)
+	for _, job := range s.runners.list(entity.RunnerFilter{PortfolioId: id}) {
+		if err := s.StopRunner(0, job.QuotationSessionId, job.UserId); err != nil {
+			logging.ErrorFormat("Cannot stop runner session-%d-user-%d of portfolio %d: %s",
+				job.QuotationSessionId, job.UserId, id, err)
+		}
+	}
+	s.savePortfolioStatus(id, entity.RunnerStopped)
+	logging.InfoFormat("Portfolio %d is stopped", id)
+	info := run.info()
+	info.Status = entity.RunnerStopped
+	return info, nil
+}
+
+// resumePortfolios registers the portfolios left running by the previous service instance,
+// so that their runners are bound to them when resumed. The supervisors are started by the returned func
+func (s *StrategyService) resumePortfolios() (func(), error) {
+	stored, err := s.portfolioRepo.GetPortfoliosByStatus(s.ctx, entity.RunnerRunning)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*portfolioRun, len(stored))
+	for i, portfolio := range stored {
+		runs[i] = &portfolioRun{
+			Portfolio: *portfolio,
+			quit:      make(chan bool),
+			pending:   map[int64]bool{},
+		}
+		s.portfolios.add(runs[i])
+	}
+	return func() {
+		for _, run := range runs {
+			logging.InfoFormat("Resumed portfolio %d", run.ID)
+			go s.superviseRun(run)
+		}
+	}, nil
+}
+
+// superviseRun allocates the sessions of the portfolio periodically, the portfolio is finished
+// once none of its runners is left
+func (s *StrategyService) superviseRun(run *portfolioRun) {
+	for {
+		if len(s.runners.list(entity.RunnerFilter{PortfolioId: run.ID})) == 0 {
+			if s.portfolios.remove(run.ID) {
+				logging.InfoFormat("Portfolio %d finished: no runners are left", run.ID)
+				s.savePortfolioStatus(run.ID, entity.RunnerFinished)
+			}
+			return
+		}
+		if err := s.allocate(run); err != nil {
+			logging.ErrorFormat("Cannot allocate sessions of portfolio %d: %s", run.ID, err)
+		}
+		timer := s.clock.NewTimer(portfolioRefresh)
+		select {
+		case <-run.quit:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+}
+
+// allocate distributes the capacity of the portfolio between its sessions, the runners
+// of the withdrawn sessions stop acting, the ones bidding again are woken up
+func (s *StrategyService) allocate(run *portfolioRun) error {
+	candidates := make([]entity.PortfolioSession, 0, len(run.Params.Sessions))
+	jobs := map[int64]*StrategyJob{}
+	for _, params := range run.Params.Sessions {
+		job := s.runners.get(params.QuotationSessionId, run.UserId)
+		if job == nil || job.PortfolioId != run.ID {
+			if !run.isPending(params.QuotationSessionId) {
+				candidates = append(candidates, entity.PortfolioSession{
+					QuotationSessionId: params.QuotationSessionId,
+					State:              entity.PortfolioEnded,
+					MinimalPrice:       params.MinimalPrice,
+				})
+				continue
+			}
+			job = nil
+		}
+		session, err := s.quotationSessionRepo.GetSessionById(s.ctx, params.QuotationSessionId)
+		if err != nil {
+			return fmt.Errorf("cannot get session %d: %w", params.QuotationSessionId, err)
+		}
+		candidate := entity.NewPortfolioSession(session, run.UserId, params.MinimalPrice)
+		if job != nil {
+			candidate.RunnerId = job.Info().RunnerId
+			jobs[params.QuotationSessionId] = job
+		}
+		candidates = append(candidates, candidate)
+	}
+	sessions, usage := run.Params.Allocate(candidates)
+	run.mu.Lock()
+	run.sessions, run.usage = sessions, usage
+	run.mu.Unlock()
+	for _, session := range sessions {
+		job := jobs[session.QuotationSessionId]
+		if job == nil {
+			continue
+		}
+		withdrawn := session.State == entity.PortfolioWithdrawn
+		if job.setWithdrawn(withdrawn) && !withdrawn {
+			logging.InfoFormat("Portfolio %d bids in session %d again", run.ID, session.QuotationSessionId)
+			job.wake()
+		} else if withdrawn {
+			logging.DebugFormat("Portfolio %d is withdrawn from session %d", run.ID, session.QuotationSessionId)
+		}
+	}
+	return nil
+}
+
+// checkPortfolioBet reports whether the portfolio of the job has withdrawn from its session,
+// the reason is set to blocked. The caller holds the bet lock of the portfolio
+func (s *StrategyService) checkPortfolioBet(job *StrategyJob, blocked *entity.BlockedAction) (bool, error) {
+	run := job.portfolio
+	if run == nil {
+		return false, nil
+	}
+	if err := s.allocate(run); err != nil {
+		return false, err
+	}
+	session, ok := run.session(job.QuotationSessionId)
+	if !ok || session.State != entity.PortfolioWithdrawn {
+		return false, nil
+	}
+	blocked.Reason = entity.BlockPortfolioWithdrawn
+	if session.Margin < 0 {
+		blocked.Message = fmt.Sprintf("portfolio %d has withdrawn from the session: margin %.4f is gone",
+			run.ID, session.Margin)
+	} else {
+		blocked.Message = fmt.Sprintf("portfolio %d has withdrawn from the session: its capacity is taken "+
+			"by the sessions of higher margin", run.ID)
+	}
+	return true, nil
+}
+
+func (s *StrategyService) savePortfolioStatus(id int64, status entity.RunnerStatus) {
+	if err := s.portfolioRepo.UpdatePortfolioStatus(s.ctx, id, status, s.clock.Now()); err != nil {
+		logging.ErrorFormat("Cannot set status %s of portfolio %d: %s", status, id, err)
+	}
+}
+
+func (r *portfolioRun) launched(sessionId int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, sessionId)
+}
+
+func (r *portfolioRun) isPending(sessionId int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pending[sessionId]
+}
+
+func (r *portfolioRun) session(sessionId int64) (entity.PortfolioSession, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.QuotationSessionId == sessionId {
+			return session, true
+		}
+	}
+	return entity.PortfolioSession{}, false
+}
+
+func (r *portfolioRun) info() entity.PortfolioInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return entity.PortfolioInfo{
+		Portfolio: r.Portfolio,
+		Sessions:  append([]entity.PortfolioSession(nil), r.sessions...),
+		Usage:     r.usage,
+	}
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"main/model/entity"
+	"main/utils"
+	"strings"
+	"testing"
+)
+
+// portfolioSession is an active session of 10% steps from 1000 at the price, its volume units
+// are of the category, leader made the last bet unless 0
+func portfolioSession(id int64, price float64, volume int32, category int64, leader int64) entity.QuotationSession {
+	session := entity.QuotationSession{
+		ID:                 id,
+		Status:             entity.StatusActive,
+		StartPrice:         1000,
+		CurrentPrice:       price,
+		SessionStepPercent: 10,
+		StartTime:          testStart,
+		SessionDuration:    60,
+		Products: []*entity.ProductJournal{
+			{Count: volume, Product: &entity.Product{CategoryId: category}},
+		},
+	}
+	if leader != 0 {
+		session.LastBet = &entity.Bet{ProviderId: leader}
+	}
+	return session
+}
+
+// portfolioFixture is portfolio 3 of user 5 running in the sessions, the runners of the sessions
+// not listed in pending are launched
+type portfolioFixture struct {
+	s        *StrategyService
+	sessions *memorySessions
+	run      *portfolioRun
+	jobs     map[int64]*StrategyJob
+}
+
+func newPortfolioFixture(params entity.PortfolioParams, sessions []entity.QuotationSession,
+	pending ...int64) portfolioFixture {
+	f := portfolioFixture{sessions: newMemorySessions(sessions...), jobs: map[int64]*StrategyJob{}}
+	f.s = testService(testRepos{sessions: f.sessions, pfRepo: &memoryPortfolios{
+		statuses: map[int64]entity.RunnerStatus{},
+	}}, utils.NewFakeClock(testStart))
+	params.UserId = 5
+	f.run = &portfolioRun{
+		Portfolio: entity.Portfolio{ID: 3, UserId: 5, Params: params},
+		quit:      make(chan bool),
+		pending:   map[int64]bool{},
+	}
+	for _, id := range pending {
+		f.run.pending[id] = true
+	}
+	for _, p := range params.Sessions {
+		if f.run.pending[p.QuotationSessionId] {
+			continue
+		}
+		job := testJob(f.s, p.QuotationSessionId, 5, 1)
+		job.RunnerId = 100 + p.QuotationSessionId
+		job.PortfolioId = f.run.ID
+		job.portfolio = f.run
+		job.wakeup = make(chan struct{}, 1)
+		_ = f.s.runners.add(job)
+		f.jobs[p.QuotationSessionId] = job
+	}
+	f.s.portfolios.add(f.run)
+	return f
+}
+
+func (f portfolioFixture) states() map[int64]string {
+	res := map[int64]string{}
+	for _, session := range f.run.info().Sessions {
+		res[session.QuotationSessionId] = string(session.State)
+	}
+	return res
+}
+
+func TestAllocate(t *testing.T) {
+	params := entity.PortfolioParams{
+		MaxVolume:      4,
+		CategoryLimits: []entity.CategoryLimit{{CategoryId: 2, MaxWins: 1}},
+		Sessions: []entity.StrategyParams{
+			{QuotationSessionId: 1, MinimalPrice: 100},
+			{QuotationSessionId: 2, MinimalPrice: 100},
+			{QuotationSessionId: 3, MinimalPrice: 350},
+			{QuotationSessionId: 4, MinimalPrice: 800},
+			{QuotationSessionId: 5, MinimalPrice: 100},
+			{QuotationSessionId: 6, MinimalPrice: 100},
+			{QuotationSessionId: 7, MinimalPrice: 200},
+			{QuotationSessionId: 8, MinimalPrice: 100},
+		},
+	}
+	finished := portfolioSession(5, 500, 1, 1, 5)
+	finished.Status = entity.StatusFinished
+	f := newPortfolioFixture(params, []entity.QuotationSession{
+		// led by the provider, takes 2 units before the others
+		portfolioSession(1, 500, 2, 1, 5),
+		// margin 6
+		portfolioSession(2, 800, 1, 1, 9),
+		// margin 1, the capacity is taken by session 7 of margin 2
+		portfolioSession(3, 800, 1, 1, 9),
+		// no margin at the next bet
+		portfolioSession(4, 800, 1, 1, 9),
+		finished,
+		// the only session of category 2 the provider may win
+		portfolioSession(7, 700, 1, 2, 9),
+		// category 2 is taken by session 7 of a higher margin
+		portfolioSession(8, 300, 0, 2, 9),
+	}, 7)
+	// the runner of session 6 is gone
+	f.s.runners.remove(6, 5)
+	f.jobs[2].setWithdrawn(true)
+
+	if err := f.s.allocate(f.run); err != nil {
+		t.Fatalf("allocate() error = %v", err)
+	}
+	want := map[int64]string{
+		1: entity.PortfolioLeading,
+		2: entity.PortfolioBidding,
+		3: entity.PortfolioWithdrawn,
+		4: entity.PortfolioWithdrawn,
+		5: entity.PortfolioEnded,
+		6: entity.PortfolioEnded,
+		7: entity.PortfolioBidding,
+		8: entity.PortfolioWithdrawn,
+	}
+	got := f.states()
+	for id, state := range want {
+		if got[id] != state {
+			t.Errorf("session %d is %s, want %s", id, got[id], state)
+		}
+	}
+	usage := f.run.info().Usage
+	if usage.Volume != 4 || usage.Wins[1] != 2 || usage.Wins[2] != 1 {
+		t.Errorf("usage = %+v, want 4 units, 2 sessions of category 1 and 1 of category 2", usage)
+	}
+	if session, _ := f.run.session(2); session.RunnerId != 102 {
+		t.Errorf("runner of session 2 = %d, want 102", session.RunnerId)
+	}
+
+	for id, job := range f.jobs {
+		if id == 6 {
+			continue
+		}
+		if withdrawn := want[id] == entity.PortfolioWithdrawn; job.isWithdrawn() != withdrawn {
+			t.Errorf("runner of session %d withdrawn = %t, want %t", id, job.isWithdrawn(), withdrawn)
+		}
+	}
+	// the runner bidding again is woken up, the others are not
+	select {
+	case <-f.jobs[2].wakeup:
+	default:
+		t.Error("runner of session 2 bidding again is not woken up")
+	}
+	for _, id := range []int64{1, 3, 4} {
+		select {
+		case <-f.jobs[id].wakeup:
+			t.Errorf("runner of session %d is woken up", id)
+		default:
+		}
+	}
+}
+
+func TestAllocateReleasesCapacity(t *testing.T) {
+	params := entity.PortfolioParams{
+		MaxVolume: 2,
+		Sessions: []entity.StrategyParams{
+			{QuotationSessionId: 1, MinimalPrice: 100},
+			{QuotationSessionId: 2, MinimalPrice: 100},
+		},
+	}
+	f := newPortfolioFixture(params, []entity.QuotationSession{
+		portfolioSession(1, 500, 2, 1, 5),
+		portfolioSession(2, 800, 1, 1, 9),
+	})
+	if err := f.s.allocate(f.run); err != nil {
+		t.Fatalf("allocate() error = %v", err)
+	}
+	if got := f.states(); got[2] != entity.PortfolioWithdrawn {
+		t.Fatalf("session 2 is %s, want withdrawn while session 1 takes the capacity", got[2])
+	}
+
+	// the provider is outbid in session 1 of the lower margin
+	f.sessions.put(portfolioSession(1, 400, 2, 1, 9))
+	if err := f.s.allocate(f.run); err != nil {
+		t.Fatalf("allocate() error = %v", err)
+	}
+	got := f.states()
+	if got[1] != entity.PortfolioWithdrawn || got[2] != entity.PortfolioBidding {
+		t.Errorf("sessions are %v, want session 2 bidding and session 1 withdrawn", got)
+	}
+	if usage := f.run.info().Usage; usage.Volume != 1 {
+		t.Errorf("usage = %+v, want the unit of session 2", usage)
+	}
+	if f.jobs[2].isWithdrawn() || !f.jobs[1].isWithdrawn() {
+		t.Error("runners are not withdrawn as their sessions")
+	}
+}
+
+func TestCheckPortfolioBet(t *testing.T) {
+	params := entity.PortfolioParams{
+		MaxVolume: 1,
+		Sessions: []entity.StrategyParams{
+			{QuotationSessionId: 1, MinimalPrice: 100},
+			{QuotationSessionId: 2, MinimalPrice: 100},
+			{QuotationSessionId: 3, MinimalPrice: 900},
+		},
+	}
+	f := newPortfolioFixture(params, []entity.QuotationSession{
+		portfolioSession(1, 800, 1, 1, 9),
+		portfolioSession(2, 700, 1, 1, 9),
+		portfolioSession(3, 800, 0, 1, 9),
+	})
+	tests := []struct {
+		sessionId   int64
+		wantBlocked bool
+		wantMessage string
+	}{
+		{sessionId: 1},
+		{sessionId: 2, wantBlocked: true, wantMessage: "capacity is taken"},
+		{sessionId: 3, wantBlocked: true, wantMessage: "margin -0.2222 is gone"},
+	}
+	for _, tt := range tests {
+		var blocked entity.BlockedAction
+		got, err := f.s.checkPortfolioBet(f.jobs[tt.sessionId], &blocked)
+		if err != nil {
+			t.Fatalf("checkPortfolioBet() error = %v", err)
+		}
+		if got != tt.wantBlocked {
+			t.Errorf("checkPortfolioBet() in session %d = %t, want %t", tt.sessionId, got, tt.wantBlocked)
+		}
+		if !tt.wantBlocked {
+			continue
+		}
+		if blocked.Reason != entity.BlockPortfolioWithdrawn || !strings.Contains(blocked.Message, tt.wantMessage) {
+			t.Errorf("blocked action in session %d = %s %q, want the message %q", tt.sessionId,
+				blocked.Reason, blocked.Message, tt.wantMessage)
+		}
+	}
+
+	// a standalone runner has no portfolio to check
+	if got, err := f.s.checkPortfolioBet(testJob(f.s, 4, 5, 1), &entity.BlockedAction{}); got || err != nil {
+		t.Errorf("checkPortfolioBet() of a standalone runner = %t, %v", got, err)
+	}
+}
+
+func TestSuperviseRunFinishes(t *testing.T) {
+	f := newPortfolioFixture(entity.PortfolioParams{
+		Sessions: []entity.StrategyParams{{QuotationSessionId: 1, MinimalPrice: 100}},
+	}, []entity.QuotationSession{portfolioSession(1, 800, 1, 1, 9)})
+	f.s.runners.remove(1, 5)
+
+	f.s.superviseRun(f.run)
+	if f.s.portfolios.get(f.run.ID) != nil {
+		t.Error("portfolio without runners is still running")
+	}
+	portfolios := f.s.portfolioRepo.(*memoryPortfolios)
+	if status := portfolios.statuses[f.run.ID]; status != entity.RunnerFinished {
+		t.Errorf("portfolio status = %s, want %s", status, entity.RunnerFinished)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"main/logging"
+	"main/model/entity"
+	"time"
+)
+
+// betAfter makes the bet once the delay has passed, unless the job is stopped or paused meanwhile
+func (j *StrategyJob) betAfter(tick int64, delay time.Duration) error {
+	timer := j.ParentService.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-j.QuitChannel:
+		return nil
+	case <-timer.C():
+	}
+	if j.isPaused() {
+		logging.InfoFormat("Runner session-%d-user-%d is paused, the delayed bet is dropped",
+			j.QuotationSessionId, j.UserId)
+		return nil
+	}
+	// the session has moved on meanwhile, the bet is checked on its current state
+	return j.placeBet(tick, nil)
+}
+
+// stop ends the job on its own STOP action
+func (j *StrategyJob) stop() {
+	// a job stopped meanwhile is already saved
+	if j.ParentService.runners.release(j) {
+		logging.InfoFormat("Runner session-%d-user-%d stopped itself", j.QuotationSessionId, j.UserId)
+		j.ParentService.saveStatus(j.Info().RunnerId, entity.RunnerStopped)
+	}
+}
+
+// awaitStep makes the ticks wait for a bet newer than the current one after WAIT_NEXT_STEP
+func (j *StrategyJob) awaitStep() {
+	if j.S.StepBased() {
+		// the job ticks on the next steps only anyway
+		return
+	}
+	j.awaiting = true
+	j.awaitedStep = j.CurrentStepNumber
+}
+
+// stepAwaited reports whether a bet has been made in the session since WAIT_NEXT_STEP
+func (j *StrategyJob) stepAwaited(session entity.QuotationSession) bool {
+	if session.LastBet == nil || int64(session.LastBet.BetNumber) <= j.awaitedStep {
+		return false
+	}
+	j.awaiting = false
+	return true
+}
+
+// notify sends the message of NOTIFY to the account which launched the runner
+func (s *StrategyService) notify(notification entity.RunnerNotification) error {
+	logging.InfoFormat("Runner session-%d-user-%d notifies account %d: %s", notification.QuotationSessionId,
+		notification.UserId, notification.AccountId, notification.Message)
+	_, err := s.notificationRepo.InsertNotification(s.ctx, notification)
+	return err
+}
+
+// GetNotifications returns the notifications sent by the runners, the latest first
+func (s *StrategyService) GetNotifications(filter entity.RunnerNotificationFilter) ([]*entity.RunnerNotification, error) {
+	return s.notificationRepo.GetNotifications(s.ctx, filter)
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedRandom is a random source safe for concurrent use
+type lockedRandom struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newLockedRandom(seed int64) *lockedRandom {
+	return &lockedRandom{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRandom) Int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Int63n(n)
+}
+
+func (r *lockedRandom) seed(seed int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rnd.Seed(seed)
+}
+
+// SeedJitter reseeds the random source of the runners, so that the jitter of their ticks and bets is reproducible
+func (s *StrategyService) SeedJitter(seed int64) {
+	s.jitter.seed(seed)
+}
+
+// betDelay returns the random delay of a bet planned after the given delay, bounded by the end of the session
+func (j *StrategyJob) betDelay(planned time.Duration) time.Duration {
+	return j.S.Jitter.BetDelay(j.ParentService.jitter, j.TimeTillEnd-planned)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"main/model/entity"
+	"main/utils"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jitteredJob is a job of user 5 in session 1 evaluating the strategy every 10s with the jitter
+func jitteredJob(t *testing.T, s *StrategyService, jitter string) *StrategyJob {
+	t.Helper()
+	job := testJob(s, 1, 5, 1)
+	job.S = parsedStrategy(t, "EVERY 10s\n"+jitter+"\nIF currentWinnerId != myId THEN BET\nELSE WAIT_TIME")
+	job.TimeTillEnd = 30 * time.Minute
+	return job
+}
+
+func TestTickJitter(t *testing.T) {
+	s := testService(testRepos{}, utils.NewFakeClock(testStart))
+	if got := jitteredJob(t, s, "").interval(); got != 10*time.Second {
+		t.Errorf("interval() without jitter = %s, want 10s", got)
+	}
+
+	job := jitteredJob(t, s, "JITTER TICK 2s")
+	lowest, highest := time.Duration(1<<62), time.Duration(0)
+	for i := 0; i < 1000; i++ {
+		got := job.interval()
+		if got < 8*time.Second || got > 12*time.Second {
+			t.Fatalf("interval() = %s, want within 10s ± 2s", got)
+		}
+		if got < lowest {
+			lowest = got
+		}
+		if got > highest {
+			highest = got
+		}
+	}
+	// the offsets spread over the bounds
+	if lowest > 9*time.Second || highest < 11*time.Second {
+		t.Errorf("intervals are within %s and %s, want them spread over 10s ± 2s", lowest, highest)
+	}
+}
+
+func TestBetDelay(t *testing.T) {
+	tests := []struct {
+		name        string
+		jitter      string
+		timeTillEnd time.Duration
+		planned     time.Duration
+		min         time.Duration
+		max         time.Duration
+	}{
+		{name: "no jitter", timeTillEnd: time.Minute},
+		{name: "tick jitter only", jitter: "JITTER TICK 2s", timeTillEnd: time.Minute},
+		{name: "within the bounds", jitter: "JITTER BET 1s TO 3s", timeTillEnd: time.Minute, min: time.Second,
+			max: 3 * time.Second},
+		{name: "up to the max", jitter: "JITTER BET 2s", timeTillEnd: time.Minute, max: 2 * time.Second},
+		{name: "fixed delay", jitter: "JITTER BET 2s TO 2s", timeTillEnd: time.Minute, min: 2 * time.Second,
+			max: 2 * time.Second},
+		{
+			name:        "cut by the end of the session",
+			jitter:      "JITTER BET 5s TO 10s",
+			timeTillEnd: 4 * time.Second,
+			min:         3 * time.Second,
+			max:         3 * time.Second,
+		},
+		{
+			name:        "cut by the end after the planned delay",
+			jitter:      "JITTER BET 5s TO 10s",
+			timeTillEnd: time.Minute,
+			planned:     55 * time.Second,
+			min:         4 * time.Second,
+			max:         4 * time.Second,
+		},
+		{name: "end too close", jitter: "JITTER BET 5s TO 10s", timeTillEnd: 500 * time.Millisecond},
+		{name: "session over", jitter: "JITTER BET 5s TO 10s", timeTillEnd: -time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testService(testRepos{}, utils.NewFakeClock(testStart))
+			job := jitteredJob(t, s, tt.jitter)
+			job.TimeTillEnd = tt.timeTillEnd
+			for i := 0; i < 100; i++ {
+				if got := job.betDelay(tt.planned); got < tt.min || got > tt.max {
+					t.Fatalf("betDelay() = %s, want within %s and %s", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+// timings draws the next tick intervals and bet delays of the job
+func timings(job *StrategyJob) []time.Duration {
+	res := make([]time.Duration, 0, 20)
+	for i := 0; i < 10; i++ {
+		res = append(res, job.interval(), job.betDelay(0))
+	}
+	return res
+}
+
+func TestSeedJitter(t *testing.T) {
+	const jitter = "JITTER TICK 2s BET 1s TO 3s"
+	seeded := func(seed int64) *StrategyJob {
+		s := testService(testRepos{}, utils.NewFakeClock(testStart))
+		s.SeedJitter(seed)
+		return jitteredJob(t, s, jitter)
+	}
+
+	job := seeded(42)
+	first := timings(job)
+	if again := timings(seeded(42)); !reflect.DeepEqual(again, first) {
+		t.Errorf("timings of the same seed = %v, want %v", again, first)
+	}
+	if other := timings(seeded(43)); reflect.DeepEqual(other, first) {
+		t.Errorf("timings of another seed = %v, want them to differ", other)
+	}
+	// reseeding replays the timings
+	job.ParentService.SeedJitter(42)
+	if replayed := timings(job); !reflect.DeepEqual(replayed, first) {
+		t.Errorf("timings after reseeding = %v, want %v", replayed, first)
+	}
+}
+
+// TestJitterSharedByRunners draws the timings of the runners of a service concurrently
+func TestJitterSharedByRunners(t *testing.T) {
+	s := testService(testRepos{}, utils.NewFakeClock(testStart))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		job := jitteredJob(t, s, "JITTER TICK 2s BET 1s TO 3s")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, d := range timings(job) {
+				if d < time.Second || d > 12*time.Second {
+					t.Errorf("timing %s is out of the bounds", d)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestJitteredBetWaits(t *testing.T) {
+	bets := &memoryBets{}
+	clock := newTimerClock()
+	s := testService(testRepos{
+		sessions: newMemorySessions(guardedSession()),
+		bets:     bets,
+		limits:   newMemoryLimits(),
+	}, clock)
+	job := jitteredJob(t, s, "JITTER BET 1s TO 3s")
+	job.QuitChannel = make(chan bool)
+	_ = s.runners.add(job)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- job.perform(1, entity.StrategyDecision{Action: entity.ActionBet}, guardedSession())
+	}()
+	delay := clock.wait(t)
+	if delay < time.Second || delay > 3*time.Second {
+		t.Fatalf("bet waits %s, want within 1s and 3s", delay)
+	}
+	clock.Advance(delay - time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("bet made before its delay, error = %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if made := bets.madeBets(); len(made) != 0 {
+		t.Fatalf("bets made = %+v before the delay", made)
+	}
+
+	clock.Advance(time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("perform() error = %v", err)
+	}
+	if made := bets.madeBets(); len(made) != 1 {
+		t.Errorf("bets made = %+v, want the delayed one", made)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+	"main/model/entity"
+	"sort"
+	"sync"
+)
+
+// runnerKey identifies a runner, a user has at most one runner in a session
+type runnerKey struct {
+	sessionId int64
+	userId    int64
+}
+
+// runnerRegistry keeps the runners of the service instance, safe for concurrent use
+type runnerRegistry struct {
+	mu   sync.RWMutex
+	jobs map[runnerKey]*StrategyJob
+	// watches the bets of the sessions the runners are registered in
+	events *betEvents
+}
+
+func newRunnerRegistry(events *betEvents) *runnerRegistry {
+	return &runnerRegistry{
+		jobs:   map[runnerKey]*StrategyJob{},
+		events: events,
+	}
+}
+
+// add registers the job and watches the bets of its session,
+// it fails when the user already has a runner in the session
+func (r *runnerRegistry) add(job *StrategyJob) error {
+	key := runnerKey{job.QuotationSessionId, job.UserId}
+	r.mu.Lock()
+	if _, ok := r.jobs[key]; ok {
+		r.mu.Unlock()
+		return fmt.Errorf("runner session-%d-user-%d is already running", key.sessionId, key.userId)
+	}
+	r.jobs[key] = job
+	r.mu.Unlock()
+	r.events.watch(key.sessionId)
+	return nil
+}
+
+func (r *runnerRegistry) get(sessionId int64, userId int64) *StrategyJob {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.jobs[runnerKey{sessionId, userId}]
+}
+
+// remove unregisters the job and returns it, nil when there is no such runner
+func (r *runnerRegistry) remove(sessionId int64, userId int64) *StrategyJob {
+	key := runnerKey{sessionId, userId}
+	r.mu.Lock()
+	job, ok := r.jobs[key]
+	delete(r.jobs, key)
+	r.mu.Unlock()
+	if ok {
+		r.events.unwatch(sessionId)
+	}
+	return job
+}
+
+// release unregisters the job unless it has been removed already,
+// it reports whether the job was registered
+func (r *runnerRegistry) release(job *StrategyJob) bool {
+	key := runnerKey{job.QuotationSessionId, job.UserId}
+	r.mu.Lock()
+	registered := r.jobs[key] == job
+	if registered {
+		delete(r.jobs, key)
+	}
+	r.mu.Unlock()
+	if registered {
+		r.events.unwatch(key.sessionId)
+	}
+	return registered
+}
+
+// list returns the runners matching the filter ordered by session and user
+func (r *runnerRegistry) list(filter entity.RunnerFilter) []*StrategyJob {
+	r.mu.RLock()
+	res := make([]*StrategyJob, 0, len(r.jobs))
+	for key, job := range r.jobs {
+		if filter.QuotationSessionId != 0 && key.sessionId != filter.QuotationSessionId ||
+			filter.UserId != 0 && key.userId != filter.UserId ||
+			filter.AccountId != 0 && job.AccountId != filter.AccountId ||
+			filter.ProfileId != 0 && job.ProfileId != filter.ProfileId ||
+			filter.PortfolioId != 0 && job.PortfolioId != filter.PortfolioId {
+			continue
+		}
+		res = append(res, job)
+	}
+	r.mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].QuotationSessionId != res[j].QuotationSessionId {
+			return res[i].QuotationSessionId < res[j].QuotationSessionId
+		}
+		return res[i].UserId < res[j].UserId
+	})
+	return res
+}
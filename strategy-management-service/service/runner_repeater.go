@@ -0,0 +1,25 @@
+package service
+
+import (
+	"main/model/entity"
+	"time"
+)
+
+// stepPolling is how often a step based runner looks for new bets in its session
+// when the bet notifications are not listened to
+const stepPolling = 5 * time.Second
+
+// stepDue reports whether a step based job ticks on the session, i.e. the session has reached a step its
+// repeater evaluates. The step is claimed before the tick, so repeated notifications of a bet and the polling
+// never make the job evaluate a step twice
+func (j *StrategyJob) stepDue(session entity.QuotationSession) bool {
+	if session.LastBet == nil {
+		return false
+	}
+	step := int64(session.LastBet.BetNumber)
+	if !j.S.StepDue(j.lastStep, step) {
+		return false
+	}
+	j.lastStep = step
+	return true
+}
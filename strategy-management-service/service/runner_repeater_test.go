@@ -0,0 +1,155 @@
+package service
+
+import (
+	"main/model/entity"
+	"main/utils"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// stepSession is session 1 of 1% steps from 1000 after the given number of bets, providers 8 and 9 take turns
+func stepSession(bets int) (entity.QuotationSession, []*entity.Bet) {
+	session := entity.QuotationSession{
+		ID:                 1,
+		Status:             entity.StatusActive,
+		StartPrice:         1000,
+		CurrentPrice:       1000,
+		SessionStepPercent: 1,
+		StartTime:          testStart.Add(-10 * time.Minute),
+		SessionDuration:    60,
+	}
+	history := make([]*entity.Bet, 0, bets)
+	for i := 0; i < bets; i++ {
+		bet := &entity.Bet{
+			ID:                 int64(i + 1),
+			QuotationSessionID: session.ID,
+			ProviderId:         int64(8 + i%2),
+			Time:               session.StartTime.Add(time.Duration(i) * time.Second),
+			NewPrice:           1000 - float64(i+1)*10,
+			BetNumber:          i,
+		}
+		history = append(history, bet)
+		session.CurrentPrice, session.LastBet, session.LastBetId = bet.NewPrice, bet, bet.ID
+	}
+	return session, history
+}
+
+func TestStepRepeater(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		// number of bets made in the session before each poll, the runner is launched on the first one
+		polls []int
+		want  []int64
+	}{
+		{
+			name:     "each step once",
+			strategy: "EVERY STEP\nIF currentPrice < 0 THEN BET\nELSE WAIT_TIME",
+			polls:    []int{0, 1, 1, 2, 3, 3, 3, 4},
+			want:     []int64{0, 1, 2, 3},
+		},
+		{
+			name:     "steps made between the polls",
+			strategy: "EVERY STEP\nIF currentPrice < 0 THEN BET\nELSE WAIT_TIME",
+			polls:    []int{2, 5, 5, 6},
+			want:     []int64{4, 5},
+		},
+		{
+			name:     "every 3 steps",
+			strategy: "EVERY 3 STEPS\nIF currentPrice < 0 THEN BET\nELSE WAIT_TIME",
+			polls:    []int{1, 2, 3, 4, 4, 5, 6, 7, 8},
+			want:     []int64{3, 6},
+		},
+		{
+			// the next 3 steps count from the step evaluated
+			name:     "every 3 steps made between the polls",
+			strategy: "EVERY 3 STEPS\nIF currentPrice < 0 THEN BET\nELSE WAIT_TIME",
+			polls:    []int{1, 3, 6, 7, 9, 10},
+			want:     []int64{5, 8},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := newMemorySessions()
+			bets := &memoryBets{history: map[int64][]*entity.Bet{}}
+			traces := &memoryTraces{}
+			runners := newMemoryRunners(entity.StrategyRunner{ID: 1})
+			clock := utils.NewFakeClock(testStart)
+			s := testService(testRepos{sessions: sessions, bets: bets, traces: traces, runners: runners}, clock)
+			job := testJob(s, 1, 5, 1)
+			job.RunnerId = 1
+			job.S = parsedStrategy(t, tt.strategy)
+
+			var polled []time.Time
+			var tick int64
+			for i, n := range tt.polls {
+				session, history := stepSession(n)
+				sessions.put(session)
+				bets.mu.Lock()
+				bets.history[session.ID] = history
+				bets.mu.Unlock()
+				if i == 0 {
+					// the step of the launch is handled by the initial bet
+					job.lastStep = session.CurrentStep()
+				}
+
+				current, ready, ended := job.ready(sessions)
+				if ended {
+					t.Fatalf("poll %d: runner finished", i)
+				}
+				if ready {
+					if job.step(tick, current, sessions, bets) {
+						t.Fatalf("poll %d: runner finished on the tick", i)
+					}
+					polled = append(polled, clock.Now())
+					tick++
+				}
+				if interval := job.interval(); interval != stepPolling {
+					t.Fatalf("interval() = %s, want %s", interval, stepPolling)
+				}
+				clock.Advance(job.interval())
+			}
+
+			saved := traces.saved()
+			var steps []int64
+			for i, trace := range saved {
+				steps = append(steps, trace.State.CurrentStepNumber)
+				if trace.Tick != int64(i) || !trace.Time.Equal(polled[i]) {
+					t.Errorf("trace %d is tick %d at %s, want at %s", i, trace.Tick, trace.Time, polled[i])
+				}
+			}
+			if !reflect.DeepEqual(steps, tt.want) {
+				t.Errorf("steps evaluated = %v, want %v", steps, tt.want)
+			}
+			if sessions.reads != len(tt.polls) {
+				t.Errorf("session read %d times, want once per poll %d", sessions.reads, len(tt.polls))
+			}
+			runner := runners.runner(1)
+			last := tt.want[len(tt.want)-1]
+			if runner.LastStep != last || runner.LastTick != int64(len(tt.want)-1) {
+				t.Errorf("runner saved on tick %d step %d, want tick %d step %d",
+					runner.LastTick, runner.LastStep, len(tt.want)-1, last)
+			}
+		})
+	}
+}
+
+func TestStepDueWithoutBets(t *testing.T) {
+	s := testService(testRepos{}, utils.NewFakeClock(testStart))
+	job := testJob(s, 1, 5, 1)
+	job.S = entity.Strategy{R: entity.RepeaterFES}
+	job.lastStep = -1
+
+	session, _ := stepSession(0)
+	if job.stepDue(session) || job.lastStep != -1 {
+		t.Errorf("stepDue() without bets claimed step %d", job.lastStep)
+	}
+	session, _ = stepSession(1)
+	if !job.stepDue(session) || job.lastStep != 0 {
+		t.Errorf("stepDue() of the first bet = false, last step %d", job.lastStep)
+	}
+	if job.stepDue(session) {
+		t.Error("stepDue() claimed the first bet twice")
+	}
+}
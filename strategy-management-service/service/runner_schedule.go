@@ -0,0 +1,215 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"main/dsl"
+	"main/logging"
+	"main/model/entity"
+	"sync"
+	"time"
+)
+
+var ErrScheduleNotFound = errors.New("scheduled runner not found")
+
+// triggerPolling is the period the start conditions of the scheduled runners are checked with
+const triggerPolling = 5 * time.Second
+
+// scheduledStart is a runner waiting for its start time or condition
+type scheduledStart struct {
+	runner  entity.StrategyRunner
+	trigger *entity.Operator
+	cancel  chan bool
+	// calculates the state the trigger is evaluated on, it is not registered.
+	// Only used by the goroutine awaiting the start, the job keeps the bets it has read
+	job *StrategyJob
+}
+
+// scheduleRegistry keeps the scheduled runners of the service instance by runner ID, safe for concurrent use
+type scheduleRegistry struct {
+	mu     sync.Mutex
+	starts map[int64]*scheduledStart
+}
+
+func (r *scheduleRegistry) add(start *scheduledStart) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.starts[start.runner.ID] = start
+}
+
+func (r *scheduleRegistry) get(id int64) *scheduledStart {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.starts[id]
+}
+
+// remove unregisters the start, it reports whether the start was registered
+func (r *scheduleRegistry) remove(id int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.starts[id]
+	delete(r.starts, id)
+	return ok
+}
+
+// schedule stores the runner as scheduled and waits for its start
+func (s *StrategyService) schedule(runner entity.StrategyRunner) error {
+	trigger, err := parseTrigger(runner.Params)
+	if err != nil {
+		return err
+	}
+	runner.Status = entity.RunnerScheduled
+	runner.StartedAt = time.Time{}
+	runner.ID, err = s.runnerRepo.InsertRunner(s.ctx, runner)
+	if err != nil {
+		return fmt.Errorf("cannot save scheduled runner session-%d-user-%d: %w",
+			runner.QuotationSessionId, runner.UserId, err)
+	}
+	start := &scheduledStart{runner: runner, trigger: trigger, cancel: make(chan bool)}
+	s.schedules.add(start)
+	logging.InfoFormat("Runner %d of session-%d-user-%d is scheduled", runner.ID, runner.QuotationSessionId,
+		runner.UserId)
+	go s.await(start)
+	return nil
+}
+
+// parseTrigger compiles the start condition of the params, nil when there is none
+func parseTrigger(params entity.StrategyParams) (*entity.Operator, error) {
+	if params.StartWhen == "" {
+		return nil, nil
+	}
+	trigger, err := dsl.ParseCondition(params.StartWhen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: start_when: %s", ErrInvalidStrategy, err)
+	}
+	return &trigger, nil
+}
+
+// await launches the runner once its start time has come and its condition is true.
+// The start is dropped when it is cancelled, and finished when the session ends meanwhile
+func (s *StrategyService) await(start *scheduledStart) {
+	runner := start.runner
+	if at := runner.Params.StartAt; at != nil {
+		timer := s.clock.NewTimer(at.Sub(s.clock.Now()))
+		select {
+		case <-start.cancel:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+	for {
+		fired, ended := s.triggered(start)
+		if ended || fired {
+			break
+		}
+		timer := s.clock.NewTimer(triggerPolling)
+		select {
+		case <-start.cancel:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+	// cancelled meanwhile
+	if !s.schedules.remove(runner.ID) {
+		return
+	}
+	if err := s.launch(runner); err != nil {
+		logging.ErrorFormat("Cannot start scheduled runner %d of session-%d-user-%d: %s", runner.ID,
+			runner.QuotationSessionId, runner.UserId, err)
+		s.saveStatus(runner.ID, entity.RunnerStopped)
+		return
+	}
+	logging.InfoFormat("Scheduled runner %d of session-%d-user-%d started", runner.ID,
+		runner.QuotationSessionId, runner.UserId)
+}
+
+// triggered evaluates the start condition of the runner on the current state of the session,
+// the runner is finished instead when the session has ended
+func (s *StrategyService) triggered(start *scheduledStart) (fired bool, ended bool) {
+	runner := start.runner
+	session, err := s.quotationSessionRepo.GetSessionById(s.ctx, runner.QuotationSessionId)
+	if err == nil && session.Status == entity.StatusActive && start.trigger != nil {
+		if start.job == nil {
+			start.job = s.newJob(runner)
+		}
+		session, err = start.job.recalculateCurrentState(&session, s.quotationSessionRepo, s.betRepo)
+	}
+	if err != nil {
+		logging.ErrorFormat("Cannot check start condition of runner %d: %s", runner.ID, err)
+		return false, false
+	}
+	if session.Status != entity.StatusActive {
+		if s.schedules.remove(runner.ID) {
+			s.saveOutcome(runner.ID, session, runner.UserId)
+		}
+		return false, true
+	}
+	return start.trigger == nil || start.trigger.Evaluate(start.job.CurrentSessionState), false
+}
+
+// ListScheduled returns the runners waiting for their start, filter.AccountId is the account
+// which scheduled them, any account when 0
+func (s *StrategyService) ListScheduled(filter entity.RunnerFilter) ([]*entity.StrategyRunner, error) {
+	stored, err := s.runnerRepo.GetRunnersByStatus(s.ctx, entity.RunnerScheduled)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*entity.StrategyRunner, 0, len(stored))
+	for _, runner := range stored {
+		if filter.QuotationSessionId != 0 && runner.QuotationSessionId != filter.QuotationSessionId ||
+			filter.UserId != 0 && runner.UserId != filter.UserId ||
+			filter.AccountId != 0 && runner.AccountId != filter.AccountId {
+			continue
+		}
+		res = append(res, runner)
+	}
+	return res, nil
+}
+
+// CancelScheduled cancels the start of a scheduled runner, accountId is the account
+// which scheduled it, any account when 0
+func (s *StrategyService) CancelScheduled(accountId int64, id int64) (entity.StrategyRunner, error) {
+	start := s.schedules.get(id)
+	if start == nil {
+		return entity.StrategyRunner{}, fmt.Errorf("%w: %d", ErrScheduleNotFound, id)
+	}
+	if accountId != 0 && start.runner.AccountId != accountId {
+		return entity.StrategyRunner{}, ErrRunnerAccessDenied
+	}
+	// started or cancelled meanwhile
+	if !s.schedules.remove(id) {
+		return entity.StrategyRunner{}, fmt.Errorf("%w: %d", ErrScheduleNotFound, id)
+	}
+	close(start.cancel)
+	s.saveStatus(id, entity.RunnerCancelled)
+	logging.InfoFormat("Scheduled runner %d of session-%d-user-%d is cancelled", id,
+		start.runner.QuotationSessionId, start.runner.UserId)
+	res := start.runner
+	res.Status = entity.RunnerCancelled
+	return res, nil
+}
+
+// resumeScheduled waits again for the starts scheduled with the previous service instance,
+// the starts with an invalid condition are cancelled
+func (s *StrategyService) resumeScheduled() error {
+	stored, err := s.runnerRepo.GetRunnersByStatus(s.ctx, entity.RunnerScheduled)
+	if err != nil {
+		return err
+	}
+	for _, runner := range stored {
+		trigger, err := parseTrigger(runner.Params)
+		if err != nil {
+			logging.ErrorFormat("Cannot resume scheduled runner %d: %s", runner.ID, err)
+			s.saveStatus(runner.ID, entity.RunnerCancelled)
+			continue
+		}
+		start := &scheduledStart{runner: *runner, trigger: trigger, cancel: make(chan bool)}
+		s.schedules.add(start)
+		go s.await(start)
+		logging.InfoFormat("Resumed scheduled runner %d of session-%d-user-%d", runner.ID,
+			runner.QuotationSessionId, runner.UserId)
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"errors"
+	"main/model/entity"
+	"main/utils"
+	"testing"
+	"time"
+)
+
+// waitingSource never bets once launched
+const waitingSource = "EVERY 60s\nIF currentPrice < 0 THEN BET\nELSE WAIT_TIME"
+
+// timerClock is a fake clock telling the test about every timer the service waits on,
+// so that the clock is advanced only once the goroutines wait for it
+type timerClock struct {
+	*utils.FakeClock
+	timers chan time.Duration
+}
+
+func newTimerClock() timerClock {
+	return timerClock{FakeClock: utils.NewFakeClock(testStart), timers: make(chan time.Duration, 16)}
+}
+
+func (c timerClock) NewTimer(d time.Duration) utils.Timer {
+	timer := c.FakeClock.NewTimer(d)
+	c.timers <- d
+	return timer
+}
+
+// wait returns the duration of the next timer waited on
+func (c timerClock) wait(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case d := <-c.timers:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("no timer is waited on")
+	}
+	return 0
+}
+
+// scheduleFixture runs the service on session 1 led by provider 9 at the price 800,
+// account 10 of organisation 1 schedules the runners of user 5
+type scheduleFixture struct {
+	s        *StrategyService
+	sessions *memorySessions
+	runners  *memoryRunners
+	clock    timerClock
+}
+
+func newScheduleFixture(t *testing.T, stored ...entity.StrategyRunner) scheduleFixture {
+	f := scheduleFixture{
+		sessions: newMemorySessions(guardedSession()),
+		runners:  newMemoryRunners(stored...),
+		clock:    newTimerClock(),
+	}
+	f.runners.updates = make(chan entity.StrategyRunner, 64)
+	f.s = testService(testRepos{
+		sessions: f.sessions,
+		bets:     &memoryBets{},
+		runners:  f.runners,
+		traces:   &memoryTraces{},
+		accounts: memoryAccounts{profiles: map[int64]int64{10: 1}},
+		limits:   newMemoryLimits(),
+		pfRepo:   &memoryPortfolios{statuses: map[int64]entity.RunnerStatus{}},
+	}, f.clock)
+	t.Cleanup(func() {
+		for _, job := range f.s.runners.list(entity.RunnerFilter{}) {
+			_ = f.s.StopRunner(0, job.QuotationSessionId, job.UserId)
+		}
+	})
+	return f
+}
+
+// schedule schedules the runner of user 5 without the initial bet, returns its ID
+func (f scheduleFixture) schedule(t *testing.T, params entity.StrategyParams) int64 {
+	t.Helper()
+	params.UserId, params.QuotationSessionId, params.AccountId = 5, 1, 10
+	params.Source, params.NoInitialBet = waitingSource, true
+	if err := f.s.RunStrategyRunner(params); err != nil {
+		t.Fatalf("RunStrategyRunner() error = %v", err)
+	}
+	scheduled, err := f.s.ListScheduled(entity.RunnerFilter{})
+	if err != nil || len(scheduled) != 1 {
+		t.Fatalf("ListScheduled() = %+v, %v, want the runner", scheduled, err)
+	}
+	return scheduled[0].ID
+}
+
+// waitStatus waits until the runner is saved with the status
+func (f scheduleFixture) waitStatus(t *testing.T, id int64, status entity.RunnerStatus) entity.StrategyRunner {
+	t.Helper()
+	for {
+		select {
+		case runner := <-f.runners.updates:
+			if runner.ID == id && runner.Status == status {
+				return runner
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("runner %d is %s, want %s", id, f.runners.runner(id).Status, status)
+		}
+	}
+}
+
+// started checks the runner has started at the time: it is saved running and its job is registered
+func (f scheduleFixture) started(t *testing.T, id int64, at time.Time) {
+	t.Helper()
+	// the job waits for its next tick once started
+	if d := f.clock.wait(t); d != 60*time.Second {
+		t.Fatalf("job waits %s, want 60s", d)
+	}
+	runner := f.runners.runner(id)
+	if runner.Status != entity.RunnerRunning || !runner.StartedAt.Equal(at) {
+		t.Errorf("runner is %s since %s, want running since %s", runner.Status, runner.StartedAt, at)
+	}
+	if job := f.s.runners.get(1, 5); job == nil || job.Info().RunnerId != id {
+		t.Error("started runner is not registered")
+	}
+	if scheduled, _ := f.s.ListScheduled(entity.RunnerFilter{}); len(scheduled) != 0 {
+		t.Errorf("scheduled runners = %+v, want none", scheduled)
+	}
+	if f.s.schedules.get(id) != nil {
+		t.Error("started runner still awaits its start")
+	}
+}
+
+func TestScheduleStartAt(t *testing.T) {
+	f := newScheduleFixture(t)
+	at := testStart.Add(time.Minute)
+	id := f.schedule(t, entity.StrategyParams{StartAt: &at})
+	if runner := f.runners.runner(id); runner.Status != entity.RunnerScheduled || !runner.StartedAt.IsZero() {
+		t.Errorf("runner is %s since %s, want scheduled", runner.Status, runner.StartedAt)
+	}
+	if f.s.runners.get(1, 5) != nil {
+		t.Error("scheduled runner is registered before its start")
+	}
+	if d := f.clock.wait(t); d != time.Minute {
+		t.Fatalf("start waits %s, want 1m", d)
+	}
+
+	f.clock.Advance(time.Minute)
+	f.started(t, id, at)
+}
+
+func TestScheduleStartWhen(t *testing.T) {
+	f := newScheduleFixture(t)
+	id := f.schedule(t, entity.StrategyParams{StartWhen: "currentPrice < 800"})
+
+	// the condition is checked at once and then polled
+	for i := 0; i < 2; i++ {
+		if d := f.clock.wait(t); d != triggerPolling {
+			t.Fatalf("start waits %s, want %s", d, triggerPolling)
+		}
+		if runner := f.runners.runner(id); runner.Status != entity.RunnerScheduled {
+			t.Fatalf("runner is %s before its condition is true", runner.Status)
+		}
+		if i == 0 {
+			f.clock.Advance(triggerPolling)
+		}
+	}
+
+	session := guardedSession()
+	session.CurrentPrice = 700
+	session.LastBet = &entity.Bet{ProviderId: 8, BetNumber: 1}
+	f.sessions.put(session)
+	f.clock.Advance(triggerPolling)
+	f.started(t, id, testStart.Add(2*triggerPolling))
+}
+
+func TestScheduleSessionEnded(t *testing.T) {
+	f := newScheduleFixture(t)
+	id := f.schedule(t, entity.StrategyParams{StartWhen: "currentPrice < 800"})
+	f.clock.wait(t)
+
+	session := guardedSession()
+	session.Status = entity.StatusFinished
+	f.sessions.put(session)
+	f.clock.Advance(triggerPolling)
+
+	runner := f.waitStatus(t, id, entity.RunnerFinished)
+	if runner.Outcome == nil || runner.Outcome.Won || runner.Outcome.WinnerId != 9 {
+		t.Errorf("outcome = %+v, want lost to provider 9", runner.Outcome)
+	}
+	if f.s.schedules.get(id) != nil || f.s.runners.get(1, 5) != nil {
+		t.Error("runner of the ended session is left")
+	}
+}
+
+func TestCancelScheduled(t *testing.T) {
+	f := newScheduleFixture(t)
+	at := testStart.Add(time.Minute)
+	id := f.schedule(t, entity.StrategyParams{StartAt: &at})
+	f.clock.wait(t)
+
+	if _, err := f.s.CancelScheduled(20, id); !errors.Is(err, ErrRunnerAccessDenied) {
+		t.Errorf("CancelScheduled() by another account error = %v, want %v", err, ErrRunnerAccessDenied)
+	}
+	if _, err := f.s.CancelScheduled(10, id+1); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("CancelScheduled() of an unknown runner error = %v, want %v", err, ErrScheduleNotFound)
+	}
+	cancelled, err := f.s.CancelScheduled(10, id)
+	if err != nil || cancelled.ID != id || cancelled.Status != entity.RunnerCancelled {
+		t.Fatalf("CancelScheduled() = %+v, %v, want the runner cancelled", cancelled, err)
+	}
+	if runner := f.runners.runner(id); runner.Status != entity.RunnerCancelled || !runner.StoppedAt.Equal(testStart) {
+		t.Errorf("runner is %s since %s, want cancelled", runner.Status, runner.StoppedAt)
+	}
+	if _, err = f.s.CancelScheduled(10, id); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("CancelScheduled() twice error = %v, want %v", err, ErrScheduleNotFound)
+	}
+
+	// the start time passing does not start it
+	f.clock.Advance(time.Minute)
+	select {
+	case d := <-f.clock.timers:
+		t.Errorf("cancelled runner waits %s", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if f.s.runners.get(1, 5) != nil || f.runners.runner(id).Status != entity.RunnerCancelled {
+		t.Error("cancelled runner has started")
+	}
+}
+
+func TestCancelScheduledWhileStarting(t *testing.T) {
+	f := newScheduleFixture(t)
+	at := testStart.Add(time.Minute)
+	id := f.schedule(t, entity.StrategyParams{StartAt: &at})
+	f.clock.wait(t)
+
+	// cancelled once the start time has come, while the start checks the session
+	cancelled := make(chan error, 1)
+	f.sessions.mu.Lock()
+	f.sessions.meanwhile = func() {
+		_, err := f.s.CancelScheduled(10, id)
+		cancelled <- err
+	}
+	f.sessions.mu.Unlock()
+	f.clock.Advance(time.Minute)
+	if err := <-cancelled; err != nil {
+		t.Fatalf("CancelScheduled() error = %v", err)
+	}
+	f.waitStatus(t, id, entity.RunnerCancelled)
+	select {
+	case d := <-f.clock.timers:
+		t.Errorf("cancelled runner waits %s", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if f.s.runners.get(1, 5) != nil || f.runners.runner(id).Status != entity.RunnerCancelled {
+		t.Error("cancelled runner has started")
+	}
+}
+
+func TestCancelStarted(t *testing.T) {
+	f := newScheduleFixture(t)
+	at := testStart.Add(time.Minute)
+	id := f.schedule(t, entity.StrategyParams{StartAt: &at})
+	f.clock.wait(t)
+	f.clock.Advance(time.Minute)
+	f.started(t, id, at)
+
+	if _, err := f.s.CancelScheduled(10, id); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("CancelScheduled() of a started runner error = %v, want %v", err, ErrScheduleNotFound)
+	}
+	if f.s.runners.get(1, 5) == nil || f.runners.runner(id).Status != entity.RunnerRunning {
+		t.Error("started runner is cancelled")
+	}
+}
+
+// TestCancelScheduledRacingStart cancels the runners as their start time comes:
+// each is either cancelled or started, never both
+func TestCancelScheduledRacingStart(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		f := newScheduleFixture(t)
+		at := testStart.Add(time.Minute)
+		id := f.schedule(t, entity.StrategyParams{StartAt: &at})
+		f.clock.wait(t)
+
+		go f.clock.Advance(time.Minute)
+		_, err := f.s.CancelScheduled(10, id)
+		switch {
+		case err == nil:
+			if f.s.runners.get(1, 5) != nil || f.runners.runner(id).Status != entity.RunnerCancelled {
+				t.Fatalf("cancelled runner has started")
+			}
+		case errors.Is(err, ErrScheduleNotFound):
+			f.started(t, id, at)
+		default:
+			t.Fatalf("CancelScheduled() error = %v", err)
+		}
+	}
+}
+
+func TestResumeScheduled(t *testing.T) {
+	at := testStart.Add(time.Minute)
+	stored := func(id int64, params entity.StrategyParams) entity.StrategyRunner {
+		params.UserId, params.QuotationSessionId, params.AccountId = 5, 1, 10
+		return entity.StrategyRunner{ID: id, QuotationSessionId: 1, UserId: 5, AccountId: 10, ProfileId: 1,
+			Params: params, Strategy: parsedStrategy(t, waitingSource), Status: entity.RunnerScheduled,
+			LastTick: -1}
+	}
+	invalid := stored(2, entity.StrategyParams{StartWhen: "currentPrice <"})
+	running := stored(3, entity.StrategyParams{})
+	running.QuotationSessionId, running.Status = 2, entity.RunnerRunning
+	f := newScheduleFixture(t, stored(1, entity.StrategyParams{StartAt: &at, NoInitialBet: true}), invalid, running)
+
+	if err := f.s.resumeScheduled(); err != nil {
+		t.Fatalf("resumeScheduled() error = %v", err)
+	}
+	if runner := f.runners.runner(2); runner.Status != entity.RunnerCancelled {
+		t.Errorf("runner of an invalid condition is %s, want cancelled", runner.Status)
+	}
+	if f.s.schedules.get(2) != nil || f.s.schedules.get(3) != nil {
+		t.Error("runners resumed which are not scheduled")
+	}
+	if d := f.clock.wait(t); d != time.Minute {
+		t.Fatalf("resumed start waits %s, want 1m", d)
+	}
+	f.clock.Advance(time.Minute)
+	f.started(t, 1, at)
+}
@@ -2,194 +2,805 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"main/dsl"
 	"main/logging"
 	"main/model/entity"
 	"main/repository"
 	"main/utils"
+	"strings"
+	"sync"
 	"time"
 )
 
 func NewStrategyService(ctx context.Context,
 	qsRepo repository.QuotationSessionRepository,
-	bRepo repository.BetRepository) StrategyService {
-	return StrategyService{
+	bRepo repository.BetRepository,
+	sRepo repository.StrategyRepository,
+	tRepo repository.DecisionTraceRepository,
+	rRepo repository.StrategyRunnerRepository,
+	baRepo repository.BlockedActionRepository,
+	nRepo repository.RunnerNotificationRepository,
+	pRepo repository.BetProposalRepository,
+	aRepo repository.AccountRepository,
+	lRepo repository.OrgLimitsRepository,
+	pfRepo repository.PortfolioRepository,
+	clock utils.Clock) *StrategyService {
+	events := newBetEvents()
+	return &StrategyService{
+		accountRepo:          aRepo,
+		limitsRepo:           lRepo,
+		portfolioRepo:        pfRepo,
+		runnerRepo:           rRepo,
+		blockedRepo:          baRepo,
+		notificationRepo:     nRepo,
+		proposalRepo:         pRepo,
 		quotationSessionRepo: qsRepo,
 		betRepo:              bRepo,
+		strategyRepo:         sRepo,
+		traceRepo:            tRepo,
+		clock:                clock,
 		ctx:                  ctx,
+		runners:              newRunnerRegistry(events),
+		events:               events,
+		portfolios:           &portfolioRegistry{runs: map[int64]*portfolioRun{}},
+		schedules:            &scheduleRegistry{starts: map[int64]*scheduledStart{}},
+		jitter:               newLockedRandom(time.Now().UnixNano()),
 	}
 }
 
 type StrategyJob struct {
 	entity.CurrentSessionState
 	QuotationSessionId int64
-	S                  entity.Strategy
-	QuitChannel        chan bool
-	ParentService      *StrategyService
+	AccountId          int64
+	// organisation of the account, its risk limits apply to the job
+	ProfileId int64
+	// portfolio the job bets for, 0 for a standalone job
+	PortfolioId int64
+	// ID of the stored runner and the number of the next tick
+	RunnerId      int64
+	Tick          int64
+	StrategyName  string
+	StartedAt     time.Time
+	S             entity.Strategy
+	QuitChannel   chan bool
+	ParentService *StrategyService
+	// signalled on the bets made in the session, see betEvents
+	wakeup chan struct{}
+	// limit of the bets including the initial one, unlimited when 0
+	maxBets int64
+	// whether the strategy has to be evaluated every N seconds regardless of the bets
+	timeBased bool
+	// set by WAIT_NEXT_STEP, the job does not tick until a bet newer than awaitedStep is made
+	awaiting    bool
+	awaitedStep int64
+	// number of the last step handled, the step based repeaters evaluate the steps after it only
+	lastStep int64
+	// bets of a semi-automatic job are proposed, proposal is the last one made
+	semiAutomatic bool
+	proposalTTL   time.Duration
+	proposal      *entity.BetProposal
+	// running portfolio of the job, nil when the job is standalone or the portfolio is stopped
+	portfolio *portfolioRun
+	// bets of the session as of the last tick, only read by the goroutine of the job
+	bets sessionBets
+
+	// guards the fields below, they are read by the registry API while the job runs
+	mu           sync.Mutex
+	paused       bool
+	betsMade     int64
+	lastDecision *entity.RunnerDecision
+	// current phase of a phased strategy
+	phase entity.PhaseState
+	// set by the portfolio withdrawn from the session, the job does not tick meanwhile
+	withdrawn bool
 }
 
-func (j StrategyJob) Run(qsRepo repository.QuotationSessionRepository, betRepo repository.BetRepository) error {
+func (j *StrategyJob) Run(qsRepo repository.QuotationSessionRepository, betRepo repository.BetRepository) error {
 	go func() {
-		counter := 0
+		counter := j.Tick
 		for {
-			select {
-			case <-j.QuitChannel:
+			session, ready, ended := j.ready(qsRepo)
+			if ended {
 				return
-			default:
-				{
-					err := j.recalculateCurrentState(qsRepo, betRepo)
-					if err != nil {
-						logging.ErrorFormat("Cannot recalculate current state params "+
-							"for runner session-%d-user-%d: %s", j.QuotationSessionId, j.UserId, err)
-					}
-					action := j.decide(j.S.BaseConditionSet)
-					err = j.perform(action)
-					if err != nil {
-						logging.ErrorFormat("Cannot perform action for runner session-%d-user-%d: %s",
-							j.QuotationSessionId, j.UserId, err)
-					}
-
-					logging.InfoFormat("Running %d; runner %d %d", counter,
-						j.UserId, j.QuotationSessionId)
-
-					time.Sleep(time.Duration(j.S.N) * time.Second)
+			}
+			if ready {
+				if j.step(counter, session, qsRepo, betRepo) {
+					return
 				}
+				logging.InfoFormat("Running %d; runner %d %d", counter,
+					j.UserId, j.QuotationSessionId)
+				counter++
 			}
 
+			timer := j.ParentService.clock.NewTimer(j.interval())
+			select {
+			case <-j.QuitChannel:
+				timer.Stop()
+				return
+			case <-j.wakeup:
+			case <-timer.C():
+			}
+			timer.Stop()
 		}
 	}()
 	return nil
 }
 
-func (j *StrategyJob) recalculateCurrentState(qsRepo repository.QuotationSessionRepository,
-	betRepo repository.BetRepository) error {
-	ctx := context.Background()
-	session, err := qsRepo.GetSessionById(ctx, j.QuotationSessionId)
+// ready reports whether the job ticks now: it does not while paused, withdrawn by its portfolio,
+// waiting for the next bet or for the step of its repeater. The session is read only to find that out
+// and returned for the tick, nil when not read. ended is true when the session has ended and the job
+// is finished instead
+func (j *StrategyJob) ready(qsRepo repository.QuotationSessionRepository) (
+	session *entity.QuotationSession, ready bool, ended bool) {
+	idle := j.isPaused() || j.isWithdrawn()
+	if !idle && !j.S.StepBased() && !j.awaiting {
+		return nil, true, false
+	}
+	current, err := qsRepo.GetSessionById(context.Background(), j.QuotationSessionId)
 	if err != nil {
-		logging.ErrorFormat("Cannot get session to recalculate current state runner session-%d-user-%d",
-			j.QuotationSessionId, j.UserId)
-		return err
+		logging.ErrorFormat("Cannot get session of runner session-%d-user-%d: %s",
+			j.QuotationSessionId, j.UserId, err)
+		return nil, false, false
 	}
-	j.IsOnAdditionalPurchase = session.IsInAdditionalPurchase
-	j.CurrentWinnerId = session.LastBet.ProviderId
-	j.MyCurrentBetNumber, err = betRepo.CountBetsBySessionAndUser(ctx, session.ID, j.UserId)
+	if j.finishIfEnded(current) {
+		return nil, false, true
+	}
+	switch {
+	case idle:
+		return nil, false, false
+	case j.S.StepBased():
+		return &current, j.stepDue(current), false
+	}
+	return &current, j.stepAwaited(current), false
+}
+
+// interval returns how long the job waits for a bet in the session before the next tick
+func (j *StrategyJob) interval() time.Duration {
+	switch {
+	case j.S.StepBased() && !j.ParentService.events.listening():
+		return stepPolling
+	case j.S.StepBased():
+		return betEventsFallback
+	case j.timeBased || !j.ParentService.events.listening():
+		return time.Duration(j.S.N)*time.Second + j.S.Jitter.TickOffset(j.ParentService.jitter)
+	}
+	return betEventsFallback
+}
+
+// wake makes the job tick without waiting for the interval to pass
+func (j *StrategyJob) wake() {
+	select {
+	case j.wakeup <- struct{}{}:
+	default:
+		// a tick is already pending
+	}
+}
+
+// step makes a single tick on the session read by ready, or on the session read now when nil:
+// recalculates the state, decides and performs the action.
+// It returns true when the session has ended and the job is finished instead
+func (j *StrategyJob) step(tick int64, current *entity.QuotationSession,
+	qsRepo repository.QuotationSessionRepository, betRepo repository.BetRepository) bool {
+	trace := entity.DecisionTrace{
+		QuotationSessionId: j.QuotationSessionId,
+		UserId:             j.UserId,
+		AccountId:          j.AccountId,
+		Tick:               tick,
+		Time:               j.ParentService.clock.Now(),
+	}
+	var problems []string
+	session, err := j.recalculateCurrentState(current, qsRepo, betRepo)
 	if err != nil {
-		logging.ErrorFormat("Cannot calculate MyCurrentBetNumber for runner session-%d-user-%d", j.QuotationSessionId, j.UserId)
-	}
-	j.CurrentStepNumber = int64(session.LastBet.BetNumber)
-	j.StepsTillZero = int64(100/session.SessionStepPercent) - j.CurrentStepNumber
-	j.CurrentPrice = session.CurrentPrice
-	j.CurrentDiscount = session.StartPrice - session.CurrentPrice
-	j.TimeSinceLastStep = time.Now().Sub(session.LastBet.Time)
-	j.TimeSinceLastMyBet, err = betRepo.GetDeltaBetTimeForUser(ctx, session.ID, j.UserId)
+		logging.ErrorFormat("Cannot recalculate current state params "+
+			"for runner session-%d-user-%d: %s", j.QuotationSessionId, j.UserId, err)
+		problems = append(problems, err.Error())
+	} else if j.finishIfEnded(session) {
+		return true
+	} else if j.CurrentStepNumber > j.lastStep {
+		// a bet made since the step was claimed, the state of the newer step is evaluated
+		j.lastStep = j.CurrentStepNumber
+	}
+	decision := j.decide()
+	action, branches := decision.Action, decision.Branches
+	trace.State = j.CurrentSessionState
+	trace.Branches = branches
+	trace.Action = action
+	trace.Phase = decision.Phase.Phase
+	trace.Transition = decision.Transition
+	err = j.perform(tick, decision, session)
 	if err != nil {
-		logging.ErrorFormat("Cannot calculate TimeSinceLastMyBet for runner session-%d-user-%d", j.QuotationSessionId, j.UserId)
+		logging.ErrorFormat("Cannot perform action for runner session-%d-user-%d: %s",
+			j.QuotationSessionId, j.UserId, err)
+		problems = append(problems, err.Error())
+	}
+	trace.Error = strings.Join(problems, "; ")
+
+	last := entity.RunnerDecision{
+		Tick:   tick,
+		Time:   trace.Time,
+		Action: action,
+		Error:  trace.Error,
+	}
+	if n := len(branches); n > 0 && branches[n-1].Result {
+		last.Branch = branches[n-1].Path
+	}
+	betsMade := j.record(last)
+	j.ParentService.saveTrace(trace)
+	j.ParentService.saveProgress(j.RunnerId, tick, betsMade, j.lastStep, trace.Time)
+	if action == entity.ActionStop {
+		j.stop()
+		return true
+	}
+	return false
+}
+
+func (j *StrategyJob) finishIfEnded(session entity.QuotationSession) bool {
+	if session.Status == entity.StatusActive {
+		return false
+	}
+	// a job stopped meanwhile keeps the STOPPED status
+	if j.ParentService.runners.release(j) {
+		j.ParentService.saveOutcome(j.Info().RunnerId, session, j.UserId)
+	}
+	return true
+}
+
+// record keeps the decision of the last tick, returns the number of bets made so far
+func (j *StrategyJob) record(decision entity.RunnerDecision) int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.lastDecision = &decision
+	return j.betsMade
+}
+
+func (j *StrategyJob) currentPhase() entity.PhaseState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.phase
+}
+
+func (j *StrategyJob) setPhase(phase entity.PhaseState) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.phase = phase
+}
+
+func (j *StrategyJob) isPaused() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.paused
+}
+
+func (j *StrategyJob) setPaused(paused bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.paused = paused
+}
+
+func (j *StrategyJob) isWithdrawn() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.withdrawn
+}
+
+// setWithdrawn reports whether the flag has changed
+func (j *StrategyJob) setWithdrawn(withdrawn bool) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	changed := j.withdrawn != withdrawn
+	j.withdrawn = withdrawn
+	return changed
+}
+
+// Info returns the current state of the runner
+func (j *StrategyJob) Info() entity.RunnerInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := entity.RunnerStatus(entity.RunnerRunning)
+	if j.paused {
+		status = entity.RunnerPaused
+	}
+	var phase *entity.PhaseState
+	if j.S.Phased() {
+		current := j.phase
+		phase = &current
+	}
+	return entity.RunnerInfo{
+		RunnerId:           j.RunnerId,
+		QuotationSessionId: j.QuotationSessionId,
+		UserId:             j.UserId,
+		AccountId:          j.AccountId,
+		ProfileId:          j.ProfileId,
+		Strategy:           j.StrategyName,
+		Status:             status,
+		StartedAt:          j.StartedAt,
+		BetsMade:           j.betsMade,
+		LastDecision:       j.lastDecision,
+		Phase:              phase,
+		PortfolioId:        j.PortfolioId,
 	}
-	j.StepSize = session.SessionStepPercent / 100 * session.StartPrice
-	j.TimeSinceStart = time.Now().Sub(session.StartTime)
-	j.TimeTillEnd = session.StartTime.Add(
-		time.Duration(session.SessionDuration) * time.Minute).Sub(time.Now())
-	j.ParticipantsCount, err = betRepo.CountSessionParticipants(ctx, session.ID)
+}
+
+// recalculateCurrentState calculates the state of the tick from the session and its bets,
+// the session is read unless the tick has read it already
+func (j *StrategyJob) recalculateCurrentState(session *entity.QuotationSession,
+	qsRepo repository.QuotationSessionRepository, betRepo repository.BetRepository) (entity.QuotationSession, error) {
+	ctx := context.Background()
+	if session == nil {
+		current, err := qsRepo.GetSessionById(ctx, j.QuotationSessionId)
+		if err != nil {
+			logging.ErrorFormat("Cannot get session to recalculate current state runner session-%d-user-%d",
+				j.QuotationSessionId, j.UserId)
+			return current, err
+		}
+		session = &current
+	}
+	bets, err := j.bets.get(ctx, betRepo, *session)
 	if err != nil {
-		logging.ErrorFormat("Cannot calculate ParticipantsCount for runner session-%d-user-%d", j.QuotationSessionId, j.UserId)
+		logging.ErrorFormat("Cannot get bet history for runner session-%d-user-%d", j.QuotationSessionId, j.UserId)
+		return *session, err
 	}
+	j.CurrentSessionState = sessionState(j.CurrentSessionState, *session, bets, j.ParentService.clock.Now())
 
 	logging.DebugFormat("Current State: %v+", j.CurrentSessionState)
 
-	return nil
+	return *session, nil
+}
+
+// sessionState calculates the params of base from the session and its bets at the time,
+// the runners and the backtesting evaluate the strategies on it
+func sessionState(base entity.CurrentSessionState, session entity.QuotationSession,
+	bets []*entity.Bet, now time.Time) entity.CurrentSessionState {
+
+	state := base
+	state.IsOnAdditionalPurchase = session.IsInAdditionalPurchase
+	state.CurrentWinnerId = 0
+	state.CurrentStepNumber = 0
+	state.TimeSinceLastStep = now.Sub(session.StartTime)
+	if session.LastBet != nil {
+		state.CurrentWinnerId = session.LastBet.ProviderId
+		state.CurrentStepNumber = int64(session.LastBet.BetNumber)
+		state.TimeSinceLastStep = now.Sub(session.LastBet.Time)
+	}
+
+	state.StepsTillZero = int64(100/session.SessionStepPercent) - state.CurrentStepNumber
+	state.CurrentPrice = session.CurrentPrice
+	state.CurrentDiscount = session.StartPrice - session.CurrentPrice
+	state.StepSize = session.StepSize()
+	state.TimeSinceStart = now.Sub(session.StartTime)
+	state.TimeTillEnd = session.EndTime().Sub(now)
+	state.SetBetHistory(session, bets, now)
+	return state
 }
 
-func (j *StrategyJob) decide(set *entity.ConditionSet) entity.Action {
-	return set.Define(j.CurrentSessionState)
+// sessionBets keeps the bets of a session until a new bet is made in it,
+// so that the bets are only read again once the last bet of the session has changed
+type sessionBets struct {
+	read      bool
+	lastBetId int64
+	bets      []*entity.Bet
 }
 
-func (j *StrategyJob) perform(action entity.Action) error {
+func (c *sessionBets) get(ctx context.Context, betRepo repository.BetRepository,
+	session entity.QuotationSession) ([]*entity.Bet, error) {
+	if c.read && c.lastBetId == session.LastBetId {
+		return c.bets, nil
+	}
+	bets, err := betRepo.GetBetBySessionId(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	c.read, c.lastBetId, c.bets = true, session.LastBetId, bets
+	return bets, nil
+}
+
+// decide returns the action together with the branches evaluated to choose it,
+// a transition of a phased strategy is saved before the action is performed
+func (j *StrategyJob) decide() entity.StrategyDecision {
+	decision := j.S.Decide(j.currentPhase(), j.CurrentSessionState)
+	if t := decision.Transition; t != nil {
+		logging.InfoFormat("Runner session-%d-user-%d: phase %s -> %s", j.QuotationSessionId,
+			j.UserId, t.From, t.To)
+		j.setPhase(decision.Phase)
+		j.ParentService.savePhase(j.RunnerId, decision.Phase)
+	}
+	branches := decision.Branches
+	if len(branches) > 0 && branches[len(branches)-1].Result {
+		logging.DebugFormat("Runner session-%d-user-%d: branch %s fired", j.QuotationSessionId,
+			j.UserId, branches[len(branches)-1].Path)
+	}
+	return decision
+}
+
+// perform makes the action, an immediate bet is checked on the session of the tick
+func (j *StrategyJob) perform(tick int64, decision entity.StrategyDecision, session entity.QuotationSession) error {
 	logging.InfoFormat("Runner session-%d-user-%d: Performing action %s", j.QuotationSessionId,
-		j.UserId, action)
-	switch action {
+		j.UserId, decision.Action)
+	switch decision.Action {
 	case entity.ActionBet:
-		err := j.ParentService.MakeBet(j.QuotationSessionId, j.UserId)
+		if delay := j.betDelay(0); delay > 0 {
+			return j.betAfter(tick, delay)
+		}
+		err := j.placeBet(tick, &session)
 		if err != nil {
 			return err
 		}
+	case entity.ActionBetAfter:
+		return j.betAfter(tick, decision.Delay+j.betDelay(decision.Delay))
+	case entity.ActionNotify:
+		return j.ParentService.notify(entity.RunnerNotification{
+			QuotationSessionId: j.QuotationSessionId,
+			UserId:             j.UserId,
+			AccountId:          j.AccountId,
+			RunnerId:           j.RunnerId,
+			Tick:               tick,
+			Time:               j.ParentService.clock.Now(),
+			Message:            decision.Message,
+		})
+	case entity.ActionWaitNextStep:
+		j.awaitStep()
 	}
 	return nil
 }
 
-var runnersPool []*StrategyJob
+var ErrRunnerNotFound = errors.New("runner not found")
 
 type StrategyService struct {
 	quotationSessionRepo repository.QuotationSessionRepository
 	betRepo              repository.BetRepository
+	strategyRepo         repository.StrategyRepository
+	traceRepo            repository.DecisionTraceRepository
+	runnerRepo           repository.StrategyRunnerRepository
+	blockedRepo          repository.BlockedActionRepository
+	notificationRepo     repository.RunnerNotificationRepository
+	proposalRepo         repository.BetProposalRepository
+	accountRepo          repository.AccountRepository
+	limitsRepo           repository.OrgLimitsRepository
+	portfolioRepo        repository.PortfolioRepository
+	clock                utils.Clock
 	ctx                  context.Context
+
+	// runners, portfolios and scheduled runners of the service instance
+	runners    *runnerRegistry
+	events     *betEvents
+	portfolios *portfolioRegistry
+	schedules  *scheduleRegistry
+	// random source of the timing jitter of the runners
+	jitter *lockedRandom
+	// serialises the launches of the runners, so that concurrent launches cannot exceed MaxRunners
+	orgLaunch sync.Mutex
 }
 
+// RunStrategyRunner launches the runner, or schedules it when the params set a start time or condition
 func (s *StrategyService) RunStrategyRunner(params entity.StrategyParams) error {
-	strat := entity.Strategy{}
-	switch params.Str {
-	case "aggressive":
-		strat = utils.AggressiveStrategy
-	case "waiting":
-		strat = utils.WaitingStrategy
-	case "progressive":
-		strat = utils.ProgressiveStrategy
-	}
-	job := StrategyJob{
-		CurrentSessionState: entity.CurrentSessionState{
-			UserId:          params.UserId,
-			MinimalPrice:    params.MinimalPrice,
-			AcceptablePrice: params.AcceptablePrice,
-			PreferablePrice: params.PreferablePrice,
-		},
-		QuotationSessionId: params.QuotationSessionId,
-		S:                  strat,
-		QuitChannel:        make(chan bool),
-		ParentService:      s,
+	strat, name, err := s.resolveStrategy(params)
+	if err != nil {
+		return err
 	}
-	if sJ, i := s.findBySessionIdAndUserId(params.QuotationSessionId, params.UserId); sJ != nil || i != -1 {
-		return fmt.Errorf("runner session-%d-user-%d is already running",
-			params.QuotationSessionId, params.UserId)
+	if err = strat.Validate().Err(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidStrategy, err)
 	}
-	runnersPool = append(runnersPool, &job)
-	err := s.MakeBet(params.QuotationSessionId, params.UserId)
+	profileId, err := s.profileOf(params.AccountId)
 	if err != nil {
+		return err
+	}
+	runner := entity.StrategyRunner{
+		QuotationSessionId: params.QuotationSessionId,
+		UserId:             params.UserId,
+		AccountId:          params.AccountId,
+		ProfileId:          profileId,
+		PortfolioId:        params.PortfolioId,
+		Params:             params,
+		StrategyName:       name,
+		Strategy:           strat,
+		Status:             entity.RunnerRunning,
+		LastTick:           -1,
+	}
+	if strat.Phased() {
+		initial := strat.InitialPhase()
+		runner.Phase = &initial
+	}
+	if params.Scheduled(s.clock.Now()) {
+		return s.schedule(runner)
+	}
+	return s.launch(runner)
+}
+
+// launch starts the runner with the initial bet unless the params skip it,
+// a scheduled runner is already stored and becomes running
+func (s *StrategyService) launch(runner entity.StrategyRunner) error {
+	params := runner.Params
+	runner.StartedAt = s.clock.Now()
+	job := s.newJob(runner)
+	// registered before the initial bet, so the same runner cannot be launched twice
+	if err := s.register(job); err != nil {
+		return err
+	}
+	var err error
+	if !params.NoInitialBet {
+		err = job.bet(runner.LastTick, nil)
+	}
+	if err != nil && job.isWithdrawn() {
+		// the runner of a portfolio waits until the portfolio bids in the session
+		logging.InfoFormat("Runner session-%d-user-%d starts withdrawn by portfolio %d: %s",
+			params.QuotationSessionId, params.UserId, params.PortfolioId, err)
+	} else if err != nil {
+		s.runners.remove(params.QuotationSessionId, params.UserId)
 		logging.ErrorFormat("Cannot make initial bet to run the strategy %s", err)
 		return err
 	}
-	err = job.Run(s.quotationSessionRepo, s.betRepo)
+	runner.BetsMade = job.countBets()
+	// the step based repeaters evaluate the steps after the initial bet, or after the current one without it
+	session, err := s.quotationSessionRepo.GetSessionById(s.ctx, params.QuotationSessionId)
+	if err == nil {
+		runner.LastStep = session.CurrentStep()
+	}
+	job.lastStep = runner.LastStep
+	runnerId := runner.ID
+	if runnerId == 0 {
+		runnerId, err = s.runnerRepo.InsertRunner(s.ctx, runner)
+	} else {
+		err = s.runnerRepo.StartRunner(s.ctx, runnerId, runner.BetsMade, runner.LastStep, runner.StartedAt)
+	}
+	if err != nil {
+		s.runners.remove(params.QuotationSessionId, params.UserId)
+		return fmt.Errorf("cannot save runner session-%d-user-%d: %w",
+			params.QuotationSessionId, params.UserId, err)
+	}
+	// the job is already listed by the registry
+	job.mu.Lock()
+	job.RunnerId = runnerId
+	job.mu.Unlock()
+	return job.Run(s.quotationSessionRepo, s.betRepo)
+}
+
+// ListenBets makes the runners tick on the bets made in their sessions
+// instead of polling the sessions every N seconds
+func (s *StrategyService) ListenBets(listener repository.BetListener) {
+	s.events.start(listener, s.runners)
+}
+
+// ResumeRunners starts the stored runners left running or paused by the previous
+// service instance and waits again for the scheduled ones, the runners of sessions
+// which are not active any more are finished
+func (s *StrategyService) ResumeRunners() error {
+	supervise, err := s.resumePortfolios()
 	if err != nil {
 		return err
 	}
+	// the portfolios finish once none of their runners is resumed
+	defer supervise()
+	var stored []*entity.StrategyRunner
+	for _, status := range []entity.RunnerStatus{entity.RunnerRunning, entity.RunnerPaused} {
+		res, err := s.runnerRepo.GetRunnersByStatus(s.ctx, status)
+		if err != nil {
+			return err
+		}
+		stored = append(stored, res...)
+	}
+	for _, runner := range stored {
+		session, err := s.quotationSessionRepo.GetSessionById(s.ctx, runner.QuotationSessionId)
+		if err != nil {
+			logging.ErrorFormat("Cannot get session to resume runner session-%d-user-%d: %s",
+				runner.QuotationSessionId, runner.UserId, err)
+			continue
+		}
+		if session.Status != entity.StatusActive {
+			logging.InfoFormat("Session %d is %s, finishing runner session-%d-user-%d", session.ID,
+				session.Status, runner.QuotationSessionId, runner.UserId)
+			s.saveOutcome(runner.ID, session, runner.UserId)
+			continue
+		}
+		job := s.newJob(*runner)
+		if err = s.runners.add(job); err != nil {
+			logging.ErrorFormat("Cannot resume runner %d: %s", runner.ID, err)
+			continue
+		}
+		if err = job.Run(s.quotationSessionRepo, s.betRepo); err != nil {
+			s.runners.remove(runner.QuotationSessionId, runner.UserId)
+			logging.ErrorFormat("Cannot resume runner session-%d-user-%d: %s",
+				runner.QuotationSessionId, runner.UserId, err)
+			continue
+		}
+		logging.InfoFormat("Resumed runner session-%d-user-%d from tick %d",
+			runner.QuotationSessionId, runner.UserId, runner.LastTick+1)
+	}
+	return s.resumeScheduled()
+}
+
+func (s *StrategyService) newJob(runner entity.StrategyRunner) *StrategyJob {
+	phase := runner.Strategy.InitialPhase()
+	if runner.Phase != nil {
+		phase = *runner.Phase
+	}
+	proposalTTL := time.Duration(runner.Params.ProposalTTL) * time.Second
+	if proposalTTL <= 0 {
+		proposalTTL = defaultProposalTTL
+	}
+	return &StrategyJob{
+		CurrentSessionState: entity.CurrentSessionState{
+			UserId:          runner.Params.UserId,
+			MinimalPrice:    runner.Params.MinimalPrice,
+			AcceptablePrice: runner.Params.AcceptablePrice,
+			PreferablePrice: runner.Params.PreferablePrice,
+		},
+		QuotationSessionId: runner.QuotationSessionId,
+		AccountId:          runner.AccountId,
+		ProfileId:          runner.ProfileId,
+		PortfolioId:        runner.PortfolioId,
+		RunnerId:           runner.ID,
+		Tick:               runner.LastTick + 1,
+		StrategyName:       runner.StrategyName,
+		StartedAt:          runner.StartedAt,
+		S:                  runner.Strategy,
+		QuitChannel:        make(chan bool),
+		ParentService:      s,
+		wakeup:             make(chan struct{}, 1),
+		timeBased:          runner.Strategy.TimeBased(),
+		maxBets:            runner.Params.MaxBets,
+		paused:             runner.Status == entity.RunnerPaused,
+		betsMade:           runner.BetsMade,
+		lastStep:           runner.LastStep,
+		semiAutomatic:      runner.Params.SemiAutomatic,
+		proposalTTL:        proposalTTL,
+		portfolio:          s.portfolios.get(runner.PortfolioId),
+		phase:              phase,
+	}
+}
+
+// resolveStrategy returns the strategy selected by the params and its name,
+// the vars of the strategy are bound to the values overridden by the params
+func (s *StrategyService) resolveStrategy(params entity.StrategyParams) (entity.Strategy, string, error) {
+	var strat entity.Strategy
+	var name string
+	switch {
+	case params.StrategyId != 0:
+		stored, err := s.strategyRepo.GetStrategyById(s.ctx, params.StrategyId)
+		if err != nil {
+			return entity.Strategy{}, "", fmt.Errorf("cannot get strategy %d: %w", params.StrategyId, err)
+		}
+		if stored.OwnerId != params.AccountId {
+			return entity.Strategy{}, "", ErrStrategyAccessDenied
+		}
+		strat, name = stored.Strategy, stored.Name
+	case params.Source != "":
+		parsed, err := dsl.ParseStrategy(params.Source)
+		if err != nil {
+			return entity.Strategy{}, "", fmt.Errorf("%w: %s", ErrInvalidStrategy, err)
+		}
+		strat, name = parsed, "source"
+	default:
+		template, ok := utils.FindTemplate(params.Str)
+		if !ok {
+			return entity.Strategy{}, "", fmt.Errorf("%w: unknown strategy %q", ErrInvalidStrategy, params.Str)
+		}
+		instance, err := template.Instantiate(params.Vars)
+		if err != nil {
+			return entity.Strategy{}, "", fmt.Errorf("%w: %s", ErrInvalidStrategy, err)
+		}
+		return instance, template.Name, nil
+	}
+	strat, err := strat.WithVars(params.Vars)
+	if err != nil {
+		return entity.Strategy{}, "", fmt.Errorf("%w: %s", ErrInvalidStrategy, err)
+	}
+	return strat, name, nil
+}
+
+// Templates returns the predefined strategies with their params
+func (s *StrategyService) Templates() []entity.StrategyTemplate {
+	return utils.Templates
+}
+
+// StopRunner stops the runner for good.
+// accountId is the account which launched the runner, any account when 0
+func (s *StrategyService) StopRunner(accountId int64, sessionId int64, userId int64) error {
+	job := s.runners.get(sessionId, userId)
+	if job != nil && accountId != 0 && job.AccountId != accountId {
+		return ErrRunnerAccessDenied
+	}
+	// the runner may have stopped itself meanwhile
+	if job == nil || !s.runners.release(job) {
+		return fmt.Errorf("%w: session - %d, user - %d", ErrRunnerNotFound, sessionId, userId)
+	}
+	close(job.QuitChannel)
+	logging.InfoFormat("Quitting runner for session %d : user %d", sessionId, userId)
+	s.saveStatus(job.Info().RunnerId, entity.RunnerStopped)
 	return nil
 }
 
-func (s *StrategyService) StopRunner(sessionId int64, userId int64) error {
-	job, index := s.findBySessionIdAndUserId(sessionId, userId)
-	if job != nil {
-		job.QuitChannel <- true
-		logging.InfoFormat("Quitting runner for session %d : user %d", sessionId, userId)
-		runnersPool = append(runnersPool[:index], runnersPool[index+1:]...)
-		return nil
-	} else {
-		return fmt.Errorf("runner for session - %d, user - %d not found", sessionId, userId)
+// ListRunners returns the runners of this service instance matching the filter
+func (s *StrategyService) ListRunners(filter entity.RunnerFilter) []entity.RunnerInfo {
+	jobs := s.runners.list(filter)
+	res := make([]entity.RunnerInfo, len(jobs))
+	for i, job := range jobs {
+		res[i] = job.Info()
 	}
+	return res
+}
 
+// PauseRunner keeps the runner registered but stops it acting until it is resumed.
+// accountId is the account which launched the runner, any account when 0
+func (s *StrategyService) PauseRunner(accountId int64, sessionId int64, userId int64) (entity.RunnerInfo, error) {
+	return s.setPaused(accountId, sessionId, userId, true)
 }
 
-func (s *StrategyService) findBySessionIdAndUserId(sessionId int64, userId int64) (*StrategyJob, int) {
-	for i, job := range runnersPool {
-		if job.UserId == userId && job.QuotationSessionId == sessionId {
-			return job, i
-		}
+// ResumeRunner makes a paused runner act again
+func (s *StrategyService) ResumeRunner(accountId int64, sessionId int64, userId int64) (entity.RunnerInfo, error) {
+	return s.setPaused(accountId, sessionId, userId, false)
+}
+
+func (s *StrategyService) setPaused(accountId int64, sessionId int64, userId int64,
+	paused bool) (entity.RunnerInfo, error) {
+	job := s.runners.get(sessionId, userId)
+	if job == nil {
+		return entity.RunnerInfo{}, fmt.Errorf("%w: session - %d, user - %d", ErrRunnerNotFound,
+			sessionId, userId)
+	}
+	if accountId != 0 && job.AccountId != accountId {
+		return entity.RunnerInfo{}, ErrRunnerAccessDenied
+	}
+	job.setPaused(paused)
+	info := job.Info()
+	s.saveStatus(info.RunnerId, info.Status)
+	logging.InfoFormat("Runner session-%d-user-%d is %s", sessionId, userId, info.Status)
+	return info, nil
+}
+
+// GetDecisionTrace returns the recorded ticks of a runner, the latest first
+func (s *StrategyService) GetDecisionTrace(filter entity.DecisionTraceFilter) ([]*entity.DecisionTrace, error) {
+	return s.traceRepo.GetTraces(s.ctx, filter)
+}
+
+func (s *StrategyService) saveTrace(trace entity.DecisionTrace) {
+	if _, err := s.traceRepo.InsertTrace(s.ctx, trace); err != nil {
+		logging.ErrorFormat("Cannot save decision trace of runner session-%d-user-%d: %s",
+			trace.QuotationSessionId, trace.UserId, err)
+	}
+}
+
+func (s *StrategyService) saveProgress(runnerId int64, tick int64, betsMade int64, lastStep int64, at time.Time) {
+	if err := s.runnerRepo.UpdateRunnerProgress(s.ctx, runnerId, tick, betsMade, lastStep, at); err != nil {
+		logging.ErrorFormat("Cannot save tick %d of runner %d: %s", tick, runnerId, err)
+	}
+}
+
+func (s *StrategyService) savePhase(runnerId int64, phase entity.PhaseState) {
+	if err := s.runnerRepo.UpdateRunnerPhase(s.ctx, runnerId, phase); err != nil {
+		logging.ErrorFormat("Cannot save phase %s of runner %d: %s", phase.Phase, runnerId, err)
+	}
+}
+
+func (s *StrategyService) saveStatus(runnerId int64, status entity.RunnerStatus) {
+	if err := s.runnerRepo.UpdateRunnerStatus(s.ctx, runnerId, status, s.clock.Now()); err != nil {
+		logging.ErrorFormat("Cannot set status %s of runner %d: %s", status, runnerId, err)
+	}
+}
+
+// saveOutcome marks the runner as finished with the outcome of the ended session
+func (s *StrategyService) saveOutcome(runnerId int64, session entity.QuotationSession, userId int64) {
+	bets, err := s.betRepo.GetBetBySessionId(s.ctx, session.ID)
+	if err != nil {
+		logging.ErrorFormat("Cannot get bets of session %d for the runner outcome: %s", session.ID, err)
+	}
+	outcome := entity.NewRunnerOutcome(session, userId, bets)
+	if err = s.runnerRepo.FinishRunner(s.ctx, runnerId, outcome, s.clock.Now()); err != nil {
+		logging.ErrorFormat("Cannot save outcome of runner %d: %s", runnerId, err)
+		return
 	}
-	return nil, -1
+	logging.InfoFormat("Runner session-%d-user-%d finished: session is %s, won - %t, final price - %.2f",
+		session.ID, userId, session.Status, outcome.Won, outcome.FinalPrice)
 }
 
-func (s *StrategyService) MakeBet(sessionId int64, profileId int64) error {
+// MakeBet makes a bot bet unless the new price would be lower than minPrice
+func (s *StrategyService) MakeBet(sessionId int64, profileId int64, minPrice float64) error {
 	bet, err := s.betRepo.MakeBet(s.ctx, entity.BetData{
 		QuotationSessionID: sessionId,
 		ProviderId:         profileId,
 		Bot:                true,
+		MinPrice:           minPrice,
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"main/model/entity"
+	"testing"
+	"time"
+)
+
+func TestLaunchSavesRunner(t *testing.T) {
+	f := newScheduleFixture(t)
+	params := entity.StrategyParams{UserId: 5, QuotationSessionId: 1, AccountId: 10, MinimalPrice: 100,
+		Source: waitingSource}
+	if err := f.s.RunStrategyRunner(params); err != nil {
+		t.Fatalf("RunStrategyRunner() error = %v", err)
+	}
+	job := f.s.runners.get(1, 5)
+	if job == nil {
+		t.Fatal("launched runner is not registered")
+	}
+	// the first tick is made right after the initial bet
+	f.clock.wait(t)
+
+	id := job.Info().RunnerId
+	runner := f.runners.runner(id)
+	if runner.QuotationSessionId != 1 || runner.UserId != 5 || runner.AccountId != 10 || runner.ProfileId != 1 ||
+		runner.StrategyName != "source" || runner.Params.Source != waitingSource || runner.Strategy.N != 60 {
+		t.Errorf("runner = %+v, want the runner of the params", runner)
+	}
+	if runner.Status != entity.RunnerRunning || !runner.StartedAt.Equal(testStart) || runner.BetsMade != 1 ||
+		runner.LastStep != 0 {
+		t.Errorf("runner is %s since %s with %d bets after step %d, want running since %s with the initial bet",
+			runner.Status, runner.StartedAt, runner.BetsMade, runner.LastStep, testStart)
+	}
+	if runner.LastTick != 0 || !runner.LastTickAt.Equal(testStart) {
+		t.Errorf("runner saved tick %d at %s, want tick 0 at %s", runner.LastTick, runner.LastTickAt, testStart)
+	}
+
+	// the next tick is saved as well
+	f.clock.Advance(time.Minute)
+	f.clock.wait(t)
+	if runner = f.runners.runner(id); runner.LastTick != 1 || !runner.LastTickAt.Equal(testStart.Add(time.Minute)) {
+		t.Errorf("runner saved tick %d at %s, want tick 1 a minute later", runner.LastTick, runner.LastTickAt)
+	}
+}
+
+func TestRunnerStatusSaved(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// changes the runner after its first tick
+		change     func(t *testing.T, f scheduleFixture)
+		wantStatus entity.RunnerStatus
+	}{
+		{
+			name:   "stopped",
+			source: waitingSource,
+			change: func(t *testing.T, f scheduleFixture) {
+				if err := f.s.StopRunner(10, 1, 5); err != nil {
+					t.Fatalf("StopRunner() error = %v", err)
+				}
+			},
+			wantStatus: entity.RunnerStopped,
+		},
+		{
+			name:       "stopped by the strategy",
+			source:     "EVERY 60s\nIF currentPrice < 900 THEN STOP\nELSE WAIT_TIME",
+			wantStatus: entity.RunnerStopped,
+		},
+		{
+			name:   "paused",
+			source: waitingSource,
+			change: func(t *testing.T, f scheduleFixture) {
+				if _, err := f.s.PauseRunner(10, 1, 5); err != nil {
+					t.Fatalf("PauseRunner() error = %v", err)
+				}
+			},
+			wantStatus: entity.RunnerPaused,
+		},
+		{
+			name:   "resumed",
+			source: waitingSource,
+			change: func(t *testing.T, f scheduleFixture) {
+				if _, err := f.s.PauseRunner(10, 1, 5); err != nil {
+					t.Fatalf("PauseRunner() error = %v", err)
+				}
+				f.waitStatus(t, f.s.runners.get(1, 5).Info().RunnerId, entity.RunnerPaused)
+				if _, err := f.s.ResumeRunner(10, 1, 5); err != nil {
+					t.Fatalf("ResumeRunner() error = %v", err)
+				}
+			},
+			wantStatus: entity.RunnerRunning,
+		},
+		{
+			name:   "finished with the session",
+			source: waitingSource,
+			change: func(t *testing.T, f scheduleFixture) {
+				session := guardedSession()
+				session.Status = entity.StatusFinished
+				session.CurrentPrice = 700
+				session.LastBet = &entity.Bet{ProviderId: 5, BetNumber: 1, NewPrice: 700}
+				f.sessions.put(session)
+				f.clock.Advance(time.Minute)
+			},
+			wantStatus: entity.RunnerFinished,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newScheduleFixture(t)
+			if err := f.s.RunStrategyRunner(entity.StrategyParams{UserId: 5, QuotationSessionId: 1, AccountId: 10,
+				MinimalPrice: 100, Source: tt.source}); err != nil {
+				t.Fatalf("RunStrategyRunner() error = %v", err)
+			}
+			id := f.waitStatus(t, 1, entity.RunnerRunning).ID
+			if tt.change != nil {
+				f.clock.wait(t)
+				tt.change(t, f)
+			}
+
+			runner := f.waitStatus(t, id, tt.wantStatus)
+			stopped := tt.wantStatus == entity.RunnerStopped || tt.wantStatus == entity.RunnerFinished
+			if stopped != !runner.StoppedAt.IsZero() {
+				t.Errorf("runner %s stopped at %s", runner.Status, runner.StoppedAt)
+			}
+			if registered := f.s.runners.get(1, 5) != nil; registered == stopped {
+				t.Errorf("%s runner registered = %t", runner.Status, registered)
+			}
+			if tt.wantStatus == entity.RunnerFinished &&
+				(runner.Outcome == nil || !runner.Outcome.Won || runner.Outcome.FinalPrice != 700) {
+				t.Errorf("outcome = %+v, want won at 700", runner.Outcome)
+			}
+		})
+	}
+}
+
+func TestResumeRunners(t *testing.T) {
+	startAt := testStart.Add(30 * time.Second)
+	stored := func(id int64, sessionId int64, userId int64, status entity.RunnerStatus) entity.StrategyRunner {
+		params := entity.StrategyParams{UserId: userId, QuotationSessionId: sessionId, AccountId: 10,
+			MinimalPrice: 100, Source: waitingSource}
+		return entity.StrategyRunner{ID: id, QuotationSessionId: sessionId, UserId: userId, AccountId: 10,
+			ProfileId: 1, Params: params, StrategyName: "source", Strategy: parsedStrategy(t, waitingSource),
+			Status: status, StartedAt: testStart.Add(-time.Hour), LastTick: 4, BetsMade: 2}
+	}
+	scheduled := stored(4, 1, 6, entity.RunnerScheduled)
+	scheduled.Params.StartAt, scheduled.Params.NoInitialBet = &startAt, true
+	scheduled.LastTick, scheduled.BetsMade, scheduled.StartedAt = -1, 0, time.Time{}
+	f := newScheduleFixture(t,
+		stored(1, 1, 5, entity.RunnerRunning),
+		stored(2, 2, 5, entity.RunnerPaused),
+		stored(3, 3, 5, entity.RunnerRunning),
+		scheduled,
+		stored(5, 1, 7, entity.RunnerStopped),
+		// the session cannot be read
+		stored(6, 9, 5, entity.RunnerRunning),
+	)
+	other := guardedSession()
+	other.ID = 2
+	finished := guardedSession()
+	finished.ID, finished.Status = 3, entity.StatusFinished
+	f.sessions.put(other)
+	f.sessions.put(finished)
+
+	if err := f.s.ResumeRunners(); err != nil {
+		t.Fatalf("ResumeRunners() error = %v", err)
+	}
+	// the running and the paused runners wait for their next tick, the scheduled one for its start
+	for i := 0; i < 3; i++ {
+		f.clock.wait(t)
+	}
+
+	running := f.s.runners.get(1, 5)
+	if running == nil || running.Info().RunnerId != 1 || running.Info().Status != entity.RunnerRunning {
+		t.Fatal("running runner is not resumed")
+	}
+	if runner := f.runners.runner(1); runner.LastTick != 5 || runner.BetsMade != 2 {
+		t.Errorf("resumed runner saved tick %d with %d bets, want tick 5 with 2 bets", runner.LastTick,
+			runner.BetsMade)
+	}
+	paused := f.s.runners.get(2, 5)
+	if paused == nil || paused.Info().Status != entity.RunnerPaused {
+		t.Error("paused runner is not resumed paused")
+	}
+	if runner := f.runners.runner(2); runner.LastTick != 4 {
+		t.Errorf("paused runner has ticked, saved tick %d", runner.LastTick)
+	}
+	if runner := f.runners.runner(3); runner.Status != entity.RunnerFinished || runner.Outcome == nil {
+		t.Errorf("runner of the ended session is %s with outcome %+v, want finished", runner.Status,
+			runner.Outcome)
+	}
+	if f.s.runners.get(3, 5) != nil || f.s.runners.get(9, 5) != nil || f.s.runners.get(1, 7) != nil {
+		t.Error("runners resumed which are not to be")
+	}
+	if runner := f.runners.runner(6); runner.Status != entity.RunnerRunning {
+		t.Errorf("runner of the unread session is %s, want left running", runner.Status)
+	}
+	if runner := f.runners.runner(5); runner.Status != entity.RunnerStopped {
+		t.Errorf("stopped runner is %s", runner.Status)
+	}
+
+	f.clock.Advance(30 * time.Second)
+	f.clock.wait(t)
+	if runner := f.runners.runner(4); runner.Status != entity.RunnerRunning || !runner.StartedAt.Equal(startAt) {
+		t.Errorf("scheduled runner is %s since %s, want started at %s", runner.Status, runner.StartedAt, startAt)
+	}
+	if job := f.s.runners.get(1, 6); job == nil || job.Info().RunnerId != 4 {
+		t.Error("scheduled runner is not started")
+	}
+}
@@ -6,6 +6,7 @@ const (
 	ListenAddressEnvKey             = "PORT"
 	ServiceNameEnvKey               = "SERVICE_NAME"
 	StrategyManagementBaseUrlEnvKey = "STRATEGY_MANAGEMENT_BASE_URL" // to generate proper Swagger docs
+	TenderApiUrlEnvKey              = "TENDER_API_URL"               // the tender service the sessions and bets go through
 
 	// Security ---------------
 	SecurityTokenTTLKey          = "TOKEN_TTL"
@@ -16,4 +17,8 @@ const (
 	// Debug -------------------
 	AppVersionEnvKey = "APP_VERSION"
 	LogLevelEnvKey   = "LOG_LEVEL"
+	JitterSeedEnvKey = "JITTER_SEED" // makes the timing jitter of the runners reproducible
+
+	// Work logic --------------
+	SimulationModeEnvKey = "SIMULATION_MODE" // the clock stands still until it is advanced by an admin
 )
@@ -12,6 +12,8 @@ func NewError(ctx *gin.Context, status int, err error) {
 
 // HTTPError example
 type HTTPError struct {
-	Code    int    `json:"code" example:"500"`
-	Message string `json:"message" example:"Internal error"`
+	Code int `json:"code" example:"500"`
+	// Tells the errors of the same status apart, e.g. the refused bets
+	ErrorCode string `json:"error_code,omitempty" example:"price_floor"`
+	Message   string `json:"message" example:"Internal error"`
 }
@@ -2,35 +2,26 @@ package utils
 
 import (
 	"main/model/entity"
-	"time"
 )
 
 var (
-	AggressiveStrategy = entity.Strategy{
-		BaseConditionSet: &entity.ConditionSet{
-			Op: entity.Operator{
-				O: entity.UnOpAnd,
-				Left: entity.Condition{
-					M:     "",
-					Param: entity.ParamCurrentPrice,
-					Op:    entity.OpGreater,
-					Val: entity.Expression{
-						IsParam: true,
-						PName:   entity.ParamPreferablePrice,
-					}, //preferablePrice
-				},
-				Right: entity.Condition{
-					M:     "",
-					Param: entity.ParamCurrentWinnerId,
-					Op:    entity.OpNotEq,
-					Val: entity.Expression{
-						IsParam: true,
-						PName:   entity.ParamMyId,
-					}, // myId
-				},
+	AggressiveTemplate = entity.StrategyTemplate{
+		Name: "aggressive",
+		Description: "Outbids the others above the preferable price, re-bids after a delay above the acceptable " +
+			"price and outbids the others down to the minimal price in the additional purchase",
+		Params: []entity.TemplateParam{
+			{
+				Name:        "rebid_delay",
+				Type:        entity.TypeDuration,
+				Default:     60,
+				Min:         1,
+				Max:         3600,
+				Description: "Delay after the own bet before bidding again below the preferable price",
 			},
-			Action: entity.ActionBet,
-			Else: &entity.ConditionSet{
+			granularityParam(5),
+		},
+		Strategy: entity.Strategy{
+			BaseConditionSet: &entity.ConditionSet{
 				Op: entity.Operator{
 					O: entity.UnOpAnd,
 					Left: entity.Condition{
@@ -39,17 +30,17 @@ var (
 						Op:    entity.OpGreater,
 						Val: entity.Expression{
 							IsParam: true,
-							PName:   entity.ParamAcceptablePrice,
-						}, //acceptablePrice
+							PName:   entity.ParamPreferablePrice,
+						}, //preferablePrice
 					},
 					Right: entity.Condition{
 						M:     "",
-						Param: entity.ParamTimeSinceLastMyBet,
-						Op:    entity.OpGreater,
+						Param: entity.ParamCurrentWinnerId,
+						Op:    entity.OpNotEq,
 						Val: entity.Expression{
-							IsConst: true,
-							Value:   1 * time.Minute,
-						}, // 1hour
+							IsParam: true,
+							PName:   entity.ParamMyId,
+						}, // myId
 					},
 				},
 				Action: entity.ActionBet,
@@ -62,114 +53,87 @@ var (
 							Op:    entity.OpGreater,
 							Val: entity.Expression{
 								IsParam: true,
-								PName:   entity.ParamMinimalPrice,
-							}, //preferablePrice
+								PName:   entity.ParamAcceptablePrice,
+							}, //acceptablePrice
+						},
+						Right: entity.Condition{
+							M:     "",
+							Param: entity.ParamTimeSinceLastMyBet,
+							Op:    entity.OpGreater,
+							Val: entity.Expression{
+								IsVar: true,
+								VName: "rebid_delay",
+							}, // 1 minute by default
 						},
-						Right: entity.Operator{
+					},
+					Action: entity.ActionBet,
+					Else: &entity.ConditionSet{
+						Op: entity.Operator{
 							O: entity.UnOpAnd,
 							Left: entity.Condition{
 								M:     "",
-								Param: entity.ParamCurrentWinnerId,
-								Op:    entity.OpNotEq,
+								Param: entity.ParamCurrentPrice,
+								Op:    entity.OpGreater,
 								Val: entity.Expression{
 									IsParam: true,
-									PName:   entity.ParamMyId,
-								}, // myId
-
+									PName:   entity.ParamMinimalPrice,
+								}, //preferablePrice
 							},
-							Right: entity.Condition{
-								M:     "",
-								Param: entity.ParamIsOnAdditionalPurchase,
-								Op:    entity.OpNone,
-								Val:   entity.Expression{IsEmpty: true},
+							Right: entity.Operator{
+								O: entity.UnOpAnd,
+								Left: entity.Condition{
+									M:     "",
+									Param: entity.ParamCurrentWinnerId,
+									Op:    entity.OpNotEq,
+									Val: entity.Expression{
+										IsParam: true,
+										PName:   entity.ParamMyId,
+									}, // myId
+
+								},
+								Right: entity.Condition{
+									M:     "",
+									Param: entity.ParamIsOnAdditionalPurchase,
+									Op:    entity.OpNone,
+									Val:   entity.Expression{IsEmpty: true},
+								},
 							},
 						},
+						Action: entity.ActionBet,
+						Else:   nil,
 					},
-					Action: entity.ActionBet,
-					Else:   nil,
 				},
 			},
+			//R:    "",
+			//Strategy granularity (seconds)
+			N: 5,
 		},
-		Vars: nil,
-		//R:    "",
-		//Strategy granularity (seconds)
-		N: 5,
 	}
 
-	WaitingStrategy = entity.Strategy{
-		BaseConditionSet: &entity.ConditionSet{
-			Op: entity.Operator{
-				O: entity.UnOpNone,
-				Left: entity.Condition{
-					M:     "",
-					Param: entity.ParamMyCurrentBetNumber,
-					Op:    entity.OpEquals,
-					Val: entity.Expression{
-						IsConst: true,
-						Value:   0,
-					},
-				},
-				Right: nil,
-			},
-			Action: entity.ActionBet,
-			Else: &entity.ConditionSet{
+	WaitingTemplate = entity.StrategyTemplate{
+		Name:        "waiting",
+		Description: "Makes the initial bet and waits for the additional purchase to bid down to the minimal price",
+		Params: []entity.TemplateParam{
+			granularityParam(5),
+		},
+		Strategy: entity.Strategy{
+			BaseConditionSet: &entity.ConditionSet{
 				Op: entity.Operator{
-					O: entity.UnOpAnd,
+					O: entity.UnOpNone,
 					Left: entity.Condition{
 						M:     "",
-						Param: entity.ParamCurrentPrice,
-						Op:    entity.OpGreater,
-						Val: entity.Expression{
-							IsParam: true,
-							PName:   entity.ParamMinimalPrice,
-						}, // minimalPrice
-					},
-					Right: entity.Condition{
-						M:     "",
-						Param: entity.ParamIsOnAdditionalPurchase,
+						Param: entity.ParamMyCurrentBetNumber,
 						Op:    entity.OpEquals,
 						Val: entity.Expression{
-							IsEmpty: true,
-						}, //
+							IsConst: true,
+							Value:   0,
+						},
 					},
+					Right: nil,
 				},
 				Action: entity.ActionBet,
-				Else:   nil,
-			},
-		},
-		Vars: nil,
-		//R:                "",
-		N: 5,
-	}
-
-	ProgressiveStrategy = entity.Strategy{
-		BaseConditionSet: &entity.ConditionSet{
-			Op: entity.Operator{
-				O: entity.UnOpAnd,
-				Left: entity.Condition{
-					M:     "",
-					Param: entity.ParamCurrentPrice,
-					Op:    entity.OpGreater,
-					Val: entity.Expression{
-						IsParam: true,
-						PName:   entity.ParamPreferablePrice,
-					},
-				},
-				Right: entity.Condition{
-					M:     "",
-					Param: entity.ParamTimeSinceLastMyBet,
-					Op:    entity.OpGreater,
-					Val: entity.Expression{
-						IsConst: true,
-						Value:   600 * time.Second,
-					},
-				},
-			},
-			Action: entity.ActionBet,
-			Else: &entity.ConditionSet{
-				Op: entity.Operator{
-					O: entity.UnOpAnd,
-					Left: entity.Operator{
+				Else: &entity.ConditionSet{
+					Op: entity.Operator{
 						O: entity.UnOpAnd,
 						Left: entity.Condition{
 							M:     "",
@@ -177,17 +141,68 @@ var (
 							Op:    entity.OpGreater,
 							Val: entity.Expression{
 								IsParam: true,
-								PName:   entity.ParamAcceptablePrice,
-							},
+								PName:   entity.ParamMinimalPrice,
+							}, // minimalPrice
 						},
 						Right: entity.Condition{
 							M:     "",
-							Param: entity.ParamCurrentPrice,
-							Op:    entity.OpLess,
+							Param: entity.ParamIsOnAdditionalPurchase,
+							Op:    entity.OpEquals,
 							Val: entity.Expression{
-								IsParam: true,
-								PName:   entity.ParamPreferablePrice,
-							},
+								IsEmpty: true,
+							}, //
+						},
+					},
+					Action: entity.ActionBet,
+					Else:   nil,
+				},
+			},
+			//R:                "",
+			N: 5,
+		},
+	}
+
+	ProgressiveTemplate = entity.StrategyTemplate{
+		Name:        "progressive",
+		Description: "Bids more often as the price approaches the minimal price",
+		Params: []entity.TemplateParam{
+			{
+				Name:        "preferable_delay",
+				Type:        entity.TypeDuration,
+				Default:     600,
+				Min:         1,
+				Max:         3600,
+				Description: "Delay between the own bets above the preferable price",
+			},
+			{
+				Name:        "acceptable_delay",
+				Type:        entity.TypeDuration,
+				Default:     100,
+				Min:         1,
+				Max:         3600,
+				Description: "Delay between the own bets from the acceptable to the preferable price",
+			},
+			{
+				Name:        "minimal_delay",
+				Type:        entity.TypeDuration,
+				Default:     5,
+				Min:         1,
+				Max:         3600,
+				Description: "Delay between the own bets from the minimal to the acceptable price",
+			},
+			granularityParam(1),
+		},
+		Strategy: entity.Strategy{
+			BaseConditionSet: &entity.ConditionSet{
+				Op: entity.Operator{
+					O: entity.UnOpAnd,
+					Left: entity.Condition{
+						M:     "",
+						Param: entity.ParamCurrentPrice,
+						Op:    entity.OpGreater,
+						Val: entity.Expression{
+							IsParam: true,
+							PName:   entity.ParamPreferablePrice,
 						},
 					},
 					Right: entity.Condition{
@@ -195,8 +210,8 @@ var (
 						Param: entity.ParamTimeSinceLastMyBet,
 						Op:    entity.OpGreater,
 						Val: entity.Expression{
-							IsConst: true,
-							Value:   100 * time.Second,
+							IsVar: true,
+							VName: "preferable_delay",
 						},
 					},
 				},
@@ -212,7 +227,7 @@ var (
 								Op:    entity.OpGreater,
 								Val: entity.Expression{
 									IsParam: true,
-									PName:   entity.ParamMinimalPrice,
+									PName:   entity.ParamAcceptablePrice,
 								},
 							},
 							Right: entity.Condition{
@@ -221,7 +236,7 @@ var (
 								Op:    entity.OpLess,
 								Val: entity.Expression{
 									IsParam: true,
-									PName:   entity.ParamAcceptablePrice,
+									PName:   entity.ParamPreferablePrice,
 								},
 							},
 						},
@@ -230,18 +245,77 @@ var (
 							Param: entity.ParamTimeSinceLastMyBet,
 							Op:    entity.OpGreater,
 							Val: entity.Expression{
-								IsConst: true,
-								Value:   5 * time.Second,
+								IsVar: true,
+								VName: "acceptable_delay",
 							},
 						},
 					},
 					Action: entity.ActionBet,
-					Else:   nil,
+					Else: &entity.ConditionSet{
+						Op: entity.Operator{
+							O: entity.UnOpAnd,
+							Left: entity.Operator{
+								O: entity.UnOpAnd,
+								Left: entity.Condition{
+									M:     "",
+									Param: entity.ParamCurrentPrice,
+									Op:    entity.OpGreater,
+									Val: entity.Expression{
+										IsParam: true,
+										PName:   entity.ParamMinimalPrice,
+									},
+								},
+								Right: entity.Condition{
+									M:     "",
+									Param: entity.ParamCurrentPrice,
+									Op:    entity.OpLess,
+									Val: entity.Expression{
+										IsParam: true,
+										PName:   entity.ParamAcceptablePrice,
+									},
+								},
+							},
+							Right: entity.Condition{
+								M:     "",
+								Param: entity.ParamTimeSinceLastMyBet,
+								Op:    entity.OpGreater,
+								Val: entity.Expression{
+									IsVar: true,
+									VName: "minimal_delay",
+								},
+							},
+						},
+						Action: entity.ActionBet,
+						Else:   nil,
+					},
 				},
 			},
+			//R:                "",
+			N: 1,
 		},
-		Vars: nil,
-		//R:                "",
-		N: 1,
 	}
 )
+
+// Templates are the predefined strategies, selected by name in StrategyParams
+var Templates = []entity.StrategyTemplate{AggressiveTemplate, WaitingTemplate, ProgressiveTemplate}
+
+// FindTemplate finds a predefined strategy by its name
+func FindTemplate(name string) (entity.StrategyTemplate, bool) {
+	for _, t := range Templates {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return entity.StrategyTemplate{}, false
+}
+
+func granularityParam(seconds float64) entity.TemplateParam {
+	return entity.TemplateParam{
+		Name:        entity.VarGranularity,
+		Type:        entity.TypeInteger,
+		Default:     seconds,
+		Min:         1,
+		Max:         60,
+		Description: "Seconds between the runner ticks",
+	}
+}
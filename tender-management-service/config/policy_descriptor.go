@@ -8,6 +8,8 @@ const (
 	Profile  = "profile"
 	Session  = "session"
 	Bet      = "bet"
+	//Simulated clock, routed in the simulation mode only
+	Clock = "clock"
 
 	//Actions-------------------------------------------
 	Read   = "read"
@@ -19,4 +21,6 @@ const (
 	Customer = "customer"
 	Admin    = "admin"
 	Provider = "provider"
+	//Other services of the app, e.g. the strategy service betting for the runners
+	Service = "service"
 )
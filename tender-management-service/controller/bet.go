@@ -3,24 +3,33 @@ package controller
 import (
 	"context"
 	"github.com/gin-gonic/gin"
+	"io"
 	"main/model/entity"
 	"main/model/response"
 	"main/repository"
+	"main/service"
 	"main/utils"
 	"net/http"
 	"strconv"
+	"time"
 )
 
+// betKeepAlive is how often an idle bet stream gets a comment line, so the proxies do not close it
+const betKeepAlive = 15 * time.Second
+
 type BetController struct {
-	BetRepo repository.BetRepository
-	ctx     context.Context
+	BetRepo     repository.BetRepository
+	Broadcaster *service.BetBroadcaster
+	ctx         context.Context
 }
 
 // NewProductController example
-func NewBetController(ctx context.Context, repo repository.BetRepository) *BetController {
+func NewBetController(ctx context.Context, repo repository.BetRepository,
+	events *service.BetBroadcaster) *BetController {
 	return &BetController{
-		BetRepo: repo,
-		ctx:     ctx,
+		BetRepo:     repo,
+		Broadcaster: events,
+		ctx:         ctx,
 	}
 }
 
@@ -31,10 +40,11 @@ func NewBetController(ctx context.Context, repo repository.BetRepository) *BetCo
 // @Accept                        json
 // @Produce                       json
 // @Param        Authorization  header    string  true  "Authentication header"
-// @Param               bet   body            entity.BetData  true  "Bet info"
+// @Param               bet   body            entity.BetData  true  "Bet info, min_price refuses a bet lowering the price below it"
 // @Success             201             {string}  string                        "New bet successfully made"
 // @Failure        400  {object}  utils.HTTPError
 // @Failure        404  {object}  utils.HTTPError
+// @Failure        409  {object}  utils.HTTPError  "Session isn't active, repeated bet or price floor, see error_code"
 // @Failure        500  {object}  utils.HTTPError
 // @Router                        /api/v1/bets [post]
 func (c *BetController) MakeBet(ctx *gin.Context) {
@@ -47,13 +57,34 @@ func (c *BetController) MakeBet(ctx *gin.Context) {
 	c2 := context.Background()
 	id, err := c.BetRepo.MakeBet(c2, p)
 	if err != nil {
-		utils.NewError(ctx, http.StatusInternalServerError, err)
+		utils.NewCodedError(ctx, betErrorStatus(err), entity.BetErrorCode(err), err)
 		return
 	}
 
 	ctx.JSON(http.StatusCreated, response.BetMade{ID: id})
 }
 
+// TryBets godoc
+// @Summary            Try bets
+// @Description    Tries bets on a copy of the session by the auction rules and returns the session after them, nothing is saved
+// @Tags                          bets
+// @Accept                        json
+// @Produce                       json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               trial   body            entity.BetTrial  true  "Session and bets, the session is expired at the time of each bet"
+// @Success             200             {object}  entity.BetTrialResult
+// @Failure        400  {object}  utils.HTTPError
+// @Router                        /api/v1/bets/trial [post]
+func (c *BetController) TryBets(ctx *gin.Context) {
+	var trial entity.BetTrial
+	if err := ctx.ShouldBindJSON(&trial); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, trial.Run())
+}
+
 // GetBetsBySessionId godoc
 // @Summary            Get bets by session Id
 // @Description    Get bets history for a selected session
@@ -85,3 +116,106 @@ func (c *BetController) GetBetsBySessionId(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, bets)
 }
+
+// GetBets godoc
+// @Summary            Get bets
+// @Description    Get bets of any session matching the filter in the order they were made
+// @Tags                          bets
+// @Accept                        json
+// @Produce                       json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               provider_id   query            []int false  "Only bets of the providers" collectionFormat(multi)
+// @Param               bot   query            bool false  "Only bot or only manual bets"
+// @Param               since   query            string false  "Only bets made at the time or later, RFC 3339"
+// @Success             200             {array}  entity.Bet
+// @Failure        400  {object}  utils.HTTPError
+// @Failure        500  {object}  utils.HTTPError
+// @Router                        /api/v1/bets [get]
+func (c *BetController) GetBets(ctx *gin.Context) {
+	var filter entity.BetFilter
+	for _, id := range ctx.QueryArray("provider_id") {
+		providerId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		filter.ProviderIds = append(filter.ProviderIds, providerId)
+	}
+	if bot := ctx.Query("bot"); bot != "" {
+		value, err := strconv.ParseBool(bot)
+		if err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		filter.Bot = &value
+	}
+	if since := ctx.Query("since"); since != "" {
+		var err error
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	bets, err := c.BetRepo.GetBets(c.ctx, filter)
+	if err != nil {
+		utils.NewError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, bets)
+}
+
+// BetEvents godoc
+// @Summary            Stream bets
+// @Description    Streams the bets made from now on as server-sent events named bet, until the client disconnects, an idle stream gets a keep-alive comment line
+// @Tags                          bets
+// @Produce                       text/event-stream
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               session_id   query            []int false  "Only bets of the sessions" collectionFormat(multi)
+// @Success             200             {object}  entity.Bet
+// @Failure        400  {object}  utils.HTTPError
+// @Router                        /api/v1/bets/events [get]
+func (c *BetController) BetEvents(ctx *gin.Context) {
+	sessions := map[int64]bool{}
+	for _, id := range ctx.QueryArray("session_id") {
+		sessionId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			utils.NewError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		sessions[sessionId] = true
+	}
+
+	bets := c.Broadcaster.Subscribe(ctx.Request.Context())
+	keepAlive := time.NewTicker(betKeepAlive)
+	defer keepAlive.Stop()
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+	// the bets are closed once the client disconnects
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case bet, ok := <-bets:
+			if !ok {
+				return false
+			}
+			if len(sessions) == 0 || sessions[bet.QuotationSessionID] {
+				ctx.SSEvent("bet", bet)
+			}
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		}
+	})
+}
+
+// betErrorStatus maps the refused bets to 409, other errors to 500
+func betErrorStatus(err error) int {
+	if entity.BetErrorCode(err) != "" {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"main/logging"
+	"main/model/entity"
+	"main/model/response"
+	"main/service"
+	"main/utils"
+	"net/http"
+	"time"
+)
+
+// Controller of the simulated clock, only routed in the simulation mode.
+// The sessions whose time is over are finished on each advance
+type ClockController struct {
+	clock      *utils.FakeClock
+	sessionSrv service.QuotationSessionService
+	ctx        context.Context
+}
+
+func NewClockController(ctx context.Context, clock *utils.FakeClock,
+	sessionSrv service.QuotationSessionService) *ClockController {
+	return &ClockController{
+		clock:      clock,
+		sessionSrv: sessionSrv,
+		ctx:        ctx,
+	}
+}
+
+// GetClock godoc
+// @Summary            Get simulated time
+// @Description    Returns the current time of the simulated clock
+// @Tags                      clock
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Success             200             {object}  response.ClockTime
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/clock [get]
+func (c ClockController) GetClock(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, response.ClockTime{Now: c.clock.Now()})
+}
+
+// AdvanceClock godoc
+// @Summary            Advance simulated time
+// @Description    Moves the simulated clock forward, the sessions whose time is over are finished
+// @Tags                      clock
+// @Accept                    json
+// @Produce                   json
+// @Param        Authorization  header    string  true  "Authentication header"
+// @Param               data            body      entity.ClockAdvance true  "Duration to advance by"
+// @Success             200             {object}  response.ClockTime
+// @Failure        400        {object}            utils.HTTPError
+// @Failure        500        {object}            utils.HTTPError
+// @Router                    /api/v1/clock/advance [post]
+func (c ClockController) AdvanceClock(ctx *gin.Context) {
+	var advance entity.ClockAdvance
+	if err := ctx.ShouldBindJSON(&advance); err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	d, err := time.ParseDuration(advance.Duration)
+	if err != nil {
+		utils.NewError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if d <= 0 {
+		utils.NewError(ctx, http.StatusBadRequest, fmt.Errorf("duration %s is not positive", d))
+		return
+	}
+	now := c.clock.Advance(d)
+	logging.InfoFormat("Simulated clock advanced by %s to %s", d, now.Format(time.RFC3339))
+	c.sessionSrv.UpdateQuotationSessionsStatus()
+
+	ctx.JSON(http.StatusOK, response.ClockTime{Now: now})
+}
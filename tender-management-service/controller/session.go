@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"main/logging"
 	"main/model/entity"
@@ -10,22 +11,23 @@ import (
 	"main/utils"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // Controller for quotation session entity
 type SessionController struct {
 	SessionRepo        repository.QuotationSessionRepository
 	ProductJournalRepo repository.ProductJournalRepository
+	clock              utils.Clock
 	ctx                context.Context
 }
 
 // NewProductController example
 func NewSessionController(ctx context.Context, repo repository.QuotationSessionRepository,
-	pJRepo repository.ProductJournalRepository) *SessionController {
+	pJRepo repository.ProductJournalRepository, clock utils.Clock) *SessionController {
 	return &SessionController{
 		ProductJournalRepo: pJRepo,
 		SessionRepo:        repo,
+		clock:              clock,
 		ctx:                ctx,
 	}
 }
@@ -59,7 +61,7 @@ func (c SessionController) NewQuotationSession(ctx *gin.Context) {
 		StartPrice:             s.StartPrice,
 		CurrentPrice:           s.StartPrice,
 		SessionStepPercent:     s.SessionStepPercent,
-		StartTime:              time.Now(),
+		StartTime:              c.clock.Now(),
 		IsInAdditionalPurchase: false,
 	}
 	for _, product := range s.Products {
@@ -124,11 +126,12 @@ func (c *SessionController) GetSessionById(ctx *gin.Context) {
 
 // GetAllSessions godoc
 // @Summary            Get all quotation sessions
-// @Description    		Returns all short sessions
+// @Description    		Returns all short sessions, or the full sessions with the status when it is set
 // @Tags                     sessions
 // @Accept                   json
 // @Produce                  json
 // @Param        Authorization  header    string  true  "Authentication header"
+// @Param               status         query       string  false  "ACTIVE, FINISHED or DIDNT_TAKE_PLACE"
 // @Success             200            {object}  entity.QuotationSessionShort
 // @Failure        400       {object}  utils.HTTPError
 // @Failure        404       {object}  utils.HTTPError
@@ -137,6 +140,10 @@ func (c *SessionController) GetSessionById(ctx *gin.Context) {
 func (c *SessionController) GetAllSessions(ctx *gin.Context) {
 	//TODO deal with contexts correctly
 	c2 := context.Background()
+	if status := entity.SessionStatus(ctx.Query("status")); status != "" {
+		c.getSessionsByStatus(ctx, c2, status)
+		return
+	}
 	sessions, err := c.SessionRepo.GetAllSessions(c2)
 	if err != nil {
 		utils.NewError(ctx, http.StatusInternalServerError, err)
@@ -152,3 +159,22 @@ func (c *SessionController) GetAllSessions(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, res)
 }
+
+func (c *SessionController) getSessionsByStatus(ctx *gin.Context, c2 context.Context, status entity.SessionStatus) {
+	switch status {
+	case entity.StatusActive, entity.StatusFinished, entity.StatusDidntTakePlace:
+	default:
+		utils.NewError(ctx, http.StatusBadRequest, fmt.Errorf("unknown session status %s", status))
+		return
+	}
+	sessions, err := c.SessionRepo.GetSessionsByStatus(c2, status)
+	if err != nil {
+		utils.NewError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if sessions == nil {
+		sessions = []*entity.QuotationSession{}
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
@@ -15,6 +15,7 @@ import (
 	repo "main/repository/impl"
 	"main/service"
 	"main/utils"
+	"time"
 
 	"github.com/gin-contrib/pprof"
 	swaggerFiles "github.com/swaggo/files"
@@ -59,6 +60,15 @@ func main() {
 		panic(err)
 	}
 
+	// in the simulation mode the clock stands still until an admin advances it
+	clock := utils.NewSystemClock()
+	var simClock *utils.FakeClock
+	if utils.GetEnvBool(utils.SimulationModeEnvKey, false) {
+		simClock = utils.NewFakeClock(time.Now())
+		clock = simClock
+		logging.InfoFormat("Simulation mode, the clock starts at %s", simClock.Now().Format(time.RFC3339))
+	}
+
 	// Two possible options: pg ORM with schema init or SQL queries based repository
 	// with custom migrations from files
 	//productRepo := repo.NewPgProductRepository(connection.Connection())
@@ -68,17 +78,19 @@ func main() {
 	accountRepo := repo.NewPgOrmAccountRepository(ctx, connection.Connection().(*pg.DB))
 	profileRepo := repo.NewPgOrmProfileRepository(ctx, connection.Connection().(*pg.DB))
 	sessionRepo := repo.NewPgOrmQuotationSessionRepository(connection.Connection().(*pg.DB))
-	betRepo := repo.NewPgOrmBetRepository(ctx, connection.Connection().(*pg.DB))
+	betRepo := repo.NewPgOrmBetRepository(ctx, connection.Connection().(*pg.DB), clock)
 	pJRepo := repo.NewPgOrmProductJournalRepository(ctx, connection.Connection().(*pg.DB))
 
 	c := controller.NewProductController(ctx, productRepo)
 	catC := controller.NewCategoryController(ctx, categoryRepo)
 	authC := controller.NewAuthController(ctx, accountRepo)
 	prfC := controller.NewProfileController(ctx, profileRepo)
-	qsC := controller.NewSessionController(ctx, sessionRepo, pJRepo)
-	betC := controller.NewBetController(ctx, betRepo)
+	qsC := controller.NewSessionController(ctx, sessionRepo, pJRepo, clock)
+	betEvents := service.NewBetBroadcaster(ctx, betRepo)
+	go betEvents.Run()
+	betC := controller.NewBetController(ctx, betRepo, betEvents)
 
-	qsSrv := service.NewQuotationSessionService(ctx, sessionRepo)
+	qsSrv := service.NewQuotationSessionService(ctx, sessionRepo, clock)
 	err = qsSrv.RunQuotationService()
 	if err != nil {
 		logging.ErrorFormat("Cannot run quotation session service: %s", err)
@@ -147,9 +159,25 @@ func main() {
 			{
 				bets.POST("", middleware.Authorize(config.Bet, config.Insert, fileAdapter),
 					betC.MakeBet)
+				bets.POST("trial", middleware.Authorize(config.Bet, config.Read, fileAdapter),
+					betC.TryBets)
+				bets.GET("", middleware.Authorize(config.Bet, config.Read, fileAdapter),
+					betC.GetBets)
+				bets.GET("events", middleware.Authorize(config.Bet, config.Read, fileAdapter),
+					betC.BetEvents)
 				bets.GET(":sessionId", middleware.Authorize(config.Bet, config.Read, fileAdapter),
 					betC.GetBetsBySessionId)
 			}
+			if simClock != nil {
+				clockC := controller.NewClockController(ctx, simClock, qsSrv)
+				simulation := v1.Group("/clock")
+				{
+					simulation.GET("", middleware.Authorize(config.Clock, config.Read, fileAdapter),
+						clockC.GetClock)
+					simulation.POST("advance", middleware.Authorize(config.Clock, config.Update, fileAdapter),
+						clockC.AdvanceClock)
+				}
+			}
 
 		}
 		authorized.POST("/logout", authC.Logout)
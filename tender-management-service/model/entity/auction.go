@@ -0,0 +1,79 @@
+package entity
+
+import "time"
+
+// AdditionalPurchaseDuration is the additional purchase window, a bet made
+// during the last minutes of a session prolongs it by the same time
+const AdditionalPurchaseDuration = 5 * time.Minute
+
+// EndTime returns the time the session ends, including the additional purchase
+func (s QuotationSession) EndTime() time.Time {
+	return s.StartTime.Add(time.Duration(s.SessionDuration) * time.Minute)
+}
+
+// ApplyBet applies the auction rules to a bet made at bet.Time, the bet is refused with ErrPriceFloor
+// when the new price would fall below minPrice, no floor when 0.
+// The price goes down by one step, the session is finished when it reaches zero.
+// A bet made during the last 5 minutes starts the additional purchase, each bet
+// made during the last 5 minutes of the additional purchase prolongs the session by 5 minutes.
+// The bet gets its number and price and becomes the last bet of the session.
+// Returns false when the additional purchase is over: the session is finished
+// and the bet is not made.
+func (s *QuotationSession) ApplyBet(bet *Bet, minPrice float64) (bool, error) {
+	if s.Status != StatusActive {
+		return false, ErrSessionNotActive
+	}
+	endTime := s.EndTime()
+	if s.IsInAdditionalPurchase && endTime.Sub(bet.Time) <= 0 {
+		s.Status = StatusFinished
+		return false, nil
+	}
+	if s.LastBet != nil && s.LastBet.ProviderId == bet.ProviderId {
+		return false, ErrRepeatedBet
+	}
+
+	newPrice := s.CurrentPrice - s.StartPrice*(s.SessionStepPercent/100)
+	if newPrice < 0 {
+		newPrice = 0
+	}
+	if newPrice < minPrice {
+		return false, ErrPriceFloor
+	}
+	if newPrice == 0 {
+		s.Status = StatusFinished
+	}
+	s.CurrentPrice = newPrice
+	bet.NewPrice = newPrice
+	if s.LastBet != nil {
+		bet.BetNumber = s.LastBet.BetNumber + 1
+	} else {
+		bet.BetNumber = 0
+	}
+	s.LastBet = bet
+
+	additionalMinutes := int(AdditionalPurchaseDuration / time.Minute)
+	if s.IsInAdditionalPurchase {
+		if endTime.Sub(bet.Time) <= AdditionalPurchaseDuration {
+			s.SessionDuration += additionalMinutes
+		}
+	} else if s.Status == StatusActive &&
+		endTime.Sub(bet.Time) > 0 && endTime.Sub(bet.Time) < AdditionalPurchaseDuration {
+		s.IsInAdditionalPurchase = true
+		s.SessionDuration += additionalMinutes
+	}
+	return true, nil
+}
+
+// Expire finishes an active session which is over at the given time,
+// a session without bets didn't take place. Returns true when the status is changed.
+func (s *QuotationSession) Expire(now time.Time) bool {
+	if s.Status != StatusActive || !s.EndTime().Before(now) {
+		return false
+	}
+	if s.LastBet != nil {
+		s.Status = StatusFinished
+	} else {
+		s.Status = StatusDidntTakePlace
+	}
+	return true
+}
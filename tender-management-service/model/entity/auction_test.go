@@ -0,0 +1,164 @@
+package entity
+
+import (
+	"errors"
+	"main/utils"
+	"testing"
+	"time"
+)
+
+func TestApplyBetAdditionalPurchase(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// state of the 60 minutes session at 1000 with 10% steps
+		additional   bool
+		duration     int
+		currentPrice float64
+		lastProvider int64
+		status       SessionStatus
+		minPrice     float64
+		// the bet of provider 2 is made once the clock has moved by after
+		after time.Duration
+
+		wantPlaced     bool
+		wantErr        error
+		wantStatus     SessionStatus
+		wantAdditional bool
+		wantDuration   int
+		wantPrice      float64
+	}{
+		{
+			name:  "bet before the window keeps the duration",
+			after: 30 * time.Minute, wantPlaced: true, wantStatus: StatusActive,
+			wantDuration: 60, wantPrice: 900,
+		},
+		{
+			name:  "bet 5 minutes before the end does not start the additional purchase",
+			after: 55 * time.Minute, wantPlaced: true, wantStatus: StatusActive,
+			wantDuration: 60, wantPrice: 900,
+		},
+		{
+			name:  "bet in the last 5 minutes starts the additional purchase",
+			after: 56 * time.Minute, wantPlaced: true, wantStatus: StatusActive,
+			wantAdditional: true, wantDuration: 65, wantPrice: 900,
+		},
+		{
+			name:       "bet in the last 5 minutes of the additional purchase prolongs it",
+			additional: true, duration: 65,
+			after: 61 * time.Minute, wantPlaced: true, wantStatus: StatusActive,
+			wantAdditional: true, wantDuration: 70, wantPrice: 900,
+		},
+		{
+			name:       "earlier bet in the additional purchase does not prolong it",
+			additional: true, duration: 70,
+			after: 61 * time.Minute, wantPlaced: true, wantStatus: StatusActive,
+			wantAdditional: true, wantDuration: 70, wantPrice: 900,
+		},
+		{
+			name:       "bet after the additional purchase finishes the session",
+			additional: true, duration: 65,
+			after: 65 * time.Minute, wantStatus: StatusFinished,
+			wantAdditional: true, wantDuration: 65, wantPrice: 1000,
+		},
+		{
+			name:         "last step finishes the session without the additional purchase",
+			currentPrice: 100,
+			after:        56 * time.Minute, wantPlaced: true, wantStatus: StatusFinished,
+			wantDuration: 60, wantPrice: 0,
+		},
+		{
+			name:         "repeated bet is refused",
+			lastProvider: 2,
+			after:        56 * time.Minute, wantErr: ErrRepeatedBet, wantStatus: StatusActive,
+			wantDuration: 60, wantPrice: 1000,
+		},
+		{
+			name:     "bet below the minimal price is refused",
+			minPrice: 950,
+			after:    56 * time.Minute, wantErr: ErrPriceFloor, wantStatus: StatusActive,
+			wantDuration: 60, wantPrice: 1000,
+		},
+		{
+			name:   "bet in a finished session is refused",
+			status: StatusFinished,
+			after:  30 * time.Minute, wantErr: ErrSessionNotActive, wantStatus: StatusFinished,
+			wantDuration: 60, wantPrice: 1000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := utils.NewFakeClock(start)
+			session := QuotationSession{
+				Status:                 StatusActive,
+				SessionDuration:        60,
+				StartPrice:             1000,
+				CurrentPrice:           1000,
+				SessionStepPercent:     10,
+				StartTime:              clock.Now(),
+				IsInAdditionalPurchase: tt.additional,
+			}
+			if tt.duration != 0 {
+				session.SessionDuration = tt.duration
+			}
+			if tt.currentPrice != 0 {
+				session.CurrentPrice = tt.currentPrice
+			}
+			if tt.status != "" {
+				session.Status = tt.status
+			}
+			if tt.lastProvider != 0 {
+				session.LastBet = &Bet{ProviderId: tt.lastProvider, BetNumber: 3, Time: start}
+			}
+
+			bet := &Bet{ProviderId: 2, Time: clock.Advance(tt.after)}
+			placed, err := session.ApplyBet(bet, tt.minPrice)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyBet() error = %v, want %v", err, tt.wantErr)
+			}
+			if placed != tt.wantPlaced {
+				t.Errorf("ApplyBet() placed = %t, want %t", placed, tt.wantPlaced)
+			}
+			if session.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", session.Status, tt.wantStatus)
+			}
+			if session.IsInAdditionalPurchase != tt.wantAdditional {
+				t.Errorf("additional purchase = %t, want %t", session.IsInAdditionalPurchase, tt.wantAdditional)
+			}
+			if session.SessionDuration != tt.wantDuration {
+				t.Errorf("duration = %d, want %d", session.SessionDuration, tt.wantDuration)
+			}
+			if session.CurrentPrice != tt.wantPrice {
+				t.Errorf("price = %.2f, want %.2f", session.CurrentPrice, tt.wantPrice)
+			}
+			if placed && (session.LastBet != bet || bet.NewPrice != tt.wantPrice) {
+				t.Errorf("bet %+v is not the last bet at price %.2f", bet, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestApplyBetNumbers(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC))
+	session := QuotationSession{
+		Status:             StatusActive,
+		SessionDuration:    60,
+		StartPrice:         1000,
+		CurrentPrice:       1000,
+		SessionStepPercent: 10,
+		StartTime:          clock.Now(),
+	}
+	for i, provider := range []int64{1, 2, 1} {
+		bet := &Bet{ProviderId: provider, Time: clock.Advance(time.Minute)}
+		if placed, err := session.ApplyBet(bet, 0); !placed || err != nil {
+			t.Fatalf("bet %d: placed = %t, error = %v", i, placed, err)
+		}
+		if bet.BetNumber != i {
+			t.Errorf("bet %d: number = %d", i, bet.BetNumber)
+		}
+	}
+	if session.CurrentPrice != 700 {
+		t.Errorf("price = %.2f, want 700", session.CurrentPrice)
+	}
+}
@@ -1,9 +1,37 @@
 package entity
 
 import (
+	"errors"
 	"time"
 )
 
+var (
+	ErrSessionNotActive = errors.New("cannot make bet: session isn't active")
+	ErrRepeatedBet      = errors.New("cannot make bet: this provider made the last bet")
+	ErrPriceFloor       = errors.New("cannot make bet: the price would fall below the minimal price")
+)
+
+// The error codes of the refused bets, the clients tell the refusals apart by them
+const (
+	CodeSessionNotActive = "session_not_active"
+	CodeRepeatedBet      = "repeated_bet"
+	CodePriceFloor       = "price_floor"
+)
+
+// BetErrorCode returns the error code of a refused bet, empty for the other errors
+func BetErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrSessionNotActive):
+		return CodeSessionNotActive
+	case errors.Is(err, ErrRepeatedBet):
+		return CodeRepeatedBet
+	case errors.Is(err, ErrPriceFloor):
+		return CodePriceFloor
+	default:
+		return ""
+	}
+}
+
 // Bet example
 type Bet struct {
 	ID                 int64     `pg:"id,pk" json:"id"`
@@ -20,4 +48,14 @@ type BetData struct {
 	QuotationSessionID int64 `json:"quotation_session_id"`
 	ProviderId         int64 `json:"provider_id"`
 	Bot                bool  `json:"bot"`
+	// The bet is refused with ErrPriceFloor when the new price would be lower, no floor when 0
+	MinPrice float64 `json:"min_price,omitempty"`
+}
+
+// BetFilter selects the bets, an empty field matches any bet
+type BetFilter struct {
+	ProviderIds []int64
+	Bot         *bool
+	// Bets made at the time or later
+	Since time.Time
 }
@@ -0,0 +1,60 @@
+package entity
+
+import "time"
+
+// BetTrial tries bets on a copy of a session by the auction rules, nothing is saved.
+// The strategy service predicts the price of its bets and replays the sessions with it.
+type BetTrial struct {
+	Session QuotationSession `json:"session"`
+	// The bets are tried in order
+	Bets []TrialBet `json:"bets"`
+	// The session is expired at the time after the bets, unless zero
+	Until time.Time `json:"until"`
+}
+
+// TrialBet is a bet made at Time
+type TrialBet struct {
+	ProviderId int64     `json:"provider_id"`
+	Time       time.Time `json:"time"`
+	Bot        bool      `json:"bot"`
+	// The bet is refused with ErrPriceFloor when the new price would be lower, no floor when 0
+	MinPrice float64 `json:"min_price,omitempty"`
+}
+
+// TrialBetResult is the bet as it would be made
+type TrialBetResult struct {
+	Bet Bet `json:"bet"`
+	// False when the bet is refused or the session is over
+	Placed bool `json:"placed"`
+	// The error code of the refused bet
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// BetTrialResult is the session after the bets
+type BetTrialResult struct {
+	Session QuotationSession `json:"session"`
+	Bets    []TrialBetResult `json:"bets"`
+}
+
+// Run tries the bets, the session is expired at the time of each bet before it
+// as the status update of the sessions would do
+func (t BetTrial) Run() BetTrialResult {
+	session := t.Session
+	res := BetTrialResult{Bets: make([]TrialBetResult, 0, len(t.Bets))}
+	for _, b := range t.Bets {
+		session.Expire(b.Time)
+		bet := &Bet{
+			QuotationSessionID: session.ID,
+			ProviderId:         b.ProviderId,
+			Time:               b.Time,
+			Bot:                b.Bot,
+		}
+		placed, err := session.ApplyBet(bet, b.MinPrice)
+		res.Bets = append(res.Bets, TrialBetResult{Bet: *bet, Placed: placed, ErrorCode: BetErrorCode(err)})
+	}
+	if !t.Until.IsZero() {
+		session.Expire(t.Until)
+	}
+	res.Session = session
+	return res
+}
@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBetTrialRun(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	session := QuotationSession{
+		ID:                 7,
+		Status:             StatusActive,
+		SessionDuration:    60,
+		StartPrice:         1000,
+		CurrentPrice:       1000,
+		SessionStepPercent: 10,
+		StartTime:          start,
+	}
+	trial := BetTrial{
+		Session: session,
+		Bets: []TrialBet{
+			{ProviderId: 1, Time: start.Add(10 * time.Minute), Bot: true},
+			{ProviderId: 1, Time: start.Add(20 * time.Minute)},
+			{ProviderId: 2, Time: start.Add(30 * time.Minute), MinPrice: 850},
+			{ProviderId: 2, Time: start.Add(56 * time.Minute), MinPrice: 800},
+			// the additional purchase ends at 65 minutes
+			{ProviderId: 1, Time: start.Add(66 * time.Minute)},
+		},
+	}
+	want := []struct {
+		placed    bool
+		errorCode string
+		number    int
+		price     float64
+	}{
+		{placed: true, number: 0, price: 900},
+		{errorCode: CodeRepeatedBet},
+		{errorCode: CodePriceFloor},
+		{placed: true, number: 1, price: 800},
+		{errorCode: CodeSessionNotActive},
+	}
+
+	res := trial.Run()
+	if len(res.Bets) != len(want) {
+		t.Fatalf("%d results, want %d", len(res.Bets), len(want))
+	}
+	for i, w := range want {
+		got := res.Bets[i]
+		if got.Placed != w.placed || got.ErrorCode != w.errorCode {
+			t.Errorf("bet %d: placed = %t, error code = %q, want %t, %q", i, got.Placed, got.ErrorCode,
+				w.placed, w.errorCode)
+		}
+		if w.placed && (got.Bet.BetNumber != w.number || got.Bet.NewPrice != w.price ||
+			got.Bet.QuotationSessionID != session.ID) {
+			t.Errorf("bet %d = %+v, want number %d at %.0f", i, got.Bet, w.number, w.price)
+		}
+	}
+	if !res.Bets[0].Bet.Bot {
+		t.Errorf("bet 0 is not a bot bet")
+	}
+	if res.Session.Status != StatusFinished || res.Session.CurrentPrice != 800 || res.Session.SessionDuration != 65 {
+		t.Errorf("session = %s at %.0f for %d minutes, want %s at 800 for 65 minutes", res.Session.Status,
+			res.Session.CurrentPrice, res.Session.SessionDuration, StatusFinished)
+	}
+	if trial.Session.Status != StatusActive || trial.Session.LastBet != nil {
+		t.Errorf("the trial changed the session %+v", trial.Session)
+	}
+}
+
+func TestBetTrialUntil(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	session := QuotationSession{Status: StatusActive, SessionDuration: 60, StartPrice: 1000, CurrentPrice: 1000,
+		SessionStepPercent: 10, StartTime: start}
+	tests := []struct {
+		name  string
+		until time.Time
+		want  SessionStatus
+	}{
+		{name: "no time keeps the session", want: StatusActive},
+		{name: "session is not over", until: start.Add(time.Hour), want: StatusActive},
+		{name: "session without bets is over", until: start.Add(time.Hour + time.Second), want: StatusDidntTakePlace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := BetTrial{Session: session, Until: tt.until}.Run()
+			if res.Session.Status != tt.want {
+				t.Errorf("status = %s, want %s", res.Session.Status, tt.want)
+			}
+		})
+	}
+}
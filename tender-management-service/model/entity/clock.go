@@ -0,0 +1,7 @@
+package entity
+
+// ClockAdvance moves the simulated clock of the service forward
+type ClockAdvance struct {
+	// Positive Go duration, e.g. 30s or 5m
+	Duration string `json:"duration" example:"5m"`
+}
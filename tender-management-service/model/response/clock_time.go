@@ -0,0 +1,7 @@
+package response
+
+import "time"
+
+type ClockTime struct {
+	Now time.Time `json:"now"`
+}
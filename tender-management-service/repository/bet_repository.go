@@ -8,9 +8,19 @@ import (
 const (
 	TagMakeBet    = "MAKE BET"
 	TagGetBetById = "GET BET BY ID"
+	TagGetBets    = "GET BETS"
+	TagListenBets = "LISTEN BETS"
 )
 
+// BetsChannel is the Postgres channel notified with every bet made, as JSON,
+// each instance listens to it once and passes the bets to its streams
+const BetsChannel = "bets"
+
 type BetRepository interface {
 	MakeBet(context.Context, entity.BetData) (int64, error)
 	GetBetBySessionId(ctx context.Context, sessionId int64) ([]*entity.Bet, error)
+	// GetBets returns the bets of any session matching the filter in the order they were made
+	GetBets(ctx context.Context, filter entity.BetFilter) ([]*entity.Bet, error)
+	// ListenBets delivers every bet made from now on, the channel is closed once ctx is done
+	ListenBets(ctx context.Context) (<-chan *entity.Bet, error)
 }
@@ -2,24 +2,26 @@ package impl
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"github.com/go-pg/pg/v10"
 	"main/logging"
 	"main/model/entity"
 	"main/repository"
 	"main/utils"
-	"time"
 )
 
 func NewPgOrmBetRepository(ctx context.Context,
-	db *pg.DB) repository.BetRepository {
+	db *pg.DB, clock utils.Clock) repository.BetRepository {
 	return pgOrmBetRepository{
 		pgOrm: db,
+		clock: clock,
 	}
 }
 
 type pgOrmBetRepository struct {
 	pgOrm *pg.DB
+	// stamps the bets, the additional purchase is measured from their time
+	clock utils.Clock
 }
 
 func (p pgOrmBetRepository) GetBetBySessionId(ctx context.Context, sessionId int64) ([]*entity.Bet, error) {
@@ -50,13 +52,51 @@ func (p pgOrmBetRepository) GetBetBySessionId(ctx context.Context, sessionId int
 	return res, nil
 }
 
+func (p pgOrmBetRepository) GetBets(ctx context.Context, filter entity.BetFilter) ([]*entity.Bet, error) {
+	var res []*entity.Bet
+	err := utils.RunWithProfiler(repository.TagGetBets, func() error {
+		tx, err := p.pgOrm.Begin()
+		if err != nil {
+			logging.ErrorFormat("Cannot open Get bets transaction: %s", err)
+			return err
+		}
+		defer tx.Rollback()
+
+		query := tx.Model(&res)
+		if len(filter.ProviderIds) > 0 {
+			query = query.Where("provider_id IN (?)", pg.In(filter.ProviderIds))
+		}
+		if filter.Bot != nil {
+			query = query.Where("bot = ?", *filter.Bot)
+		}
+		if !filter.Since.IsZero() {
+			query = query.Where("time >= ?", filter.Since)
+		}
+		err = query.Order("id").Select()
+		if err != nil {
+			logging.ErrorFormat("Error selecting bets: %s", err)
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			logging.Error("could not commit a transaction")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 func (p pgOrmBetRepository) MakeBet(ctx context.Context, data entity.BetData) (int64, error) {
 	bet := entity.Bet{
 		ID:                 0,
 		QuotationSessionID: data.QuotationSessionID,
 		ProviderId:         data.ProviderId,
 		BetNumber:          -1,
-		Time:               time.Now(),
+		Time:               p.clock.Now(),
 		Bot:                data.Bot,
 	}
 	err := utils.RunWithProfiler(repository.TagMakeBet, func() error {
@@ -77,25 +117,11 @@ func (p pgOrmBetRepository) MakeBet(ctx context.Context, data entity.BetData) (i
 				err.Error())
 			return err
 		}
-		if session.Status == entity.StatusActive && !session.IsInAdditionalPurchase {
-			newPrice := session.CurrentPrice - session.StartPrice*(session.SessionStepPercent/100)
-			if newPrice <= 0 {
-				session.CurrentPrice = 0
-				bet.NewPrice = 0
-				session.Status = entity.StatusFinished
-			} else {
-				session.CurrentPrice = newPrice
-				bet.NewPrice = newPrice
-			}
-
-			if session.LastBet != nil {
-				bet.BetNumber = session.LastBet.BetNumber + 1
-				if session.LastBet.ProviderId == bet.ProviderId {
-					return fmt.Errorf("cannot make bet: this provider made the last bet")
-				}
-			} else {
-				bet.BetNumber = 0
-			}
+		placed, err := session.ApplyBet(&bet, data.MinPrice)
+		if err != nil {
+			return err
+		}
+		if placed {
 			_, err = tx.Model(&bet).Returning("id").Insert()
 			if err != nil {
 				logging.ErrorFormat("Cannot Insert new bet %v+: %s", bet,
@@ -103,51 +129,6 @@ func (p pgOrmBetRepository) MakeBet(ctx context.Context, data entity.BetData) (i
 				return err
 			}
 			session.LastBetId = bet.ID
-			session.LastBet = &bet
-			endTime := session.StartTime.Add(time.Duration(session.SessionDuration) * time.Minute)
-			if session.Status == entity.StatusActive &&
-				endTime.Sub(bet.Time) > (0*time.Millisecond) && endTime.Sub(bet.Time) < (5*time.Minute) {
-				session.IsInAdditionalPurchase = true
-				session.SessionDuration += 5
-			}
-		} else if session.Status == entity.StatusActive && session.IsInAdditionalPurchase {
-			endTime := session.StartTime.Add(time.Duration(session.SessionDuration) * time.Minute)
-			if endTime.Sub(bet.Time) > (0 * time.Millisecond) {
-				newPrice := session.CurrentPrice - session.StartPrice*(session.SessionStepPercent/100)
-				if newPrice <= 0 {
-					session.CurrentPrice = 0
-					bet.NewPrice = 0
-					session.Status = entity.StatusFinished
-				} else {
-					session.CurrentPrice = newPrice
-					bet.NewPrice = newPrice
-				}
-
-				if session.LastBet != nil {
-					bet.BetNumber = session.LastBet.BetNumber + 1
-					if session.LastBet.ProviderId == bet.ProviderId {
-						return fmt.Errorf("cannot make bet: this provider made the last bet")
-					}
-				} else {
-					bet.BetNumber = 0
-				}
-				_, err = tx.Model(&bet).Returning("id").Insert()
-				if err != nil {
-					logging.ErrorFormat("Cannot Insert new bet %v+: %s", bet,
-						err.Error())
-					return err
-				}
-				session.LastBetId = bet.ID
-				session.LastBet = &bet
-				if endTime.Sub(bet.Time) <= (5 * time.Minute) {
-					session.SessionDuration += 5
-				}
-			} else {
-				session.Status = entity.StatusFinished
-			}
-
-		} else {
-			return fmt.Errorf("cannot make bet: session isn't active")
 		}
 
 		_, err = tx.Model(&session).WherePK().Update()
@@ -155,6 +136,18 @@ func (p pgOrmBetRepository) MakeBet(ctx context.Context, data entity.BetData) (i
 			logging.ErrorFormat("Cannot Update session during Bet transaction: %s", err)
 			return err
 		}
+		if bet.ID != 0 {
+			// delivered to the listeners on commit
+			payload, err := json.Marshal(bet)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("SELECT pg_notify(?, ?)", repository.BetsChannel, string(payload))
+			if err != nil {
+				logging.ErrorFormat("Cannot notify about bet %d: %s", bet.ID, err)
+				return err
+			}
+		}
 		if err = tx.Commit(); err != nil {
 			logging.Error("could not commit a transaction")
 			return err
@@ -166,3 +159,45 @@ func (p pgOrmBetRepository) MakeBet(ctx context.Context, data entity.BetData) (i
 	}
 	return bet.ID, nil
 }
+
+func (p pgOrmBetRepository) ListenBets(ctx context.Context) (<-chan *entity.Bet, error) {
+	listener := p.pgOrm.Listen(ctx)
+	err := utils.RunWithProfiler(repository.TagListenBets, func() error {
+		if err := listener.Listen(ctx, repository.BetsChannel); err != nil {
+			logging.ErrorFormat("Cannot listen to the bets: %s", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	bets := make(chan *entity.Bet)
+	go func() {
+		defer close(bets)
+		defer listener.Close()
+		notifications := listener.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n, ok := <-notifications:
+				if !ok {
+					return
+				}
+				bet := &entity.Bet{}
+				if err := json.Unmarshal([]byte(n.Payload), bet); err != nil {
+					logging.ErrorFormat("Unexpected bet notification %s: %s", n.Payload, err)
+					continue
+				}
+				select {
+				case bets <- bet:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return bets, nil
+}
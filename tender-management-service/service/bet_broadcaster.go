@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"main/logging"
+	"main/model/entity"
+	"main/repository"
+	"sync"
+	"time"
+)
+
+// betListenRetry is how long the broadcaster waits before listening to the bets again once the listening breaks
+const betListenRetry = 5 * time.Second
+
+// betSubscriberBuffer is how many bets a subscriber may fall behind before it is dropped
+const betSubscriberBuffer = 64
+
+// BetBroadcaster listens to the bets once for the whole instance and passes every bet to its subscribers,
+// so the streams of the clients do not hold a database connection each
+type BetBroadcaster struct {
+	betRepo     repository.BetRepository
+	ctx         context.Context
+	mu          sync.Mutex
+	subscribers map[chan *entity.Bet]bool
+}
+
+func NewBetBroadcaster(ctx context.Context, betRepo repository.BetRepository) *BetBroadcaster {
+	return &BetBroadcaster{
+		betRepo:     betRepo,
+		ctx:         ctx,
+		subscribers: map[chan *entity.Bet]bool{},
+	}
+}
+
+// Run listens to the bets until the context of the broadcaster is done, listening again after betListenRetry
+// whenever it breaks. The bets made while it is not listening are not delivered
+func (b *BetBroadcaster) Run() {
+	for {
+		bets, err := b.betRepo.ListenBets(b.ctx)
+		if err == nil {
+			for bet := range bets {
+				b.broadcast(bet)
+			}
+		}
+		select {
+		case <-b.ctx.Done():
+			b.mu.Lock()
+			for ch := range b.subscribers {
+				b.drop(ch)
+			}
+			b.mu.Unlock()
+			return
+		case <-time.After(betListenRetry):
+			logging.Info("Listening to the bets again")
+		}
+	}
+}
+
+// Subscribe delivers the bets made from now on until ctx is done. The channel is closed then,
+// as well as when the subscriber falls more than betSubscriberBuffer bets behind
+func (b *BetBroadcaster) Subscribe(ctx context.Context) <-chan *entity.Bet {
+	ch := make(chan *entity.Bet, betSubscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = true
+	b.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		b.drop(ch)
+		b.mu.Unlock()
+	}()
+	return ch
+}
+
+func (b *BetBroadcaster) broadcast(bet *entity.Bet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- bet:
+		default:
+			logging.ErrorFormat("Bet stream is %d bets behind, closing it", betSubscriberBuffer)
+			b.drop(ch)
+		}
+	}
+}
+
+// drop closes the channel of the subscriber unless it is closed already, b.mu is held
+func (b *BetBroadcaster) drop(ch chan *entity.Bet) {
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"main/model/entity"
+	"main/repository"
+	"testing"
+	"time"
+)
+
+// listenedBets is a bet repository whose every listening delivers the bets sent to bets
+type listenedBets struct {
+	repository.BetRepository
+	listens chan struct{}
+	bets    chan *entity.Bet
+}
+
+func (l listenedBets) ListenBets(ctx context.Context) (<-chan *entity.Bet, error) {
+	l.listens <- struct{}{}
+	return l.bets, nil
+}
+
+func receiveBet(t *testing.T, bets <-chan *entity.Bet) *entity.Bet {
+	t.Helper()
+	select {
+	case bet := <-bets:
+		return bet
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no bet received")
+		return nil
+	}
+}
+
+func TestBetBroadcaster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := listenedBets{listens: make(chan struct{}, 1), bets: make(chan *entity.Bet)}
+	broadcaster := NewBetBroadcaster(ctx, repo)
+	first := broadcaster.Subscribe(context.Background())
+	secondCtx, unsubscribe := context.WithCancel(context.Background())
+	second := broadcaster.Subscribe(secondCtx)
+	done := make(chan struct{})
+	go func() {
+		broadcaster.Run()
+		close(done)
+	}()
+	<-repo.listens
+
+	repo.bets <- &entity.Bet{ID: 1}
+	if bet := receiveBet(t, first); bet.ID != 1 {
+		t.Errorf("first subscriber got bet %d, want 1", bet.ID)
+	}
+	if bet := receiveBet(t, second); bet.ID != 1 {
+		t.Errorf("second subscriber got bet %d, want 1", bet.ID)
+	}
+
+	unsubscribe()
+	for range second {
+	}
+	repo.bets <- &entity.Bet{ID: 2}
+	if bet := receiveBet(t, first); bet.ID != 2 {
+		t.Errorf("first subscriber got bet %d, want 2", bet.ID)
+	}
+
+	// the listening is done once, however many streams there are
+	select {
+	case <-repo.listens:
+		t.Errorf("bets are listened to again")
+	default:
+	}
+	cancel()
+	close(repo.bets)
+	<-done
+	if _, ok := <-first; ok {
+		t.Errorf("subscription is open after the broadcaster is stopped")
+	}
+}
+
+func TestBetBroadcasterSlowSubscriber(t *testing.T) {
+	broadcaster := NewBetBroadcaster(context.Background(), nil)
+	slow := broadcaster.Subscribe(context.Background())
+	for i := 0; i <= betSubscriberBuffer; i++ {
+		broadcaster.broadcast(&entity.Bet{ID: int64(i)})
+	}
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != betSubscriberBuffer {
+		t.Errorf("slow subscriber got %d bets before it was dropped, want %d", received, betSubscriberBuffer)
+	}
+}
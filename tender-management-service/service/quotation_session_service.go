@@ -7,19 +7,20 @@ import (
 	"main/model/entity"
 	"main/repository"
 	"main/utils"
-	"time"
 )
 
 func NewQuotationSessionService(ctx context.Context,
-	qsRepo repository.QuotationSessionRepository) QuotationSessionService {
+	qsRepo repository.QuotationSessionRepository, clock utils.Clock) QuotationSessionService {
 	return QuotationSessionService{
 		quotationSessionRepo: qsRepo,
+		clock:                clock,
 		ctx:                  ctx,
 	}
 }
 
 type QuotationSessionService struct {
 	quotationSessionRepo repository.QuotationSessionRepository
+	clock                utils.Clock
 	ctx                  context.Context
 }
 
@@ -29,7 +30,7 @@ func (s QuotationSessionService) RunQuotationService() error {
 		schedule := utils.GetEnv(utils.SessionUpdateFrequencyEnvKey, "*/1 * * * *")
 		logging.InfoFormat("Starting quotation service with schedule %s", schedule)
 		_, err := scheduler.AddFunc(schedule,
-			s.updateQuotationSessionsStatus)
+			s.UpdateQuotationSessionsStatus)
 		if err != nil {
 			return err
 		}
@@ -38,32 +39,17 @@ func (s QuotationSessionService) RunQuotationService() error {
 	return nil
 }
 
-func (s *QuotationSessionService) updateQuotationSessionsStatus() {
+// UpdateQuotationSessionsStatus finishes the active sessions whose time is over
+func (s *QuotationSessionService) UpdateQuotationSessionsStatus() {
 	logging.InfoFormat("Updating sessions statuses...")
 	if sessions, err := s.quotationSessionRepo.GetSessionsByStatus(s.ctx, entity.StatusActive); err == nil {
 		logging.InfoFormat("Found %d active sessions", len(sessions))
 		for _, session := range sessions {
-			currentTime := time.Now()
-			endTime := session.StartTime.Add(time.Duration(session.SessionDuration) * time.Minute)
-			if endTime.Before(currentTime) {
-				//if session.IsInAdditionalPurchase {
-				//	if session.LastBet != nil && session.LastBet.Time.After(
-				//		currentTime.Add(5*time.Minute)) {
-				//		logging.InfoFormat("Finishing session %d", session.ID)
-				//		session.Status = entity.StatusFinished
-				//	}
-				//	err := s.quotationSessionRepo.UpdateQuotationSession(s.ctx, *session)
-				//	if err != nil {
-				//		logging.ErrorFormat("Cannot update session %d", session.ID)
-				//		continue
-				//	}
-				//} else {
-				if session.LastBet != nil {
+			if session.Expire(s.clock.Now()) {
+				if session.Status == entity.StatusFinished {
 					logging.InfoFormat("Finishing session %d", session.ID)
-					session.Status = entity.StatusFinished
 				} else {
 					logging.InfoFormat("Session %d didnt take place", session.ID)
-					session.Status = entity.StatusDidntTakePlace
 				}
 				err := s.quotationSessionRepo.UpdateQuotationSession(s.ctx, *session)
 				if err != nil {
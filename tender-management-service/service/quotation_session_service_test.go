@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"main/model/entity"
+	"main/repository"
+	"main/utils"
+	"testing"
+	"time"
+)
+
+// memorySessionRepository keeps the sessions in memory, the sessions are copied in and out
+type memorySessionRepository struct {
+	repository.QuotationSessionRepository
+	sessions map[int64]entity.QuotationSession
+}
+
+func (m *memorySessionRepository) GetSessionsByStatus(ctx context.Context,
+	status entity.SessionStatus) ([]*entity.QuotationSession, error) {
+	var res []*entity.QuotationSession
+	for _, session := range m.sessions {
+		if session.Status == status {
+			session := session
+			res = append(res, &session)
+		}
+	}
+	return res, nil
+}
+
+func (m *memorySessionRepository) UpdateQuotationSession(ctx context.Context,
+	quotationSession entity.QuotationSession) error {
+	m.sessions[quotationSession.ID] = quotationSession
+	return nil
+}
+
+func TestUpdateQuotationSessionsStatus(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		session entity.QuotationSession
+		// the clock is moved by advance before the update
+		advance time.Duration
+		want    entity.SessionStatus
+	}{
+		{
+			name:    "session before its end stays active",
+			session: entity.QuotationSession{SessionDuration: 60, LastBet: &entity.Bet{ProviderId: 2}},
+			advance: 59 * time.Minute,
+			want:    entity.StatusActive,
+		},
+		{
+			name:    "session at its end stays active",
+			session: entity.QuotationSession{SessionDuration: 60, LastBet: &entity.Bet{ProviderId: 2}},
+			advance: 60 * time.Minute,
+			want:    entity.StatusActive,
+		},
+		{
+			name:    "session with bets is finished after its end",
+			session: entity.QuotationSession{SessionDuration: 60, LastBet: &entity.Bet{ProviderId: 2}},
+			advance: 61 * time.Minute,
+			want:    entity.StatusFinished,
+		},
+		{
+			name:    "session without bets didn't take place",
+			session: entity.QuotationSession{SessionDuration: 60},
+			advance: 61 * time.Minute,
+			want:    entity.StatusDidntTakePlace,
+		},
+		{
+			name: "session prolonged by the additional purchase stays active",
+			session: entity.QuotationSession{SessionDuration: 65, IsInAdditionalPurchase: true,
+				LastBet: &entity.Bet{ProviderId: 2}},
+			advance: 61 * time.Minute,
+			want:    entity.StatusActive,
+		},
+		{
+			name: "session is finished after the additional purchase",
+			session: entity.QuotationSession{SessionDuration: 65, IsInAdditionalPurchase: true,
+				LastBet: &entity.Bet{ProviderId: 2}},
+			advance: 66 * time.Minute,
+			want:    entity.StatusFinished,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := utils.NewFakeClock(start)
+			session := tt.session
+			session.ID = 1
+			session.Status = entity.StatusActive
+			session.StartTime = clock.Now()
+			repo := &memorySessionRepository{sessions: map[int64]entity.QuotationSession{1: session}}
+			srv := NewQuotationSessionService(context.Background(), repo, clock)
+
+			clock.Advance(tt.advance)
+			srv.UpdateQuotationSessionsStatus()
+
+			if got := repo.sessions[1].Status; got != tt.want {
+				t.Errorf("status = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateQuotationSessionsStatusLeavesEndedSessions(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC))
+	repo := &memorySessionRepository{sessions: map[int64]entity.QuotationSession{
+		1: {ID: 1, Status: entity.StatusDidntTakePlace, SessionDuration: 60, StartTime: clock.Now(),
+			LastBet: &entity.Bet{ProviderId: 2}},
+	}}
+	srv := NewQuotationSessionService(context.Background(), repo, clock)
+
+	clock.Advance(2 * time.Hour)
+	srv.UpdateQuotationSessionsStatus()
+
+	if got := repo.sessions[1].Status; got != entity.StatusDidntTakePlace {
+		t.Errorf("status = %s, want %s", got, entity.StatusDidntTakePlace)
+	}
+}
@@ -0,0 +1,127 @@
+// The clock is the same in both services, which share no code: keep utils/clock.go of the
+// strategy-management-service and the tender-management-service identical.
+
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time of the auctions. It is the system clock unless the service runs
+// in the simulation mode, where a FakeClock is advanced by hand
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a timer firing once d has passed on the clock
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single shot timer of a Clock
+type Timer interface {
+	C() <-chan time.Time
+	// Stop reports whether the timer was stopped before it fired
+	Stop() bool
+}
+
+// NewSystemClock returns the clock of the machine
+func NewSystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{timer: time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// FakeClock stands still until it is advanced, its timers fire once it passes their deadlines.
+// Safe for concurrent use
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers map[*fakeTimer]bool
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, timers: map[*fakeTimer]bool{}}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers[t] = true
+	return t
+}
+
+// Advance moves the clock forward by d and fires the timers it passes, it returns the new time.
+// A timer fires once however far the clock moves, so the runners act once per advance at most
+func (c *FakeClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.advanceTo(c.now.Add(d))
+}
+
+// AdvanceTo moves the clock forward to the time like Advance, an earlier time leaves it where it is
+func (c *FakeClock) AdvanceTo(now time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.advanceTo(now)
+}
+
+func (c *FakeClock) advanceTo(now time.Time) time.Time {
+	if now.After(c.now) {
+		c.now = now
+	}
+	for t := range c.timers {
+		if !t.at.After(c.now) {
+			delete(c.timers, t)
+			t.c <- c.now
+		}
+	}
+	return c.now
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	pending := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return pending
+}
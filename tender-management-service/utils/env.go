@@ -19,4 +19,5 @@ const (
 	// Work logic --------------
 	RunSessionCron               = "RUN_SESSION_CRON"
 	SessionUpdateFrequencyEnvKey = "SESSION_UPDATE_FREQUENCY"
+	SimulationModeEnvKey         = "SIMULATION_MODE" // the clock stands still until it is advanced by an admin
 )
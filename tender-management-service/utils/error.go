@@ -10,8 +10,20 @@ func NewError(ctx *gin.Context, status int, err error) {
 	ctx.JSON(status, er)
 }
 
+// NewCodedError is NewError with the error code the clients tell the errors apart by
+func NewCodedError(ctx *gin.Context, status int, code string, err error) {
+	er := HTTPError{
+		Code:      status,
+		ErrorCode: code,
+		Message:   err.Error(),
+	}
+	ctx.JSON(status, er)
+}
+
 // HTTPError example
 type HTTPError struct {
-	Code    int    `json:"code" example:"500"`
-	Message string `json:"message" example:"Internal error"`
+	Code int `json:"code" example:"500"`
+	// Tells the errors of the same status apart, e.g. the refused bets
+	ErrorCode string `json:"error_code,omitempty" example:"price_floor"`
+	Message   string `json:"message" example:"Internal error"`
 }